@@ -2,14 +2,38 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Database   DatabaseConfig
-	FreeSWITCH FreeSWITCHConfig
-	Server     ServerConfig
+	Database      DatabaseConfig
+	FreeSWITCH    FreeSWITCHConfig
+	Server        ServerConfig
+	Scheduler     SchedulerConfig
+	Campaign      CampaignConfig
+	Numbering     NumberingConfig
+	LCR           LCRConfig
+	Rating        RatingConfig
+	Prepaid       PrepaidConfig
+	Fraud         FraudConfig
+	Recordings    RecordingsConfig
+	Auth          AuthConfig
+	Storage       StorageConfig
+	Retention     RetentionConfig
+	Encryption    EncryptionConfig
+	Transcription TranscriptionConfig
+	Conference    ConferenceConfig
+	Wallboard     WallboardConfig
+	DTMF          DTMFConfig
+	IVR           IVRConfig
+	Voice         VoiceConfig
+	AMD           AMDConfig
+	Voicemail     VoicemailConfig
 }
 
 type DatabaseConfig struct {
@@ -24,17 +48,226 @@ type FreeSWITCHConfig struct {
 	Host     string
 	Port     string
 	Password string
+	Domain   string
+	Gateway  string
+	// SoundsDir is the FreeSWITCH sounds directory. Files played by
+	// absolute path must be inside it.
+	SoundsDir string
 }
 
 type ServerConfig struct {
 	Port string
 }
 
+// NumberingConfig holds the numbering defaults for tenants without their own
+// number_settings row.
+type NumberingConfig struct {
+	CountryCode         string
+	NationalPrefix      string
+	InternationalPrefix string
+	ExtensionMaxLength  int
+}
+
+// LCRConfig lists the hangup causes, as Q.850 codes or FreeSWITCH cause
+// names, on which a call fails over to the next least-cost route.
+type LCRConfig struct {
+	FailoverCauses []string
+}
+
+// RatingConfig controls the worker that rates new CDRs. CDRs older than
+// Lookback are never picked up.
+type RatingConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	Lookback     time.Duration
+}
+
+// PrepaidConfig controls credit enforcement for prepaid tenants.
+// MaxCallSeconds caps calls of accounts without their own limit.
+type PrepaidConfig struct {
+	CheckInterval  time.Duration
+	MaxCallSeconds int
+}
+
+// FraudConfig controls fraud detection. WebhookURL receives alerts of rules
+// without their own webhook_url.
+type FraudConfig struct {
+	CheckInterval  time.Duration
+	WebhookURL     string
+	WebhookTimeout time.Duration
+}
+
+// RecordingsConfig locates call recordings. FSRoot is the directory
+// FreeSWITCH writes recordings to, as stored in v_xml_cdr.record_path, and
+// Root is where the API finds that directory. Both are the same unless the
+// recordings are mounted elsewhere.
+type RecordingsConfig struct {
+	Root       string
+	FSRoot     string
+	Transcode  bool
+	FFmpegPath string
+	CacheDir   string
+}
+
+// StorageConfig selects where finished recordings are moved: "local" (a
+// directory such as an archive mount) or "s3" (any S3-compatible bucket).
+// With no driver, recordings stay where FreeSWITCH wrote them.
+type StorageConfig struct {
+	Driver        string
+	LocalRoot     string
+	S3Endpoint    string
+	S3Region      string
+	S3Bucket      string
+	S3AccessKey   string
+	S3SecretKey   string
+	S3PathStyle   bool
+	PresignExpiry time.Duration
+	MoveInterval  time.Duration
+	MoveBatchSize int
+	MinAge        time.Duration
+	DeleteLocal   bool
+}
+
+// RetentionConfig controls the retention engine. ArchiveRoot is the
+// directory, typically a cold storage mount, that the archive action moves
+// recordings to; without it only the delete action is available.
+type RetentionConfig struct {
+	Interval    time.Duration
+	BatchSize   int
+	ArchiveRoot string
+}
+
+// EncryptionConfig holds the master keys that wrap the per-tenant data keys
+// of encrypted recordings, as "id:base64key" entries. KeyFile, one entry
+// per line, takes precedence over MasterKeys. The last entry is the current
+// key; earlier ones are kept until every data key has been re-wrapped.
+type EncryptionConfig struct {
+	MasterKeys []string
+	KeyFile    string
+}
+
+// TranscriptionConfig selects the transcription engine: "stub" (fixed text,
+// for development and tests) or "http" (an OpenAI-compatible
+// speech-to-text server at URL). With no engine, nothing is transcribed.
+// AutoEnqueue queues every new recording from the last Lookback.
+type TranscriptionConfig struct {
+	Engine       string
+	URL          string
+	APIKey       string
+	Model        string
+	Language     string
+	StubText     string
+	Timeout      time.Duration
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	AutoEnqueue  bool
+	Lookback     time.Duration
+}
+
+// ConferenceConfig controls the live conference view. The view follows
+// conference events and is rebuilt from "conference json_list" every
+// SyncInterval, in case events were missed. RecordDir is where conference
+// recordings are written, as seen by FreeSWITCH.
+type ConferenceConfig struct {
+	SyncInterval time.Duration
+	RecordDir    string
+}
+
+// WallboardConfig controls the live queue wallboard. Snapshots are pushed
+// every Interval; the live state is reloaded from mod_callcenter and
+// v_xml_cdr on every ESL connect and every ReseedInterval. Calls answered
+// within ServiceLevel count towards the service level. AllowedOrigins lists
+// the browser origins allowed to open the WebSocket; empty allows the API's
+// own origin only.
+type WallboardConfig struct {
+	Interval       time.Duration
+	ReseedInterval time.Duration
+	ServiceLevel   time.Duration
+	AllowedOrigins []string
+}
+
+// DTMFConfig controls DTMF reporting. Every digit a caller presses is
+// posted to WebhookURL when set. The digits of a call stay available for
+// Retention after it hangs up. AllowedOrigins works as for the wallboard.
+type DTMFConfig struct {
+	WebhookURL     string
+	WebhookTimeout time.Duration
+	Retention      time.Duration
+	AllowedOrigins []string
+}
+
+// IVRConfig controls play-and-collect on live calls. Gather results are
+// posted to the request callback_url, or WebhookURL when it has none, and
+// stay available for Retention after they complete.
+type IVRConfig struct {
+	WebhookURL     string
+	WebhookTimeout time.Duration
+	Retention      time.Duration
+}
+
+// VoiceConfig controls programmable voice. FreeSWITCH hands calls to the
+// outbound event socket server on ListenAddr with the socket application;
+// empty turns the server off. A call may fetch at most MaxFetches verb
+// documents, which stops redirect loops. Recordings of the record verb are
+// written to RecordDir, as seen by FreeSWITCH.
+type VoiceConfig struct {
+	ListenAddr     string
+	WebhookTimeout time.Duration
+	MaxFetches     int
+	RecordDir      string
+}
+
+// AMDConfig controls answering machine detection on originated calls. App
+// is run on the answered leg with Args and leaves its verdict in ResultVar,
+// as mod_amd does with amd_result; App "avmd" uses mod_avmd beep detection
+// alone instead. Detection gives up after Timeout. Before a message is
+// played to a machine, mod_avmd waits up to BeepTimeout for the beep; zero
+// plays it straight away. Results are posted to WebhookURL and stay
+// available for Retention after the call ends.
+type AMDConfig struct {
+	App            string
+	Args           string
+	ResultVar      string
+	Timeout        time.Duration
+	BeepTimeout    time.Duration
+	WebhookURL     string
+	WebhookTimeout time.Duration
+	Retention      time.Duration
+}
+
+// VoicemailConfig locates FusionPBX voicemail audio, stored as
+// StorageDir/default/<domain>/<box>/msg_<uuid>.<ext> unless it is kept in
+// the database.
+type VoicemailConfig struct {
+	StorageDir string
+}
+
+// AuthConfig holds the bootstrap admin key, which is accepted in addition
+// to the keys stored in api_keys, and the secret signed URLs are signed
+// with. Instances sharing a database should share the secret.
+type AuthConfig struct {
+	AdminKey      string
+	URLSigningKey string
+	SignedURLTTL  time.Duration
+}
+
+type CampaignConfig struct {
+	TickInterval time.Duration
+}
+
+type SchedulerConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+}
+
 func Load() (*Config, error) {
 	if err := godotenv.Load(); err != nil {
 		return nil, err
 	}
 
+	recordingsRoot := getEnv("RECORDINGS_ROOT", "/var/lib/freeswitch/recordings")
+
 	return &Config{
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -44,13 +277,136 @@ func Load() (*Config, error) {
 			DBName:   getEnv("DB_NAME", "fusionpbx"),
 		},
 		FreeSWITCH: FreeSWITCHConfig{
-			Host:     getEnv("FS_HOST", "127.0.0.1"),
-			Port:     getEnv("FS_PORT", "8021"),
-			Password: getEnv("FS_PASSWORD", "ClueCon"),
+			Host:      getEnv("FS_HOST", "127.0.0.1"),
+			Port:      getEnv("FS_PORT", "8021"),
+			Password:  getEnv("FS_PASSWORD", "ClueCon"),
+			Domain:    getEnv("FS_DOMAIN", "172.27.191.2"),
+			Gateway:   getEnv("FS_GATEWAY", ""),
+			SoundsDir: getEnv("FS_SOUNDS_DIR", "/usr/share/freeswitch/sounds"),
 		},
 		Server: ServerConfig{
 			Port: getEnv("SERVER_PORT", "8086"),
 		},
+		Scheduler: SchedulerConfig{
+			PollInterval: time.Duration(getEnvPositiveInt("SCHEDULER_POLL_SECONDS", 5)) * time.Second,
+			BatchSize:    getEnvPositiveInt("SCHEDULER_BATCH_SIZE", 20),
+		},
+		Numbering: NumberingConfig{
+			CountryCode:         getEnv("DEFAULT_COUNTRY_CODE", "1"),
+			NationalPrefix:      getEnv("DEFAULT_NATIONAL_PREFIX", "1"),
+			InternationalPrefix: getEnv("DEFAULT_INTERNATIONAL_PREFIX", "011"),
+			ExtensionMaxLength:  getEnvInt("EXTENSION_MAX_LENGTH", 5),
+		},
+		Campaign: CampaignConfig{
+			TickInterval: time.Duration(getEnvPositiveInt("CAMPAIGN_TICK_SECONDS", 2)) * time.Second,
+		},
+		Rating: RatingConfig{
+			PollInterval: time.Duration(getEnvPositiveInt("RATING_POLL_SECONDS", 30)) * time.Second,
+			BatchSize:    getEnvPositiveInt("RATING_BATCH_SIZE", 200),
+			Lookback:     time.Duration(getEnvInt("RATING_LOOKBACK_DAYS", 30)) * 24 * time.Hour,
+		},
+		Prepaid: PrepaidConfig{
+			CheckInterval:  time.Duration(getEnvPositiveInt("PREPAID_CHECK_SECONDS", 5)) * time.Second,
+			MaxCallSeconds: getEnvInt("PREPAID_MAX_CALL_SECONDS", 14400),
+		},
+		Fraud: FraudConfig{
+			CheckInterval:  time.Duration(getEnvPositiveInt("FRAUD_CHECK_SECONDS", 60)) * time.Second,
+			WebhookURL:     getEnv("FRAUD_WEBHOOK_URL", ""),
+			WebhookTimeout: time.Duration(getEnvInt("FRAUD_WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second,
+		},
+		Recordings: RecordingsConfig{
+			Root:       recordingsRoot,
+			FSRoot:     getEnv("RECORDINGS_FS_ROOT", recordingsRoot),
+			Transcode:  getEnvBool("RECORDINGS_TRANSCODE", false),
+			FFmpegPath: getEnv("FFMPEG_PATH", "ffmpeg"),
+			CacheDir:   getEnv("RECORDINGS_CACHE_DIR", filepath.Join(os.TempDir(), "voip-api-recordings")),
+		},
+		Storage: StorageConfig{
+			Driver:        getEnv("STORAGE_DRIVER", ""),
+			LocalRoot:     getEnv("STORAGE_LOCAL_ROOT", ""),
+			S3Endpoint:    getEnv("S3_ENDPOINT", ""),
+			S3Region:      getEnv("S3_REGION", "us-east-1"),
+			S3Bucket:      getEnv("S3_BUCKET", ""),
+			S3AccessKey:   getEnv("S3_ACCESS_KEY", ""),
+			S3SecretKey:   getEnv("S3_SECRET_KEY", ""),
+			S3PathStyle:   getEnvBool("S3_PATH_STYLE", true),
+			PresignExpiry: time.Duration(getEnvInt("STORAGE_PRESIGN_SECONDS", 300)) * time.Second,
+			MoveInterval:  time.Duration(getEnvPositiveInt("STORAGE_MOVE_SECONDS", 60)) * time.Second,
+			MoveBatchSize: getEnvPositiveInt("STORAGE_MOVE_BATCH_SIZE", 50),
+			MinAge:        time.Duration(getEnvInt("STORAGE_MIN_AGE_SECONDS", 300)) * time.Second,
+			DeleteLocal:   getEnvBool("STORAGE_DELETE_LOCAL", true),
+		},
+		Retention: RetentionConfig{
+			Interval:    time.Duration(getEnvPositiveInt("RETENTION_INTERVAL_MINUTES", 60)) * time.Minute,
+			BatchSize:   getEnvPositiveInt("RETENTION_BATCH_SIZE", 100),
+			ArchiveRoot: getEnv("RETENTION_ARCHIVE_ROOT", ""),
+		},
+		Encryption: EncryptionConfig{
+			MasterKeys: getEnvList("RECORDINGS_MASTER_KEYS", ""),
+			KeyFile:    getEnv("RECORDINGS_MASTER_KEY_FILE", ""),
+		},
+		Transcription: TranscriptionConfig{
+			Engine:       getEnv("TRANSCRIPTION_ENGINE", ""),
+			URL:          getEnv("TRANSCRIPTION_URL", ""),
+			APIKey:       getEnv("TRANSCRIPTION_API_KEY", ""),
+			Model:        getEnv("TRANSCRIPTION_MODEL", "whisper-1"),
+			Language:     getEnv("TRANSCRIPTION_LANGUAGE", ""),
+			StubText:     getEnv("TRANSCRIPTION_STUB_TEXT", ""),
+			Timeout:      time.Duration(getEnvInt("TRANSCRIPTION_TIMEOUT_SECONDS", 300)) * time.Second,
+			PollInterval: time.Duration(getEnvPositiveInt("TRANSCRIPTION_POLL_SECONDS", 10)) * time.Second,
+			BatchSize:    getEnvPositiveInt("TRANSCRIPTION_BATCH_SIZE", 4),
+			MaxAttempts:  getEnvInt("TRANSCRIPTION_MAX_ATTEMPTS", 3),
+			AutoEnqueue:  getEnvBool("TRANSCRIPTION_AUTO", false),
+			Lookback:     time.Duration(getEnvInt("TRANSCRIPTION_LOOKBACK_DAYS", 7)) * 24 * time.Hour,
+		},
+		Conference: ConferenceConfig{
+			SyncInterval: time.Duration(getEnvPositiveInt("CONFERENCE_SYNC_SECONDS", 60)) * time.Second,
+			RecordDir:    getEnv("CONFERENCE_RECORD_DIR", filepath.Join(getEnv("RECORDINGS_FS_ROOT", recordingsRoot), "conferences")),
+		},
+		Wallboard: WallboardConfig{
+			Interval:       time.Duration(getEnvPositiveInt("WALLBOARD_INTERVAL_MS", 1000)) * time.Millisecond,
+			ReseedInterval: time.Duration(getEnvPositiveInt("WALLBOARD_RESEED_SECONDS", 300)) * time.Second,
+			ServiceLevel:   time.Duration(getEnvInt("WALLBOARD_SERVICE_LEVEL_SECONDS", 20)) * time.Second,
+			AllowedOrigins: getEnvList("WALLBOARD_ALLOWED_ORIGINS", ""),
+		},
+		DTMF: DTMFConfig{
+			WebhookURL:     getEnv("DTMF_WEBHOOK_URL", ""),
+			WebhookTimeout: time.Duration(getEnvInt("DTMF_WEBHOOK_TIMEOUT_SECONDS", 5)) * time.Second,
+			Retention:      time.Duration(getEnvInt("DTMF_RETENTION_SECONDS", 300)) * time.Second,
+			AllowedOrigins: getEnvList("DTMF_ALLOWED_ORIGINS", ""),
+		},
+		IVR: IVRConfig{
+			WebhookURL:     getEnv("IVR_WEBHOOK_URL", ""),
+			WebhookTimeout: time.Duration(getEnvInt("IVR_WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second,
+			Retention:      time.Duration(getEnvInt("IVR_RETENTION_SECONDS", 300)) * time.Second,
+		},
+		Voice: VoiceConfig{
+			ListenAddr:     getEnv("VOICE_SOCKET_LISTEN", ""),
+			WebhookTimeout: time.Duration(getEnvInt("VOICE_WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second,
+			MaxFetches:     getEnvInt("VOICE_MAX_FETCHES", 20),
+			RecordDir:      getEnv("VOICE_RECORD_DIR", filepath.Join(getEnv("RECORDINGS_FS_ROOT", recordingsRoot), "voice")),
+		},
+		AMD: AMDConfig{
+			App:            getEnv("AMD_APP", "amd"),
+			Args:           getEnv("AMD_APP_ARGS", ""),
+			ResultVar:      getEnv("AMD_RESULT_VARIABLE", "amd_result"),
+			Timeout:        time.Duration(getEnvInt("AMD_TIMEOUT_SECONDS", 30)) * time.Second,
+			BeepTimeout:    time.Duration(getEnvInt("AMD_BEEP_TIMEOUT_SECONDS", 30)) * time.Second,
+			WebhookURL:     getEnv("AMD_WEBHOOK_URL", ""),
+			WebhookTimeout: time.Duration(getEnvInt("AMD_WEBHOOK_TIMEOUT_SECONDS", 10)) * time.Second,
+			Retention:      time.Duration(getEnvInt("AMD_RETENTION_SECONDS", 300)) * time.Second,
+		},
+		Voicemail: VoicemailConfig{
+			StorageDir: getEnv("VOICEMAIL_STORAGE_DIR", "/var/lib/freeswitch/storage/voicemail"),
+		},
+		Auth: AuthConfig{
+			AdminKey:      getEnv("API_ADMIN_KEY", ""),
+			URLSigningKey: getEnv("API_URL_SIGNING_KEY", ""),
+			SignedURLTTL:  time.Duration(getEnvInt("API_SIGNED_URL_SECONDS", 300)) * time.Second,
+		},
+		LCR: LCRConfig{
+			FailoverCauses: getEnvList("LCR_FAILOVER_CAUSES", "3,27,31,34,38,41,42,44,88,102,127,GATEWAY_DOWN"),
+		},
 	}, nil
 }
 
@@ -60,3 +416,36 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvPositiveInt is getEnvInt for values that must be above zero, such
+// as ticker intervals and batch sizes.
+func getEnvPositiveInt(key string, defaultValue int) int {
+	if value := getEnvInt(key, defaultValue); value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvList(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
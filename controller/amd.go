@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type AMDController struct {
+	amd *manager.AMDManager
+}
+
+func NewAMDController(amd *manager.AMDManager) *AMDController {
+	return &AMDController{
+		amd: amd,
+	}
+}
+
+// GetResult returns the answering machine detection of the call in the
+// path. Keys bound to a tenant get not found for calls of other tenants.
+func (ac *AMDController) GetResult(c *gin.Context) {
+	result, err := ac.amd.Get(c.Param("uuid"))
+	if err == nil && !manager.CanAccessDomain(requestKey(c), result.DomainUUID) {
+		err = manager.ErrAMDNotFound
+	}
+	if err != nil {
+		ac.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ac.mapResultToResponse(*result))
+}
+
+func (ac *AMDController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrAMDNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s AMD result: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " AMD result"})
+	}
+}
+
+func (ac *AMDController) mapResultToResponse(r manager.AMDResult) response.AMDResponse {
+	resp := response.AMDResponse{
+		CallUUID:   r.CallUUID,
+		DomainUUID: r.DomainUUID,
+		Status:     r.Status,
+		Result:     r.Result,
+		Beep:       r.Beep,
+		Action:     r.Action,
+		Detail:     r.Detail,
+		StartedAt:  r.StartedAt,
+	}
+	if !r.DetectedAt.IsZero() {
+		resp.DetectedAt = &r.DetectedAt
+	}
+	if !r.EndedAt.IsZero() {
+		resp.EndedAt = &r.EndedAt
+	}
+	return resp
+}
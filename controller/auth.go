@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+const apiKeyContextKey = "api_key"
+
+type AuthController struct {
+	auth *manager.AuthManager
+}
+
+func NewAuthController(auth *manager.AuthManager) *AuthController {
+	return &AuthController{
+		auth: auth,
+	}
+}
+
+// Require authenticates the request API key and checks that its role grants
+// perm; an empty perm accepts any valid key. The key is read from
+// "Authorization: Bearer" or X-API-Key. Keys are never read from the query
+// string, which ends up in access logs. Clients such as audio elements that
+// cannot set headers use a signed URL from POST /auth/signed-urls instead.
+func (ac *AuthController) Require(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, err := ac.authenticate(c)
+		if errors.Is(err, manager.ErrUnauthorized) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to authenticate API key: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate API key"})
+			return
+		}
+		if perm != "" && !manager.HasPermission(key, perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key lacks the " + perm + " permission"})
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// authenticate returns the key of the request headers, or of a signed URL
+// for GET requests without one.
+func (ac *AuthController) authenticate(c *gin.Context) (*models.APIKey, error) {
+	token := requestAPIKey(c)
+	if token == "" && c.Query("sig") != "" && (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) {
+		return ac.auth.AuthenticateSigned(c.Request.URL.Path, c.Query("key_id"), c.Query("expires"), c.Query("sig"))
+	}
+	return ac.auth.Authenticate(token)
+}
+
+func requestAPIKey(c *gin.Context) string {
+	if h := c.GetHeader("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	return c.GetHeader("X-API-Key")
+}
+
+// SignURL returns a URL of path that works without the API key until it
+// expires. It carries the permissions of the key that asked for it.
+func (ac *AuthController) SignURL(c *gin.Context) {
+	var req request.SignedURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	signed, expires, err := ac.auth.SignURL(requestKey(c), req.Path)
+	if errors.Is(err, manager.ErrInvalidSignedURL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to sign URL: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.SignedURLResponse{URL: signed, ExpiresAt: expires})
+}
+
+// requestKey returns the key authenticated by Require.
+func requestKey(c *gin.Context) *models.APIKey {
+	key, _ := c.MustGet(apiKeyContextKey).(*models.APIKey)
+	return key
+}
+
+// tenantScope returns the tenant a request acts on: domainUUID, or the
+// caller's tenant when its key is bound to one and domainUUID is empty. ok
+// is false when a bound key asks for another tenant.
+func tenantScope(c *gin.Context, domainUUID string) (string, bool) {
+	caller := requestKey(c)
+	if !caller.DomainUUID.Valid {
+		return domainUUID, true
+	}
+	if domainUUID == "" {
+		return caller.DomainUUID.String, true
+	}
+	return domainUUID, domainUUID == caller.DomainUUID.String
+}
+
+// canAccessCall reports whether the request's key may reach the running
+// call uuid. Calls that do not exist and calls of other tenants both give
+// false, so they look the same to the client.
+func canAccessCall(c *gin.Context, eslMgr *manager.ESLManager, uuid string) (bool, error) {
+	domainUUID, found, err := eslMgr.CallDomain(uuid)
+	if err != nil || !found {
+		return false, err
+	}
+	return manager.CanAccessDomain(requestKey(c), domainUUID), nil
+}
+
+// CreateKey issues a key. Keys bound to a tenant can only issue keys for
+// that tenant.
+func (ac *AuthController) CreateKey(c *gin.Context) {
+	var req request.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	caller := requestKey(c)
+	if caller.DomainUUID.Valid {
+		if req.DomainUUID == "" {
+			req.DomainUUID = caller.DomainUUID.String
+		}
+		if req.DomainUUID != caller.DomainUUID.String {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot issue keys for another tenant"})
+			return
+		}
+	}
+
+	key, token, err := ac.auth.CreateKey(req)
+	if err != nil {
+		ac.handleError(c, "create", err)
+		return
+	}
+
+	resp := ac.mapKeyToResponse(*key)
+	resp.Key = token
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (ac *AuthController) GetKeys(c *gin.Context) {
+	domainUUID := c.Query("domain_uuid")
+	if caller := requestKey(c); caller.DomainUUID.Valid {
+		domainUUID = caller.DomainUUID.String
+	}
+
+	keys, err := ac.auth.ListKeys(domainUUID)
+	if err != nil {
+		ac.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		resp = append(resp, ac.mapKeyToResponse(key))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keys": resp})
+}
+
+func (ac *AuthController) DeleteKey(c *gin.Context) {
+	key, err := ac.auth.GetKey(c.Param("uuid"))
+	if err == nil && !manager.CanAccessDomain(requestKey(c), key.DomainUUID.String) {
+		err = manager.ErrAPIKeyNotFound
+	}
+	if err == nil {
+		err = ac.auth.DeleteKey(key.KeyUUID)
+	}
+	if err != nil {
+		ac.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (ac *AuthController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s API key: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " API key"})
+	}
+}
+
+func (ac *AuthController) mapKeyToResponse(key models.APIKey) response.APIKeyResponse {
+	resp := response.APIKeyResponse{
+		KeyUUID:    key.KeyUUID,
+		Name:       key.Name,
+		KeyPrefix:  key.KeyPrefix,
+		Role:       key.Role,
+		DomainUUID: key.DomainUUID.String,
+		Enabled:    key.Enabled,
+		CreatedAt:  key.CreatedAt,
+	}
+	if key.LastUsedAt.Valid {
+		resp.LastUsedAt = &key.LastUsedAt.Time
+	}
+	return resp
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"log"
 	"net/http"
 
@@ -11,11 +12,13 @@ import (
 
 type CallController struct {
 	eslMgr *manager.ESLManager
+	checks *manager.CallChecker
 }
 
-func NewCallController(eslMgr *manager.ESLManager) *CallController {
+func NewCallController(eslMgr *manager.ESLManager, checks *manager.CallChecker) *CallController {
 	return &CallController{
 		eslMgr: eslMgr,
+		checks: checks,
 	}
 }
 
@@ -26,7 +29,20 @@ func (cc *CallController) InitiateCall(c *gin.Context) {
 		return
 	}
 
-	callID, err := cc.eslMgr.OriginateCall(req.Caller, req.Callee)
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot place calls for another tenant"})
+		return
+	}
+	if !cc.authorize(c, &req) {
+		return
+	}
+
+	callID, err := cc.eslMgr.OriginateCall(req)
+	if errors.Is(err, manager.ErrInvalidOriginate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to originate call: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -39,6 +55,36 @@ func (cc *CallController) InitiateCall(c *gin.Context) {
 	})
 }
 
+// authorize runs the fraud, do-not-call and prepaid checks on a call and
+// sets the prepaid limits on req. It writes the error response and returns
+// false when the call may not be placed.
+func (cc *CallController) authorize(c *gin.Context, req *request.CallRequest) bool {
+	err := cc.checks.Check(req)
+	var blocked *manager.BlockedError
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, manager.ErrExtensionBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.As(err, &blocked):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  blocked.Error(),
+			"number": blocked.Number,
+			"reason": blocked.Reason,
+		})
+	case errors.Is(err, manager.ErrInsufficientBalance):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrNoRate):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrTenantUnknown):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to check call: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check call"})
+	}
+	return false
+}
+
 
 func (cc *CallController) GetCallStatus(c *gin.Context) {
 	uuid := c.Param("uuid")
@@ -46,7 +92,17 @@ func (cc *CallController) GetCallStatus(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "uuid is required"})
 		return
 	}
-	
+
+	ok, err := canAccessCall(c, cc.eslMgr, uuid)
+	if err != nil {
+		log.Printf("Failed to look up call %s: %v", uuid, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch call status"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "call not found"})
+		return
+	}
 
 	status, err := cc.eslMgr.GetCallStatus(uuid)
 	if err != nil {
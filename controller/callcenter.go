@@ -0,0 +1,331 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type CallCenterController struct {
+	callcenter *manager.CallCenterManager
+}
+
+func NewCallCenterController(callcenter *manager.CallCenterManager) *CallCenterController {
+	return &CallCenterController{
+		callcenter: callcenter,
+	}
+}
+
+// GetAgents lists the agents. Keys bound to a tenant only see their own.
+func (cc *CallCenterController) GetAgents(c *gin.Context) {
+	agents, err := cc.callcenter.ListAgents()
+	if err != nil {
+		cc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.CallCenterAgentResponse, 0, len(agents))
+	for _, agent := range agents {
+		ok, err := cc.owns(c, agent.Name)
+		if err != nil {
+			cc.handleError(c, "list", err)
+			return
+		}
+		if ok {
+			resp = append(resp, cc.mapAgentToResponse(agent))
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"agents": resp})
+}
+
+func (cc *CallCenterController) GetAgent(c *gin.Context) {
+	name, err := cc.own(c, c.Param("agent"), manager.ErrCallCenterAgentNotFound)
+	var agent *manager.CallCenterAgent
+	if err == nil {
+		agent, err = cc.callcenter.GetAgent(name)
+	}
+	if err != nil {
+		cc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cc.mapAgentToResponse(*agent))
+}
+
+func (cc *CallCenterController) SetAgentStatus(c *gin.Context) {
+	var req request.AgentStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name, err := cc.own(c, c.Param("agent"), manager.ErrCallCenterAgentNotFound)
+	var agent *manager.CallCenterAgent
+	if err == nil {
+		agent, err = cc.callcenter.SetAgentStatus(name, req.Status)
+	}
+	if err != nil {
+		cc.handleError(c, "update", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cc.mapAgentToResponse(*agent))
+}
+
+func (cc *CallCenterController) GetQueues(c *gin.Context) {
+	queues, err := cc.queues(c)
+	if err != nil {
+		cc.handleError(c, "list", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"queues": queues})
+}
+
+// GetMembers lists the callers in a queue, longest waiting first.
+func (cc *CallCenterController) GetMembers(c *gin.Context) {
+	queue, err := cc.own(c, c.Param("queue"), manager.ErrCallCenterQueueNotFound)
+	var members []manager.CallCenterMember
+	if err == nil {
+		members, err = cc.callcenter.ListMembers(queue)
+	}
+	if err != nil {
+		cc.handleError(c, "list", err)
+		return
+	}
+
+	now := time.Now()
+	resp := make([]response.CallCenterMemberResponse, 0, len(members))
+	for _, m := range members {
+		member := response.CallCenterMemberResponse{
+			CCQueue:             m.Queue,
+			CCMemberUUID:        m.UUID,
+			CCMemberSessionUUID: m.SessionUUID,
+			CallerIDNumber:      m.CallerIDNumber,
+			CallerIDName:        m.CallerIDName,
+			Joined:              m.Joined,
+			WaitSeconds:         m.WaitSeconds(now),
+			State:               m.State,
+			CCAgent:             m.ServingAgent,
+		}
+		if !m.Joined.IsZero() {
+			member.CCQueueJoinedEpoch = m.Joined.Unix()
+		}
+		resp = append(resp, member)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": resp})
+}
+
+func (cc *CallCenterController) GetTiers(c *gin.Context) {
+	queue, err := cc.own(c, c.Param("queue"), manager.ErrCallCenterQueueNotFound)
+	var tiers []manager.CallCenterTier
+	if err == nil {
+		tiers, err = cc.callcenter.ListTiers(queue)
+	}
+	if err != nil {
+		cc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.CallCenterTierResponse, 0, len(tiers))
+	for _, t := range tiers {
+		resp = append(resp, response.CallCenterTierResponse{
+			Queue:    t.Queue,
+			Agent:    t.Agent,
+			State:    t.State,
+			Level:    t.Level,
+			Position: t.Position,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tiers": resp})
+}
+
+func (cc *CallCenterController) AddTier(c *gin.Context) {
+	var req request.TierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Level == 0 {
+		req.Level = 1
+	}
+	if req.Position == 0 {
+		req.Position = 1
+	}
+
+	queue, err := cc.own(c, c.Param("queue"), manager.ErrCallCenterQueueNotFound)
+	if err == nil {
+		_, err = cc.own(c, req.Agent, manager.ErrCallCenterAgentNotFound)
+	}
+	if err == nil {
+		err = cc.callcenter.AddTier(queue, req.Agent, req.Level, req.Position)
+	}
+	if err != nil {
+		cc.handleError(c, "add", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.CallCenterTierResponse{
+		Queue:    queue,
+		Agent:    req.Agent,
+		Level:    req.Level,
+		Position: req.Position,
+	})
+}
+
+func (cc *CallCenterController) RemoveTier(c *gin.Context) {
+	queue, err := cc.own(c, c.Param("queue"), manager.ErrCallCenterQueueNotFound)
+	if err == nil {
+		_, err = cc.own(c, c.Param("agent"), manager.ErrCallCenterAgentNotFound)
+	}
+	if err == nil {
+		err = cc.callcenter.RemoveTier(queue, c.Param("agent"))
+	}
+	if err != nil {
+		cc.handleError(c, "remove", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetStats returns the live figures of one queue.
+func (cc *CallCenterController) GetStats(c *gin.Context) {
+	queue, err := cc.own(c, c.Param("queue"), manager.ErrCallCenterQueueNotFound)
+	var stats *manager.CallCenterStats
+	if err == nil {
+		stats, err = cc.callcenter.Stats(queue)
+	}
+	if err != nil {
+		cc.handleError(c, "fetch stats for", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cc.mapStatsToResponse(*stats))
+}
+
+// GetAllStats returns the live figures of every queue the key can see.
+func (cc *CallCenterController) GetAllStats(c *gin.Context) {
+	queues, err := cc.queues(c)
+	if err != nil {
+		cc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.CallCenterStatsResponse, 0, len(queues))
+	for _, queue := range queues {
+		stats, err := cc.callcenter.Stats(queue)
+		if err != nil {
+			cc.handleError(c, "fetch stats for", err)
+			return
+		}
+		resp = append(resp, cc.mapStatsToResponse(*stats))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"queues": resp})
+}
+
+// owns reports whether the request's key may reach an agent or queue.
+// Names whose tenant is not known are only reachable by keys without a
+// tenant.
+func (cc *CallCenterController) owns(c *gin.Context, name string) (bool, error) {
+	key := requestKey(c)
+	if !key.DomainUUID.Valid {
+		return true, nil
+	}
+	domainUUID, err := cc.callcenter.Domain(name)
+	if err != nil {
+		return false, err
+	}
+	return manager.CanAccessDomain(key, domainUUID), nil
+}
+
+// own returns name when the request's key may reach that agent or queue,
+// and notFound when it belongs to another tenant.
+func (cc *CallCenterController) own(c *gin.Context, name string, notFound error) (string, error) {
+	ok, err := cc.owns(c, name)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", notFound
+	}
+	return name, nil
+}
+
+// queues returns the names of the queues the request's key can see.
+func (cc *CallCenterController) queues(c *gin.Context) ([]string, error) {
+	all, err := cc.callcenter.ListQueues()
+	if err != nil {
+		return nil, err
+	}
+	queues := make([]string, 0, len(all))
+	for _, queue := range all {
+		ok, err := cc.owns(c, queue)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			queues = append(queues, queue)
+		}
+	}
+	return queues, nil
+}
+
+func (cc *CallCenterController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrCallCenterAgentNotFound), errors.Is(err, manager.ErrCallCenterQueueNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrCallCenterTierExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidCallCenter):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s call center: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " call center"})
+	}
+}
+
+func (cc *CallCenterController) mapAgentToResponse(a manager.CallCenterAgent) response.CallCenterAgentResponse {
+	resp := response.CallCenterAgentResponse{
+		Name:          a.Name,
+		Type:          a.Type,
+		Contact:       a.Contact,
+		Status:        a.Status,
+		State:         a.State,
+		NoAnswerCount: a.NoAnswerCount,
+		CallsAnswered: a.CallsAnswered,
+		TalkTime:      a.TalkTime,
+	}
+	if !a.LastStatusChange.IsZero() {
+		resp.LastStatusChange = &a.LastStatusChange
+	}
+	return resp
+}
+
+func (cc *CallCenterController) mapStatsToResponse(s manager.CallCenterStats) response.CallCenterStatsResponse {
+	return response.CallCenterStatsResponse{
+		Queue:           s.Queue,
+		Waiting:         s.Waiting,
+		LongestWait:     s.LongestWait,
+		AgentsAvailable: s.AgentsAvailable,
+		AgentsOnBreak:   s.AgentsOnBreak,
+		AgentsLoggedOut: s.AgentsLoggedOut,
+		AgentsOnCall:    s.AgentsOnCall,
+		Offered:         s.Offered,
+		Answered:        s.Answered,
+		Abandoned:       s.Abandoned,
+		AverageWait:     s.AverageWait,
+		MaxAnsweredWait: s.MaxAnsweredWait,
+		Since:           s.Since,
+	}
+}
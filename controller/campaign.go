@@ -0,0 +1,313 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type CampaignController struct {
+	campaigns *manager.CampaignManager
+}
+
+func NewCampaignController(campaigns *manager.CampaignManager) *CampaignController {
+	return &CampaignController{
+		campaigns: campaigns,
+	}
+}
+
+func (cc *CampaignController) CreateCampaign(c *gin.Context) {
+	var req request.CampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's campaigns"})
+		return
+	}
+
+	campaign, err := cc.campaigns.Create(req)
+	if err != nil {
+		cc.handleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, cc.mapCampaignToResponse(*campaign, nil))
+}
+
+func (cc *CampaignController) GetCampaigns(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's campaigns"})
+		return
+	}
+	page, limit := paginate(c)
+
+	campaigns, total, err := cc.campaigns.List(domainUUID, limit, (page-1)*limit)
+	if err != nil {
+		cc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.CampaignResponse, 0, len(campaigns))
+	for _, campaign := range campaigns {
+		resp = append(resp, cc.mapCampaignToResponse(campaign, nil))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"campaigns": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (cc *CampaignController) GetCampaign(c *gin.Context) {
+	campaign, err := cc.ownCampaign(c)
+	if err != nil {
+		cc.handleError(c, "fetch", err)
+		return
+	}
+
+	stats, err := cc.campaigns.ContactStats(campaign.CampaignUUID)
+	if err != nil {
+		cc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cc.mapCampaignToResponse(*campaign, stats))
+}
+
+func (cc *CampaignController) UpdateCampaign(c *gin.Context) {
+	var req request.CampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's campaigns"})
+		return
+	}
+
+	campaign, err := cc.ownCampaign(c)
+	if err == nil {
+		campaign, err = cc.campaigns.Update(campaign.CampaignUUID, req)
+	}
+	if err != nil {
+		cc.handleError(c, "update", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cc.mapCampaignToResponse(*campaign, nil))
+}
+
+func (cc *CampaignController) DeleteCampaign(c *gin.Context) {
+	campaign, err := cc.ownCampaign(c)
+	if err == nil {
+		err = cc.campaigns.Delete(campaign.CampaignUUID)
+	}
+	if err != nil {
+		cc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ControlCampaign returns a handler applying action (start, pause, resume or
+// stop) to the campaign in the path.
+func (cc *CampaignController) ControlCampaign(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaign, err := cc.ownCampaign(c)
+		if err == nil {
+			campaign, err = cc.campaigns.SetStatus(campaign.CampaignUUID, action)
+		}
+		if err != nil {
+			cc.handleError(c, action, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, cc.mapCampaignToResponse(*campaign, nil))
+	}
+}
+
+// UploadContacts accepts a CSV either as a multipart "file" field or as a
+// raw text/csv request body.
+func (cc *CampaignController) UploadContacts(c *gin.Context) {
+	campaign, err := cc.ownCampaign(c)
+	if err != nil {
+		cc.handleError(c, "import contacts for", err)
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	result, err := cc.campaigns.ImportContacts(campaign.CampaignUUID, body)
+	if err != nil {
+		cc.handleError(c, "import contacts for", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (cc *CampaignController) GetContacts(c *gin.Context) {
+	campaign, err := cc.ownCampaign(c)
+	if err != nil {
+		cc.handleError(c, "list contacts for", err)
+		return
+	}
+	page, limit := paginate(c)
+
+	contacts, total, err := cc.campaigns.ListContacts(campaign.CampaignUUID, c.Query("status"), limit, (page-1)*limit)
+	if err != nil {
+		cc.handleError(c, "list contacts for", err)
+		return
+	}
+
+	resp := make([]response.CampaignContactResponse, 0, len(contacts))
+	for _, contact := range contacts {
+		resp = append(resp, cc.mapContactToResponse(contact))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"contacts": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// ownCampaign fetches the campaign in the path. Keys bound to a tenant get
+// not found for campaigns of other tenants.
+func (cc *CampaignController) ownCampaign(c *gin.Context) (*models.Campaign, error) {
+	campaign, err := cc.campaigns.Get(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), campaign.DomainUUID.String) {
+		return nil, manager.ErrCampaignNotFound
+	}
+	return campaign, nil
+}
+
+func (cc *CampaignController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrCampaignNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidCampaign), errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrCampaignState):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s campaign: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " campaign"})
+	}
+}
+
+func (cc *CampaignController) mapCampaignToResponse(campaign models.Campaign, stats map[string]int) response.CampaignResponse {
+	resp := response.CampaignResponse{
+		CampaignUUID:      campaign.CampaignUUID,
+		DomainUUID:        campaign.DomainUUID.String,
+		Name:              campaign.Name,
+		Mode:              campaign.Mode,
+		Status:            campaign.Status,
+		Agents:            campaign.Agents,
+		CallerIDName:      campaign.CallerIDName,
+		CallerIDNumber:    campaign.CallerIDNumber,
+		DialTimeout:       campaign.DialTimeout,
+		MaxDialRatio:      campaign.MaxDialRatio,
+		MaxAttempts:       campaign.MaxAttempts,
+		RetryDelaySeconds: campaign.RetryDelaySeconds,
+		RetryCauses:       campaign.RetryCauses,
+		Timezone:          campaign.Timezone,
+		WindowStart:       campaign.WindowStart,
+		WindowEnd:         campaign.WindowEnd,
+		Contacts:          stats,
+		CreatedAt:         campaign.CreatedAt,
+		UpdatedAt:         campaign.UpdatedAt,
+	}
+
+	if len(campaign.Destination) > 0 {
+		if err := json.Unmarshal(campaign.Destination, &resp.Destination); err != nil {
+			log.Printf("Failed to decode destination of campaign %s: %v", campaign.CampaignUUID, err)
+		}
+	}
+
+	if len(campaign.AMD) > 0 {
+		if err := json.Unmarshal(campaign.AMD, &resp.AMD); err != nil {
+			log.Printf("Failed to decode AMD of campaign %s: %v", campaign.CampaignUUID, err)
+		}
+	}
+
+	return resp
+}
+
+func (cc *CampaignController) mapContactToResponse(contact models.CampaignContact) response.CampaignContactResponse {
+	resp := response.CampaignContactResponse{
+		ContactUUID:     contact.ContactUUID,
+		PhoneNumber:     contact.PhoneNumber,
+		Name:            contact.Name,
+		Timezone:        contact.Timezone,
+		Status:          contact.Status,
+		Attempts:        contact.Attempts,
+		LastCallUUID:    contact.LastCallUUID,
+		LastHangupCause: contact.LastHangupCause,
+		LastAgent:       contact.LastAgent,
+		BillSec:         contact.BillSec,
+		AMDResult:       contact.AMDResult,
+	}
+
+	if err := json.Unmarshal(contact.Variables, &resp.Variables); err != nil {
+		log.Printf("Failed to decode variables of contact %s: %v", contact.ContactUUID, err)
+	}
+
+	if contact.NextAttemptAt.Valid {
+		resp.NextAttemptAt = &contact.NextAttemptAt.Time
+	}
+
+	if contact.LastAttemptAt.Valid {
+		resp.LastAttemptAt = &contact.LastAttemptAt.Time
+	}
+
+	return resp
+}
+
+func paginate(c *gin.Context) (int, int) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	return page, limit
+}
@@ -2,23 +2,33 @@ package controller
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
 	"github.com/vishaltalsaniya-7/voip-api/models"
 	"github.com/vishaltalsaniya-7/voip-api/response"
 )
 
+// errForeignTenant is returned by buildFilters when a key bound to a tenant
+// filters on another one.
+var errForeignTenant = errors.New("domain_uuid of another tenant")
+
 type CDRController struct {
-	db *sql.DB
+	db      *sql.DB
+	numbers *manager.NumberManager
 }
 
-func NewCDRController(db *sql.DB) *CDRController {
+func NewCDRController(db *sql.DB, numbers *manager.NumberManager) *CDRController {
 	return &CDRController{
-		db: db,
+		db:      db,
+		numbers: numbers,
 	}
 }
 
@@ -35,8 +45,23 @@ func (cdc *CDRController) GetCDRs(c *gin.Context) {
 
 	offset := (page - 1) * limit
 
+	where, args, err := cdc.buildFilters(c)
+	if errors.Is(err, errForeignTenant) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's CDRs"})
+		return
+	}
+	if errors.Is(err, manager.ErrInvalidDomain) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to build CDR filters: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to filter CDRs"})
+		return
+	}
+
 	var total int
-	err = cdc.db.QueryRow(`SELECT COUNT(*) FROM v_xml_cdr`).Scan(&total)
+	err = cdc.db.QueryRow(`SELECT COUNT(*) FROM v_xml_cdr `+where, args...).Scan(&total)
 	if err != nil {
 		log.Printf("Failed to count CDRs: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count CDRs"})
@@ -55,11 +80,11 @@ func (cdc *CDRController) GetCDRs(c *gin.Context) {
 			cc_queue_terminated_epoch, cc_queue_canceled_epoch, cc_cancel_reason, cc_cause, waitsec, conference_name, conference_uuid, 
 			conference_member_id, digits_dialed, pin_number, status, hangup_cause, hangup_cause_q850, sip_hangup_disposition, 
 			ring_group_uuid, ivr_menu_uuid, call_flow, xml, json, insert_date, insert_user, update_date, update_user
-		FROM v_xml_cdr 
+		FROM v_xml_cdr %s
 		ORDER BY start_stamp DESC 
-		LIMIT %d OFFSET %d`, limit, offset)
+		LIMIT %d OFFSET %d`, where, limit, offset)
 
-	rows, err := cdc.db.Query(query)
+	rows, err := cdc.db.Query(query, args...)
 	if err != nil {
 		log.Printf("Failed to fetch CDRs: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch CDRs"})
@@ -109,6 +134,57 @@ func (cdc *CDRController) GetCDRs(c *gin.Context) {
 	})
 }
 
+// buildFilters turns the domain_uuid, caller, destination and number query
+// parameters into a WHERE clause. Keys bound to a tenant only see their own
+// CDRs. Numbers are normalized and matched in every
+// spelling they may be stored under, so "+1 (555) 010-0000" finds CDRs
+// recorded as 5550100000 or +15550100000.
+func (cdc *CDRController) buildFilters(c *gin.Context) (string, []any, error) {
+	var conds []string
+	var args []any
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		return "", nil, errForeignTenant
+	}
+
+	if domainUUID != "" {
+		args = append(args, domainUUID)
+		conds = append(conds, fmt.Sprintf("domain_uuid::text = $%d", len(args)))
+	}
+
+	numberFilters := []struct {
+		param   string
+		columns []string
+	}{
+		{"caller", []string{"caller_id_number"}},
+		{"destination", []string{"destination_number"}},
+		{"number", []string{"caller_id_number", "destination_number"}},
+	}
+	for _, f := range numberFilters {
+		raw := c.Query(f.param)
+		if raw == "" {
+			continue
+		}
+
+		variants, err := cdc.numbers.Variants(domainUUID, raw)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, pq.Array(variants))
+
+		var matches []string
+		for _, col := range f.columns {
+			matches = append(matches, fmt.Sprintf("%s = ANY($%d)", col, len(args)))
+		}
+		conds = append(conds, "("+strings.Join(matches, " OR ")+")")
+	}
+
+	if len(conds) == 0 {
+		return "", nil, nil
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args, nil
+}
+
 func (cdc *CDRController) mapCDRToResponse(cdr models.CDR) response.CDRResponse {
 	resp := response.CDRResponse{
 		XMLCDRUUID:             cdr.XMLCDRUUID.String,
@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type ConferenceController struct {
+	conferences *manager.ConferenceManager
+	calls       *CallController
+}
+
+// NewConferenceController takes the call controller, so participants are
+// dialed through the same fraud, do-not-call and prepaid checks as
+// POST /call.
+func NewConferenceController(conferences *manager.ConferenceManager, calls *CallController) *ConferenceController {
+	return &ConferenceController{
+		conferences: conferences,
+		calls:       calls,
+	}
+}
+
+// GetConferences lists the running rooms, filtered by domain_uuid. Keys
+// bound to a tenant only see their own rooms.
+func (cc *ConferenceController) GetConferences(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's conferences"})
+		return
+	}
+	rooms := cc.conferences.List(domainUUID)
+
+	resp := make([]response.ConferenceResponse, 0, len(rooms))
+	for _, room := range rooms {
+		resp = append(resp, cc.mapConferenceToResponse(room))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conferences": resp})
+}
+
+func (cc *ConferenceController) GetConference(c *gin.Context) {
+	room, err := cc.room(c)
+	if err != nil {
+		cc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cc.mapConferenceToResponse(*room))
+}
+
+// DialMember calls a number into a room. The member shows up in the room
+// once the callee answers. Dialing into a room that is not running yet
+// starts it.
+func (cc *ConferenceController) DialMember(c *gin.Context) {
+	var req request.ConferenceDialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot place calls for another tenant"})
+		return
+	}
+	// Keys bound to a tenant cannot dial into another tenant's running room.
+	if room, err := cc.conferences.Get(c.Param("name")); err == nil &&
+		!manager.CanAccessDomain(requestKey(c), room.DomainUUID) {
+		cc.handleError(c, "dial into", manager.ErrConferenceNotFound)
+		return
+	}
+
+	call := request.CallRequest{
+		DomainUUID:       req.DomainUUID,
+		Caller:           req.Number,
+		CallerIDName:     req.CallerIDName,
+		CallerIDNumber:   req.CallerIDNumber,
+		Timeout:          req.Timeout,
+		Variables:        req.Variables,
+		IgnoreEarlyMedia: req.IgnoreEarlyMedia,
+		AutoAnswer:       req.AutoAnswer,
+		Destination: &request.CallDestination{
+			Type:       "conference",
+			Conference: c.Param("name"),
+			Profile:    req.Profile,
+		},
+	}
+	if !cc.calls.authorize(c, &call) {
+		return
+	}
+
+	callID, err := cc.conferences.Dial(call)
+	if err != nil {
+		cc.handleError(c, "dial into", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"call_id": callID,
+		"status":  "Call initiated",
+	})
+}
+
+// ControlMember runs action on the member in the path: mute, unmute,
+// deaf, undeaf, kick or floor.
+func (cc *ConferenceController) ControlMember(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		memberID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "member id must be a number"})
+			return
+		}
+
+		room, err := cc.room(c)
+		if err == nil {
+			err = cc.conferences.ControlMember(room.Name, memberID, action)
+		}
+		if err != nil {
+			cc.handleError(c, action+" member of", err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": action + " sent"})
+	}
+}
+
+// Lock keeps new callers out of a room, or lets them in again.
+func (cc *ConferenceController) Lock(locked bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		action := manager.ConferenceUnlock
+		if locked {
+			action = manager.ConferenceLock
+		}
+		room, err := cc.room(c)
+		if err == nil {
+			err = cc.conferences.Lock(room.Name, locked)
+		}
+		if err != nil {
+			cc.handleError(c, action, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": action + " sent"})
+	}
+}
+
+func (cc *ConferenceController) StartRecording(c *gin.Context) {
+	room, err := cc.room(c)
+	var file string
+	if err == nil {
+		file, err = cc.conferences.StartRecording(room.Name)
+	}
+	if err != nil {
+		cc.handleError(c, "record", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "Recording started",
+		"path":   file,
+	})
+}
+
+func (cc *ConferenceController) StopRecording(c *gin.Context) {
+	room, err := cc.room(c)
+	if err == nil {
+		err = cc.conferences.StopRecording(room.Name)
+	}
+	if err != nil {
+		cc.handleError(c, "stop recording", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Recording stopped"})
+}
+
+// room returns the running room in the path. Keys bound to a tenant get
+// not found for rooms of other tenants, and for rooms whose tenant is not
+// known yet.
+func (cc *ConferenceController) room(c *gin.Context) (*manager.Conference, error) {
+	room, err := cc.conferences.Get(c.Param("name"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), room.DomainUUID) {
+		return nil, manager.ErrConferenceNotFound
+	}
+	return room, nil
+}
+
+func (cc *ConferenceController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrConferenceNotFound), errors.Is(err, manager.ErrConferenceMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidConference), errors.Is(err, manager.ErrInvalidOriginate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s conference: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " conference"})
+	}
+}
+
+func (cc *ConferenceController) mapConferenceToResponse(room manager.Conference) response.ConferenceResponse {
+	resp := response.ConferenceResponse{
+		Name:        room.Name,
+		UUID:        room.UUID,
+		DomainUUID:  room.DomainUUID,
+		Locked:      room.Locked,
+		Recording:   room.Recording,
+		Recordings:  room.Recordings,
+		Floor:       room.Floor,
+		Started:     room.Started,
+		MemberCount: len(room.Members),
+		Members:     make([]response.ConferenceMemberResponse, 0, len(room.Members)),
+	}
+	if resp.Recordings == nil {
+		resp.Recordings = []string{}
+	}
+	for _, m := range room.Members {
+		resp.Members = append(resp.Members, response.ConferenceMemberResponse{
+			ID:             m.ID,
+			CallUUID:       m.CallUUID,
+			DomainUUID:     m.DomainUUID,
+			CallerIDName:   m.CallerIDName,
+			CallerIDNumber: m.CallerIDNumber,
+			Muted:          m.Muted,
+			Deaf:           m.Deaf,
+			Talking:        m.Talking,
+			Floor:          m.ID == room.Floor,
+			Moderator:      m.Moderator,
+			Joined:         m.Joined,
+		})
+	}
+	return resp
+}
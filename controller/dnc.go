@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type DNCController struct {
+	dnc *manager.DNCManager
+}
+
+func NewDNCController(dnc *manager.DNCManager) *DNCController {
+	return &DNCController{
+		dnc: dnc,
+	}
+}
+
+func (dc *DNCController) CreateEntry(c *gin.Context) {
+	var req request.DNCEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's do-not-call list"})
+		return
+	}
+
+	entry, err := dc.dnc.Create(req)
+	if err != nil {
+		dc.handleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, dc.mapEntryToResponse(*entry))
+}
+
+func (dc *DNCController) GetEntries(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's do-not-call list"})
+		return
+	}
+	page, limit := paginate(c)
+
+	entries, total, err := dc.dnc.List(domainUUID, c.Query("q"), limit, (page-1)*limit)
+	if err != nil {
+		dc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.DNCEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		resp = append(resp, dc.mapEntryToResponse(entry))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// GetEntry returns an entry of the caller's tenant or a global one.
+func (dc *DNCController) GetEntry(c *gin.Context) {
+	entry, err := dc.dnc.Get(c.Param("uuid"))
+	if err == nil && entry.DomainUUID.Valid && !manager.CanAccessDomain(requestKey(c), entry.DomainUUID.String) {
+		err = manager.ErrDNCEntryNotFound
+	}
+	if err != nil {
+		dc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dc.mapEntryToResponse(*entry))
+}
+
+func (dc *DNCController) UpdateEntry(c *gin.Context) {
+	var req request.DNCEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's do-not-call list"})
+		return
+	}
+
+	entry, err := dc.ownEntry(c)
+	if err == nil {
+		entry, err = dc.dnc.Update(entry.DNCEntryUUID, req)
+	}
+	if err != nil {
+		dc.handleError(c, "update", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dc.mapEntryToResponse(*entry))
+}
+
+func (dc *DNCController) DeleteEntry(c *gin.Context) {
+	entry, err := dc.ownEntry(c)
+	if err == nil {
+		err = dc.dnc.Delete(entry.DNCEntryUUID)
+	}
+	if err != nil {
+		dc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ImportEntries bulk-loads a CSV (multipart "file" field or raw text/csv
+// body) into the list of the domain_uuid query parameter, or the global list.
+// Keys bound to a tenant always import into their own list.
+func (dc *DNCController) ImportEntries(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's do-not-call list"})
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	result, err := dc.dnc.Import(domainUUID, body)
+	if err != nil {
+		dc.handleError(c, "import", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (dc *DNCController) GetAudit(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's blocked calls"})
+		return
+	}
+	page, limit := paginate(c)
+
+	audits, total, err := dc.dnc.ListAudit(domainUUID, limit, (page-1)*limit)
+	if err != nil {
+		dc.handleError(c, "list blocked calls for", err)
+		return
+	}
+
+	resp := make([]response.DNCAuditResponse, 0, len(audits))
+	for _, a := range audits {
+		resp = append(resp, response.DNCAuditResponse{
+			DNCAuditUUID: a.DNCAuditUUID,
+			DomainUUID:   a.DomainUUID.String,
+			Caller:       a.Caller,
+			Callee:       a.Callee,
+			Number:       a.Number,
+			DNCEntryUUID: a.DNCEntryUUID.String,
+			Reason:       a.Reason,
+			CreatedAt:    a.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"blocked_calls": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// ownEntry fetches the entry in the path for a change. Keys bound to a
+// tenant can only change that tenant's entries, not global ones.
+func (dc *DNCController) ownEntry(c *gin.Context) (*models.DNCEntry, error) {
+	entry, err := dc.dnc.Get(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), entry.DomainUUID.String) {
+		return nil, manager.ErrDNCEntryNotFound
+	}
+	return entry, nil
+}
+
+func (dc *DNCController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrDNCEntryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidDNCEntry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s do-not-call entries: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " do-not-call entries"})
+	}
+}
+
+func (dc *DNCController) mapEntryToResponse(entry models.DNCEntry) response.DNCEntryResponse {
+	return response.DNCEntryResponse{
+		DNCEntryUUID: entry.DNCEntryUUID,
+		DomainUUID:   entry.DomainUUID.String,
+		MatchType:    entry.MatchType,
+		Pattern:      entry.Pattern,
+		Reason:       entry.Reason,
+		CreatedAt:    entry.CreatedAt,
+	}
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type DTMFController struct {
+	dtmf     *manager.DTMFManager
+	eslMgr   *manager.ESLManager
+	upgrader websocket.Upgrader
+}
+
+func NewDTMFController(dtmf *manager.DTMFManager, eslMgr *manager.ESLManager, cfg config.DTMFConfig) *DTMFController {
+	return &DTMFController{
+		dtmf:   dtmf,
+		eslMgr: eslMgr,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: websocketOrigin(cfg.AllowedOrigins),
+		},
+	}
+}
+
+// SendDTMF plays digits into the call in the path. Keys bound to a tenant
+// get not found for calls of other tenants.
+func (dc *DTMFController) SendDTMF(c *gin.Context) {
+	var req request.DTMFRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uuid := c.Param("uuid")
+	ok, err := canAccessCall(c, dc.eslMgr, uuid)
+	if err == nil && !ok {
+		err = manager.ErrDTMFCallNotFound
+	}
+	if err == nil {
+		err = dc.dtmf.Send(uuid, req.Digits, req.DurationMS)
+	}
+	if err != nil {
+		dc.handleError(c, "send", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "DTMF sent"})
+}
+
+// GetDigits returns the digits the call in the path has received.
+func (dc *DTMFController) GetDigits(c *gin.Context) {
+	digits, err := dc.digits(c)
+	if err != nil {
+		dc.handleError(c, "fetch", err)
+		return
+	}
+
+	resp := response.CallDigitsResponse{
+		CallUUID:     digits.CallUUID,
+		DomainUUID:   digits.DomainUUID,
+		DigitsDialed: digits.DigitsDialed,
+		Ended:        digits.Ended,
+		Events:       make([]response.DTMFEventResponse, 0, len(digits.Events)),
+	}
+	for _, e := range digits.Events {
+		resp.Events = append(resp.Events, dc.mapEventToResponse(e))
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Stream upgrades to a WebSocket and pushes the digits of the call in the
+// path, or of every call of ?domain_uuid=, as they are pressed. A call's
+// stream closes when the call ends. Keys bound to a tenant only see their
+// own calls.
+func (dc *DTMFController) Stream(c *gin.Context) {
+	callUUID, domainUUID := c.Param("uuid"), ""
+	if callUUID != "" {
+		if _, err := dc.digits(c); err != nil {
+			dc.handleError(c, "stream", err)
+			return
+		}
+	} else {
+		var ok bool
+		if domainUUID, ok = tenantScope(c, c.Query("domain_uuid")); !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's DTMF"})
+			return
+		}
+	}
+
+	events, cancel, err := dc.dtmf.Subscribe(callUUID, domainUUID)
+	if err != nil {
+		dc.handleError(c, "stream", err)
+		return
+	}
+	defer cancel()
+
+	conn, err := dc.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written the error response.
+		log.Printf("Failed to open DTMF stream: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	streamJSON(conn, events, func(e manager.DTMFEvent) any {
+		return dc.mapEventToResponse(e)
+	})
+}
+
+// digits returns the digits of the call in the path. Keys bound to a
+// tenant get not found for calls of other tenants.
+func (dc *DTMFController) digits(c *gin.Context) (*manager.CallDigits, error) {
+	digits, err := dc.dtmf.Digits(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), digits.DomainUUID) {
+		return nil, manager.ErrDTMFCallNotFound
+	}
+	return digits, nil
+}
+
+func (dc *DTMFController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrDTMFCallNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidDTMF), errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s DTMF: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " DTMF"})
+	}
+}
+
+func (dc *DTMFController) mapEventToResponse(e manager.DTMFEvent) response.DTMFEventResponse {
+	return response.DTMFEventResponse{
+		CallUUID:     e.CallUUID,
+		DomainUUID:   e.DomainUUID,
+		Digit:        e.Digit,
+		DurationMS:   e.DurationMS,
+		Source:       e.Source,
+		Seq:          e.Seq,
+		DigitsDialed: e.DigitsDialed,
+		Time:         e.Time,
+	}
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type EncryptionController struct {
+	keys *manager.EncryptionManager
+}
+
+func NewEncryptionController(keys *manager.EncryptionManager) *EncryptionController {
+	return &EncryptionController{
+		keys: keys,
+	}
+}
+
+// GetKeys lists the data keys, without key material.
+func (ec *EncryptionController) GetKeys(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's keys"})
+		return
+	}
+
+	keys, err := ec.keys.ListKeys(domainUUID)
+	if err != nil {
+		ec.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.RecordingKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		resp = append(resp, ec.mapKeyToResponse(key))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keys": resp, "encryption_enabled": ec.keys.Enabled()})
+}
+
+// RotateKey gives a tenant a new data key for the recordings stored from
+// now on.
+func (ec *EncryptionController) RotateKey(c *gin.Context) {
+	var req request.RecordingKeyRotateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot rotate another tenant's key"})
+		return
+	}
+
+	key, err := ec.keys.Rotate(req.DomainUUID)
+	if err != nil {
+		ec.handleError(c, "rotate", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ec.mapKeyToResponse(*key))
+}
+
+// Rewrap wraps all data keys with the current master key. It covers every
+// tenant, so keys bound to a tenant cannot run it.
+func (ec *EncryptionController) Rewrap(c *gin.Context) {
+	if requestKey(c).DomainUUID.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot re-wrap the keys of every tenant"})
+		return
+	}
+
+	n, err := ec.keys.Rewrap()
+	if err != nil {
+		ec.handleError(c, "re-wrap", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rewrapped": n})
+}
+
+func (ec *EncryptionController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrDataKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrEncryptionDisabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s recording keys: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " recording keys"})
+	}
+}
+
+func (ec *EncryptionController) mapKeyToResponse(key models.RecordingKey) response.RecordingKeyResponse {
+	resp := response.RecordingKeyResponse{
+		KeyUUID:     key.KeyUUID,
+		DomainUUID:  key.DomainUUID.String,
+		MasterKeyID: key.MasterKeyID,
+		Active:      key.Active,
+		Objects:     key.Objects,
+		CreatedAt:   key.CreatedAt,
+	}
+	if key.RewrappedAt.Valid {
+		resp.RewrappedAt = &key.RewrappedAt.Time
+	}
+	if key.RetiredAt.Valid {
+		resp.RetiredAt = &key.RetiredAt.Time
+	}
+	return resp
+}
@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type ExtensionController struct {
+	extensions *manager.ExtensionManager
+}
+
+func NewExtensionController(extensions *manager.ExtensionManager) *ExtensionController {
+	return &ExtensionController{
+		extensions: extensions,
+	}
+}
+
+// CreateExtension adds an extension. Keys bound to a tenant create it in
+// their tenant; other keys must give a domain_uuid. The response is the
+// only one carrying the passwords.
+func (ec *ExtensionController) CreateExtension(c *gin.Context) {
+	var req request.ExtensionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's extensions"})
+		return
+	}
+
+	ext, secrets, err := ec.extensions.CreateExtension(req)
+	if err != nil {
+		ec.handleError(c, "create", err)
+		return
+	}
+
+	resp := ec.mapExtensionToResponse(*ext)
+	resp.Password = secrets.Password
+	if resp.Voicemail != nil {
+		resp.Voicemail.Password = secrets.VoicemailPassword
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// GetExtensions returns extensions, filtered by domain_uuid. Keys bound to
+// a tenant only see their own.
+func (ec *ExtensionController) GetExtensions(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's extensions"})
+		return
+	}
+	page, limit := paginate(c)
+
+	extensions, total, err := ec.extensions.ListExtensions(domainUUID, limit, (page-1)*limit)
+	if err != nil {
+		ec.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.ExtensionResponse, 0, len(extensions))
+	for _, ext := range extensions {
+		resp = append(resp, ec.mapExtensionToResponse(ext))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"extensions": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (ec *ExtensionController) GetExtension(c *gin.Context) {
+	ext, err := ec.extension(c)
+	if err != nil {
+		ec.handleError(c, "fetch", err)
+		return
+	}
+	c.JSON(http.StatusOK, ec.mapExtensionToResponse(*ext))
+}
+
+func (ec *ExtensionController) UpdateExtension(c *gin.Context) {
+	var req request.ExtensionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ext, err := ec.extension(c)
+	if err == nil && req.DomainUUID != "" && req.DomainUUID != ext.DomainUUID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "domain_uuid of an extension cannot change"})
+		return
+	}
+	if err == nil {
+		ext, err = ec.extensions.UpdateExtension(ext.ExtensionUUID, req)
+	}
+	if err != nil {
+		ec.handleError(c, "update", err)
+		return
+	}
+	c.JSON(http.StatusOK, ec.mapExtensionToResponse(*ext))
+}
+
+func (ec *ExtensionController) EnableExtension(c *gin.Context) {
+	ec.setEnabled(c, true)
+}
+
+func (ec *ExtensionController) DisableExtension(c *gin.Context) {
+	ec.setEnabled(c, false)
+}
+
+func (ec *ExtensionController) setEnabled(c *gin.Context, enabled bool) {
+	ext, err := ec.extension(c)
+	if err == nil {
+		ext, err = ec.extensions.SetEnabled(ext.ExtensionUUID, enabled)
+	}
+	if err != nil {
+		ec.handleError(c, "update", err)
+		return
+	}
+	c.JSON(http.StatusOK, ec.mapExtensionToResponse(*ext))
+}
+
+// ResetPassword generates a new SIP password and returns it once.
+func (ec *ExtensionController) ResetPassword(c *gin.Context) {
+	ext, err := ec.extension(c)
+	if err != nil {
+		ec.handleError(c, "update", err)
+		return
+	}
+
+	ext, password, err := ec.extensions.ResetPassword(ext.ExtensionUUID)
+	if err != nil {
+		ec.handleError(c, "update", err)
+		return
+	}
+
+	resp := ec.mapExtensionToResponse(*ext)
+	resp.Password = password
+	c.JSON(http.StatusOK, resp)
+}
+
+func (ec *ExtensionController) DeleteExtension(c *gin.Context) {
+	ext, err := ec.extension(c)
+	if err == nil {
+		err = ec.extensions.DeleteExtension(ext.ExtensionUUID)
+	}
+	if err != nil {
+		ec.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// extension returns the extension in the path. Keys bound to a tenant get
+// not found for extensions of other tenants.
+func (ec *ExtensionController) extension(c *gin.Context) (*models.Extension, error) {
+	ext, err := ec.extensions.GetExtension(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), ext.DomainUUID) {
+		return nil, manager.ErrExtensionNotFound
+	}
+	return ext, nil
+}
+
+func (ec *ExtensionController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrExtensionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrExtensionExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidDomain), errors.Is(err, manager.ErrInvalidUserContext):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s extension: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " extension"})
+	}
+}
+
+func (ec *ExtensionController) mapExtensionToResponse(e models.Extension) response.ExtensionResponse {
+	resp := response.ExtensionResponse{
+		ExtensionUUID:           e.ExtensionUUID,
+		DomainUUID:              e.DomainUUID,
+		DomainName:              e.DomainName,
+		Extension:               e.Extension,
+		NumberAlias:             e.NumberAlias,
+		EffectiveCallerIDName:   e.EffectiveCallerIDName,
+		EffectiveCallerIDNumber: e.EffectiveCallerIDNumber,
+		OutboundCallerIDName:    e.OutboundCallerIDName,
+		OutboundCallerIDNumber:  e.OutboundCallerIDNumber,
+		DirectoryFirstName:      e.DirectoryFirstName,
+		DirectoryLastName:       e.DirectoryLastName,
+		UserContext:             e.UserContext,
+		CallTimeout:             e.CallTimeout,
+		Description:             e.Description,
+		Enabled:                 e.Enabled,
+	}
+	if e.VoicemailUUID.Valid {
+		resp.Voicemail = &response.ExtensionVoicemailResponse{
+			VoicemailUUID: e.VoicemailUUID.String,
+			Enabled:       e.VoicemailEnabled.Bool,
+			MailTo:        e.VoicemailMailTo.String,
+			AttachFile:    e.VoicemailAttachFile.Bool,
+			KeepLocal:     e.VoicemailKeepLocal.Bool,
+		}
+	}
+	if e.CreatedAt.Valid {
+		resp.CreatedAt = &e.CreatedAt.Time
+	}
+	if e.UpdatedAt.Valid {
+		resp.UpdatedAt = &e.UpdatedAt.Time
+	}
+	return resp
+}
@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type FraudController struct {
+	fraud *manager.FraudManager
+}
+
+func NewFraudController(fraud *manager.FraudManager) *FraudController {
+	return &FraudController{
+		fraud: fraud,
+	}
+}
+
+func (fc *FraudController) CreateRule(c *gin.Context) {
+	var req request.FraudRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's fraud rules"})
+		return
+	}
+
+	rule, err := fc.fraud.CreateRule(req)
+	if err != nil {
+		fc.handleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, fc.mapRuleToResponse(*rule))
+}
+
+// GetRules returns the rules watching ?domain_uuid=. Keys bound to a tenant
+// see their own and global rules.
+func (fc *FraudController) GetRules(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's fraud rules"})
+		return
+	}
+
+	rules, err := fc.fraud.ListRules(domainUUID)
+	if err != nil {
+		fc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.FraudRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		resp = append(resp, fc.mapRuleToResponse(rule))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": resp})
+}
+
+func (fc *FraudController) GetRule(c *gin.Context) {
+	rule, err := fc.fraud.GetRule(c.Param("uuid"))
+	if err == nil && rule.DomainUUID.Valid && !manager.CanAccessDomain(requestKey(c), rule.DomainUUID.String) {
+		err = manager.ErrFraudRuleNotFound
+	}
+	if err != nil {
+		fc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, fc.mapRuleToResponse(*rule))
+}
+
+func (fc *FraudController) UpdateRule(c *gin.Context) {
+	var req request.FraudRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's fraud rules"})
+		return
+	}
+
+	rule, err := fc.ownRule(c)
+	if err == nil {
+		rule, err = fc.fraud.UpdateRule(rule.RuleUUID, req)
+	}
+	if err != nil {
+		fc.handleError(c, "update", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, fc.mapRuleToResponse(*rule))
+}
+
+func (fc *FraudController) DeleteRule(c *gin.Context) {
+	rule, err := fc.ownRule(c)
+	if err == nil {
+		err = fc.fraud.DeleteRule(rule.RuleUUID)
+	}
+	if err != nil {
+		fc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetEvents returns the fraud audit trail, filtered by domain_uuid,
+// rule_uuid and extension. Keys bound to a tenant only see their own events.
+func (fc *FraudController) GetEvents(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's fraud events"})
+		return
+	}
+	page, limit := paginate(c)
+
+	events, total, err := fc.fraud.ListEvents(domainUUID, c.Query("rule_uuid"), c.Query("extension"),
+		limit, (page-1)*limit)
+	if err != nil {
+		fc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.FraudEventResponse, 0, len(events))
+	for _, e := range events {
+		resp = append(resp, response.FraudEventResponse{
+			EventUUID:  e.EventUUID,
+			RuleUUID:   e.RuleUUID.String,
+			DomainUUID: e.DomainUUID.String,
+			Kind:       e.Kind,
+			Extension:  e.Extension,
+			CallUUID:   e.CallUUID,
+			Number:     e.Number,
+			Detail:     e.Detail,
+			Actions:    e.Actions,
+			CreatedAt:  e.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (fc *FraudController) CreateBlock(c *gin.Context) {
+	var req request.FraudBlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot block another tenant's extensions"})
+		return
+	}
+
+	block, err := fc.fraud.CreateBlock(req)
+	if err != nil {
+		fc.handleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, fc.mapBlockToResponse(*block))
+}
+
+func (fc *FraudController) GetBlocks(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's fraud blocks"})
+		return
+	}
+	page, limit := paginate(c)
+
+	blocks, total, err := fc.fraud.ListBlocks(domainUUID, limit, (page-1)*limit)
+	if err != nil {
+		fc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.FraudBlockResponse, 0, len(blocks))
+	for _, b := range blocks {
+		resp = append(resp, fc.mapBlockToResponse(b))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"blocks": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (fc *FraudController) DeleteBlock(c *gin.Context) {
+	block, err := fc.fraud.GetBlock(c.Param("uuid"))
+	if err == nil && !manager.CanAccessDomain(requestKey(c), block.DomainUUID.String) {
+		err = manager.ErrFraudBlockNotFound
+	}
+	if err == nil {
+		err = fc.fraud.DeleteBlock(block.BlockUUID)
+	}
+	if err != nil {
+		fc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ownRule fetches the rule in the path for a change. Keys bound to a tenant
+// can only change that tenant's rules, not global ones.
+func (fc *FraudController) ownRule(c *gin.Context) (*models.FraudRule, error) {
+	rule, err := fc.fraud.GetRule(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), rule.DomainUUID.String) {
+		return nil, manager.ErrFraudRuleNotFound
+	}
+	return rule, nil
+}
+
+func (fc *FraudController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrFraudRuleNotFound), errors.Is(err, manager.ErrFraudBlockNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidFraudRule), errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s fraud data: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " fraud data"})
+	}
+}
+
+func (fc *FraudController) mapRuleToResponse(rule models.FraudRule) response.FraudRuleResponse {
+	return response.FraudRuleResponse{
+		RuleUUID:      rule.RuleUUID,
+		DomainUUID:    rule.DomainUUID.String,
+		Name:          rule.Name,
+		Kind:          rule.Kind,
+		Prefixes:      rule.Prefixes,
+		Threshold:     rule.Threshold,
+		WindowSeconds: rule.WindowSeconds,
+		MaxDuration:   rule.MaxDuration,
+		BusinessStart: rule.BusinessStart.String,
+		BusinessEnd:   rule.BusinessEnd.String,
+		BusinessDays:  rule.BusinessDays,
+		Timezone:      rule.Timezone,
+		Actions:       rule.Actions,
+		WebhookURL:    rule.WebhookURL,
+		Enabled:       rule.Enabled,
+		CreatedAt:     rule.CreatedAt,
+		UpdatedAt:     rule.UpdatedAt,
+	}
+}
+
+func (fc *FraudController) mapBlockToResponse(b models.FraudBlock) response.FraudBlockResponse {
+	return response.FraudBlockResponse{
+		BlockUUID:  b.BlockUUID,
+		DomainUUID: b.DomainUUID.String,
+		Extension:  b.Extension,
+		RuleUUID:   b.RuleUUID.String,
+		Reason:     b.Reason,
+		CreatedAt:  b.CreatedAt,
+	}
+}
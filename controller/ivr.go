@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type IVRController struct {
+	ivr    *manager.IVRManager
+	eslMgr *manager.ESLManager
+}
+
+func NewIVRController(ivr *manager.IVRManager, eslMgr *manager.ESLManager) *IVRController {
+	return &IVRController{
+		ivr:    ivr,
+		eslMgr: eslMgr,
+	}
+}
+
+func (ic *IVRController) Play(c *gin.Context) {
+	var req request.PlayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := ic.call(c)
+	if err == nil {
+		err = ic.ivr.Play(c.Param("uuid"), req)
+	}
+	if err != nil {
+		ic.handleError(c, "play", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "Playback started"})
+}
+
+// Gather queues a play-and-collect on the call in the path. The digits are
+// posted to the callback once the caller is done.
+func (ic *IVRController) Gather(c *gin.Context) {
+	var req request.GatherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var gather *manager.Gather
+	err := ic.call(c)
+	if err == nil {
+		gather, err = ic.ivr.Gather(c.Param("uuid"), req)
+	}
+	if err != nil {
+		ic.handleError(c, "gather", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, ic.mapGatherToResponse(*gather))
+}
+
+func (ic *IVRController) GetGather(c *gin.Context) {
+	gather, err := ic.ivr.GetGather(c.Param("uuid"), c.Param("id"))
+	if err == nil && !manager.CanAccessDomain(requestKey(c), gather.DomainUUID) {
+		err = manager.ErrGatherNotFound
+	}
+	if err != nil {
+		ic.handleError(c, "fetch gather", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ic.mapGatherToResponse(*gather))
+}
+
+// call checks the call in the path. Keys bound to a tenant get not found
+// for calls of other tenants.
+func (ic *IVRController) call(c *gin.Context) error {
+	ok, err := canAccessCall(c, ic.eslMgr, c.Param("uuid"))
+	if err == nil && !ok {
+		err = manager.ErrIVRCallNotFound
+	}
+	return err
+}
+
+func (ic *IVRController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrIVRCallNotFound), errors.Is(err, manager.ErrGatherNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidIVR):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s on call: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " on call"})
+	}
+}
+
+func (ic *IVRController) mapGatherToResponse(g manager.Gather) response.GatherResponse {
+	resp := response.GatherResponse{
+		GatherUUID: g.GatherUUID,
+		CallUUID:   g.CallUUID,
+		Status:     g.Status,
+		Digits:     g.Digits,
+		CreatedAt:  g.CreatedAt,
+	}
+	if !g.CompletedAt.IsZero() {
+		resp.CompletedAt = &g.CompletedAt
+	}
+	return resp
+}
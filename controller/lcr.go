@@ -0,0 +1,340 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/number"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type RouteController struct {
+	routes  *manager.RouteManager
+	numbers *manager.NumberManager
+}
+
+func NewRouteController(routes *manager.RouteManager, numbers *manager.NumberManager) *RouteController {
+	return &RouteController{
+		routes:  routes,
+		numbers: numbers,
+	}
+}
+
+// CreateGateway adds a gateway. Gateways are shared by every tenant, so keys
+// bound to a tenant cannot change them.
+func (rc *RouteController) CreateGateway(c *gin.Context) {
+	if requestKey(c).DomainUUID.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage gateways shared by every tenant"})
+		return
+	}
+
+	var req request.GatewayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	gw, err := rc.routes.CreateGateway(req)
+	if err != nil {
+		rc.handleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rc.mapGatewayToResponse(*gw))
+}
+
+func (rc *RouteController) GetGateways(c *gin.Context) {
+	gateways, err := rc.routes.ListGateways()
+	if err != nil {
+		rc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.GatewayResponse, 0, len(gateways))
+	for _, gw := range gateways {
+		resp = append(resp, rc.mapGatewayToResponse(gw))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"gateways": resp})
+}
+
+func (rc *RouteController) GetGateway(c *gin.Context) {
+	gw, err := rc.routes.GetGateway(c.Param("uuid"))
+	if err != nil {
+		rc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rc.mapGatewayToResponse(*gw))
+}
+
+func (rc *RouteController) UpdateGateway(c *gin.Context) {
+	if requestKey(c).DomainUUID.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage gateways shared by every tenant"})
+		return
+	}
+
+	var req request.GatewayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	gw, err := rc.routes.UpdateGateway(c.Param("uuid"), req)
+	if err != nil {
+		rc.handleError(c, "update", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rc.mapGatewayToResponse(*gw))
+}
+
+func (rc *RouteController) DeleteGateway(c *gin.Context) {
+	if requestKey(c).DomainUUID.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage gateways shared by every tenant"})
+		return
+	}
+
+	if err := rc.routes.DeleteGateway(c.Param("uuid")); err != nil {
+		rc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (rc *RouteController) CreateRoute(c *gin.Context) {
+	var req request.RouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's routes"})
+		return
+	}
+
+	route, err := rc.routes.CreateRoute(req)
+	if err != nil {
+		rc.handleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rc.mapRouteToResponse(*route))
+}
+
+func (rc *RouteController) GetRoutes(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's routes"})
+		return
+	}
+	page, limit := paginate(c)
+
+	routes, total, err := rc.routes.ListRoutes(c.Query("gateway_uuid"), domainUUID, c.Query("prefix"),
+		limit, (page-1)*limit)
+	if err != nil {
+		rc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.RouteResponse, 0, len(routes))
+	for _, route := range routes {
+		resp = append(resp, rc.mapRouteToResponse(route))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"routes": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// GetRoute returns a route of the caller's tenant or a global one.
+func (rc *RouteController) GetRoute(c *gin.Context) {
+	route, err := rc.routes.GetRoute(c.Param("uuid"))
+	if err == nil && route.DomainUUID.Valid && !manager.CanAccessDomain(requestKey(c), route.DomainUUID.String) {
+		err = manager.ErrRouteNotFound
+	}
+	if err != nil {
+		rc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rc.mapRouteToResponse(*route))
+}
+
+func (rc *RouteController) UpdateRoute(c *gin.Context) {
+	var req request.RouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's routes"})
+		return
+	}
+
+	route, err := rc.ownRoute(c)
+	if err == nil {
+		route, err = rc.routes.UpdateRoute(route.RouteUUID, req)
+	}
+	if err != nil {
+		rc.handleError(c, "update", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rc.mapRouteToResponse(*route))
+}
+
+func (rc *RouteController) DeleteRoute(c *gin.Context) {
+	route, err := rc.ownRoute(c)
+	if err == nil {
+		err = rc.routes.DeleteRoute(route.RouteUUID)
+	}
+	if err != nil {
+		rc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Lookup shows the routes an external number would be tried on, in order.
+func (rc *RouteController) Lookup(c *gin.Context) {
+	raw := c.Query("number")
+	if raw == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "number is required"})
+		return
+	}
+
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's routes"})
+		return
+	}
+
+	n, err := rc.numbers.Resolve(domainUUID, raw)
+	if err != nil {
+		rc.handleError(c, "look up", err)
+		return
+	}
+	if n.Kind != number.PSTN {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "number " + n.Dial + " is a local extension"})
+		return
+	}
+
+	routes, err := rc.routes.Lookup(domainUUID, n.E164)
+	if err != nil {
+		rc.handleError(c, "look up", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"number": n,
+		"routes": routes,
+	})
+}
+
+// GetCallRoutes lists the routed legs of a call, including failed attempts.
+// Keys bound to a tenant only see the legs of their own calls.
+func (rc *RouteController) GetCallRoutes(c *gin.Context) {
+	routes, err := rc.routes.ListCallRoutes(c.Param("uuid"))
+	if err != nil {
+		rc.handleError(c, "fetch", err)
+		return
+	}
+
+	resp := make([]response.CallRouteResponse, 0, len(routes))
+	for _, cr := range routes {
+		if !manager.CanAccessDomain(requestKey(c), cr.DomainUUID.String) {
+			continue
+		}
+		r := response.CallRouteResponse{
+			LegUUID:     cr.LegUUID,
+			CallUUID:    cr.CallUUID,
+			DomainUUID:  cr.DomainUUID.String,
+			Number:      cr.Number,
+			RouteUUID:   cr.RouteUUID.String,
+			Gateway:     cr.Gateway,
+			Prefix:      cr.Prefix,
+			Rate:        cr.Rate,
+			Attempt:     cr.Attempt,
+			HangupCause: cr.HangupCause,
+			BillSec:     cr.BillSec,
+			CreatedAt:   cr.CreatedAt,
+			UpdatedAt:   cr.UpdatedAt,
+		}
+		if cr.HangupCauseQ850.Valid {
+			r.HangupCauseQ850 = &cr.HangupCauseQ850.Int64
+		}
+		resp = append(resp, r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"routes": resp})
+}
+
+// ownRoute fetches the route in the path for a change. Keys bound to a
+// tenant can only change that tenant's routes, not global ones.
+func (rc *RouteController) ownRoute(c *gin.Context) (*models.Route, error) {
+	route, err := rc.routes.GetRoute(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), route.DomainUUID.String) {
+		return nil, manager.ErrRouteNotFound
+	}
+	return route, nil
+}
+
+func (rc *RouteController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrGatewayNotFound), errors.Is(err, manager.ErrRouteNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrGatewayExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidRoute), errors.Is(err, manager.ErrInvalidDomain),
+		errors.Is(err, number.ErrInvalidNumber):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s routing data: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " routing data"})
+	}
+}
+
+func (rc *RouteController) mapGatewayToResponse(gw models.Gateway) response.GatewayResponse {
+	return response.GatewayResponse{
+		GatewayUUID: gw.GatewayUUID,
+		Name:        gw.Name,
+		Enabled:     gw.Enabled,
+		Description: gw.Description,
+		CreatedAt:   gw.CreatedAt,
+		UpdatedAt:   gw.UpdatedAt,
+	}
+}
+
+func (rc *RouteController) mapRouteToResponse(route models.Route) response.RouteResponse {
+	return response.RouteResponse{
+		RouteUUID:   route.RouteUUID,
+		GatewayUUID: route.GatewayUUID,
+		GatewayName: route.GatewayName,
+		DomainUUID:  route.DomainUUID.String,
+		Prefix:      route.Prefix,
+		Rate:        route.Rate,
+		Priority:    route.Priority,
+		Enabled:     route.Enabled,
+		Description: route.Description,
+		CreatedAt:   route.CreatedAt,
+		UpdatedAt:   route.UpdatedAt,
+	}
+}
@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type MonitorController struct {
+	monitor *manager.MonitorManager
+}
+
+func NewMonitorController(monitor *manager.MonitorManager) *MonitorController {
+	return &MonitorController{
+		monitor: monitor,
+	}
+}
+
+// Monitor rings the supervisor into the call in the path. It returns once
+// the supervisor answers.
+func (mc *MonitorController) Monitor(c *gin.Context) {
+	var req request.MonitorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := mc.monitor.Monitor(c.Param("uuid"), req, requestKey(c))
+	if cause := manager.HangupCause(err); cause != "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":        "supervisor did not answer",
+			"hangup_cause": cause,
+		})
+		return
+	}
+	if err != nil {
+		mc.handleError(c, "start", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, mc.mapSessionToResponse(*session))
+}
+
+// GetSessions returns the monitor audit log, filtered by domain_uuid and
+// call_uuid. Keys bound to a tenant only see their own.
+func (mc *MonitorController) GetSessions(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": manager.ErrMonitorForbidden.Error()})
+		return
+	}
+	page, limit := paginate(c)
+
+	sessions, total, err := mc.monitor.ListSessions(domainUUID, c.Query("call_uuid"), limit, (page-1)*limit)
+	if err != nil {
+		mc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.MonitorSessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, mc.mapSessionToResponse(s))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (mc *MonitorController) CreateGroup(c *gin.Context) {
+	var req request.MonitorGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's monitor groups"})
+		return
+	}
+
+	group, err := mc.monitor.CreateGroup(req)
+	if err != nil {
+		mc.handleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, mc.mapGroupToResponse(*group))
+}
+
+// GetGroups lists the monitor groups of ?domain_uuid=. Keys bound to a
+// tenant only see their own.
+func (mc *MonitorController) GetGroups(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's monitor groups"})
+		return
+	}
+
+	groups, err := mc.monitor.ListGroups(domainUUID)
+	if err != nil {
+		mc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.MonitorGroupResponse, 0, len(groups))
+	for _, g := range groups {
+		resp = append(resp, mc.mapGroupToResponse(g))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"groups": resp})
+}
+
+func (mc *MonitorController) GetGroup(c *gin.Context) {
+	group, err := mc.ownGroup(c)
+	if err != nil {
+		mc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, mc.mapGroupToResponse(*group))
+}
+
+func (mc *MonitorController) UpdateGroup(c *gin.Context) {
+	var req request.MonitorGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's monitor groups"})
+		return
+	}
+
+	group, err := mc.ownGroup(c)
+	if err == nil {
+		group, err = mc.monitor.UpdateGroup(group.GroupUUID, req)
+	}
+	if err != nil {
+		mc.handleError(c, "update", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, mc.mapGroupToResponse(*group))
+}
+
+func (mc *MonitorController) DeleteGroup(c *gin.Context) {
+	group, err := mc.ownGroup(c)
+	if err == nil {
+		err = mc.monitor.DeleteGroup(group.GroupUUID)
+	}
+	if err != nil {
+		mc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ownGroup fetches the group in the path. Keys bound to a tenant get not
+// found for groups of other tenants.
+func (mc *MonitorController) ownGroup(c *gin.Context) (*models.MonitorGroup, error) {
+	group, err := mc.monitor.GetGroup(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), group.DomainUUID) {
+		return nil, manager.ErrMonitorGroupNotFound
+	}
+	return group, nil
+}
+
+func (mc *MonitorController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrMonitorCallNotFound), errors.Is(err, manager.ErrMonitorGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrMonitorForbidden), errors.Is(err, manager.ErrMonitorNotSupervisor):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidMonitor), errors.Is(err, manager.ErrInvalidMonitorGroup),
+		errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s monitor session: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " monitor session"})
+	}
+}
+
+func (mc *MonitorController) mapSessionToResponse(s models.MonitorSession) response.MonitorSessionResponse {
+	resp := response.MonitorSessionResponse{
+		SessionUUID: s.SessionUUID,
+		CallUUID:    s.CallUUID,
+		DomainUUID:  s.DomainUUID.String,
+		Mode:        s.Mode,
+		Supervisor:  s.Supervisor,
+		KeyUUID:     s.KeyUUID.String,
+		KeyName:     s.KeyName,
+		Status:      s.Status,
+		HangupCause: s.HangupCause,
+		CreatedAt:   s.CreatedAt,
+	}
+	if s.AnsweredAt.Valid {
+		resp.AnsweredAt = &s.AnsweredAt.Time
+	}
+	if s.EndedAt.Valid {
+		resp.EndedAt = &s.EndedAt.Time
+	}
+	return resp
+}
+
+func (mc *MonitorController) mapGroupToResponse(g models.MonitorGroup) response.MonitorGroupResponse {
+	return response.MonitorGroupResponse{
+		GroupUUID:   g.GroupUUID,
+		DomainUUID:  g.DomainUUID,
+		Name:        g.Name,
+		Supervisors: g.Supervisors,
+		Agents:      g.Agents,
+		CreatedAt:   g.CreatedAt,
+		UpdatedAt:   g.UpdatedAt,
+	}
+}
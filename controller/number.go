@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/number"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type NumberController struct {
+	numbers *manager.NumberManager
+}
+
+func NewNumberController(numbers *manager.NumberManager) *NumberController {
+	return &NumberController{
+		numbers: numbers,
+	}
+}
+
+// Normalize shows how a number would be classified and dialed, which is
+// handy when writing translation rules.
+func (nc *NumberController) Normalize(c *gin.Context) {
+	raw := c.Query("number")
+	if raw == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "number is required"})
+		return
+	}
+
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's numbering"})
+		return
+	}
+
+	n, err := nc.numbers.Resolve(domainUUID, raw)
+	if err != nil {
+		nc.handleError(c, "normalize", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, n)
+}
+
+func (nc *NumberController) GetSettings(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Param("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's numbering"})
+		return
+	}
+
+	settings, err := nc.numbers.Settings(domainUUID)
+	if err != nil {
+		nc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (nc *NumberController) SaveSettings(c *gin.Context) {
+	var req request.NumberSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	domainUUID, ok := tenantScope(c, c.Param("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's numbering"})
+		return
+	}
+
+	settings, err := nc.numbers.SaveSettings(domainUUID, req)
+	if err != nil {
+		nc.handleError(c, "save", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+func (nc *NumberController) DeleteSettings(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Param("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's numbering"})
+		return
+	}
+
+	if err := nc.numbers.DeleteSettings(domainUUID); err != nil {
+		nc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (nc *NumberController) GetRules(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's numbering"})
+		return
+	}
+
+	rules, err := nc.numbers.ListRules(domainUUID)
+	if err != nil {
+		nc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.TranslationRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		resp = append(resp, nc.mapRuleToResponse(rule))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": resp})
+}
+
+func (nc *NumberController) CreateRule(c *gin.Context) {
+	var req request.TranslationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's numbering"})
+		return
+	}
+
+	rule, err := nc.numbers.CreateRule(req)
+	if err != nil {
+		nc.handleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, nc.mapRuleToResponse(*rule))
+}
+
+func (nc *NumberController) UpdateRule(c *gin.Context) {
+	var req request.TranslationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's numbering"})
+		return
+	}
+
+	rule, err := nc.ownRule(c)
+	if err == nil {
+		rule, err = nc.numbers.UpdateRule(rule.RuleUUID, req)
+	}
+	if err != nil {
+		nc.handleError(c, "update", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, nc.mapRuleToResponse(*rule))
+}
+
+func (nc *NumberController) DeleteRule(c *gin.Context) {
+	rule, err := nc.ownRule(c)
+	if err == nil {
+		err = nc.numbers.DeleteRule(rule.RuleUUID)
+	}
+	if err != nil {
+		nc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ownRule fetches the rule in the path for a change. Keys bound to a tenant
+// can only change that tenant's rules, not global ones.
+func (nc *NumberController) ownRule(c *gin.Context) (*models.TranslationRule, error) {
+	rule, err := nc.numbers.GetRule(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), rule.DomainUUID.String) {
+		return nil, manager.ErrRuleNotFound
+	}
+	return rule, nil
+}
+
+func (nc *NumberController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidRule), errors.Is(err, manager.ErrInvalidDomain),
+		errors.Is(err, number.ErrInvalidNumber):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s numbering data: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " numbering data"})
+	}
+}
+
+func (nc *NumberController) mapRuleToResponse(rule models.TranslationRule) response.TranslationRuleResponse {
+	return response.TranslationRuleResponse{
+		RuleUUID:    rule.RuleUUID,
+		DomainUUID:  rule.DomainUUID.String,
+		AppliesTo:   rule.AppliesTo,
+		Match:       rule.Match,
+		Strip:       rule.Strip,
+		Prepend:     rule.Prepend,
+		Priority:    rule.Priority,
+		Description: rule.Description,
+		CreatedAt:   rule.CreatedAt,
+	}
+}
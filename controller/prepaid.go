@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type PrepaidController struct {
+	prepaid *manager.PrepaidManager
+}
+
+func NewPrepaidController(prepaid *manager.PrepaidManager) *PrepaidController {
+	return &PrepaidController{
+		prepaid: prepaid,
+	}
+}
+
+// GetAccounts lists every prepaid account. Keys bound to a tenant only see
+// their own.
+func (pc *PrepaidController) GetAccounts(c *gin.Context) {
+	accounts, err := pc.prepaid.ListAccounts()
+	if err != nil {
+		pc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.PrepaidAccountResponse, 0, len(accounts))
+	for _, acct := range accounts {
+		if !manager.CanAccessDomain(requestKey(c), acct.DomainUUID) {
+			continue
+		}
+		resp = append(resp, pc.mapAccountToResponse(acct))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accounts": resp})
+}
+
+func (pc *PrepaidController) GetAccount(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Param("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's prepaid account"})
+		return
+	}
+
+	acct, err := pc.prepaid.GetAccount(domainUUID)
+	if err != nil {
+		pc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pc.mapAccountToResponse(*acct))
+}
+
+// SaveAccount creates or updates an account. Balances are what tenants
+// can spend, so keys bound to a tenant cannot change accounts or credit them.
+func (pc *PrepaidController) SaveAccount(c *gin.Context) {
+	if requestKey(c).DomainUUID.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage prepaid accounts"})
+		return
+	}
+
+	var req request.PrepaidAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	acct, err := pc.prepaid.SaveAccount(c.Param("domain_uuid"), req)
+	if err != nil {
+		pc.handleError(c, "save", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pc.mapAccountToResponse(*acct))
+}
+
+func (pc *PrepaidController) CreateTransaction(c *gin.Context) {
+	if requestKey(c).DomainUUID.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage prepaid accounts"})
+		return
+	}
+
+	var req request.PrepaidTransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := pc.prepaid.AddTransaction(c.Param("domain_uuid"), req)
+	if err != nil {
+		pc.handleError(c, "apply", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, pc.mapEntryToResponse(*entry))
+}
+
+func (pc *PrepaidController) GetTransactions(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Param("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's prepaid account"})
+		return
+	}
+	page, limit := paginate(c)
+
+	entries, total, err := pc.prepaid.ListLedger(domainUUID, limit, (page-1)*limit)
+	if err != nil {
+		pc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.LedgerEntryResponse, 0, len(entries))
+	for _, e := range entries {
+		resp = append(resp, pc.mapEntryToResponse(e))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transactions": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (pc *PrepaidController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrPrepaidAccountNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidTransaction), errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s prepaid data: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " prepaid data"})
+	}
+}
+
+func (pc *PrepaidController) mapAccountToResponse(acct models.PrepaidAccount) response.PrepaidAccountResponse {
+	return response.PrepaidAccountResponse{
+		DomainUUID:     acct.DomainUUID,
+		Balance:        acct.Balance,
+		Currency:       acct.Currency,
+		Enabled:        acct.Enabled,
+		MaxCallSeconds: acct.MaxCallSeconds,
+		ActiveCalls:    pc.prepaid.ActiveCalls(acct.DomainUUID),
+		CreatedAt:      acct.CreatedAt,
+		UpdatedAt:      acct.UpdatedAt,
+	}
+}
+
+func (pc *PrepaidController) mapEntryToResponse(e models.LedgerEntry) response.LedgerEntryResponse {
+	return response.LedgerEntryResponse{
+		EntryUUID:    e.EntryUUID,
+		DomainUUID:   e.DomainUUID,
+		Kind:         e.Kind,
+		Amount:       e.Amount,
+		BalanceAfter: e.BalanceAfter,
+		Reference:    e.Reference,
+		Description:  e.Description,
+		CreatedAt:    e.CreatedAt,
+	}
+}
@@ -0,0 +1,420 @@
+package controller
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+var errInvalidPeriod = errors.New("from and to must be YYYY-MM-DD dates or RFC 3339 times, with from before to")
+
+type RatingController struct {
+	rating *manager.RatingManager
+}
+
+func NewRatingController(rating *manager.RatingManager) *RatingController {
+	return &RatingController{
+		rating: rating,
+	}
+}
+
+// CreateDeck adds a rate deck. Decks set what tenants are charged, so keys
+// bound to a tenant can read them but not change them.
+func (rc *RatingController) CreateDeck(c *gin.Context) {
+	if requestKey(c).DomainUUID.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage rate decks"})
+		return
+	}
+
+	var req request.RateDeckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deck, err := rc.rating.CreateDeck(req)
+	if err != nil {
+		rc.handleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rc.mapDeckToResponse(*deck))
+}
+
+func (rc *RatingController) GetDecks(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's rate decks"})
+		return
+	}
+
+	decks, err := rc.rating.ListDecks(domainUUID)
+	if err != nil {
+		rc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.RateDeckResponse, 0, len(decks))
+	for _, deck := range decks {
+		resp = append(resp, rc.mapDeckToResponse(deck))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"decks": resp})
+}
+
+func (rc *RatingController) GetDeck(c *gin.Context) {
+	deck, err := rc.deck(c)
+	if err != nil {
+		rc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rc.mapDeckToResponse(*deck))
+}
+
+func (rc *RatingController) UpdateDeck(c *gin.Context) {
+	if requestKey(c).DomainUUID.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage rate decks"})
+		return
+	}
+
+	var req request.RateDeckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deck, err := rc.rating.UpdateDeck(c.Param("uuid"), req)
+	if err != nil {
+		rc.handleError(c, "update", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rc.mapDeckToResponse(*deck))
+}
+
+func (rc *RatingController) DeleteDeck(c *gin.Context) {
+	if requestKey(c).DomainUUID.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage rate decks"})
+		return
+	}
+
+	if err := rc.rating.DeleteDeck(c.Param("uuid")); err != nil {
+		rc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ImportRates loads a CSV rate deck (multipart "file" field or raw text/csv
+// body). With replace=true the existing rates of the deck are dropped first.
+func (rc *RatingController) ImportRates(c *gin.Context) {
+	if requestKey(c).DomainUUID.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage rate decks"})
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	replace, _ := strconv.ParseBool(c.Query("replace"))
+	result, err := rc.rating.ImportRates(c.Param("uuid"), body, replace)
+	if err != nil {
+		rc.handleError(c, "import", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (rc *RatingController) GetRates(c *gin.Context) {
+	deck, err := rc.deck(c)
+	if err != nil {
+		rc.handleError(c, "list", err)
+		return
+	}
+	page, limit := paginate(c)
+
+	rates, total, err := rc.rating.ListRates(deck.DeckUUID, c.Query("prefix"), limit, (page-1)*limit)
+	if err != nil {
+		rc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.RateResponse, 0, len(rates))
+	for _, r := range rates {
+		resp = append(resp, response.RateResponse{
+			RateUUID:         r.RateUUID,
+			DeckUUID:         r.DeckUUID,
+			Prefix:           r.Prefix,
+			Destination:      r.Destination,
+			ConnectFee:       r.ConnectFee,
+			Rate:             r.Rate,
+			InitialIncrement: r.InitialIncrement,
+			Increment:        r.Increment,
+			BandStart:        r.BandStart.String,
+			BandEnd:          r.BandEnd.String,
+			CreatedAt:        r.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rates": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (rc *RatingController) GetRating(c *gin.Context) {
+	r, err := rc.rating.GetRating(c.Param("uuid"))
+	if err == nil && !manager.CanAccessDomain(requestKey(c), r.DomainUUID.String) {
+		err = manager.ErrRatingNotFound
+	}
+	if err != nil {
+		rc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response.CDRRatingResponse{
+		XMLCDRUUID:    r.XMLCDRUUID,
+		DomainUUID:    r.DomainUUID.String,
+		StartStamp:    r.StartStamp,
+		Number:        r.Number,
+		Destination:   r.Destination,
+		BillSec:       r.BillSec,
+		BilledSeconds: r.BilledSeconds,
+		PriceRateUUID: r.PriceRateUUID.String,
+		PricePrefix:   r.PricePrefix,
+		Price:         r.Price,
+		CostRateUUID:  r.CostRateUUID.String,
+		Cost:          r.Cost,
+		Currency:      r.Currency,
+		Status:        r.Status,
+		RatedAt:       r.RatedAt,
+	})
+}
+
+func (rc *RatingController) Rerate(c *gin.Context) {
+	if requestKey(c).DomainUUID.Valid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot re-rate calls"})
+		return
+	}
+
+	var req request.RerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, to, err := parsePeriod(req.From, req.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cleared, err := rc.rating.Rerate(req.DomainUUID, from, to)
+	if err != nil {
+		rc.handleError(c, "clear", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"cleared": cleared})
+}
+
+// GetInvoice sums a tenant's rated calls over the from/to period (the
+// current month by default) as JSON, or as CSV with format=csv.
+func (rc *RatingController) GetInvoice(c *gin.Context) {
+	from, to, err := parsePeriod(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	domainUUID, ok := tenantScope(c, c.Param("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's invoices"})
+		return
+	}
+	lines, unrated, err := rc.rating.Invoice(domainUUID, from, to)
+	if err != nil {
+		rc.handleError(c, "build", err)
+		return
+	}
+
+	invoice := rc.mapInvoiceToResponse(domainUUID, from, to, lines, unrated)
+	if c.Query("format") == "csv" {
+		rc.writeInvoiceCSV(c, invoice)
+		return
+	}
+
+	c.JSON(http.StatusOK, invoice)
+}
+
+func (rc *RatingController) writeInvoiceCSV(c *gin.Context, invoice response.InvoiceResponse) {
+	filename := fmt.Sprintf("invoice-%s-%s.csv", invoice.DomainUUID, invoice.From.Format("2006-01-02"))
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"prefix", "destination", "currency", "calls", "billsec", "billed_minutes", "price", "cost"})
+	for _, l := range invoice.Lines {
+		w.Write([]string{
+			l.Prefix, l.Destination, l.Currency, strconv.Itoa(l.Calls), strconv.Itoa(l.BillSec),
+			formatAmount(l.BilledMinutes), formatAmount(l.Price), formatAmount(l.Cost),
+		})
+	}
+	w.Write([]string{
+		"", "TOTAL", "", strconv.Itoa(invoice.Calls), "", formatAmount(invoice.BilledMinutes),
+		formatAmount(invoice.Price), formatAmount(invoice.Cost),
+	})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Printf("Failed to write invoice CSV: %v", err)
+	}
+}
+
+// deck fetches the deck in the path. Keys bound to a tenant get not found
+// for decks of other tenants; global decks are visible to everyone.
+func (rc *RatingController) deck(c *gin.Context) (*models.RateDeck, error) {
+	deck, err := rc.rating.GetDeck(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if deck.DomainUUID.Valid && !manager.CanAccessDomain(requestKey(c), deck.DomainUUID.String) {
+		return nil, manager.ErrRateDeckNotFound
+	}
+	return deck, nil
+}
+
+func (rc *RatingController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrRateDeckNotFound), errors.Is(err, manager.ErrRatingNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrRateDeckExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidRateDeck), errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s rating data: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " rating data"})
+	}
+}
+
+func (rc *RatingController) mapDeckToResponse(deck models.RateDeck) response.RateDeckResponse {
+	return response.RateDeckResponse{
+		DeckUUID:    deck.DeckUUID,
+		Name:        deck.Name,
+		Purpose:     deck.Purpose,
+		DomainUUID:  deck.DomainUUID.String,
+		Currency:    deck.Currency,
+		Timezone:    deck.Timezone,
+		Description: deck.Description,
+		CreatedAt:   deck.CreatedAt,
+		UpdatedAt:   deck.UpdatedAt,
+	}
+}
+
+func (rc *RatingController) mapInvoiceToResponse(domainUUID string, from, to time.Time, lines []models.InvoiceLine, unrated int) response.InvoiceResponse {
+	invoice := response.InvoiceResponse{
+		DomainUUID:   domainUUID,
+		From:         from,
+		To:           to,
+		Lines:        make([]response.InvoiceLineResponse, 0, len(lines)),
+		UnratedCalls: unrated,
+	}
+	for _, l := range lines {
+		line := response.InvoiceLineResponse{
+			Prefix:        l.Prefix,
+			Destination:   l.Destination,
+			Currency:      l.Currency,
+			Calls:         l.Calls,
+			BillSec:       l.BillSec,
+			BilledMinutes: roundAmount(float64(l.BilledSeconds) / 60),
+			Price:         roundAmount(l.Price),
+			Cost:          roundAmount(l.Cost),
+		}
+		invoice.Lines = append(invoice.Lines, line)
+		invoice.Calls += line.Calls
+		invoice.BilledMinutes += line.BilledMinutes
+		invoice.Price += line.Price
+		invoice.Cost += line.Cost
+	}
+	invoice.BilledMinutes = roundAmount(invoice.BilledMinutes)
+	invoice.Price = roundAmount(invoice.Price)
+	invoice.Cost = roundAmount(invoice.Cost)
+	return invoice
+}
+
+// parsePeriod reads a from/to period given as dates (YYYY-MM-DD, to being
+// inclusive) or RFC 3339 times. Missing bounds default to the current month
+// in UTC.
+func parsePeriod(fromStr, toStr string) (time.Time, time.Time, error) {
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	if fromStr != "" {
+		t, err := parsePeriodBound(fromStr, false)
+		if err != nil {
+			return from, to, err
+		}
+		from = t
+	}
+	if toStr != "" {
+		t, err := parsePeriodBound(toStr, true)
+		if err != nil {
+			return from, to, err
+		}
+		to = t
+	}
+	if !from.Before(to) {
+		return from, to, errInvalidPeriod
+	}
+	return from, to, nil
+}
+
+func parsePeriodBound(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errInvalidPeriod
+}
+
+func roundAmount(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
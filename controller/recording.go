@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+)
+
+type RecordingController struct {
+	recordings *manager.RecordingManager
+}
+
+func NewRecordingController(recordings *manager.RecordingManager) *RecordingController {
+	return &RecordingController{
+		recordings: recordings,
+	}
+}
+
+// GetRecording streams the recording of a CDR with Range support, so
+// browsers can seek, or redirects to a presigned URL when the recording was
+// moved unencrypted to a backend that supports them. Encrypted recordings
+// are decrypted as they stream. ?format=wav|mp3 converts it when
+// transcoding is enabled. Keys bound to a tenant get 404 for recordings of
+// other tenants.
+func (rc *RecordingController) GetRecording(c *gin.Context) {
+	rec, err := rc.recordings.Find(c.Param("uuid"))
+	if err == nil && !manager.CanAccessDomain(requestKey(c), rec.DomainUUID) {
+		err = manager.ErrRecordingNotFound
+	}
+	if err != nil {
+		rc.handleError(c, err)
+		return
+	}
+
+	// Stored recordings are downloaded straight from the backend when it can
+	// presign URLs and no conversion is asked for.
+	if format := strings.ToLower(c.Query("format")); format == "" || format == rec.Ext() {
+		url, err := rc.recordings.PresignURL(rec)
+		if err != nil {
+			rc.handleError(c, err)
+			return
+		}
+		if url != "" {
+			c.Redirect(http.StatusFound, url)
+			return
+		}
+	}
+
+	f, err := rc.recordings.Open(rec, c.Query("format"))
+	if err != nil {
+		rc.handleError(c, err)
+		return
+	}
+	defer f.Close()
+
+	name := filepath.Base(rec.Path)
+	if rec.Key != "" {
+		name = path.Base(rec.Key)
+	}
+	if format := c.Query("format"); format != "" {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + "." + strings.ToLower(format)
+	}
+	c.Header("Content-Type", f.ContentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, name))
+	c.Header("Cache-Control", "private, no-store")
+	http.ServeContent(c.Writer, c.Request, name, f.ModTime, f)
+}
+
+func (rc *RecordingController) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, manager.ErrRecordingNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrUnsupportedFormat):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrTranscodingDisabled):
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to serve recording: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to serve recording"})
+	}
+}
@@ -0,0 +1,335 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+// RetentionController manages retention policies and legal holds. Keys
+// bound to a tenant only see and change their tenant's; global policies are
+// visible to them but read-only.
+type RetentionController struct {
+	retention *manager.RetentionManager
+}
+
+func NewRetentionController(retention *manager.RetentionManager) *RetentionController {
+	return &RetentionController{
+		retention: retention,
+	}
+}
+
+func (rc *RetentionController) CreatePolicy(c *gin.Context) {
+	var req request.RetentionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's policies"})
+		return
+	}
+
+	policy, err := rc.retention.CreatePolicy(req)
+	if err != nil {
+		rc.handleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rc.mapPolicyToResponse(*policy))
+}
+
+func (rc *RetentionController) GetPolicies(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's policies"})
+		return
+	}
+
+	policies, err := rc.retention.ListPolicies(domainUUID)
+	if err != nil {
+		rc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.RetentionPolicyResponse, 0, len(policies))
+	for _, policy := range policies {
+		resp = append(resp, rc.mapPolicyToResponse(policy))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policies": resp})
+}
+
+func (rc *RetentionController) GetPolicy(c *gin.Context) {
+	policy, err := rc.retention.GetPolicy(c.Param("uuid"))
+	if err == nil && policy.DomainUUID.Valid && !manager.CanAccessDomain(requestKey(c), policy.DomainUUID.String) {
+		err = manager.ErrRetentionPolicyNotFound
+	}
+	if err != nil {
+		rc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rc.mapPolicyToResponse(*policy))
+}
+
+func (rc *RetentionController) UpdatePolicy(c *gin.Context) {
+	var req request.RetentionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's policies"})
+		return
+	}
+
+	policy, err := rc.ownPolicy(c)
+	if err == nil {
+		policy, err = rc.retention.UpdatePolicy(policy.PolicyUUID, req)
+	}
+	if err != nil {
+		rc.handleError(c, "update", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rc.mapPolicyToResponse(*policy))
+}
+
+func (rc *RetentionController) DeletePolicy(c *gin.Context) {
+	policy, err := rc.ownPolicy(c)
+	if err == nil {
+		err = rc.retention.DeletePolicy(policy.PolicyUUID)
+	}
+	if err != nil {
+		rc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ownPolicy fetches the policy of the request for a change. Keys bound to a
+// tenant can only change that tenant's policies.
+func (rc *RetentionController) ownPolicy(c *gin.Context) (*models.RetentionPolicy, error) {
+	policy, err := rc.retention.GetPolicy(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), policy.DomainUUID.String) {
+		return nil, manager.ErrRetentionPolicyNotFound
+	}
+	return policy, nil
+}
+
+func (rc *RetentionController) CreateHold(c *gin.Context) {
+	var req request.LegalHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot place holds for another tenant"})
+		return
+	}
+
+	hold, err := rc.retention.CreateHold(req)
+	if err != nil {
+		rc.handleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rc.mapHoldToResponse(*hold))
+}
+
+// GetHolds lists legal holds. active=true leaves out released and expired
+// ones.
+func (rc *RetentionController) GetHolds(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's holds"})
+		return
+	}
+	page, limit := paginate(c)
+
+	holds, total, err := rc.retention.ListHolds(domainUUID, c.Query("active") == "true", limit, (page-1)*limit)
+	if err != nil {
+		rc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.LegalHoldResponse, 0, len(holds))
+	for _, hold := range holds {
+		resp = append(resp, rc.mapHoldToResponse(hold))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"holds": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// ReleaseHold ends a legal hold; its recordings become subject to the
+// retention policies again.
+func (rc *RetentionController) ReleaseHold(c *gin.Context) {
+	hold, err := rc.retention.GetHold(c.Param("uuid"))
+	if err == nil && !manager.CanAccessDomain(requestKey(c), hold.DomainUUID.String) {
+		err = manager.ErrLegalHoldNotFound
+	}
+	if err == nil {
+		hold, err = rc.retention.ReleaseHold(hold.HoldUUID)
+	}
+	if err != nil {
+		rc.handleError(c, "release", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rc.mapHoldToResponse(*hold))
+}
+
+// GetReport is a dry run: the recordings the next retention run would
+// purge or archive, and why.
+func (rc *RetentionController) GetReport(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's recordings"})
+		return
+	}
+	page, limit := paginate(c)
+
+	candidates, total, held, err := rc.retention.Report(domainUUID, limit, (page-1)*limit)
+	if err != nil {
+		rc.handleError(c, "report", err)
+		return
+	}
+
+	resp := make([]response.RetentionCandidateResponse, 0, len(candidates))
+	for _, r := range candidates {
+		resp = append(resp, response.RetentionCandidateResponse{
+			XMLCDRUUID: r.XMLCDRUUID,
+			DomainUUID: r.DomainUUID.String,
+			Direction:  r.Direction,
+			StartStamp: r.StartStamp,
+			RecordPath: r.RecordPath,
+			RecordName: r.RecordName,
+			PolicyUUID: r.PolicyUUID,
+			RetainDays: r.RetainDays,
+			Action:     r.Action,
+			Reason:     r.Reason,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recordings": resp,
+		"held":       held,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// GetPurges returns the purge log, filtered by domain_uuid and
+// xml_cdr_uuid.
+func (rc *RetentionController) GetPurges(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's purges"})
+		return
+	}
+	page, limit := paginate(c)
+
+	purges, total, err := rc.retention.ListPurges(domainUUID, c.Query("xml_cdr_uuid"), limit, (page-1)*limit)
+	if err != nil {
+		rc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.RetentionPurgeResponse, 0, len(purges))
+	for _, p := range purges {
+		resp = append(resp, response.RetentionPurgeResponse{
+			PurgeUUID:   p.PurgeUUID,
+			XMLCDRUUID:  p.XMLCDRUUID,
+			DomainUUID:  p.DomainUUID.String,
+			PolicyUUID:  p.PolicyUUID.String,
+			Action:      p.Action,
+			RecordPath:  p.RecordPath,
+			RecordName:  p.RecordName,
+			Destination: p.Destination,
+			Reason:      p.Reason,
+			PurgedAt:    p.PurgedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"purges": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (rc *RetentionController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrRetentionPolicyNotFound), errors.Is(err, manager.ErrLegalHoldNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrRetentionPolicyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidRetentionPolicy), errors.Is(err, manager.ErrInvalidLegalHold),
+		errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s retention data: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " retention data"})
+	}
+}
+
+func (rc *RetentionController) mapPolicyToResponse(p models.RetentionPolicy) response.RetentionPolicyResponse {
+	return response.RetentionPolicyResponse{
+		PolicyUUID:  p.PolicyUUID,
+		DomainUUID:  p.DomainUUID.String,
+		Direction:   p.Direction,
+		RetainDays:  p.RetainDays,
+		Action:      p.Action,
+		Description: p.Description,
+		Enabled:     p.Enabled,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+	}
+}
+
+func (rc *RetentionController) mapHoldToResponse(h models.LegalHold) response.LegalHoldResponse {
+	resp := response.LegalHoldResponse{
+		HoldUUID:   h.HoldUUID,
+		DomainUUID: h.DomainUUID.String,
+		XMLCDRUUID: h.XMLCDRUUID.String,
+		Number:     h.Number,
+		Reason:     h.Reason,
+		Active:     !h.ReleasedAt.Valid && (!h.ExpiresAt.Valid || h.ExpiresAt.Time.After(time.Now())),
+		CreatedAt:  h.CreatedAt,
+	}
+	if h.ExpiresAt.Valid {
+		resp.ExpiresAt = &h.ExpiresAt.Time
+	}
+	if h.ReleasedAt.Valid {
+		resp.ReleasedAt = &h.ReleasedAt.Time
+	}
+	return resp
+}
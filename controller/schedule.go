@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type ScheduleController struct {
+	scheduler *manager.SchedulerManager
+}
+
+func NewScheduleController(scheduler *manager.SchedulerManager) *ScheduleController {
+	return &ScheduleController{
+		scheduler: scheduler,
+	}
+}
+
+func (sc *ScheduleController) CreateSchedule(c *gin.Context) {
+	var req request.ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.Call.DomainUUID, ok = tenantScope(c, req.Call.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot schedule calls for another tenant"})
+		return
+	}
+
+	sched, err := sc.scheduler.Create(req)
+	if err != nil {
+		sc.handleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, sc.mapScheduleToResponse(*sched))
+}
+
+func (sc *ScheduleController) GetSchedules(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's schedules"})
+		return
+	}
+	page, limit := paginate(c)
+
+	schedules, total, err := sc.scheduler.List(domainUUID, limit, (page-1)*limit)
+	if err != nil {
+		sc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.ScheduleResponse, 0, len(schedules))
+	for _, sched := range schedules {
+		resp = append(resp, sc.mapScheduleToResponse(sched))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedules": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (sc *ScheduleController) GetSchedule(c *gin.Context) {
+	sched, err := sc.ownSchedule(c)
+	if err != nil {
+		sc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sc.mapScheduleToResponse(*sched))
+}
+
+func (sc *ScheduleController) UpdateSchedule(c *gin.Context) {
+	var req request.ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.Call.DomainUUID, ok = tenantScope(c, req.Call.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot schedule calls for another tenant"})
+		return
+	}
+
+	sched, err := sc.ownSchedule(c)
+	if err == nil {
+		sched, err = sc.scheduler.Update(sched.ScheduleUUID, req)
+	}
+	if err != nil {
+		sc.handleError(c, "update", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sc.mapScheduleToResponse(*sched))
+}
+
+func (sc *ScheduleController) DeleteSchedule(c *gin.Context) {
+	sched, err := sc.ownSchedule(c)
+	if err == nil {
+		err = sc.scheduler.Delete(sched.ScheduleUUID)
+	}
+	if err != nil {
+		sc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ownSchedule fetches the schedule in the path. Keys bound to a tenant get
+// not found for schedules of other tenants.
+func (sc *ScheduleController) ownSchedule(c *gin.Context) (*models.CallSchedule, error) {
+	sched, err := sc.scheduler.Get(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), sched.DomainUUID.String) {
+		return nil, manager.ErrScheduleNotFound
+	}
+	return sched, nil
+}
+
+func (sc *ScheduleController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidSchedule), errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s schedule: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " schedule"})
+	}
+}
+
+func (sc *ScheduleController) mapScheduleToResponse(sched models.CallSchedule) response.ScheduleResponse {
+	resp := response.ScheduleResponse{
+		ScheduleUUID: sched.ScheduleUUID,
+		DomainUUID:   sched.DomainUUID.String,
+		Name:         sched.Name,
+		Cron:         sched.CronExpression,
+		Timezone:     sched.Timezone,
+		Enabled:      sched.Enabled,
+		LastCallID:   sched.LastCallID,
+		LastError:    sched.LastError,
+		RunCount:     sched.RunCount,
+		CreatedAt:    sched.CreatedAt,
+		UpdatedAt:    sched.UpdatedAt,
+	}
+
+	if err := json.Unmarshal(sched.CallRequest, &resp.Call); err != nil {
+		log.Printf("Failed to decode call request of schedule %s: %v", sched.ScheduleUUID, err)
+	}
+
+	if sched.RunAt.Valid {
+		resp.RunAt = &sched.RunAt.Time
+	}
+
+	if sched.NextRunAt.Valid {
+		resp.NextRunAt = &sched.NextRunAt.Time
+	}
+
+	if sched.LastRunAt.Valid {
+		resp.LastRunAt = &sched.LastRunAt.Time
+	}
+
+	return resp
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+// TranscriptionController serves call transcriptions and searches them.
+// Keys bound to a tenant only see their tenant's calls.
+type TranscriptionController struct {
+	transcriptions *manager.TranscriptionManager
+	recordings     *manager.RecordingManager
+}
+
+func NewTranscriptionController(transcriptions *manager.TranscriptionManager, recordings *manager.RecordingManager) *TranscriptionController {
+	return &TranscriptionController{
+		transcriptions: transcriptions,
+		recordings:     recordings,
+	}
+}
+
+// SearchCDRs runs a full-text search over call transcriptions. q takes web
+// search syntax; each hit carries a snippet with the matches in <mark>
+// tags.
+func (tc *TranscriptionController) SearchCDRs(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot search another tenant's calls"})
+		return
+	}
+	page, limit := paginate(c)
+
+	hits, total, err := tc.transcriptions.Search(domainUUID, c.Query("q"), limit, (page-1)*limit)
+	if err != nil {
+		tc.handleError(c, "search", err)
+		return
+	}
+
+	resp := make([]response.TranscriptHitResponse, 0, len(hits))
+	for _, h := range hits {
+		hit := response.TranscriptHitResponse{
+			XMLCDRUUID:        h.XMLCDRUUID,
+			DomainUUID:        h.DomainUUID.String,
+			Direction:         h.Direction,
+			CallerIDNumber:    h.CallerIDNumber,
+			DestinationNumber: h.DestinationNumber,
+			Rank:              h.Rank,
+			Snippet:           h.Snippet,
+		}
+		if h.StartStamp.Valid {
+			hit.StartStamp = &h.StartStamp.Time
+		}
+		resp = append(resp, hit)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// GetTranscription returns the transcription of a CDR and the state of its
+// transcription job.
+func (tc *TranscriptionController) GetTranscription(c *gin.Context) {
+	rec, err := tc.recording(c)
+	if err != nil {
+		tc.handleError(c, "fetch", err)
+		return
+	}
+
+	text, err := tc.transcriptions.Transcription(rec.CDRUUID)
+	if err != nil {
+		tc.handleError(c, "fetch", err)
+		return
+	}
+	job, err := tc.transcriptions.GetJob(rec.CDRUUID)
+	if err != nil {
+		tc.handleError(c, "fetch", err)
+		return
+	}
+
+	resp := response.TranscriptionResponse{
+		XMLCDRUUID:    rec.CDRUUID,
+		Transcription: text,
+	}
+	if job != nil {
+		jobResp := tc.mapJobToResponse(*job)
+		resp.Job = &jobResp
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Transcribe queues the recording of a CDR for transcription, replacing the
+// stored text once the job is done.
+func (tc *TranscriptionController) Transcribe(c *gin.Context) {
+	rec, err := tc.recording(c)
+	if err != nil {
+		tc.handleError(c, "queue", err)
+		return
+	}
+
+	job, err := tc.transcriptions.Enqueue(rec)
+	if err != nil {
+		tc.handleError(c, "queue", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, tc.mapJobToResponse(*job))
+}
+
+// GetJobs lists transcription jobs, filtered by domain_uuid and status.
+func (tc *TranscriptionController) GetJobs(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's transcription jobs"})
+		return
+	}
+	page, limit := paginate(c)
+
+	jobs, total, err := tc.transcriptions.ListJobs(domainUUID, c.Query("status"), limit, (page-1)*limit)
+	if err != nil {
+		tc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.TranscriptionJobResponse, 0, len(jobs))
+	for _, job := range jobs {
+		resp = append(resp, tc.mapJobToResponse(job))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// recording finds the recording of the CDR in the path, as 404 for keys of
+// other tenants.
+func (tc *TranscriptionController) recording(c *gin.Context) (*manager.Recording, error) {
+	rec, err := tc.recordings.Find(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), rec.DomainUUID) {
+		return nil, manager.ErrRecordingNotFound
+	}
+	return rec, nil
+}
+
+func (tc *TranscriptionController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrRecordingNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidSearch), errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrTranscriptionDisabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s transcription: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " transcription"})
+	}
+}
+
+func (tc *TranscriptionController) mapJobToResponse(j models.TranscriptionJob) response.TranscriptionJobResponse {
+	resp := response.TranscriptionJobResponse{
+		JobUUID:       j.JobUUID,
+		XMLCDRUUID:    j.XMLCDRUUID,
+		DomainUUID:    j.DomainUUID.String,
+		Status:        j.Status,
+		Attempts:      j.Attempts,
+		Engine:        j.Engine,
+		Language:      j.Language,
+		Error:         j.Error,
+		NextAttemptAt: j.NextAttemptAt,
+		CreatedAt:     j.CreatedAt,
+	}
+	if j.StartedAt.Valid {
+		resp.StartedAt = &j.StartedAt.Time
+	}
+	if j.FinishedAt.Valid {
+		resp.FinishedAt = &j.FinishedAt.Time
+	}
+	return resp
+}
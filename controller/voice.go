@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type VoiceController struct {
+	voice *manager.VoiceManager
+}
+
+func NewVoiceController(voice *manager.VoiceManager) *VoiceController {
+	return &VoiceController{
+		voice: voice,
+	}
+}
+
+// GetApps lists the apps of ?domain_uuid= and the global ones. Keys bound
+// to a tenant only see their own and the global apps.
+func (vc *VoiceController) GetApps(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's voice apps"})
+		return
+	}
+
+	apps, err := vc.voice.ListApps(domainUUID)
+	if err != nil {
+		vc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.VoiceAppResponse, 0, len(apps))
+	for _, app := range apps {
+		resp = append(resp, vc.mapAppToResponse(app))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"apps": resp})
+}
+
+func (vc *VoiceController) GetApp(c *gin.Context) {
+	app, err := vc.voice.GetApp(c.Param("uuid"))
+	if err == nil && app.DomainUUID.Valid && !manager.CanAccessDomain(requestKey(c), app.DomainUUID.String) {
+		err = manager.ErrVoiceAppNotFound
+	}
+	if err != nil {
+		vc.handleError(c, "fetch", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, vc.mapAppToResponse(*app))
+}
+
+func (vc *VoiceController) CreateApp(c *gin.Context) {
+	var req request.VoiceAppRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's voice apps"})
+		return
+	}
+
+	app, err := vc.voice.CreateApp(req)
+	if err != nil {
+		vc.handleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, vc.mapAppToResponse(*app))
+}
+
+func (vc *VoiceController) UpdateApp(c *gin.Context) {
+	var req request.VoiceAppRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ok bool
+	if req.DomainUUID, ok = tenantScope(c, req.DomainUUID); !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot manage another tenant's voice apps"})
+		return
+	}
+
+	app, err := vc.ownApp(c)
+	if err == nil {
+		app, err = vc.voice.UpdateApp(app.AppUUID, req)
+	}
+	if err != nil {
+		vc.handleError(c, "update", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, vc.mapAppToResponse(*app))
+}
+
+func (vc *VoiceController) DeleteApp(c *gin.Context) {
+	app, err := vc.ownApp(c)
+	if err == nil {
+		err = vc.voice.DeleteApp(app.AppUUID)
+	}
+	if err != nil {
+		vc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ownApp fetches the app in the path for a change. Keys bound to a tenant
+// get not found for global apps and those of other tenants.
+func (vc *VoiceController) ownApp(c *gin.Context) (*models.VoiceApp, error) {
+	app, err := vc.voice.GetApp(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), app.DomainUUID.String) {
+		return nil, manager.ErrVoiceAppNotFound
+	}
+	return app, nil
+}
+
+func (vc *VoiceController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrVoiceAppNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrVoiceAppExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s voice app: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " voice app"})
+	}
+}
+
+func (vc *VoiceController) mapAppToResponse(app models.VoiceApp) response.VoiceAppResponse {
+	return response.VoiceAppResponse{
+		AppUUID:     app.AppUUID,
+		DomainUUID:  app.DomainUUID.String,
+		Number:      app.Number,
+		URL:         app.URL,
+		FallbackURL: app.FallbackURL,
+		Description: app.Description,
+		Enabled:     app.Enabled,
+		CreatedAt:   app.CreatedAt,
+		UpdatedAt:   app.UpdatedAt,
+	}
+}
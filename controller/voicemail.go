@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type VoicemailController struct {
+	voicemail *manager.VoicemailManager
+}
+
+func NewVoicemailController(voicemail *manager.VoicemailManager) *VoicemailController {
+	return &VoicemailController{
+		voicemail: voicemail,
+	}
+}
+
+// GetBoxes returns voicemail boxes, filtered by domain_uuid and extension.
+// Keys bound to a tenant only see their own.
+func (vc *VoicemailController) GetBoxes(c *gin.Context) {
+	domainUUID, ok := tenantScope(c, c.Query("domain_uuid"))
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's voicemail"})
+		return
+	}
+	page, limit := paginate(c)
+
+	boxes, total, err := vc.voicemail.ListBoxes(domainUUID, c.Query("extension"), limit, (page-1)*limit)
+	if err != nil {
+		vc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.VoicemailBoxResponse, 0, len(boxes))
+	for _, b := range boxes {
+		resp = append(resp, vc.mapBoxToResponse(b))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"boxes": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (vc *VoicemailController) GetBox(c *gin.Context) {
+	box, err := vc.box(c)
+	if err != nil {
+		vc.handleError(c, "fetch", err)
+		return
+	}
+	c.JSON(http.StatusOK, vc.mapBoxToResponse(*box))
+}
+
+// GetMessages returns the messages of a box, newest first. ?status=new|saved
+// filters them.
+func (vc *VoicemailController) GetMessages(c *gin.Context) {
+	box, err := vc.box(c)
+	if err != nil {
+		vc.handleError(c, "list", err)
+		return
+	}
+	page, limit := paginate(c)
+
+	messages, total, err := vc.voicemail.ListMessages(box.VoicemailUUID, c.Query("status"), limit, (page-1)*limit)
+	if err != nil {
+		vc.handleError(c, "list", err)
+		return
+	}
+
+	resp := make([]response.VoicemailMessageResponse, 0, len(messages))
+	for _, m := range messages {
+		resp = append(resp, vc.mapMessageToResponse(m))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"messages": resp,
+		"meta": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+func (vc *VoicemailController) GetMessage(c *gin.Context) {
+	msg, err := vc.message(c)
+	if err != nil {
+		vc.handleError(c, "fetch", err)
+		return
+	}
+	c.JSON(http.StatusOK, vc.mapMessageToResponse(*msg))
+}
+
+// GetAudio streams the recording of a message with Range support.
+func (vc *VoicemailController) GetAudio(c *gin.Context) {
+	msg, err := vc.message(c)
+	if err != nil {
+		vc.handleError(c, "fetch", err)
+		return
+	}
+
+	audio, err := vc.voicemail.Audio(msg)
+	if err != nil {
+		vc.handleError(c, "fetch", err)
+		return
+	}
+	defer audio.Close()
+
+	c.Header("Content-Type", audio.ContentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, audio.Name))
+	c.Header("Cache-Control", "private, no-store")
+	http.ServeContent(c.Writer, c.Request, audio.Name, audio.ModTime, audio)
+}
+
+func (vc *VoicemailController) MarkRead(c *gin.Context) {
+	vc.setRead(c, true)
+}
+
+func (vc *VoicemailController) MarkUnread(c *gin.Context) {
+	vc.setRead(c, false)
+}
+
+func (vc *VoicemailController) setRead(c *gin.Context, read bool) {
+	msg, err := vc.message(c)
+	if err == nil {
+		msg, err = vc.voicemail.SetRead(msg.MessageUUID, read)
+	}
+	if err != nil {
+		vc.handleError(c, "update", err)
+		return
+	}
+	c.JSON(http.StatusOK, vc.mapMessageToResponse(*msg))
+}
+
+func (vc *VoicemailController) DeleteMessage(c *gin.Context) {
+	msg, err := vc.message(c)
+	if err == nil {
+		err = vc.voicemail.DeleteMessage(msg.MessageUUID)
+	}
+	if err != nil {
+		vc.handleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// NotifyMWI resends the message waiting state of a box to its phones.
+func (vc *VoicemailController) NotifyMWI(c *gin.Context) {
+	box, err := vc.box(c)
+	if err == nil {
+		box, err = vc.voicemail.NotifyMWI(box.VoicemailUUID)
+	}
+	if err != nil {
+		vc.handleError(c, "notify", err)
+		return
+	}
+	c.JSON(http.StatusOK, vc.mapBoxToResponse(*box))
+}
+
+// box returns the box in the path. Keys bound to a tenant get not found
+// for boxes of other tenants.
+func (vc *VoicemailController) box(c *gin.Context) (*models.VoicemailBox, error) {
+	box, err := vc.voicemail.GetBox(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), box.DomainUUID) {
+		return nil, manager.ErrVoicemailBoxNotFound
+	}
+	return box, nil
+}
+
+func (vc *VoicemailController) message(c *gin.Context) (*models.VoicemailMessage, error) {
+	msg, err := vc.voicemail.GetMessage(c.Param("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if !manager.CanAccessDomain(requestKey(c), msg.DomainUUID) {
+		return nil, manager.ErrVoicemailMessageNotFound
+	}
+	return msg, nil
+}
+
+func (vc *VoicemailController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrVoicemailBoxNotFound), errors.Is(err, manager.ErrVoicemailMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidVoicemail), errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s voicemail: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " voicemail"})
+	}
+}
+
+func (vc *VoicemailController) mapBoxToResponse(b models.VoicemailBox) response.VoicemailBoxResponse {
+	return response.VoicemailBoxResponse{
+		VoicemailUUID: b.VoicemailUUID,
+		DomainUUID:    b.DomainUUID,
+		DomainName:    b.DomainName,
+		VoicemailID:   b.VoicemailID,
+		MailTo:        b.MailTo,
+		Description:   b.Description,
+		Enabled:       b.Enabled,
+		NewMessages:   b.NewMessages,
+		SavedMessages: b.SavedMessages,
+	}
+}
+
+func (vc *VoicemailController) mapMessageToResponse(m models.VoicemailMessage) response.VoicemailMessageResponse {
+	resp := response.VoicemailMessageResponse{
+		MessageUUID:    m.MessageUUID,
+		VoicemailUUID:  m.VoicemailUUID,
+		DomainUUID:     m.DomainUUID,
+		VoicemailID:    m.VoicemailID,
+		CallerIDName:   m.CallerIDName,
+		CallerIDNumber: m.CallerIDNumber,
+		Length:         m.Length,
+		Read:           m.Status == manager.VoicemailSaved,
+		Priority:       m.Priority,
+		Transcription:  m.Transcription,
+		CreatedAt:      m.CreatedAt,
+	}
+	if m.ReadAt.Valid {
+		resp.ReadAt = &m.ReadAt.Time
+	}
+	return resp
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/manager"
+	"github.com/vishaltalsaniya-7/voip-api/response"
+)
+
+type WallboardController struct {
+	wallboard *manager.WallboardManager
+	upgrader  websocket.Upgrader
+}
+
+func NewWallboardController(wallboard *manager.WallboardManager, cfg config.WallboardConfig) *WallboardController {
+	return &WallboardController{
+		wallboard: wallboard,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: websocketOrigin(cfg.AllowedOrigins),
+		},
+	}
+}
+
+// GetWallboard returns the current wallboard of ?queue= or ?domain_uuid=.
+// Keys bound to a tenant only see their own queues.
+func (wc *WallboardController) GetWallboard(c *gin.Context) {
+	snapshot, ok := wc.snapshot(c, "fetch")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, wc.mapSnapshotToResponse(*snapshot))
+}
+
+// Stream upgrades to a WebSocket and pushes the wallboard of ?queue= or
+// ?domain_uuid= every interval until the client goes away.
+func (wc *WallboardController) Stream(c *gin.Context) {
+	snapshot, ok := wc.snapshot(c, "stream")
+	if !ok {
+		return
+	}
+
+	queue, domainUUID := snapshot.Queue, snapshot.DomainUUID
+	if queue != "" {
+		domainUUID = ""
+	}
+	updates, cancel, err := wc.wallboard.Subscribe(queue, domainUUID)
+	if err != nil {
+		wc.handleError(c, "stream", err)
+		return
+	}
+	defer cancel()
+
+	conn, err := wc.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written the error response.
+		log.Printf("Failed to open wallboard stream: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	streamJSON(conn, updates, func(s manager.WallboardSnapshot) any {
+		return wc.mapSnapshotToResponse(s)
+	})
+}
+
+// snapshot returns the wallboard the request asks for, or writes the error
+// response. Keys bound to a tenant default to their own domain and get not
+// found for queues of other tenants.
+func (wc *WallboardController) snapshot(c *gin.Context, action string) (*manager.WallboardSnapshot, bool) {
+	queue, domainUUID := c.Query("queue"), c.Query("domain_uuid")
+	if queue == "" {
+		var ok bool
+		if domainUUID, ok = tenantScope(c, domainUUID); !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key cannot read another tenant's wallboard"})
+			return nil, false
+		}
+	}
+
+	snapshot, err := wc.wallboard.Snapshot(queue, domainUUID)
+	if err == nil && !manager.CanAccessDomain(requestKey(c), snapshot.DomainUUID) {
+		err = manager.ErrCallCenterQueueNotFound
+	}
+	if err != nil {
+		wc.handleError(c, action, err)
+		return nil, false
+	}
+	return snapshot, true
+}
+
+func (wc *WallboardController) handleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, manager.ErrCallCenterQueueNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrInvalidWallboard), errors.Is(err, manager.ErrInvalidDomain):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Failed to %s wallboard: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " wallboard"})
+	}
+}
+
+func (wc *WallboardController) mapSnapshotToResponse(s manager.WallboardSnapshot) response.WallboardResponse {
+	return response.WallboardResponse{
+		Queue:        s.Queue,
+		DomainUUID:   s.DomainUUID,
+		Time:         s.Time,
+		Waiting:      s.Waiting,
+		LongestWait:  s.LongestWait,
+		Agents:       s.Agents,
+		Answered:     s.Answered,
+		Abandoned:    s.Abandoned,
+		AnsweredInSL: s.AnsweredInSL,
+		ServiceLevel: s.ServiceLevel,
+		AverageWait:  s.AverageWait,
+		Since:        s.Since,
+	}
+}
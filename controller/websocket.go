@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	websocketWriteTimeout = 10 * time.Second
+	websocketPongTimeout  = 60 * time.Second
+	websocketPingInterval = 30 * time.Second
+)
+
+// websocketOrigin accepts the listed browser origins, or the API's own
+// origin when none are listed. Clients that send no Origin are not
+// browsers and are let through.
+func websocketOrigin(allowed []string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if len(allowed) > 0 {
+			return slices.Contains(allowed, "*") || slices.Contains(allowed, strings.TrimSuffix(origin, "/"))
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
+
+// streamJSON writes every update to conn as JSON until updates is closed or
+// the client goes away, pinging it to notice dead connections.
+func streamJSON[T any](conn *websocket.Conn, updates <-chan T, mapFn func(T) any) {
+	// The client sends nothing; reading only notices when it goes away.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(websocketPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(websocketPongTimeout))
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(websocketPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+					time.Now().Add(websocketWriteTimeout))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(websocketWriteTimeout))
+			if err := conn.WriteJSON(mapFn(update)); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(websocketWriteTimeout)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations creates the tables owned by this service. FusionPBX owns the
+// rest of the schema (v_xml_cdr, v_extensions, ...), so every statement must
+// be idempotent and must never alter FusionPBX tables.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS call_schedules (
+		schedule_uuid   uuid PRIMARY KEY,
+		name            text NOT NULL DEFAULT '',
+		call_request    jsonb NOT NULL,
+		run_at          timestamptz,
+		cron_expression text NOT NULL DEFAULT '',
+		timezone        text NOT NULL DEFAULT 'UTC',
+		enabled         boolean NOT NULL DEFAULT true,
+		next_run_at     timestamptz,
+		last_run_at     timestamptz,
+		last_call_id    text NOT NULL DEFAULT '',
+		last_error      text NOT NULL DEFAULT '',
+		run_count       integer NOT NULL DEFAULT 0,
+		created_at      timestamptz NOT NULL DEFAULT now(),
+		updated_at      timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS call_schedules_due_idx ON call_schedules (next_run_at) WHERE enabled`,
+	`CREATE TABLE IF NOT EXISTS campaigns (
+		campaign_uuid       uuid PRIMARY KEY,
+		name                text NOT NULL,
+		mode                text NOT NULL DEFAULT 'progressive',
+		status              text NOT NULL DEFAULT 'draft',
+		agents              text[] NOT NULL DEFAULT '{}',
+		destination         jsonb,
+		caller_id_name      text NOT NULL DEFAULT '',
+		caller_id_number    text NOT NULL DEFAULT '',
+		dial_timeout        integer NOT NULL DEFAULT 30,
+		max_dial_ratio      double precision NOT NULL DEFAULT 2,
+		max_attempts        integer NOT NULL DEFAULT 3,
+		retry_delay_seconds integer NOT NULL DEFAULT 1800,
+		retry_causes        text[] NOT NULL DEFAULT '{}',
+		timezone            text NOT NULL DEFAULT 'UTC',
+		window_start        text NOT NULL DEFAULT '09:00',
+		window_end          text NOT NULL DEFAULT '20:00',
+		created_at          timestamptz NOT NULL DEFAULT now(),
+		updated_at          timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS campaign_contacts (
+		contact_uuid      uuid PRIMARY KEY,
+		campaign_uuid     uuid NOT NULL REFERENCES campaigns (campaign_uuid) ON DELETE CASCADE,
+		phone_number      text NOT NULL,
+		name              text NOT NULL DEFAULT '',
+		timezone          text NOT NULL DEFAULT '',
+		variables         jsonb NOT NULL DEFAULT '{}',
+		status            text NOT NULL DEFAULT 'pending',
+		attempts          integer NOT NULL DEFAULT 0,
+		next_attempt_at   timestamptz,
+		last_attempt_at   timestamptz,
+		last_call_uuid    text NOT NULL DEFAULT '',
+		last_hangup_cause text NOT NULL DEFAULT '',
+		last_agent        text NOT NULL DEFAULT '',
+		billsec           integer NOT NULL DEFAULT 0,
+		created_at        timestamptz NOT NULL DEFAULT now(),
+		updated_at        timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS campaign_contacts_dial_idx ON campaign_contacts (campaign_uuid, status, next_attempt_at)`,
+	`CREATE INDEX IF NOT EXISTS campaign_contacts_call_idx ON campaign_contacts (last_call_uuid)`,
+	`CREATE TABLE IF NOT EXISTS dnc_entries (
+		dnc_entry_uuid uuid PRIMARY KEY,
+		domain_uuid    uuid,
+		match_type     text NOT NULL DEFAULT 'exact',
+		pattern        text NOT NULL,
+		reason         text NOT NULL DEFAULT '',
+		created_at     timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS dnc_entries_pattern_idx
+		ON dnc_entries ((COALESCE(domain_uuid, '00000000-0000-0000-0000-000000000000')), match_type, pattern)`,
+	`CREATE TABLE IF NOT EXISTS dnc_audit (
+		dnc_audit_uuid uuid PRIMARY KEY,
+		domain_uuid    uuid,
+		caller         text NOT NULL DEFAULT '',
+		callee         text NOT NULL DEFAULT '',
+		number         text NOT NULL,
+		dnc_entry_uuid uuid,
+		reason         text NOT NULL DEFAULT '',
+		created_at     timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS dnc_audit_created_idx ON dnc_audit (created_at DESC)`,
+	`CREATE TABLE IF NOT EXISTS number_settings (
+		domain_uuid          uuid PRIMARY KEY,
+		country_code         text NOT NULL,
+		national_prefix      text NOT NULL DEFAULT '',
+		international_prefix text NOT NULL DEFAULT '',
+		extension_max_length integer NOT NULL DEFAULT 5,
+		updated_at           timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS number_translation_rules (
+		rule_uuid    uuid PRIMARY KEY,
+		domain_uuid  uuid,
+		applies_to   text NOT NULL DEFAULT 'pstn',
+		match_prefix text NOT NULL DEFAULT '',
+		strip_digits integer NOT NULL DEFAULT 0,
+		prepend      text NOT NULL DEFAULT '',
+		priority     integer NOT NULL DEFAULT 100,
+		description  text NOT NULL DEFAULT '',
+		created_at   timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS gateways (
+		gateway_uuid uuid PRIMARY KEY,
+		name         text NOT NULL UNIQUE,
+		enabled      boolean NOT NULL DEFAULT true,
+		description  text NOT NULL DEFAULT '',
+		created_at   timestamptz NOT NULL DEFAULT now(),
+		updated_at   timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS lcr_routes (
+		route_uuid   uuid PRIMARY KEY,
+		gateway_uuid uuid NOT NULL REFERENCES gateways (gateway_uuid) ON DELETE CASCADE,
+		domain_uuid  uuid,
+		prefix       text NOT NULL DEFAULT '',
+		rate         numeric(12,6) NOT NULL DEFAULT 0,
+		priority     integer NOT NULL DEFAULT 100,
+		enabled      boolean NOT NULL DEFAULT true,
+		description  text NOT NULL DEFAULT '',
+		created_at   timestamptz NOT NULL DEFAULT now(),
+		updated_at   timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS lcr_routes_prefix_idx ON lcr_routes (prefix text_pattern_ops)`,
+	`CREATE TABLE IF NOT EXISTS call_routes (
+		leg_uuid          uuid PRIMARY KEY,
+		call_uuid         uuid NOT NULL,
+		domain_uuid       uuid,
+		number            text NOT NULL,
+		route_uuid        uuid,
+		gateway           text NOT NULL,
+		prefix            text NOT NULL DEFAULT '',
+		rate              numeric(12,6) NOT NULL DEFAULT 0,
+		attempt           integer NOT NULL DEFAULT 1,
+		hangup_cause      text NOT NULL DEFAULT '',
+		hangup_cause_q850 integer,
+		billsec           integer NOT NULL DEFAULT 0,
+		created_at        timestamptz NOT NULL DEFAULT now(),
+		updated_at        timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS call_routes_call_idx ON call_routes (call_uuid)`,
+	`CREATE TABLE IF NOT EXISTS rate_decks (
+		deck_uuid   uuid PRIMARY KEY,
+		name        text NOT NULL,
+		purpose     text NOT NULL DEFAULT 'price',
+		domain_uuid uuid,
+		currency    text NOT NULL DEFAULT 'USD',
+		timezone    text NOT NULL DEFAULT 'UTC',
+		description text NOT NULL DEFAULT '',
+		created_at  timestamptz NOT NULL DEFAULT now(),
+		updated_at  timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS rate_decks_domain_idx
+		ON rate_decks (purpose, (COALESCE(domain_uuid, '00000000-0000-0000-0000-000000000000')))`,
+	`CREATE TABLE IF NOT EXISTS rates (
+		rate_uuid         uuid PRIMARY KEY,
+		deck_uuid         uuid NOT NULL REFERENCES rate_decks (deck_uuid) ON DELETE CASCADE,
+		prefix            text NOT NULL DEFAULT '',
+		destination       text NOT NULL DEFAULT '',
+		connect_fee       numeric(12,6) NOT NULL DEFAULT 0,
+		rate              numeric(12,6) NOT NULL,
+		initial_increment integer NOT NULL DEFAULT 60,
+		increment         integer NOT NULL DEFAULT 60,
+		band_start        time,
+		band_end          time,
+		created_at        timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS rates_prefix_band_idx
+		ON rates (deck_uuid, prefix, (COALESCE(band_start, '00:00')), (COALESCE(band_end, '00:00')))`,
+	`CREATE TABLE IF NOT EXISTS cdr_ratings (
+		xml_cdr_uuid    uuid PRIMARY KEY,
+		domain_uuid     uuid,
+		start_stamp     timestamptz NOT NULL,
+		number          text NOT NULL DEFAULT '',
+		destination     text NOT NULL DEFAULT '',
+		billsec         integer NOT NULL DEFAULT 0,
+		billed_seconds  integer NOT NULL DEFAULT 0,
+		price_rate_uuid uuid,
+		price_prefix    text NOT NULL DEFAULT '',
+		price           numeric(14,6) NOT NULL DEFAULT 0,
+		cost_rate_uuid  uuid,
+		cost            numeric(14,6) NOT NULL DEFAULT 0,
+		currency        text NOT NULL DEFAULT '',
+		status          text NOT NULL,
+		rated_at        timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS cdr_ratings_domain_idx ON cdr_ratings (domain_uuid, start_stamp)`,
+	`CREATE TABLE IF NOT EXISTS prepaid_accounts (
+		domain_uuid      uuid PRIMARY KEY,
+		balance          numeric(14,6) NOT NULL DEFAULT 0,
+		currency         text NOT NULL DEFAULT 'USD',
+		enabled          boolean NOT NULL DEFAULT true,
+		max_call_seconds integer NOT NULL DEFAULT 0,
+		created_at       timestamptz NOT NULL DEFAULT now(),
+		updated_at       timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS prepaid_ledger (
+		entry_uuid    uuid PRIMARY KEY,
+		domain_uuid   uuid NOT NULL REFERENCES prepaid_accounts (domain_uuid),
+		kind          text NOT NULL,
+		amount        numeric(14,6) NOT NULL,
+		balance_after numeric(14,6) NOT NULL,
+		reference     text NOT NULL DEFAULT '',
+		description   text NOT NULL DEFAULT '',
+		created_at    timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS prepaid_ledger_domain_idx ON prepaid_ledger (domain_uuid, created_at DESC)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS prepaid_ledger_call_idx ON prepaid_ledger (reference) WHERE kind = 'call'`,
+	`CREATE TABLE IF NOT EXISTS fraud_rules (
+		rule_uuid      uuid PRIMARY KEY,
+		domain_uuid    uuid,
+		name           text NOT NULL,
+		kind           text NOT NULL,
+		prefixes       text[] NOT NULL DEFAULT '{}',
+		threshold      integer NOT NULL DEFAULT 0,
+		window_seconds integer NOT NULL DEFAULT 0,
+		max_duration   integer NOT NULL DEFAULT 0,
+		business_start time,
+		business_end   time,
+		business_days  integer[] NOT NULL DEFAULT '{1,2,3,4,5}',
+		timezone       text NOT NULL DEFAULT 'UTC',
+		actions        text[] NOT NULL DEFAULT '{alert}',
+		webhook_url    text NOT NULL DEFAULT '',
+		enabled        boolean NOT NULL DEFAULT true,
+		created_at     timestamptz NOT NULL DEFAULT now(),
+		updated_at     timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS fraud_blocks (
+		block_uuid  uuid PRIMARY KEY,
+		domain_uuid uuid,
+		extension   text NOT NULL,
+		rule_uuid   uuid REFERENCES fraud_rules (rule_uuid) ON DELETE SET NULL,
+		reason      text NOT NULL DEFAULT '',
+		created_at  timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS fraud_blocks_extension_idx
+		ON fraud_blocks ((COALESCE(domain_uuid, '00000000-0000-0000-0000-000000000000')), extension)`,
+	`CREATE TABLE IF NOT EXISTS fraud_events (
+		event_uuid  uuid PRIMARY KEY,
+		rule_uuid   uuid REFERENCES fraud_rules (rule_uuid) ON DELETE SET NULL,
+		domain_uuid uuid,
+		kind        text NOT NULL,
+		extension   text NOT NULL DEFAULT '',
+		call_uuid   text NOT NULL DEFAULT '',
+		number      text NOT NULL DEFAULT '',
+		detail      text NOT NULL DEFAULT '',
+		actions     text[] NOT NULL DEFAULT '{}',
+		created_at  timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS fraud_events_domain_idx ON fraud_events (domain_uuid, created_at DESC)`,
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		key_uuid     uuid PRIMARY KEY,
+		name         text NOT NULL,
+		key_hash     text NOT NULL UNIQUE,
+		key_prefix   text NOT NULL,
+		role         text NOT NULL,
+		domain_uuid  uuid,
+		enabled      boolean NOT NULL DEFAULT true,
+		last_used_at timestamptz,
+		created_at   timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS recording_objects (
+		object_key  text PRIMARY KEY,
+		backend     text NOT NULL,
+		source_path text NOT NULL,
+		size        bigint NOT NULL,
+		sha256      text NOT NULL,
+		stored_at   timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS retention_policies (
+		policy_uuid uuid PRIMARY KEY,
+		domain_uuid uuid,
+		direction   text NOT NULL DEFAULT '',
+		retain_days integer NOT NULL,
+		action      text NOT NULL DEFAULT 'delete',
+		description text NOT NULL DEFAULT '',
+		enabled     boolean NOT NULL DEFAULT true,
+		created_at  timestamptz NOT NULL DEFAULT now(),
+		updated_at  timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS retention_policies_scope_idx
+		ON retention_policies ((COALESCE(domain_uuid, '00000000-0000-0000-0000-000000000000')), direction)`,
+	`CREATE TABLE IF NOT EXISTS legal_holds (
+		hold_uuid    uuid PRIMARY KEY,
+		domain_uuid  uuid,
+		xml_cdr_uuid uuid,
+		number       text NOT NULL DEFAULT '',
+		numbers      text[] NOT NULL DEFAULT '{}',
+		reason       text NOT NULL,
+		expires_at   timestamptz,
+		released_at  timestamptz,
+		created_at   timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS retention_purges (
+		purge_uuid   uuid PRIMARY KEY,
+		xml_cdr_uuid uuid NOT NULL,
+		domain_uuid  uuid,
+		policy_uuid  uuid REFERENCES retention_policies (policy_uuid) ON DELETE SET NULL,
+		action       text NOT NULL,
+		record_path  text NOT NULL,
+		record_name  text NOT NULL,
+		destination  text NOT NULL DEFAULT '',
+		reason       text NOT NULL,
+		purged_at    timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS retention_purges_domain_idx ON retention_purges (domain_uuid, purged_at DESC)`,
+	`CREATE TABLE IF NOT EXISTS recording_keys (
+		key_uuid      uuid PRIMARY KEY,
+		domain_uuid   uuid,
+		master_key_id text NOT NULL,
+		wrapped_key   bytea NOT NULL,
+		active        boolean NOT NULL DEFAULT true,
+		created_at    timestamptz NOT NULL DEFAULT now(),
+		rewrapped_at  timestamptz,
+		retired_at    timestamptz
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS recording_keys_active_idx
+		ON recording_keys ((COALESCE(domain_uuid, '00000000-0000-0000-0000-000000000000'))) WHERE active`,
+	`ALTER TABLE recording_objects ADD COLUMN IF NOT EXISTS encryption_key_uuid uuid REFERENCES recording_keys (key_uuid)`,
+	`CREATE TABLE IF NOT EXISTS transcription_jobs (
+		job_uuid        uuid PRIMARY KEY,
+		xml_cdr_uuid    uuid NOT NULL UNIQUE,
+		domain_uuid     uuid,
+		status          text NOT NULL DEFAULT 'queued',
+		attempts        integer NOT NULL DEFAULT 0,
+		engine          text NOT NULL DEFAULT '',
+		language        text NOT NULL DEFAULT '',
+		error           text NOT NULL DEFAULT '',
+		next_attempt_at timestamptz NOT NULL DEFAULT now(),
+		created_at      timestamptz NOT NULL DEFAULT now(),
+		started_at      timestamptz,
+		finished_at     timestamptz
+	)`,
+	`CREATE INDEX IF NOT EXISTS transcription_jobs_due_idx ON transcription_jobs (status, next_attempt_at)`,
+	`CREATE TABLE IF NOT EXISTS monitor_sessions (
+		session_uuid  uuid PRIMARY KEY,
+		call_uuid     uuid NOT NULL,
+		domain_uuid   uuid,
+		mode          text NOT NULL,
+		supervisor    text NOT NULL,
+		key_uuid      uuid,
+		key_name      text NOT NULL DEFAULT '',
+		status        text NOT NULL DEFAULT 'dialing',
+		hangup_cause  text NOT NULL DEFAULT '',
+		created_at    timestamptz NOT NULL DEFAULT now(),
+		answered_at   timestamptz,
+		ended_at      timestamptz
+	)`,
+	`CREATE INDEX IF NOT EXISTS monitor_sessions_domain_idx ON monitor_sessions (domain_uuid, created_at DESC)`,
+	`CREATE INDEX IF NOT EXISTS monitor_sessions_call_idx ON monitor_sessions (call_uuid)`,
+	`CREATE TABLE IF NOT EXISTS voice_apps (
+		app_uuid     uuid PRIMARY KEY,
+		domain_uuid  uuid,
+		number       text NOT NULL,
+		url          text NOT NULL,
+		fallback_url text NOT NULL DEFAULT '',
+		description  text NOT NULL DEFAULT '',
+		enabled      boolean NOT NULL DEFAULT true,
+		created_at   timestamptz NOT NULL DEFAULT now(),
+		updated_at   timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS voice_apps_number_idx
+		ON voice_apps ((COALESCE(domain_uuid, '00000000-0000-0000-0000-000000000000')), number)`,
+	`ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS amd jsonb`,
+	`ALTER TABLE campaign_contacts ADD COLUMN IF NOT EXISTS amd_result text NOT NULL DEFAULT ''`,
+	// Schedules are scoped to the tenant of their call. Rows created before
+	// the column existed take it from the stored call request.
+	`ALTER TABLE call_schedules ADD COLUMN IF NOT EXISTS domain_uuid uuid`,
+	`UPDATE call_schedules SET domain_uuid = (call_request->>'domain_uuid')::uuid
+		WHERE domain_uuid IS NULL
+			AND call_request->>'domain_uuid' ~* '^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$'`,
+	`CREATE INDEX IF NOT EXISTS call_schedules_domain_idx ON call_schedules (domain_uuid, created_at DESC)`,
+	// Campaign calls are checked and billed against the campaign's tenant.
+	`ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS domain_uuid uuid`,
+	`CREATE INDEX IF NOT EXISTS campaigns_domain_idx ON campaigns (domain_uuid, created_at DESC)`,
+	// Transcripts are searched in their own table instead of through an index
+	// on v_xml_cdr. The transcripts of finished jobs are copied over and that
+	// index is dropped again.
+	`CREATE TABLE IF NOT EXISTS cdr_transcripts (
+		xml_cdr_uuid uuid PRIMARY KEY,
+		domain_uuid  uuid,
+		language     text NOT NULL DEFAULT '',
+		text         text NOT NULL,
+		search       tsvector NOT NULL,
+		created_at   timestamptz NOT NULL DEFAULT now(),
+		updated_at   timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS cdr_transcripts_search_idx ON cdr_transcripts USING gin (search)`,
+	`CREATE INDEX IF NOT EXISTS cdr_transcripts_domain_idx ON cdr_transcripts (domain_uuid)`,
+	// A transcript is indexed with the text search configuration of its
+	// language: an ISO 639-1 code such as en-US or a name such as english.
+	// Languages Postgres has no configuration for fall back to simple.
+	`CREATE OR REPLACE FUNCTION transcript_search_config(lang text) RETURNS regconfig
+		LANGUAGE sql STABLE AS $$
+		SELECT COALESCE((
+			SELECT c.oid::regconfig FROM pg_ts_config c
+			WHERE c.cfgnamespace = 'pg_catalog'::regnamespace
+				AND c.cfgname = CASE lower(split_part(replace(lang, '_', '-'), '-', 1))
+					WHEN 'ar' THEN 'arabic' WHEN 'da' THEN 'danish' WHEN 'de' THEN 'german' WHEN 'el' THEN 'greek'
+					WHEN 'en' THEN 'english' WHEN 'es' THEN 'spanish' WHEN 'fi' THEN 'finnish' WHEN 'fr' THEN 'french'
+					WHEN 'ga' THEN 'irish' WHEN 'hu' THEN 'hungarian' WHEN 'id' THEN 'indonesian' WHEN 'it' THEN 'italian'
+					WHEN 'lt' THEN 'lithuanian' WHEN 'nb' THEN 'norwegian' WHEN 'ne' THEN 'nepali' WHEN 'nl' THEN 'dutch'
+					WHEN 'no' THEN 'norwegian' WHEN 'pt' THEN 'portuguese' WHEN 'ro' THEN 'romanian' WHEN 'ru' THEN 'russian'
+					WHEN 'sv' THEN 'swedish' WHEN 'ta' THEN 'tamil' WHEN 'tr' THEN 'turkish'
+					ELSE lower(lang) END
+		), 'simple'::regconfig)
+	$$`,
+	`ALTER TABLE cdr_transcripts ADD COLUMN IF NOT EXISTS search_config regconfig`,
+	`INSERT INTO cdr_transcripts (xml_cdr_uuid, domain_uuid, language, text, search_config, search)
+		SELECT c.xml_cdr_uuid, c.domain_uuid, j.language, c.record_transcription,
+			transcript_search_config(j.language), to_tsvector(transcript_search_config(j.language), c.record_transcription)
+		FROM transcription_jobs j JOIN v_xml_cdr c ON c.xml_cdr_uuid = j.xml_cdr_uuid
+		WHERE j.status = 'done' AND COALESCE(c.record_transcription, '') <> ''
+			AND NOT EXISTS (SELECT 1 FROM cdr_transcripts t WHERE t.xml_cdr_uuid = j.xml_cdr_uuid)`,
+	`UPDATE cdr_transcripts
+		SET search_config = transcript_search_config(language), search = to_tsvector(transcript_search_config(language), text)
+		WHERE search_config IS NULL`,
+	`DROP INDEX IF EXISTS v_xml_cdr_transcription_fts_idx`,
+	// Supervisors can only monitor calls of agents they share a group with.
+	`CREATE TABLE IF NOT EXISTS monitor_groups (
+		group_uuid  uuid PRIMARY KEY,
+		domain_uuid uuid NOT NULL,
+		name        text NOT NULL,
+		supervisors text[] NOT NULL DEFAULT '{}',
+		agents      text[] NOT NULL DEFAULT '{}',
+		created_at  timestamptz NOT NULL DEFAULT now(),
+		updated_at  timestamptz NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS monitor_groups_domain_idx ON monitor_groups (domain_uuid, name)`,
+}
+
+func Migrate(db *sql.DB) error {
+	for i, stmt := range migrations {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to run migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Package envelope implements envelope encryption for recordings: files are
+// encrypted with data keys, and data keys are wrapped by master keys.
+// Rotating a master key only re-wraps the data keys.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vishaltalsaniya-7/voip-api/config"
+)
+
+var (
+	ErrUnknownMasterKey = errors.New("unknown master key")
+	ErrNotEncrypted     = errors.New("file is not encrypted")
+	ErrCorrupt          = errors.New("encrypted data is corrupt or the key is wrong")
+)
+
+// KeySize is the size of master and data keys, for AES-256.
+const KeySize = 32
+
+// Keyring holds the master keys by id. The current key wraps data keys; the
+// others only unwrap data keys that have not been re-wrapped yet.
+type Keyring struct {
+	keys    map[string][]byte
+	current string
+}
+
+// Load reads the master keys from cfg.KeyFile, or from cfg.MasterKeys when
+// no file is set. Both list "id:base64key" entries, one per line in the
+// file, and the last entry is the current key. It returns nil when no keys
+// are configured.
+func Load(cfg config.EncryptionConfig) (*Keyring, error) {
+	entries := cfg.MasterKeys
+	if cfg.KeyFile != "" {
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		entries = nil
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				entries = append(entries, line)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return Parse(entries)
+}
+
+// Parse builds a keyring from "id:base64key" entries. The last entry is the
+// current key.
+func Parse(entries []string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+	for _, entry := range entries {
+		id, encoded, ok := strings.Cut(entry, ":")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return nil, fmt.Errorf("master key %q must be written as id:base64key", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("master key %q must be %d base64-encoded bytes", id, KeySize)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("master key %q is listed twice", id)
+		}
+		k.keys[id] = key
+		k.current = id
+	}
+	return k, nil
+}
+
+// Current returns the id of the master key that wraps new data keys.
+func (k *Keyring) Current() string {
+	return k.current
+}
+
+// NewDataKey returns a random data key.
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return key, nil
+}
+
+// Wrap encrypts a data key with the current master key. aad binds the
+// wrapped key to its owner, and must be passed again to Unwrap.
+func (k *Keyring) Wrap(dataKey, aad []byte) (string, []byte, error) {
+	aead, err := newGCM(k.keys[k.current])
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return k.current, aead.Seal(nonce, nonce, dataKey, aad), nil
+}
+
+// Unwrap decrypts a data key wrapped by the master key masterID.
+func (k *Keyring) Unwrap(masterID string, wrapped, aad []byte) ([]byte, error) {
+	master, ok := k.keys[masterID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownMasterKey, masterID)
+	}
+	aead, err := newGCM(master)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrCorrupt
+	}
+	key, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,243 @@
+package envelope
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted files start with a header of the magic, the id of the data key
+// and a random nonce prefix. The plaintext follows in chunks of ChunkSize,
+// each sealed with AES-256-GCM under a nonce made of the prefix, the chunk
+// number and a flag marking the last chunk, so chunks cannot be reordered
+// and the file cannot be truncated unnoticed. Fixed-size chunks let readers
+// seek without decrypting what comes before.
+const (
+	magic       = "VXE1"
+	keyIDSize   = 36 // a UUID in text form
+	prefixSize  = 7
+	HeaderSize  = len(magic) + keyIDSize + prefixSize
+	ChunkSize   = 64 * 1024
+	tagSize     = 16
+	sealedChunk = ChunkSize + tagSize
+)
+
+// Writer encrypts what is written to it. Close must be called to write the
+// last chunk.
+type Writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte
+	chunk  uint32
+	err    error
+}
+
+// NewWriter writes the header to w and returns a Writer that encrypts with
+// dataKey. keyID names the data key and is stored in the header.
+func NewWriter(w io.Writer, keyID string, dataKey []byte) (*Writer, error) {
+	if len(keyID) != keyIDSize {
+		return nil, fmt.Errorf("data key id %q must be a UUID", keyID)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, HeaderSize)
+	header = append(header, magic...)
+	header = append(header, keyID...)
+	prefix := make([]byte, prefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce prefix: %w", err)
+	}
+	header = append(header, prefix...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &Writer{w: w, aead: aead, header: header, buf: make([]byte, 0, ChunkSize+1)}, nil
+}
+
+// Write buffers p and seals every chunk that is known not to be the last.
+func (ew *Writer) Write(p []byte) (int, error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+	n := 0
+	for len(p) > 0 {
+		take := min(len(p), ChunkSize+1-len(ew.buf))
+		ew.buf = append(ew.buf, p[:take]...)
+		p, n = p[take:], n+take
+		if len(ew.buf) > ChunkSize {
+			if ew.err = ew.seal(ew.buf[:ChunkSize], false); ew.err != nil {
+				return n, ew.err
+			}
+			ew.buf = append(ew.buf[:0], ew.buf[ChunkSize])
+		}
+	}
+	return n, nil
+}
+
+// Close seals the last chunk. It does not close the underlying writer.
+func (ew *Writer) Close() error {
+	if ew.err != nil {
+		return ew.err
+	}
+	ew.err = ew.seal(ew.buf, true)
+	if ew.err == nil {
+		ew.err = errors.New("envelope: write after close")
+		return nil
+	}
+	return ew.err
+}
+
+func (ew *Writer) seal(plain []byte, last bool) error {
+	sealed := ew.aead.Seal(nil, chunkNonce(ew.header, ew.chunk, last), plain, ew.header)
+	ew.chunk++
+	_, err := ew.w.Write(sealed)
+	return err
+}
+
+// Reader decrypts an encrypted file and supports seeking, which is what
+// http.ServeContent needs for Range requests.
+type Reader struct {
+	r      io.ReaderAt
+	aead   cipher.AEAD
+	header []byte
+	size   int64 // plaintext size
+	chunks int64
+	pos    int64
+
+	cached int64 // chunk held in plain, -1 for none
+	plain  []byte
+}
+
+// KeyID reads the id of the data key an encrypted file was written with.
+func KeyID(r io.ReaderAt) (string, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return "", ErrNotEncrypted
+		}
+		return "", err
+	}
+	if string(header[:len(magic)]) != magic {
+		return "", ErrNotEncrypted
+	}
+	return string(header[len(magic) : len(magic)+keyIDSize]), nil
+}
+
+// NewReader returns a Reader over the encrypted file r of size bytes.
+func NewReader(r io.ReaderAt, size int64, dataKey []byte) (*Reader, error) {
+	if _, err := KeyID(r); err != nil {
+		return nil, err
+	}
+	header := make([]byte, HeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	body := size - int64(HeaderSize)
+	chunks := (body + sealedChunk - 1) / sealedChunk
+	if chunks == 0 || body-(chunks-1)*sealedChunk < tagSize {
+		return nil, ErrCorrupt
+	}
+
+	return &Reader{
+		r:      r,
+		aead:   aead,
+		header: header,
+		size:   body - chunks*tagSize,
+		chunks: chunks,
+		cached: -1,
+	}, nil
+}
+
+// Size returns the plaintext size.
+func (er *Reader) Size() int64 {
+	return er.size
+}
+
+func (er *Reader) Read(p []byte) (int, error) {
+	if er.pos >= er.size {
+		// An empty file still has its last chunk checked.
+		if er.size == 0 && er.cached < 0 {
+			if err := er.load(0); err != nil {
+				return 0, err
+			}
+		}
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && er.pos < er.size {
+		idx := er.pos / ChunkSize
+		if err := er.load(idx); err != nil {
+			return n, err
+		}
+		c := copy(p[n:], er.plain[er.pos-idx*ChunkSize:])
+		n += c
+		er.pos += int64(c)
+	}
+	return n, nil
+}
+
+func (er *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += er.pos
+	case io.SeekEnd:
+		offset += er.size
+	default:
+		return 0, errors.New("envelope: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("envelope: negative position")
+	}
+	er.pos = offset
+	return offset, nil
+}
+
+// load decrypts chunk idx into er.plain.
+func (er *Reader) load(idx int64) error {
+	if er.cached == idx {
+		return nil
+	}
+	start := int64(HeaderSize) + idx*sealedChunk
+	length := int64(sealedChunk)
+	last := idx == er.chunks-1
+	if last {
+		length = er.size - idx*ChunkSize + tagSize
+	}
+
+	sealed := make([]byte, length)
+	if _, err := er.r.ReadAt(sealed, start); err != nil && !(errors.Is(err, io.EOF) && last) {
+		return fmt.Errorf("failed to read encrypted chunk: %w", err)
+	}
+	plain, err := er.aead.Open(er.plain[:0], chunkNonce(er.header, uint32(idx), last), sealed, er.header)
+	if err != nil {
+		er.cached = -1
+		return ErrCorrupt
+	}
+	er.plain, er.cached = plain, idx
+	return nil
+}
+
+func chunkNonce(header []byte, chunk uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, header[len(magic)+keyIDSize:])
+	binary.BigEndian.PutUint32(nonce[prefixSize:], chunk)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
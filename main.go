@@ -7,6 +7,12 @@ import (
 	"github.com/vishaltalsaniya-7/voip-api/manager"
 	"github.com/vishaltalsaniya-7/voip-api/controller"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/storage"
+	"github.com/vishaltalsaniya-7/voip-api/transcribe"
+	"github.com/vishaltalsaniya-7/voip-api/envelope"
 )
 
 func main() {
@@ -21,18 +27,299 @@ func main() {
 	}
 	log.Println("Successfully connected to PostgreSQL")
 
-	eslMgr := manager.NewESLManager(cfg.FreeSWITCH)
+	if err := database.Migrate(db); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		if err := request.RegisterValidators(v); err != nil {
+			log.Fatal("Failed to register request validators:", err)
+		}
+	}
+
+	numbers := manager.NewNumberManager(db, cfg.Numbering)
+	routes := manager.NewRouteManager(db, cfg.LCR)
+	eslMgr := manager.NewESLManager(cfg.FreeSWITCH, numbers, routes)
+	dnc := manager.NewDNCManager(db, numbers)
+
+	rating := manager.NewRatingManager(db, numbers, cfg.Rating)
+	go rating.Run()
+
+	prepaid := manager.NewPrepaidManager(db, eslMgr, rating, numbers, cfg.Prepaid)
+	go prepaid.Run()
+
+	fraud := manager.NewFraudManager(db, eslMgr, numbers, cfg.Fraud)
+	go fraud.Run()
+
+	// Every path that originates calls runs them through the same checks.
+	checks := manager.NewCallChecker(db, eslMgr, fraud, dnc, prepaid)
+
+	scheduler := manager.NewSchedulerManager(db, eslMgr, checks, cfg.Scheduler)
+	go scheduler.Run()
+
+	campaigns := manager.NewCampaignManager(db, eslMgr, checks, cfg.Campaign)
+	go campaigns.Run()
+
+	auth := manager.NewAuthManager(db, cfg.Auth)
 
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatal("Failed to initialize recording storage:", err)
+	}
+	var archive storage.Store
+	if cfg.Retention.ArchiveRoot != "" {
+		if archive, err = storage.NewLocal(cfg.Retention.ArchiveRoot); err != nil {
+			log.Fatal("Failed to initialize recording archive:", err)
+		}
+	}
+	keyring, err := envelope.Load(cfg.Encryption)
+	if err != nil {
+		log.Fatal("Failed to load recording master keys:", err)
+	}
+	encryption := manager.NewEncryptionManager(db, keyring)
+	if encryption.Enabled() {
+		// Data keys still wrapped by an older master key are moved to the
+		// current one, so older keys can be dropped after a restart.
+		if n, err := encryption.Rewrap(); err != nil {
+			log.Printf("Failed to re-wrap recording data keys: %v", err)
+		} else if n > 0 {
+			log.Printf("Re-wrapped %d recording data keys with master key %s", n, keyring.Current())
+		}
+	}
+	recordings := manager.NewRecordingManager(db, store, archive, encryption, cfg.Recordings, cfg.Storage)
+	go recordings.Run()
+
+	retention := manager.NewRetentionManager(db, recordings, numbers, cfg.Retention)
+	go retention.Run()
+
+	engine, err := transcribe.New(cfg.Transcription)
+	if err != nil {
+		log.Fatal("Failed to initialize transcription engine:", err)
+	}
+	transcriptions := manager.NewTranscriptionManager(db, recordings, engine, cfg.Transcription)
+	go transcriptions.Run()
+
+	conferences := manager.NewConferenceManager(eslMgr, cfg.Conference)
+	go conferences.Run()
+
+	callcenter := manager.NewCallCenterManager(db, eslMgr)
+	wallboard := manager.NewWallboardManager(db, eslMgr, callcenter, cfg.Wallboard)
+	go wallboard.Run()
+	monitor := manager.NewMonitorManager(db, eslMgr)
+	dtmf := manager.NewDTMFManager(eslMgr, cfg.DTMF)
+	go dtmf.Run()
+	ivr := manager.NewIVRManager(eslMgr, cfg.IVR)
+	go ivr.Run()
+	voice := manager.NewVoiceManager(db, eslMgr, numbers, checks, cfg.Voice)
+	go voice.Run()
+	amd := manager.NewAMDManager(eslMgr, cfg.AMD)
+	go amd.Run()
+	voicemail := manager.NewVoicemailManager(db, eslMgr, cfg.Voicemail)
+	extensions := manager.NewExtensionManager(db, eslMgr)
+
+	// Every manager subscribes to events in its constructor, so the listener
+	// starts once they are all created.
 	go eslMgr.ListenEvents()
 
-	callController := controller.NewCallController(eslMgr)
-	cdrController := controller.NewCDRController(db)
+	callController := controller.NewCallController(eslMgr, checks)
+	cdrController := controller.NewCDRController(db, numbers)
+	scheduleController := controller.NewScheduleController(scheduler)
+	campaignController := controller.NewCampaignController(campaigns)
+	dncController := controller.NewDNCController(dnc)
+	numberController := controller.NewNumberController(numbers)
+	routeController := controller.NewRouteController(routes, numbers)
+	ratingController := controller.NewRatingController(rating)
+	prepaidController := controller.NewPrepaidController(prepaid)
+	fraudController := controller.NewFraudController(fraud)
+	authController := controller.NewAuthController(auth)
+	recordingController := controller.NewRecordingController(recordings)
+	retentionController := controller.NewRetentionController(retention)
+	encryptionController := controller.NewEncryptionController(encryption)
+	transcriptionController := controller.NewTranscriptionController(transcriptions, recordings)
+	conferenceController := controller.NewConferenceController(conferences, callController)
+	callCenterController := controller.NewCallCenterController(callcenter)
+	wallboardController := controller.NewWallboardController(wallboard, cfg.Wallboard)
+	monitorController := controller.NewMonitorController(monitor)
+	dtmfController := controller.NewDTMFController(dtmf, eslMgr, cfg.DTMF)
+	ivrController := controller.NewIVRController(ivr, eslMgr)
+	voiceController := controller.NewVoiceController(voice)
+	amdController := controller.NewAMDController(amd)
+	voicemailController := controller.NewVoicemailController(voicemail)
+	extensionController := controller.NewExtensionController(extensions)
 
 	r := gin.Default()
 
-	r.POST("/call", callController.InitiateCall)
-	r.GET("/call/status/:uuid/", callController.GetCallStatus) 
-	r.GET("/cdrs", cdrController.GetCDRs)
+	r.POST("/call", authController.Require(manager.PermCallsControl), callController.InitiateCall)
+	r.GET("/call/status/:uuid/", authController.Require(manager.PermCallsControl), callController.GetCallStatus) 
+	r.GET("/call/routes/:uuid", authController.Require(manager.PermCDRsRead), routeController.GetCallRoutes)
+	r.POST("/call/:uuid/monitor", authController.Require(manager.PermCallsMonitor), monitorController.Monitor)
+	r.GET("/call/monitors", authController.Require(manager.PermCallsMonitor), monitorController.GetSessions)
+	r.POST("/monitor/groups", authController.Require(manager.PermExtensionsManage), monitorController.CreateGroup)
+	r.GET("/monitor/groups", authController.Require(manager.PermCallsMonitor), monitorController.GetGroups)
+	r.GET("/monitor/groups/:uuid", authController.Require(manager.PermCallsMonitor), monitorController.GetGroup)
+	r.PUT("/monitor/groups/:uuid", authController.Require(manager.PermExtensionsManage), monitorController.UpdateGroup)
+	r.DELETE("/monitor/groups/:uuid", authController.Require(manager.PermExtensionsManage), monitorController.DeleteGroup)
+	r.POST("/call/:uuid/dtmf", authController.Require(manager.PermCallsControl), dtmfController.SendDTMF)
+	r.GET("/call/:uuid/dtmf", authController.Require(manager.PermCallsMonitor), dtmfController.GetDigits)
+	r.GET("/call/:uuid/dtmf/ws", authController.Require(manager.PermCallsMonitor), dtmfController.Stream)
+	r.GET("/dtmf/ws", authController.Require(manager.PermCallsMonitor), dtmfController.Stream)
+	r.POST("/call/:uuid/play", authController.Require(manager.PermCallsControl), ivrController.Play)
+	r.POST("/call/:uuid/gather", authController.Require(manager.PermCallsControl), ivrController.Gather)
+	r.GET("/call/:uuid/gather/:id", authController.Require(manager.PermCallsControl), ivrController.GetGather)
+	r.GET("/call/:uuid/amd", authController.Require(manager.PermCallsMonitor), amdController.GetResult)
+	r.GET("/voice/apps", authController.Require(manager.PermRoutingManage), voiceController.GetApps)
+	r.POST("/voice/apps", authController.Require(manager.PermRoutingManage), voiceController.CreateApp)
+	r.GET("/voice/apps/:uuid", authController.Require(manager.PermRoutingManage), voiceController.GetApp)
+	r.PUT("/voice/apps/:uuid", authController.Require(manager.PermRoutingManage), voiceController.UpdateApp)
+	r.DELETE("/voice/apps/:uuid", authController.Require(manager.PermRoutingManage), voiceController.DeleteApp)
+	r.GET("/cdrs", authController.Require(manager.PermCDRsRead), cdrController.GetCDRs)
+
+	r.GET("/conferences", authController.Require(manager.PermCallsMonitor), conferenceController.GetConferences)
+	r.GET("/conferences/:name", authController.Require(manager.PermCallsMonitor), conferenceController.GetConference)
+	r.POST("/conferences/:name/members", authController.Require(manager.PermCallsControl), conferenceController.DialMember)
+	r.POST("/conferences/:name/members/:id/mute", authController.Require(manager.PermCallsControl), conferenceController.ControlMember(manager.ConferenceMute))
+	r.POST("/conferences/:name/members/:id/unmute", authController.Require(manager.PermCallsControl), conferenceController.ControlMember(manager.ConferenceUnmute))
+	r.POST("/conferences/:name/members/:id/deaf", authController.Require(manager.PermCallsControl), conferenceController.ControlMember(manager.ConferenceDeaf))
+	r.POST("/conferences/:name/members/:id/undeaf", authController.Require(manager.PermCallsControl), conferenceController.ControlMember(manager.ConferenceUndeaf))
+	r.POST("/conferences/:name/members/:id/floor", authController.Require(manager.PermCallsControl), conferenceController.ControlMember(manager.ConferenceFloor))
+	r.DELETE("/conferences/:name/members/:id", authController.Require(manager.PermCallsControl), conferenceController.ControlMember(manager.ConferenceKick))
+	r.POST("/conferences/:name/lock", authController.Require(manager.PermCallsControl), conferenceController.Lock(true))
+	r.POST("/conferences/:name/unlock", authController.Require(manager.PermCallsControl), conferenceController.Lock(false))
+	r.POST("/conferences/:name/recording", authController.Require(manager.PermCallsControl), conferenceController.StartRecording)
+	r.DELETE("/conferences/:name/recording", authController.Require(manager.PermCallsControl), conferenceController.StopRecording)
+
+	r.GET("/callcenter/agents", authController.Require(manager.PermCallsMonitor), callCenterController.GetAgents)
+	r.GET("/callcenter/agents/:agent", authController.Require(manager.PermCallsMonitor), callCenterController.GetAgent)
+	r.PUT("/callcenter/agents/:agent/status", authController.Require(manager.PermCallCenterManage), callCenterController.SetAgentStatus)
+	r.GET("/callcenter/queues", authController.Require(manager.PermCallsMonitor), callCenterController.GetQueues)
+	r.GET("/callcenter/queues/:queue/members", authController.Require(manager.PermCallsMonitor), callCenterController.GetMembers)
+	r.GET("/callcenter/queues/:queue/tiers", authController.Require(manager.PermCallsMonitor), callCenterController.GetTiers)
+	r.POST("/callcenter/queues/:queue/tiers", authController.Require(manager.PermCallCenterManage), callCenterController.AddTier)
+	r.DELETE("/callcenter/queues/:queue/tiers/:agent", authController.Require(manager.PermCallCenterManage), callCenterController.RemoveTier)
+	r.GET("/callcenter/queues/:queue/stats", authController.Require(manager.PermCallsMonitor), callCenterController.GetStats)
+	r.GET("/callcenter/stats", authController.Require(manager.PermCallsMonitor), callCenterController.GetAllStats)
+
+	r.GET("/wallboard", authController.Require(manager.PermCallsMonitor), wallboardController.GetWallboard)
+	r.GET("/wallboard/ws", authController.Require(manager.PermCallsMonitor), wallboardController.Stream)
+	r.GET("/cdrs/search", authController.Require(manager.PermRecordingsRead), transcriptionController.SearchCDRs)
+	r.GET("/cdrs/:uuid/recording", authController.Require(manager.PermRecordingsRead), recordingController.GetRecording)
+	r.GET("/cdrs/:uuid/transcription", authController.Require(manager.PermRecordingsRead), transcriptionController.GetTranscription)
+	r.POST("/cdrs/:uuid/transcription", authController.Require(manager.PermTranscriptionsManage), transcriptionController.Transcribe)
+
+	r.POST("/schedules", authController.Require(manager.PermCallsControl), scheduleController.CreateSchedule)
+	r.GET("/schedules", authController.Require(manager.PermCallsControl), scheduleController.GetSchedules)
+	r.GET("/schedules/:uuid", authController.Require(manager.PermCallsControl), scheduleController.GetSchedule)
+	r.PUT("/schedules/:uuid", authController.Require(manager.PermCallsControl), scheduleController.UpdateSchedule)
+	r.DELETE("/schedules/:uuid", authController.Require(manager.PermCallsControl), scheduleController.DeleteSchedule)
+
+	r.POST("/campaigns", authController.Require(manager.PermCallsControl), campaignController.CreateCampaign)
+	r.GET("/campaigns", authController.Require(manager.PermCallsControl), campaignController.GetCampaigns)
+	r.GET("/campaigns/:uuid", authController.Require(manager.PermCallsControl), campaignController.GetCampaign)
+	r.PUT("/campaigns/:uuid", authController.Require(manager.PermCallsControl), campaignController.UpdateCampaign)
+	r.DELETE("/campaigns/:uuid", authController.Require(manager.PermCallsControl), campaignController.DeleteCampaign)
+	r.POST("/campaigns/:uuid/start", authController.Require(manager.PermCallsControl), campaignController.ControlCampaign("start"))
+	r.POST("/campaigns/:uuid/pause", authController.Require(manager.PermCallsControl), campaignController.ControlCampaign("pause"))
+	r.POST("/campaigns/:uuid/resume", authController.Require(manager.PermCallsControl), campaignController.ControlCampaign("resume"))
+	r.POST("/campaigns/:uuid/stop", authController.Require(manager.PermCallsControl), campaignController.ControlCampaign("stop"))
+	r.POST("/campaigns/:uuid/contacts", authController.Require(manager.PermCallsControl), campaignController.UploadContacts)
+	r.GET("/campaigns/:uuid/contacts", authController.Require(manager.PermCallsControl), campaignController.GetContacts)
+
+	r.POST("/dnc", authController.Require(manager.PermDNCManage), dncController.CreateEntry)
+	r.GET("/dnc", authController.Require(manager.PermDNCManage), dncController.GetEntries)
+	r.POST("/dnc/import", authController.Require(manager.PermDNCManage), dncController.ImportEntries)
+	r.GET("/dnc/audit", authController.Require(manager.PermDNCManage), dncController.GetAudit)
+	r.GET("/dnc/:uuid", authController.Require(manager.PermDNCManage), dncController.GetEntry)
+	r.PUT("/dnc/:uuid", authController.Require(manager.PermDNCManage), dncController.UpdateEntry)
+	r.DELETE("/dnc/:uuid", authController.Require(manager.PermDNCManage), dncController.DeleteEntry)
+
+	r.GET("/numbering/normalize", authController.Require(manager.PermRoutingManage), numberController.Normalize)
+	r.GET("/numbering/settings/:domain_uuid", authController.Require(manager.PermRoutingManage), numberController.GetSettings)
+	r.PUT("/numbering/settings/:domain_uuid", authController.Require(manager.PermRoutingManage), numberController.SaveSettings)
+	r.DELETE("/numbering/settings/:domain_uuid", authController.Require(manager.PermRoutingManage), numberController.DeleteSettings)
+	r.GET("/numbering/rules", authController.Require(manager.PermRoutingManage), numberController.GetRules)
+	r.POST("/numbering/rules", authController.Require(manager.PermRoutingManage), numberController.CreateRule)
+	r.PUT("/numbering/rules/:uuid", authController.Require(manager.PermRoutingManage), numberController.UpdateRule)
+	r.DELETE("/numbering/rules/:uuid", authController.Require(manager.PermRoutingManage), numberController.DeleteRule)
+
+	r.POST("/gateways", authController.Require(manager.PermRoutingManage), routeController.CreateGateway)
+	r.GET("/gateways", authController.Require(manager.PermRoutingManage), routeController.GetGateways)
+	r.GET("/gateways/:uuid", authController.Require(manager.PermRoutingManage), routeController.GetGateway)
+	r.PUT("/gateways/:uuid", authController.Require(manager.PermRoutingManage), routeController.UpdateGateway)
+	r.DELETE("/gateways/:uuid", authController.Require(manager.PermRoutingManage), routeController.DeleteGateway)
+	r.POST("/lcr/routes", authController.Require(manager.PermRoutingManage), routeController.CreateRoute)
+	r.GET("/lcr/routes", authController.Require(manager.PermRoutingManage), routeController.GetRoutes)
+	r.GET("/lcr/routes/:uuid", authController.Require(manager.PermRoutingManage), routeController.GetRoute)
+	r.PUT("/lcr/routes/:uuid", authController.Require(manager.PermRoutingManage), routeController.UpdateRoute)
+	r.DELETE("/lcr/routes/:uuid", authController.Require(manager.PermRoutingManage), routeController.DeleteRoute)
+	r.GET("/lcr/lookup", authController.Require(manager.PermRoutingManage), routeController.Lookup)
+
+	r.POST("/rating/decks", authController.Require(manager.PermBillingManage), ratingController.CreateDeck)
+	r.GET("/rating/decks", authController.Require(manager.PermBillingManage), ratingController.GetDecks)
+	r.GET("/rating/decks/:uuid", authController.Require(manager.PermBillingManage), ratingController.GetDeck)
+	r.PUT("/rating/decks/:uuid", authController.Require(manager.PermBillingManage), ratingController.UpdateDeck)
+	r.DELETE("/rating/decks/:uuid", authController.Require(manager.PermBillingManage), ratingController.DeleteDeck)
+	r.POST("/rating/decks/:uuid/rates", authController.Require(manager.PermBillingManage), ratingController.ImportRates)
+	r.GET("/rating/decks/:uuid/rates", authController.Require(manager.PermBillingManage), ratingController.GetRates)
+	r.GET("/rating/cdrs/:uuid", authController.Require(manager.PermBillingManage), ratingController.GetRating)
+	r.POST("/rating/rerate", authController.Require(manager.PermBillingManage), ratingController.Rerate)
+	r.GET("/billing/invoices/:domain_uuid", authController.Require(manager.PermBillingManage), ratingController.GetInvoice)
+
+	r.GET("/prepaid/accounts", authController.Require(manager.PermBillingManage), prepaidController.GetAccounts)
+	r.GET("/prepaid/accounts/:domain_uuid", authController.Require(manager.PermBillingManage), prepaidController.GetAccount)
+	r.PUT("/prepaid/accounts/:domain_uuid", authController.Require(manager.PermBillingManage), prepaidController.SaveAccount)
+	r.POST("/prepaid/accounts/:domain_uuid/transactions", authController.Require(manager.PermBillingManage), prepaidController.CreateTransaction)
+	r.GET("/prepaid/accounts/:domain_uuid/transactions", authController.Require(manager.PermBillingManage), prepaidController.GetTransactions)
+
+	r.POST("/fraud/rules", authController.Require(manager.PermFraudManage), fraudController.CreateRule)
+	r.GET("/fraud/rules", authController.Require(manager.PermFraudManage), fraudController.GetRules)
+	r.GET("/fraud/rules/:uuid", authController.Require(manager.PermFraudManage), fraudController.GetRule)
+	r.PUT("/fraud/rules/:uuid", authController.Require(manager.PermFraudManage), fraudController.UpdateRule)
+	r.DELETE("/fraud/rules/:uuid", authController.Require(manager.PermFraudManage), fraudController.DeleteRule)
+	r.GET("/fraud/events", authController.Require(manager.PermFraudManage), fraudController.GetEvents)
+	r.POST("/fraud/blocks", authController.Require(manager.PermFraudManage), fraudController.CreateBlock)
+	r.GET("/fraud/blocks", authController.Require(manager.PermFraudManage), fraudController.GetBlocks)
+	r.DELETE("/fraud/blocks/:uuid", authController.Require(manager.PermFraudManage), fraudController.DeleteBlock)
+
+	r.POST("/auth/keys", authController.Require(manager.PermKeysManage), authController.CreateKey)
+	r.GET("/auth/keys", authController.Require(manager.PermKeysManage), authController.GetKeys)
+	r.DELETE("/auth/keys/:uuid", authController.Require(manager.PermKeysManage), authController.DeleteKey)
+	r.POST("/auth/signed-urls", authController.Require(""), authController.SignURL)
+
+	r.POST("/retention/policies", authController.Require(manager.PermRetentionManage), retentionController.CreatePolicy)
+	r.GET("/retention/policies", authController.Require(manager.PermRetentionManage), retentionController.GetPolicies)
+	r.GET("/retention/policies/:uuid", authController.Require(manager.PermRetentionManage), retentionController.GetPolicy)
+	r.PUT("/retention/policies/:uuid", authController.Require(manager.PermRetentionManage), retentionController.UpdatePolicy)
+	r.DELETE("/retention/policies/:uuid", authController.Require(manager.PermRetentionManage), retentionController.DeletePolicy)
+	r.POST("/retention/holds", authController.Require(manager.PermRetentionManage), retentionController.CreateHold)
+	r.GET("/retention/holds", authController.Require(manager.PermRetentionManage), retentionController.GetHolds)
+	r.POST("/retention/holds/:uuid/release", authController.Require(manager.PermRetentionManage), retentionController.ReleaseHold)
+	r.GET("/retention/report", authController.Require(manager.PermRetentionManage), retentionController.GetReport)
+	r.GET("/retention/purges", authController.Require(manager.PermRetentionManage), retentionController.GetPurges)
+
+	r.GET("/recordings/keys", authController.Require(manager.PermEncryptionManage), encryptionController.GetKeys)
+	r.POST("/recordings/keys/rotate", authController.Require(manager.PermEncryptionManage), encryptionController.RotateKey)
+	r.POST("/recordings/keys/rewrap", authController.Require(manager.PermEncryptionManage), encryptionController.Rewrap)
+
+	r.GET("/transcriptions/jobs", authController.Require(manager.PermTranscriptionsManage), transcriptionController.GetJobs)
+
+	r.GET("/voicemail/boxes", authController.Require(manager.PermVoicemailRead), voicemailController.GetBoxes)
+	r.GET("/voicemail/boxes/:uuid", authController.Require(manager.PermVoicemailRead), voicemailController.GetBox)
+	r.GET("/voicemail/boxes/:uuid/messages", authController.Require(manager.PermVoicemailRead), voicemailController.GetMessages)
+	r.POST("/voicemail/boxes/:uuid/mwi", authController.Require(manager.PermVoicemailManage), voicemailController.NotifyMWI)
+	r.GET("/voicemail/messages/:uuid", authController.Require(manager.PermVoicemailRead), voicemailController.GetMessage)
+	r.GET("/voicemail/messages/:uuid/audio", authController.Require(manager.PermVoicemailRead), voicemailController.GetAudio)
+	r.POST("/voicemail/messages/:uuid/read", authController.Require(manager.PermVoicemailManage), voicemailController.MarkRead)
+	r.POST("/voicemail/messages/:uuid/unread", authController.Require(manager.PermVoicemailManage), voicemailController.MarkUnread)
+	r.DELETE("/voicemail/messages/:uuid", authController.Require(manager.PermVoicemailManage), voicemailController.DeleteMessage)
+
+	r.POST("/extensions", authController.Require(manager.PermExtensionsManage), extensionController.CreateExtension)
+	r.GET("/extensions", authController.Require(manager.PermExtensionsManage), extensionController.GetExtensions)
+	r.GET("/extensions/:uuid", authController.Require(manager.PermExtensionsManage), extensionController.GetExtension)
+	r.PUT("/extensions/:uuid", authController.Require(manager.PermExtensionsManage), extensionController.UpdateExtension)
+	r.POST("/extensions/:uuid/enable", authController.Require(manager.PermExtensionsManage), extensionController.EnableExtension)
+	r.POST("/extensions/:uuid/disable", authController.Require(manager.PermExtensionsManage), extensionController.DisableExtension)
+	r.POST("/extensions/:uuid/password", authController.Require(manager.PermExtensionsManage), extensionController.ResetPassword)
+	r.DELETE("/extensions/:uuid", authController.Require(manager.PermExtensionsManage), extensionController.DeleteExtension)
 
 	// Start server
 	log.Printf("Starting server on :%s", cfg.Server.Port)
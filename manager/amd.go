@@ -0,0 +1,496 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var ErrAMDNotFound = errors.New("no answering machine detection for call")
+
+// AMD outcomes.
+const (
+	AMDHuman   = "human"
+	AMDMachine = "machine"
+	AMDUnknown = "unknown"
+)
+
+// AMD actions.
+const (
+	AMDBridge = "bridge"
+	AMDPlay   = "play"
+	AMDHangup = "hangup"
+)
+
+// AMD statuses. A call that ends while detection runs is hangup, without
+// a result.
+const (
+	AMDDetecting = "detecting"
+	AMDDetected  = "detected"
+	AMDEnded     = "hangup"
+)
+
+// AMDResult is the detection of one call.
+type AMDResult struct {
+	CallUUID   string
+	DomainUUID string
+	Status     string
+	Result     string
+	Beep       bool
+	Action     string
+	Detail     string
+	StartedAt  time.Time
+	DetectedAt time.Time
+	EndedAt    time.Time
+}
+
+// AMDEvent is the JSON body posted to the AMD webhook once a call is
+// classified.
+type AMDEvent struct {
+	Event      string    `json:"event"`
+	CallUUID   string    `json:"call_uuid"`
+	DomainUUID string    `json:"domain_uuid"`
+	Result     string    `json:"result"`
+	Beep       bool      `json:"beep"`
+	Action     string    `json:"action"`
+	Detail     string    `json:"detail"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+type amdCall struct {
+	result  AMDResult
+	options request.CallAMD
+	target  string
+	done    chan struct{}
+}
+
+// amdWaiter receives the event a detection step waits for. ch is closed
+// when the call hangs up first.
+type amdWaiter struct {
+	callUUID string
+	ch       chan *eventsocket.Event
+}
+
+// AMDManager runs answering machine detection on calls originated with an
+// amd block. OriginateCall parks the answered leg and hands it over; the
+// manager then drives it with sendmsg and follows the results through the
+// shared event listener.
+type AMDManager struct {
+	eslMgr *ESLManager
+	config config.AMDConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	calls   map[string]*amdCall
+	waiters map[string]*amdWaiter
+}
+
+// NewAMDManager also registers the manager with eslMgr, which is what turns
+// on the amd block of call requests.
+func NewAMDManager(eslMgr *ESLManager, cfg config.AMDConfig) *AMDManager {
+	am := &AMDManager{
+		eslMgr:  eslMgr,
+		config:  cfg,
+		client:  &http.Client{Timeout: cfg.WebhookTimeout},
+		calls:   make(map[string]*amdCall),
+		waiters: make(map[string]*amdWaiter),
+	}
+	eslMgr.amd = am
+	eslMgr.Subscribe("CHANNEL_EXECUTE_COMPLETE", am.handleExecuteComplete)
+	eslMgr.Subscribe("CHANNEL_HANGUP_COMPLETE", am.handleHangup)
+	eslMgr.Subscribe("avmd::beep", am.handleBeep)
+	return am
+}
+
+// Run forgets detections of calls that ended more than Retention ago.
+func (am *AMDManager) Run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		am.mu.Lock()
+		for callUUID, call := range am.calls {
+			if !call.result.EndedAt.IsZero() && time.Since(call.result.EndedAt) > am.config.Retention {
+				delete(am.calls, callUUID)
+			}
+		}
+		am.mu.Unlock()
+	}
+}
+
+// Get returns the detection of a call.
+func (am *AMDManager) Get(callUUID string) (*AMDResult, error) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	call := am.calls[callUUID]
+	if call == nil {
+		return nil, ErrAMDNotFound
+	}
+	result := call.result
+	return &result, nil
+}
+
+// wait blocks until the call is classified or has ended.
+func (am *AMDManager) wait(callUUID string) (*AMDResult, error) {
+	am.mu.Lock()
+	call := am.calls[callUUID]
+	am.mu.Unlock()
+	if call == nil {
+		return nil, ErrAMDNotFound
+	}
+
+	<-call.done
+	return am.Get(callUUID)
+}
+
+// normalizeAMD validates the actions of a request and fills in the
+// defaults. Files are checked with soundFile.
+func normalizeAMD(opts *request.CallAMD, soundsDir string) error {
+	if opts.OnHuman == nil {
+		opts.OnHuman = &request.AMDAction{Action: AMDBridge}
+	}
+	if opts.OnMachine == nil {
+		opts.OnMachine = &request.AMDAction{Action: AMDHangup}
+	}
+	if opts.OnUnknown == nil {
+		opts.OnUnknown = &request.AMDAction{Action: AMDBridge}
+	}
+	for _, a := range []*request.AMDAction{opts.OnHuman, opts.OnMachine, opts.OnUnknown} {
+		switch a.Action {
+		case AMDBridge, AMDHangup:
+		case AMDPlay:
+			if !soundFile(soundsDir, a.File) {
+				return fmt.Errorf("invalid amd file %q", a.File)
+			}
+		default:
+			return fmt.Errorf("unknown amd action %q", a.Action)
+		}
+	}
+	return nil
+}
+
+// start begins detection on an answered, parked call. target is the
+// destination the call would have gone to without detection.
+func (am *AMDManager) start(callUUID, domainUUID string, opts request.CallAMD, target string) {
+	call := &amdCall{
+		result: AMDResult{
+			CallUUID:   callUUID,
+			DomainUUID: domainUUID,
+			Status:     AMDDetecting,
+			StartedAt:  time.Now(),
+		},
+		options: opts,
+		target:  target,
+		done:    make(chan struct{}),
+	}
+
+	am.mu.Lock()
+	am.calls[callUUID] = call
+	am.mu.Unlock()
+
+	go am.detect(call)
+}
+
+func (am *AMDManager) detect(call *amdCall) {
+	callUUID := call.result.CallUUID
+
+	result, beep, detail, err := am.classify(callUUID)
+	if errors.Is(err, errCallEnded) {
+		am.ended(callUUID)
+		return
+	}
+	if err != nil {
+		log.Printf("Answering machine detection failed on call %s: %v", callUUID, err)
+		result, detail = AMDUnknown, err.Error()
+	}
+
+	action := call.options.OnUnknown
+	switch result {
+	case AMDHuman:
+		action = call.options.OnHuman
+	case AMDMachine:
+		action = call.options.OnMachine
+	}
+
+	am.mu.Lock()
+	call.result.Status = AMDDetected
+	call.result.Result = result
+	call.result.Beep = beep
+	call.result.Action = action.Action
+	call.result.Detail = detail
+	call.result.DetectedAt = time.Now()
+	detected := call.result
+	close(call.done)
+	am.mu.Unlock()
+
+	log.Printf("Call %s answered by %s, running %s", callUUID, result, action.Action)
+	// The outcome is kept on the channel so it ends up in the CDR.
+	if _, err := am.eslMgr.api(fmt.Sprintf("uuid_setvar %s amd_outcome %s", callUUID, result)); err != nil {
+		log.Printf("Failed to set AMD outcome on call %s: %v", callUUID, err)
+	}
+	go am.sendWebhook(detected)
+
+	if err := am.act(call, *action, beep); err != nil && !errors.Is(err, errCallEnded) {
+		log.Printf("Failed to %s call %s after AMD: %v", action.Action, callUUID, err)
+		am.hangup(callUUID)
+	}
+}
+
+// classify runs the detection application and maps its verdict to an
+// outcome. With mod_avmd alone a beep means a machine, and anything else
+// is unknown.
+func (am *AMDManager) classify(callUUID string) (result string, beep bool, detail string, err error) {
+	if am.config.App == "avmd" {
+		beep, err = am.waitBeep(callUUID, am.config.Timeout)
+		if err != nil {
+			return "", false, "", err
+		}
+		if beep {
+			return AMDMachine, true, "beep", nil
+		}
+		return AMDUnknown, false, "no beep", nil
+	}
+
+	ev, err := am.run(callUUID, am.config.App, am.config.Args, am.config.Timeout)
+	if err != nil {
+		return "", false, "", err
+	}
+	detail = ev.Get("Variable_" + am.config.ResultVar)
+	if detail == "" {
+		if resp, err := am.eslMgr.api(fmt.Sprintf("uuid_getvar %s %s", callUUID, am.config.ResultVar)); err == nil {
+			if v := strings.TrimSpace(resp.Body); v != "_undef_" {
+				detail = v
+			}
+		}
+	}
+
+	switch strings.ToUpper(detail) {
+	case "HUMAN", "PERSON":
+		return AMDHuman, false, detail, nil
+	case "MACHINE":
+		return AMDMachine, false, detail, nil
+	}
+	return AMDUnknown, false, detail, nil
+}
+
+// act carries out the action picked for the outcome.
+func (am *AMDManager) act(call *amdCall, action request.AMDAction, beep bool) error {
+	callUUID := call.result.CallUUID
+
+	switch action.Action {
+	case AMDHangup:
+		am.hangup(callUUID)
+		return nil
+
+	case AMDPlay:
+		if !beep && am.config.BeepTimeout > 0 {
+			if _, err := am.waitBeep(callUUID, am.config.BeepTimeout); err != nil {
+				return err
+			}
+		}
+		if _, err := am.run(callUUID, "playback", action.File, 0); err != nil {
+			return err
+		}
+		am.hangup(callUUID)
+		return nil
+
+	default:
+		app, arg := destinationApp(call.target)
+		if _, err := am.run(callUUID, app, arg, 0); err != nil {
+			return err
+		}
+		// A transfer hands the call to the dialplan. Anything else ran in
+		// place of the originate application, which would have hung up
+		// once it returned.
+		if app != "transfer" {
+			am.hangup(callUUID)
+		}
+		return nil
+	}
+}
+
+// waitBeep runs mod_avmd until it hears a beep or timeout passes, and
+// reports whether it heard one.
+func (am *AMDManager) waitBeep(callUUID string, timeout time.Duration) (bool, error) {
+	beepKey := "beep:" + callUUID
+	ch := am.addWaiter(callUUID, beepKey)
+	defer am.removeWaiter(beepKey)
+
+	if _, err := am.run(callUUID, "avmd_start", "", am.config.Timeout); err != nil {
+		return false, err
+	}
+	defer func() {
+		if _, err := am.run(callUUID, "avmd_stop", "", am.config.Timeout); err != nil && !errors.Is(err, errCallEnded) {
+			log.Printf("Failed to stop avmd on call %s: %v", callUUID, err)
+		}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case _, ok := <-ch:
+		if !ok {
+			return false, errCallEnded
+		}
+		return true, nil
+	case <-timer.C:
+		return false, nil
+	}
+}
+
+// run executes an application on the call and waits for it to complete.
+// A zero timeout waits as long as the call lasts.
+func (am *AMDManager) run(callUUID, app, arg string, timeout time.Duration) (*eventsocket.Event, error) {
+	eventUUID := newUUID()
+	ch := am.addWaiter(callUUID, eventUUID)
+	defer am.removeWaiter(eventUUID)
+
+	if err := am.eslMgr.execute(callUUID, app, arg, eventUUID); err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "invalid session id") {
+			return nil, errCallEnded
+		}
+		return nil, err
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case ev, ok := <-ch:
+		if !ok {
+			return nil, errCallEnded
+		}
+		return ev, nil
+	case <-expired:
+		return nil, fmt.Errorf("%s did not complete within %s", app, timeout)
+	}
+}
+
+func (am *AMDManager) addWaiter(callUUID, key string) <-chan *eventsocket.Event {
+	w := &amdWaiter{callUUID: callUUID, ch: make(chan *eventsocket.Event, 1)}
+	am.mu.Lock()
+	am.waiters[key] = w
+	am.mu.Unlock()
+	return w.ch
+}
+
+func (am *AMDManager) removeWaiter(key string) {
+	am.mu.Lock()
+	delete(am.waiters, key)
+	am.mu.Unlock()
+}
+
+// notify hands ev to the waiter of key, if any.
+func (am *AMDManager) notify(key string, ev *eventsocket.Event) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if w := am.waiters[key]; w != nil {
+		select {
+		case w.ch <- ev:
+		default:
+		}
+	}
+}
+
+func (am *AMDManager) handleExecuteComplete(ev *eventsocket.Event) {
+	am.notify(ev.Get("Application-Uuid"), ev)
+}
+
+func (am *AMDManager) handleBeep(ev *eventsocket.Event) {
+	am.notify("beep:"+ev.Get("Unique-Id"), ev)
+}
+
+func (am *AMDManager) handleHangup(ev *eventsocket.Event) {
+	am.ended(ev.Get("Unique-Id"))
+}
+
+// ended marks a call as over and releases whatever waits on it.
+func (am *AMDManager) ended(callUUID string) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	for key, w := range am.waiters {
+		if w.callUUID == callUUID {
+			delete(am.waiters, key)
+			close(w.ch)
+		}
+	}
+
+	call := am.calls[callUUID]
+	if call == nil || !call.result.EndedAt.IsZero() {
+		return
+	}
+	call.result.EndedAt = time.Now()
+	if call.result.Status == AMDDetecting {
+		call.result.Status = AMDEnded
+		close(call.done)
+	}
+}
+
+func (am *AMDManager) hangup(callUUID string) {
+	if err := am.eslMgr.Kill(callUUID, "NORMAL_CLEARING"); err != nil &&
+		!strings.Contains(strings.ToLower(err.Error()), "no such channel") {
+		log.Printf("Failed to hang up call %s: %v", callUUID, err)
+	}
+}
+
+// sendWebhook posts an outcome to the webhook. Without one it is only kept
+// for the API.
+func (am *AMDManager) sendWebhook(result AMDResult) {
+	if am.config.WebhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(AMDEvent{
+		Event:      "call.amd",
+		CallUUID:   result.CallUUID,
+		DomainUUID: result.DomainUUID,
+		Result:     result.Result,
+		Beep:       result.Beep,
+		Action:     result.Action,
+		Detail:     result.Detail,
+		DetectedAt: result.DetectedAt,
+	})
+	if err != nil {
+		log.Printf("Failed to encode AMD result: %v", err)
+		return
+	}
+
+	resp, err := am.client.Post(am.config.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to send AMD result of call %s: %v", result.CallUUID, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("AMD result of call %s was rejected with status %d", result.CallUUID, resp.StatusCode)
+	}
+}
+
+// destinationApp turns an originate destination built by buildDestination
+// into the application that runs it on a parked call: "&app(arg)" runs app,
+// and "<extension> <dialplan> <context>" is a transfer.
+func destinationApp(target string) (app, arg string) {
+	target = strings.Trim(target, "'")
+	if strings.HasPrefix(target, "&") {
+		if open := strings.Index(target, "("); open > 0 && strings.HasSuffix(target, ")") {
+			return target[1:open], target[open+1 : len(target)-1]
+		}
+	}
+	return "transfer", target
+}
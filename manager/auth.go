@@ -0,0 +1,272 @@
+package manager
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrUnauthorized     = errors.New("missing or invalid API key")
+	ErrAPIKeyNotFound   = errors.New("API key not found")
+	ErrInvalidSignedURL = errors.New("invalid signed URL request")
+)
+
+// Roles an API key can have.
+const (
+	RoleAdmin      = "admin"
+	RoleSupervisor = "supervisor"
+)
+
+// Permissions checked by the API routes.
+const (
+	PermRecordingsRead       = "recordings:read"
+	PermKeysManage           = "keys:manage"
+	PermRetentionManage      = "retention:manage"
+	PermEncryptionManage     = "encryption:manage"
+	PermTranscriptionsManage = "transcriptions:manage"
+	PermCallsMonitor         = "calls:monitor"
+	PermVoicemailRead        = "voicemail:read"
+	PermVoicemailManage      = "voicemail:manage"
+	PermExtensionsManage     = "extensions:manage"
+	PermCallsControl         = "calls:control"
+	PermCDRsRead             = "cdrs:read"
+	PermDNCManage            = "dnc:manage"
+	PermRoutingManage        = "routing:manage"
+	PermBillingManage        = "billing:manage"
+	PermFraudManage          = "fraud:manage"
+	PermCallCenterManage     = "callcenter:manage"
+)
+
+var rolePermissions = map[string][]string{
+	RoleAdmin: {PermRecordingsRead, PermKeysManage, PermRetentionManage, PermEncryptionManage,
+		PermTranscriptionsManage, PermCallsMonitor, PermVoicemailRead, PermVoicemailManage, PermExtensionsManage,
+		PermCallsControl, PermCDRsRead, PermDNCManage, PermRoutingManage, PermBillingManage,
+		PermFraudManage, PermCallCenterManage},
+	RoleSupervisor: {PermRecordingsRead, PermCallsMonitor, PermCDRsRead, PermCallCenterManage},
+}
+
+const apiKeyColumns = `key_uuid, name, key_prefix, role, domain_uuid, enabled, last_used_at, created_at`
+
+// AuthManager issues and checks API keys.
+type AuthManager struct {
+	db     *sql.DB
+	config config.AuthConfig
+	secret []byte
+}
+
+// NewAuthManager signs URLs with URLSigningKey. Without one a random
+// secret is used, and signed URLs stop working when the process restarts.
+func NewAuthManager(db *sql.DB, cfg config.AuthConfig) *AuthManager {
+	secret := []byte(cfg.URLSigningKey)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(fmt.Sprintf("failed to read random bytes: %v", err))
+		}
+		log.Println("API_URL_SIGNING_KEY is not set; signed URLs only work on this instance until it restarts")
+	}
+	return &AuthManager{
+		db:     db,
+		config: cfg,
+		secret: secret,
+	}
+}
+
+// Authenticate returns the enabled key matching token. The bootstrap admin
+// key from the configuration is accepted as an admin of every tenant.
+func (am *AuthManager) Authenticate(token string) (*models.APIKey, error) {
+	if token == "" {
+		return nil, ErrUnauthorized
+	}
+	if am.config.AdminKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(am.config.AdminKey)) == 1 {
+		return &models.APIKey{Name: "bootstrap admin", Role: RoleAdmin, Enabled: true}, nil
+	}
+
+	row := am.db.QueryRow(`
+		UPDATE api_keys SET last_used_at = now()
+		WHERE key_hash = $1 AND enabled
+		RETURNING `+apiKeyColumns, hashAPIKey(token))
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrUnauthorized
+	}
+	return key, err
+}
+
+// SignURL returns path with a signature that lets it be fetched with the
+// permissions of key until the returned time, without the key itself.
+func (am *AuthManager) SignURL(key *models.APIKey, path string) (string, time.Time, error) {
+	if len(path) == 0 || path[0] != '/' {
+		return "", time.Time{}, fmt.Errorf("%w: path must start with /", ErrInvalidSignedURL)
+	}
+	expires := time.Now().Add(am.config.SignedURLTTL).Truncate(time.Second)
+	expiresText := strconv.FormatInt(expires.Unix(), 10)
+
+	q := url.Values{}
+	q.Set("key_id", key.KeyUUID)
+	q.Set("expires", expiresText)
+	q.Set("sig", am.urlSignature(path, key.KeyUUID, expiresText))
+	return path + "?" + q.Encode(), expires, nil
+}
+
+// AuthenticateSigned returns the key a signed URL was issued for, as long
+// as the signature matches path and has not expired and the key is still
+// enabled. URLs signed with the bootstrap admin key have no key_id.
+func (am *AuthManager) AuthenticateSigned(path, keyID, expires, sig string) (*models.APIKey, error) {
+	at, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > at {
+		return nil, ErrUnauthorized
+	}
+	if !hmac.Equal([]byte(sig), []byte(am.urlSignature(path, keyID, expires))) {
+		return nil, ErrUnauthorized
+	}
+
+	if keyID == "" {
+		if am.config.AdminKey == "" {
+			return nil, ErrUnauthorized
+		}
+		return &models.APIKey{Name: "bootstrap admin", Role: RoleAdmin, Enabled: true}, nil
+	}
+	if !isUUID(keyID) {
+		return nil, ErrUnauthorized
+	}
+	row := am.db.QueryRow(`
+		UPDATE api_keys SET last_used_at = now()
+		WHERE key_uuid = $1 AND enabled
+		RETURNING `+apiKeyColumns, keyID)
+
+	key, err := scanAPIKey(row)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrUnauthorized
+	}
+	return key, err
+}
+
+func (am *AuthManager) urlSignature(path, keyID, expires string) string {
+	mac := hmac.New(sha256.New, am.secret)
+	mac.Write([]byte(path + "\n" + keyID + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HasPermission reports whether the role of key grants perm.
+func HasPermission(key *models.APIKey, perm string) bool {
+	for _, p := range rolePermissions[key.Role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessDomain reports whether key may see data of domainUUID. Keys
+// without a tenant see every tenant.
+func CanAccessDomain(key *models.APIKey, domainUUID string) bool {
+	return !key.DomainUUID.Valid || key.DomainUUID.String == domainUUID
+}
+
+// CreateKey stores a new key and returns it along with the secret, which is
+// not kept and cannot be shown again.
+func (am *AuthManager) CreateKey(req request.APIKeyRequest) (*models.APIKey, string, error) {
+	var b [24]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	token := "vk_" + hex.EncodeToString(b[:])
+
+	row := am.db.QueryRow(`
+		INSERT INTO api_keys (key_uuid, name, key_hash, key_prefix, role, domain_uuid)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid)
+		RETURNING `+apiKeyColumns,
+		newUUID(), req.Name, hashAPIKey(token), token[:11], req.Role, req.DomainUUID)
+
+	key, err := scanAPIKey(row)
+	if err != nil {
+		return nil, "", err
+	}
+	log.Printf("Created %s API key %s (%s)", key.Role, key.KeyPrefix, key.Name)
+	return key, token, nil
+}
+
+// ListKeys returns the keys of a tenant, or every key when domainUUID is
+// empty.
+func (am *AuthManager) ListKeys(domainUUID string) ([]models.APIKey, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, ErrInvalidDomain
+	}
+	rows, err := am.db.Query(`
+		SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE NULLIF($1, '') IS NULL OR domain_uuid = NULLIF($1, '')::uuid
+		ORDER BY created_at`, domainUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (am *AuthManager) GetKey(id string) (*models.APIKey, error) {
+	if !isUUID(id) {
+		return nil, ErrAPIKeyNotFound
+	}
+	row := am.db.QueryRow(`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_uuid = $1`, id)
+	return scanAPIKey(row)
+}
+
+func (am *AuthManager) DeleteKey(id string) error {
+	if !isUUID(id) {
+		return ErrAPIKeyNotFound
+	}
+	res, err := am.db.Exec(`DELETE FROM api_keys WHERE key_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func hashAPIKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(&key.KeyUUID, &key.Name, &key.KeyPrefix, &key.Role, &key.DomainUUID, &key.Enabled,
+		&key.LastUsedAt, &key.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan API key: %w", err)
+	}
+	return &key, nil
+}
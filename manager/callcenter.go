@@ -0,0 +1,521 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+)
+
+var (
+	ErrCallCenterAgentNotFound = errors.New("call center agent not found")
+	ErrCallCenterQueueNotFound = errors.New("call center queue not found")
+	ErrCallCenterTierExists    = errors.New("agent already has a tier in this queue")
+	ErrInvalidCallCenter       = errors.New("invalid call center request")
+)
+
+// Agent statuses understood by mod_callcenter.
+const (
+	AgentAvailable         = "Available"
+	AgentAvailableOnDemand = "Available (On Demand)"
+	AgentOnBreak           = "On Break"
+	AgentLoggedOut         = "Logged Out"
+)
+
+// Agent states, which mod_callcenter changes as it offers calls.
+const (
+	AgentWaiting     = "Waiting"
+	AgentReceiving   = "Receiving"
+	AgentInQueueCall = "In a queue call"
+	AgentIdle        = "Idle"
+	AgentReserved    = "Reserved"
+)
+
+var agentStatuses = map[string]bool{
+	AgentAvailable:         true,
+	AgentAvailableOnDemand: true,
+	AgentOnBreak:           true,
+	AgentLoggedOut:         true,
+}
+
+// CallCenterAgent is one row of "callcenter_config agent list".
+type CallCenterAgent struct {
+	Name             string
+	Type             string
+	Contact          string
+	Status           string
+	State            string
+	LastStatusChange time.Time
+	NoAnswerCount    int
+	CallsAnswered    int
+	TalkTime         int
+}
+
+// CallCenterMember is a caller in a queue: waiting, being offered to
+// agents, or talking to one.
+type CallCenterMember struct {
+	Queue          string
+	UUID           string
+	SessionUUID    string
+	CallerIDNumber string
+	CallerIDName   string
+	Joined         time.Time
+	State          string
+	ServingAgent   string
+}
+
+// WaitSeconds is how long the member has been in the queue.
+func (m CallCenterMember) WaitSeconds(now time.Time) int {
+	if m.Joined.IsZero() {
+		return 0
+	}
+	return int(now.Sub(m.Joined).Seconds())
+}
+
+// CallCenterTier links an agent to a queue. Lower levels are offered calls
+// first.
+type CallCenterTier struct {
+	Queue    string
+	Agent    string
+	State    string
+	Level    int
+	Position int
+}
+
+// CallCenterStats are the live figures of a queue. Offered, Answered,
+// Abandoned and the wait times count since local midnight, from
+// callcenter::info events; the rest is read from mod_callcenter when asked.
+type CallCenterStats struct {
+	Queue           string
+	Waiting         int
+	LongestWait     int
+	AgentsAvailable int
+	AgentsOnBreak   int
+	AgentsLoggedOut int
+	AgentsOnCall    int
+	Offered         int
+	Answered        int
+	Abandoned       int
+	AverageWait     float64
+	MaxAnsweredWait int
+	Since           time.Time
+}
+
+// queueCounters are the event-driven part of CallCenterStats.
+type queueCounters struct {
+	offered, answered, abandoned int
+	waitTotal, maxWait           int
+}
+
+// CallCenterManager controls mod_callcenter agents, tiers and queues through
+// callcenter_config, and counts queue traffic from callcenter::info events.
+type CallCenterManager struct {
+	db     *sql.DB
+	eslMgr *ESLManager
+
+	mu       sync.Mutex
+	day      time.Time
+	counters map[string]*queueCounters // queue -> today's counters
+	domains  map[string]string         // domain name -> uuid
+}
+
+func NewCallCenterManager(db *sql.DB, eslMgr *ESLManager) *CallCenterManager {
+	cm := &CallCenterManager{
+		db:       db,
+		eslMgr:   eslMgr,
+		day:      today(),
+		counters: make(map[string]*queueCounters),
+		domains:  make(map[string]string),
+	}
+	eslMgr.Subscribe("callcenter::info", cm.handleInfo)
+	return cm
+}
+
+func today() time.Time {
+	y, m, d := time.Now().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// queue returns today's counters of a queue, starting a new day when the
+// date changed. The caller holds cm.mu.
+func (cm *CallCenterManager) queue(name string) *queueCounters {
+	if d := today(); !d.Equal(cm.day) {
+		cm.day = d
+		cm.counters = make(map[string]*queueCounters)
+	}
+	qc := cm.counters[name]
+	if qc == nil {
+		qc = &queueCounters{}
+		cm.counters[name] = qc
+	}
+	return qc
+}
+
+func (cm *CallCenterManager) handleInfo(ev *eventsocket.Event) {
+	queue := ev.Get("Cc-Queue")
+	if queue == "" {
+		return
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	switch ev.Get("Cc-Action") {
+	case "member-queue-start":
+		cm.queue(queue).offered++
+	case "bridge-agent-start":
+		qc := cm.queue(queue)
+		qc.answered++
+		joined, _ := strconv.ParseInt(ev.Get("Cc-Member-Joined-Time"), 10, 64)
+		answered, _ := strconv.ParseInt(ev.Get("Cc-Agent-Answered-Time"), 10, 64)
+		if joined > 0 && answered >= joined {
+			wait := int(answered - joined)
+			qc.waitTotal += wait
+			qc.maxWait = max(qc.maxWait, wait)
+		}
+	case "member-queue-end":
+		// Members that leave before an agent answers end with the Cancel
+		// cause; answered members end with Terminated.
+		if ev.Get("Cc-Cause") == "Cancel" {
+			cm.queue(queue).abandoned++
+		}
+	}
+}
+
+// ListAgents returns every agent with its status and state.
+func (cm *CallCenterManager) ListAgents() ([]CallCenterAgent, error) {
+	rows, err := cm.list("agent list")
+	if err != nil {
+		return nil, err
+	}
+
+	agents := make([]CallCenterAgent, 0, len(rows))
+	for _, row := range rows {
+		agents = append(agents, agentFromRow(row))
+	}
+	return agents, nil
+}
+
+// GetAgent returns one agent.
+func (cm *CallCenterManager) GetAgent(name string) (*CallCenterAgent, error) {
+	if err := validateCallCenterName("agent", name); err != nil {
+		return nil, err
+	}
+	rows, err := cm.list("agent list " + name)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrCallCenterAgentNotFound
+	}
+	agent := agentFromRow(rows[0])
+	return &agent, nil
+}
+
+// ListQueueAgents returns the agents with a tier in a queue.
+func (cm *CallCenterManager) ListQueueAgents(queue string) ([]CallCenterAgent, error) {
+	if err := validateCallCenterName("queue", queue); err != nil {
+		return nil, err
+	}
+	rows, err := cm.list("queue list agents " + queue)
+	if err != nil {
+		return nil, err
+	}
+
+	agents := make([]CallCenterAgent, 0, len(rows))
+	for _, row := range rows {
+		agents = append(agents, agentFromRow(row))
+	}
+	return agents, nil
+}
+
+func agentFromRow(row map[string]string) CallCenterAgent {
+	return CallCenterAgent{
+		Name:             row["name"],
+		Type:             row["type"],
+		Contact:          row["contact"],
+		Status:           row["status"],
+		State:            row["state"],
+		LastStatusChange: epochTime(row["last_status_change"]),
+		NoAnswerCount:    atoi(row["no_answer_count"]),
+		CallsAnswered:    atoi(row["calls_answered"]),
+		TalkTime:         atoi(row["talk_time"]),
+	}
+}
+
+// SetAgentStatus sets an agent Available, Available (On Demand), On Break
+// or Logged Out.
+func (cm *CallCenterManager) SetAgentStatus(name, status string) (*CallCenterAgent, error) {
+	if err := validateCallCenterName("agent", name); err != nil {
+		return nil, err
+	}
+	if !agentStatuses[status] {
+		return nil, fmt.Errorf("%w: status must be one of %q, %q, %q or %q", ErrInvalidCallCenter,
+			AgentAvailable, AgentAvailableOnDemand, AgentOnBreak, AgentLoggedOut)
+	}
+	if err := cm.command(fmt.Sprintf("agent set status %s '%s'", name, status)); err != nil {
+		return nil, err
+	}
+	return cm.GetAgent(name)
+}
+
+// ListQueues returns the names of the loaded queues.
+func (cm *CallCenterManager) ListQueues() ([]string, error) {
+	rows, err := cm.list("queue list")
+	if err != nil {
+		return nil, err
+	}
+
+	queues := make([]string, 0, len(rows))
+	for _, row := range rows {
+		queues = append(queues, row["name"])
+	}
+	sort.Strings(queues)
+	return queues, nil
+}
+
+// ListMembers returns the callers in a queue, longest waiting first.
+func (cm *CallCenterManager) ListMembers(queue string) ([]CallCenterMember, error) {
+	if err := validateCallCenterName("queue", queue); err != nil {
+		return nil, err
+	}
+	rows, err := cm.list("queue list members " + queue)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]CallCenterMember, 0, len(rows))
+	for _, row := range rows {
+		joined := epochTime(row["rejoined_epoch"])
+		if joined.IsZero() {
+			joined = epochTime(row["joined_epoch"])
+		}
+		members = append(members, CallCenterMember{
+			Queue:          row["queue"],
+			UUID:           row["uuid"],
+			SessionUUID:    row["session_uuid"],
+			CallerIDNumber: row["cid_number"],
+			CallerIDName:   row["cid_name"],
+			Joined:         joined,
+			State:          row["state"],
+			ServingAgent:   row["serving_agent"],
+		})
+	}
+	sort.SliceStable(members, func(i, j int) bool { return members[i].Joined.Before(members[j].Joined) })
+	return members, nil
+}
+
+// ListTiers returns the agents of a queue by level and position.
+func (cm *CallCenterManager) ListTiers(queue string) ([]CallCenterTier, error) {
+	if err := validateCallCenterName("queue", queue); err != nil {
+		return nil, err
+	}
+	rows, err := cm.list("queue list tiers " + queue)
+	if err != nil {
+		return nil, err
+	}
+
+	tiers := make([]CallCenterTier, 0, len(rows))
+	for _, row := range rows {
+		tiers = append(tiers, CallCenterTier{
+			Queue:    row["queue"],
+			Agent:    row["agent"],
+			State:    row["state"],
+			Level:    atoi(row["level"]),
+			Position: atoi(row["position"]),
+		})
+	}
+	sort.SliceStable(tiers, func(i, j int) bool {
+		if tiers[i].Level != tiers[j].Level {
+			return tiers[i].Level < tiers[j].Level
+		}
+		return tiers[i].Position < tiers[j].Position
+	})
+	return tiers, nil
+}
+
+// AddTier puts an agent in a queue at level and position, both at least 1.
+func (cm *CallCenterManager) AddTier(queue, agent string, level, position int) error {
+	if err := validateCallCenterName("queue", queue); err != nil {
+		return err
+	}
+	if err := validateCallCenterName("agent", agent); err != nil {
+		return err
+	}
+	if level < 1 || position < 1 {
+		return fmt.Errorf("%w: level and position must be at least 1", ErrInvalidCallCenter)
+	}
+	return cm.command(fmt.Sprintf("tier add %s %s %d %d", queue, agent, level, position))
+}
+
+// RemoveTier takes an agent out of a queue.
+func (cm *CallCenterManager) RemoveTier(queue, agent string) error {
+	if err := validateCallCenterName("queue", queue); err != nil {
+		return err
+	}
+	if err := validateCallCenterName("agent", agent); err != nil {
+		return err
+	}
+	return cm.command(fmt.Sprintf("tier del %s %s", queue, agent))
+}
+
+// Stats returns the live figures of a queue.
+func (cm *CallCenterManager) Stats(queue string) (*CallCenterStats, error) {
+	members, err := cm.ListMembers(queue)
+	if err != nil {
+		return nil, err
+	}
+	agents, err := cm.ListQueueAgents(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	stats := &CallCenterStats{Queue: queue}
+	for _, m := range members {
+		if m.State == "Waiting" || m.State == "Trying" {
+			stats.Waiting++
+			stats.LongestWait = max(stats.LongestWait, m.WaitSeconds(now))
+		}
+	}
+	for _, a := range agents {
+		switch {
+		case a.State == AgentInQueueCall:
+			stats.AgentsOnCall++
+		case a.Status == AgentOnBreak:
+			stats.AgentsOnBreak++
+		case a.Status == AgentLoggedOut:
+			stats.AgentsLoggedOut++
+		case a.Status == AgentAvailable || a.Status == AgentAvailableOnDemand:
+			stats.AgentsAvailable++
+		}
+	}
+
+	cm.mu.Lock()
+	qc := *cm.queue(queue)
+	stats.Since = cm.day
+	cm.mu.Unlock()
+
+	stats.Offered, stats.Answered, stats.Abandoned = qc.offered, qc.answered, qc.abandoned
+	stats.MaxAnsweredWait = qc.maxWait
+	if qc.answered > 0 {
+		stats.AverageWait = float64(qc.waitTotal) / float64(qc.answered)
+	}
+	return stats, nil
+}
+
+// command runs a callcenter_config command that replies +OK.
+func (cm *CallCenterManager) command(args string) error {
+	_, err := cm.eslMgr.api("callcenter_config " + args)
+	return callCenterError(err)
+}
+
+// list runs a callcenter_config list command. The reply is a "|" separated
+// table with a header row, ending in +OK.
+func (cm *CallCenterManager) list(args string) ([]map[string]string, error) {
+	resp, err := cm.eslMgr.api("callcenter_config " + args)
+	if err != nil {
+		return nil, callCenterError(err)
+	}
+
+	var header []string
+	var rows []map[string]string
+	for _, line := range strings.Split(resp.Body, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || line == "+OK" {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		if header == nil {
+			header = fields
+			continue
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(fields) {
+				row[name] = fields[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Domain returns the tenant of an agent or queue, or "" when it is not
+// known. Queues and agents named <name>@<domain name> belong to that
+// domain; FusionPBX names agents by their v_call_center_agents uuid.
+func (cm *CallCenterManager) Domain(name string) (string, error) {
+	if _, domain, ok := strings.Cut(name, "@"); ok {
+		cm.mu.Lock()
+		domainUUID, known := cm.domains[domain]
+		cm.mu.Unlock()
+		if known {
+			return domainUUID, nil
+		}
+
+		err := cm.db.QueryRow(`SELECT domain_uuid FROM v_domains WHERE domain_name = $1`, domain).Scan(&domainUUID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("failed to look up domain %s: %w", domain, err)
+		}
+		cm.mu.Lock()
+		cm.domains[domain] = domainUUID
+		cm.mu.Unlock()
+		return domainUUID, nil
+	}
+
+	if !isUUID(name) {
+		return "", nil
+	}
+	var domainUUID sql.NullString
+	err := cm.db.QueryRow(`SELECT domain_uuid FROM v_call_center_agents WHERE call_center_agent_uuid = $1`,
+		name).Scan(&domainUUID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to look up agent %s: %w", name, err)
+	}
+	return domainUUID.String, nil
+}
+
+// callCenterError maps the -ERR replies of callcenter_config to errors.
+func callCenterError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "Invalid Agent"), strings.Contains(msg, "Agent not found"):
+		return ErrCallCenterAgentNotFound
+	case strings.Contains(msg, "Invalid Queue"), strings.Contains(msg, "Queue not found"):
+		return ErrCallCenterQueueNotFound
+	case strings.Contains(msg, "already exist"):
+		return ErrCallCenterTierExists
+	case strings.Contains(msg, "Invalid"):
+		return fmt.Errorf("%w: %s", ErrInvalidCallCenter, strings.TrimSpace(msg[strings.Index(msg, "Invalid"):]))
+	}
+	return err
+}
+
+func validateCallCenterName(field, value string) error {
+	if !appArgPattern.MatchString(value) {
+		return fmt.Errorf("%w: invalid %s %q", ErrInvalidCallCenter, field, value)
+	}
+	return nil
+}
+
+func epochTime(s string) time.Time {
+	epoch, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || epoch <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(epoch, 0)
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,122 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+// CallChecker runs the checks every originated call goes through, whether
+// it comes from the API, a schedule or a campaign: fraud blocks, the
+// do-not-call list and the prepaid balance, in that order.
+type CallChecker struct {
+	db      *sql.DB
+	eslMgr  *ESLManager
+	fraud   *FraudManager
+	dnc     *DNCManager
+	prepaid *PrepaidManager
+}
+
+func NewCallChecker(db *sql.DB, eslMgr *ESLManager, fraud *FraudManager, dnc *DNCManager, prepaid *PrepaidManager) *CallChecker {
+	return &CallChecker{
+		db:      db,
+		eslMgr:  eslMgr,
+		fraud:   fraud,
+		dnc:     dnc,
+		prepaid: prepaid,
+	}
+}
+
+// Check returns ErrExtensionBlocked, a *BlockedError, ErrInsufficientBalance,
+// ErrNoRate or ErrTenantUnknown when the call may not be placed. Calls
+// without a tenant take the one of their caller extension. Otherwise it
+// sets the prepaid limits of the call in req.PrepaidVariables.
+func (cc *CallChecker) Check(req *request.CallRequest) error {
+	if req.DomainUUID == "" {
+		domainUUID, err := cc.callerDomain(req.Caller)
+		if err != nil {
+			return err
+		}
+		req.DomainUUID = domainUUID
+	}
+	if cc.fraud != nil {
+		if err := cc.fraud.CheckCall(*req); err != nil {
+			return err
+		}
+	}
+	if cc.dnc != nil {
+		if err := cc.dnc.CheckCall(*req); err != nil {
+			return err
+		}
+	}
+	if cc.prepaid == nil {
+		return nil
+	}
+
+	limits, err := cc.prepaid.Authorize(*req)
+	if err != nil {
+		return err
+	}
+	req.PrepaidVariables = limits
+	return nil
+}
+
+// callerDomain returns the tenant of the extension caller rings, or "" when
+// no single extension matches. The caller leg is dialed in the FreeSWITCH
+// domain, so an extension of that domain wins over those of other tenants.
+func (cc *CallChecker) callerDomain(caller string) (string, error) {
+	if cc.db == nil || caller == "" {
+		return "", nil
+	}
+	rows, err := cc.db.Query(`
+		SELECT e.domain_uuid, d.domain_name = $2
+		FROM v_extensions e JOIN v_domains d ON d.domain_uuid = e.domain_uuid
+		WHERE e.extension = $1 OR e.number_alias = $1
+		ORDER BY d.domain_name = $2 DESC
+		LIMIT 2`, caller, cc.eslMgr.config.Domain)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up caller extension: %w", err)
+	}
+	defer rows.Close()
+
+	var domains []string
+	for rows.Next() {
+		var domainUUID string
+		var local bool
+		if err := rows.Scan(&domainUUID, &local); err != nil {
+			return "", fmt.Errorf("failed to scan caller extension: %w", err)
+		}
+		if local {
+			return domainUUID, nil
+		}
+		domains = append(domains, domainUUID)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to read caller extensions: %w", err)
+	}
+	if len(domains) != 1 {
+		return "", nil
+	}
+	return domains[0], nil
+}
+
+// CheckCause returns the failure cause recorded for calls Check refused, or
+// "" when err is not a refusal.
+func CheckCause(err error) string {
+	var blocked *BlockedError
+	switch {
+	case errors.As(err, &blocked):
+		return "DNC_BLOCKED"
+	case errors.Is(err, ErrExtensionBlocked):
+		return "FRAUD_BLOCKED"
+	case errors.Is(err, ErrInsufficientBalance):
+		return "INSUFFICIENT_BALANCE"
+	case errors.Is(err, ErrNoRate):
+		return "NO_RATE"
+	case errors.Is(err, ErrTenantUnknown):
+		return "TENANT_UNKNOWN"
+	}
+	return ""
+}
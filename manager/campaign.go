@@ -0,0 +1,491 @@
+package manager
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/number"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrCampaignNotFound = errors.New("campaign not found")
+	ErrInvalidCampaign  = errors.New("invalid campaign")
+	ErrCampaignState    = errors.New("invalid campaign state transition")
+)
+
+const (
+	CampaignDraft   = "draft"
+	CampaignRunning = "running"
+	CampaignPaused  = "paused"
+	CampaignStopped = "stopped"
+)
+
+const (
+	ContactPending   = "pending"
+	ContactDialing   = "dialing"
+	ContactAnswered  = "answered"
+	ContactRetry     = "retry"
+	ContactCompleted = "completed"
+	ContactFailed    = "failed"
+)
+
+var defaultRetryCauses = []string{"USER_BUSY", "NO_ANSWER", "NO_USER_RESPONSE", "ORIGINATOR_CANCEL"}
+
+const campaignColumns = `campaign_uuid, domain_uuid, name, mode, status, agents, destination, caller_id_name, caller_id_number,
+	dial_timeout, max_dial_ratio, max_attempts, retry_delay_seconds, retry_causes, timezone, window_start, window_end,
+	amd, created_at, updated_at`
+
+const contactColumns = `contact_uuid, campaign_uuid, phone_number, name, timezone, variables, status, attempts,
+	next_attempt_at, last_attempt_at, last_call_uuid, last_hangup_cause, last_agent, billsec, amd_result, created_at,
+	updated_at`
+
+var contactVarPattern = regexp.MustCompile(`[^a-z0-9_]+`)
+
+type ImportResult struct {
+	Imported int            `json:"imported"`
+	Rejected []ImportReject `json:"rejected"`
+}
+
+type ImportReject struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// CampaignManager stores outbound campaigns and runs the dialer that works
+// through their contact lists. See dialer.go for the pacing loop.
+type CampaignManager struct {
+	db     *sql.DB
+	eslMgr *ESLManager
+	checks *CallChecker
+	config config.CampaignConfig
+
+	mu       sync.Mutex
+	reserved map[string]string // agent extension -> contact uuid
+	inFlight map[string]int    // campaign uuid -> calls not yet answered
+}
+
+func NewCampaignManager(db *sql.DB, eslMgr *ESLManager, checks *CallChecker, cfg config.CampaignConfig) *CampaignManager {
+	cm := &CampaignManager{
+		db:       db,
+		eslMgr:   eslMgr,
+		checks:   checks,
+		config:   cfg,
+		reserved: make(map[string]string),
+		inFlight: make(map[string]int),
+	}
+	eslMgr.Subscribe("CHANNEL_HANGUP_COMPLETE", cm.handleHangup)
+	return cm
+}
+
+func (cm *CampaignManager) Create(req request.CampaignRequest) (*models.Campaign, error) {
+	destination, err := campaignDestination(req)
+	if err != nil {
+		return nil, err
+	}
+	amd, err := campaignAMD(req, cm.eslMgr.config.SoundsDir)
+	if err != nil {
+		return nil, err
+	}
+	applyCampaignDefaults(&req)
+
+	row := cm.db.QueryRow(`
+		INSERT INTO campaigns (campaign_uuid, domain_uuid, name, mode, agents, destination, caller_id_name, caller_id_number,
+			dial_timeout, max_dial_ratio, max_attempts, retry_delay_seconds, retry_causes, timezone, window_start, window_end,
+			amd)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		RETURNING `+campaignColumns,
+		newUUID(), req.DomainUUID, req.Name, req.Mode, pq.Array(req.Agents), destination, req.CallerIDName, req.CallerIDNumber,
+		req.DialTimeout, req.MaxDialRatio, req.MaxAttempts, req.RetryDelaySeconds, pq.Array(req.RetryCauses),
+		req.Timezone, req.WindowStart, req.WindowEnd, amd)
+
+	return scanCampaign(row)
+}
+
+func (cm *CampaignManager) Get(id string) (*models.Campaign, error) {
+	if !isUUID(id) {
+		return nil, ErrCampaignNotFound
+	}
+	row := cm.db.QueryRow(`SELECT `+campaignColumns+` FROM campaigns WHERE campaign_uuid = $1`, id)
+	return scanCampaign(row)
+}
+
+// List returns the campaigns of domainUUID, or of every tenant when it is
+// empty.
+func (cm *CampaignManager) List(domainUUID string, limit, offset int) ([]models.Campaign, int, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, 0, ErrInvalidDomain
+	}
+	const where = `WHERE NULLIF($1, '') IS NULL OR domain_uuid = NULLIF($1, '')::uuid`
+
+	var total int
+	if err := cm.db.QueryRow(`SELECT COUNT(*) FROM campaigns `+where, domainUUID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count campaigns: %w", err)
+	}
+
+	rows, err := cm.db.Query(`SELECT `+campaignColumns+` FROM campaigns `+where+`
+		ORDER BY created_at DESC LIMIT $2 OFFSET $3`, domainUUID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	var campaigns []models.Campaign
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		campaigns = append(campaigns, *campaign)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read campaigns: %w", err)
+	}
+
+	return campaigns, total, nil
+}
+
+func (cm *CampaignManager) Update(id string, req request.CampaignRequest) (*models.Campaign, error) {
+	if !isUUID(id) {
+		return nil, ErrCampaignNotFound
+	}
+	destination, err := campaignDestination(req)
+	if err != nil {
+		return nil, err
+	}
+	amd, err := campaignAMD(req, cm.eslMgr.config.SoundsDir)
+	if err != nil {
+		return nil, err
+	}
+	applyCampaignDefaults(&req)
+
+	row := cm.db.QueryRow(`
+		UPDATE campaigns
+		SET domain_uuid = NULLIF($2, '')::uuid, name = $3, mode = $4, agents = $5, destination = $6,
+			caller_id_name = $7, caller_id_number = $8, dial_timeout = $9, max_dial_ratio = $10, max_attempts = $11,
+			retry_delay_seconds = $12, retry_causes = $13, timezone = $14, window_start = $15, window_end = $16,
+			amd = $17, updated_at = now()
+		WHERE campaign_uuid = $1
+		RETURNING `+campaignColumns,
+		id, req.DomainUUID, req.Name, req.Mode, pq.Array(req.Agents), destination, req.CallerIDName, req.CallerIDNumber,
+		req.DialTimeout, req.MaxDialRatio, req.MaxAttempts, req.RetryDelaySeconds, pq.Array(req.RetryCauses),
+		req.Timezone, req.WindowStart, req.WindowEnd, amd)
+
+	return scanCampaign(row)
+}
+
+func (cm *CampaignManager) Delete(id string) error {
+	if !isUUID(id) {
+		return ErrCampaignNotFound
+	}
+	res, err := cm.db.Exec(`DELETE FROM campaigns WHERE campaign_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete campaign: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCampaignNotFound
+	}
+	return nil
+}
+
+// SetStatus applies a control action: start, pause, resume or stop. Stopping
+// only prevents new attempts; calls already in progress are left alone.
+func (cm *CampaignManager) SetStatus(id, action string) (*models.Campaign, error) {
+	if !isUUID(id) {
+		return nil, ErrCampaignNotFound
+	}
+
+	var from []string
+	var to string
+	switch action {
+	case "start":
+		from, to = []string{CampaignDraft}, CampaignRunning
+	case "resume":
+		from, to = []string{CampaignPaused}, CampaignRunning
+	case "pause":
+		from, to = []string{CampaignRunning}, CampaignPaused
+	case "stop":
+		from, to = []string{CampaignDraft, CampaignRunning, CampaignPaused}, CampaignStopped
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", ErrCampaignState, action)
+	}
+
+	row := cm.db.QueryRow(`
+		UPDATE campaigns SET status = $2, updated_at = now()
+		WHERE campaign_uuid = $1 AND status = ANY($3)
+		RETURNING `+campaignColumns, id, to, pq.Array(from))
+
+	campaign, err := scanCampaign(row)
+	if errors.Is(err, ErrCampaignNotFound) {
+		if _, getErr := cm.Get(id); getErr != nil {
+			return nil, getErr
+		}
+		return nil, fmt.Errorf("%w: cannot %s campaign", ErrCampaignState, action)
+	}
+	return campaign, err
+}
+
+// ImportContacts loads a CSV contact list. The header row must contain a
+// phone_number (or phone) column; name and timezone are optional and every
+// other column is stored as a channel variable on the call.
+func (cm *CampaignManager) ImportContacts(id string, r io.Reader) (*ImportResult, error) {
+	if _, err := cm.Get(id); err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read CSV header: %v", ErrInvalidCampaign, err)
+	}
+
+	phoneCol, nameCol, tzCol := -1, -1, -1
+	varNames := make([]string, len(header))
+	for i, col := range header {
+		key := strings.Trim(contactVarPattern.ReplaceAllString(strings.ToLower(strings.TrimSpace(col)), "_"), "_")
+		switch key {
+		case "phone_number", "phone", "number":
+			phoneCol = i
+		case "name":
+			nameCol = i
+		case "timezone", "tz":
+			tzCol = i
+		default:
+			if key != "" && varNamePattern.MatchString(key) && !reservedVarPattern.MatchString(key) {
+				varNames[i] = "campaign_" + key
+			}
+		}
+	}
+	if phoneCol < 0 {
+		return nil, fmt.Errorf("%w: CSV header has no phone_number column", ErrInvalidCampaign)
+	}
+
+	tx, err := cm.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(pq.CopyIn("campaign_contacts",
+		"contact_uuid", "campaign_uuid", "phone_number", "name", "timezone", "variables"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare contact import: %w", err)
+	}
+
+	result := &ImportResult{Rejected: []ImportReject{}}
+	line := 1
+	for {
+		record, err := reader.Read()
+		line++
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.Rejected = append(result.Rejected, ImportReject{Line: line, Reason: err.Error()})
+			continue
+		}
+
+		phone := number.Clean(field(record, phoneCol))
+		if !dialTokenPattern.MatchString(phone) {
+			result.Rejected = append(result.Rejected, ImportReject{Line: line, Reason: "invalid phone number"})
+			continue
+		}
+
+		tz := field(record, tzCol)
+		if tz != "" {
+			if _, err := time.LoadLocation(tz); err != nil {
+				result.Rejected = append(result.Rejected, ImportReject{Line: line, Reason: "unknown timezone " + tz})
+				continue
+			}
+		}
+
+		vars := make(map[string]string)
+		for i, name := range varNames {
+			if name != "" && field(record, i) != "" {
+				vars[name] = field(record, i)
+			}
+		}
+		varsJSON, _ := json.Marshal(vars)
+
+		if _, err := stmt.Exec(newUUID(), id, phone, field(record, nameCol), tz, string(varsJSON)); err != nil {
+			stmt.Close()
+			return nil, fmt.Errorf("failed to import contact on line %d: %w", line, err)
+		}
+		result.Imported++
+	}
+
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return nil, fmt.Errorf("failed to flush contact import: %w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish contact import: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit contact import: %w", err)
+	}
+
+	log.Printf("Imported %d contacts into campaign %s (%d rejected)", result.Imported, id, len(result.Rejected))
+	return result, nil
+}
+
+func (cm *CampaignManager) ListContacts(id, status string, limit, offset int) ([]models.CampaignContact, int, error) {
+	if !isUUID(id) {
+		return nil, 0, ErrCampaignNotFound
+	}
+
+	var total int
+	if err := cm.db.QueryRow(`SELECT COUNT(*) FROM campaign_contacts
+		WHERE campaign_uuid = $1 AND ($2 = '' OR status = $2)`, id, status).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count contacts: %w", err)
+	}
+
+	rows, err := cm.db.Query(`SELECT `+contactColumns+` FROM campaign_contacts
+		WHERE campaign_uuid = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at, contact_uuid LIMIT $3 OFFSET $4`, id, status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch contacts: %w", err)
+	}
+	defer rows.Close()
+
+	var contacts []models.CampaignContact
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		contacts = append(contacts, *contact)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read contacts: %w", err)
+	}
+
+	return contacts, total, nil
+}
+
+// ContactStats returns the number of contacts per status.
+func (cm *CampaignManager) ContactStats(id string) (map[string]int, error) {
+	rows, err := cm.db.Query(`SELECT status, COUNT(*) FROM campaign_contacts
+		WHERE campaign_uuid = $1 GROUP BY status`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count contacts: %w", err)
+	}
+	defer rows.Close()
+
+	stats := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan contact stats: %w", err)
+		}
+		stats[status] = count
+	}
+	return stats, rows.Err()
+}
+
+func campaignDestination(req request.CampaignRequest) ([]byte, error) {
+	if req.Mode == "power" && req.Destination == nil {
+		return nil, fmt.Errorf("%w: power mode requires a destination", ErrInvalidCampaign)
+	}
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidCampaign, req.Timezone)
+		}
+	}
+	if req.Destination == nil {
+		return nil, nil
+	}
+	return json.Marshal(req.Destination)
+}
+
+// campaignAMD validates the answering machine detection of a campaign and
+// returns it with the defaults filled in.
+func campaignAMD(req request.CampaignRequest, soundsDir string) ([]byte, error) {
+	if req.AMD == nil {
+		return nil, nil
+	}
+	amd := *req.AMD
+	if err := normalizeAMD(&amd, soundsDir); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCampaign, err)
+	}
+	return json.Marshal(amd)
+}
+
+func applyCampaignDefaults(req *request.CampaignRequest) {
+	if req.DialTimeout == 0 {
+		req.DialTimeout = 30
+	}
+	if req.MaxDialRatio == 0 {
+		req.MaxDialRatio = 2
+	}
+	if req.MaxAttempts == 0 {
+		req.MaxAttempts = 3
+	}
+	if req.RetryDelaySeconds == 0 {
+		req.RetryDelaySeconds = 1800
+	}
+	if req.RetryCauses == nil {
+		req.RetryCauses = defaultRetryCauses
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if req.WindowStart == "" {
+		req.WindowStart = "09:00"
+	}
+	if req.WindowEnd == "" {
+		req.WindowEnd = "20:00"
+	}
+}
+
+func field(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+func scanCampaign(row rowScanner) (*models.Campaign, error) {
+	var c models.Campaign
+	err := row.Scan(
+		&c.CampaignUUID, &c.DomainUUID, &c.Name, &c.Mode, &c.Status, pq.Array(&c.Agents), &c.Destination, &c.CallerIDName,
+		&c.CallerIDNumber, &c.DialTimeout, &c.MaxDialRatio, &c.MaxAttempts, &c.RetryDelaySeconds,
+		pq.Array(&c.RetryCauses), &c.Timezone, &c.WindowStart, &c.WindowEnd, &c.AMD, &c.CreatedAt, &c.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCampaignNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan campaign: %w", err)
+	}
+	return &c, nil
+}
+
+func scanContact(row rowScanner) (*models.CampaignContact, error) {
+	var c models.CampaignContact
+	err := row.Scan(
+		&c.ContactUUID, &c.CampaignUUID, &c.PhoneNumber, &c.Name, &c.Timezone, &c.Variables, &c.Status,
+		&c.Attempts, &c.NextAttemptAt, &c.LastAttemptAt, &c.LastCallUUID, &c.LastHangupCause, &c.LastAgent,
+		&c.BillSec, &c.AMDResult, &c.CreatedAt, &c.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan contact: %w", err)
+	}
+	return &c, nil
+}
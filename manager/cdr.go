@@ -1,13 +1,18 @@
 package manager
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fiorix/go-eventsocket/eventsocket"
 	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
 )
 
 
@@ -24,16 +29,131 @@ type CallStatus struct {
 }
 
 type ESLManager struct {
-	config config.FreeSWITCHConfig
+	config  config.FreeSWITCHConfig
+	numbers *NumberManager
+	routes  *RouteManager
+	amd     *AMDManager
+
+	mu           sync.RWMutex
+	handlers     map[string][]EventHandler
+	connectHooks []func()
 }
 
-func NewESLManager(cfg config.FreeSWITCHConfig) *ESLManager {
-	return &ESLManager{
-		config: cfg,
+func NewESLManager(cfg config.FreeSWITCHConfig, numbers *NumberManager, routes *RouteManager) *ESLManager {
+	e := &ESLManager{
+		config:   cfg,
+		numbers:  numbers,
+		routes:   routes,
+		handlers: make(map[string][]EventHandler),
+	}
+	e.Subscribe("CHANNEL_HANGUP", e.handleHangupEvent)
+	if routes != nil {
+		e.Subscribe("CHANNEL_HANGUP_COMPLETE", routes.handleHangupEvent)
+	}
+	return e
+}
+
+// OriginateCall places the call and returns the uuid of its first leg. When
+// the caller is an external number each least-cost route is tried in turn,
+// moving on only when the previous one failed with a failover cause.
+func (e *ESLManager) OriginateCall(req request.CallRequest) (string, error) {
+	callerLegs, err := e.dialLegs(req.DomainUUID, req.Caller)
+	if err != nil {
+		return "", err
+	}
+
+	vars, err := e.buildChannelVars(req)
+	if err != nil {
+		return "", err
+	}
+
+	// Every leg of the call is recorded against the uuid of the first
+	// attempt, so the bridge target can be built once. A caller that picked
+	// the uuid up front, like the campaign dialer, keeps it for that leg.
+	routeUUID := vars["origination_uuid"]
+	if !isUUID(routeUUID) {
+		routeUUID = newUUID()
+	}
+	target, billed, err := e.buildDestination(req, routeUUID)
+	if err != nil {
+		return "", err
+	}
+
+	// With answering machine detection the answered leg is parked, and the
+	// destination only runs once the detection picks it.
+	var amdTarget string
+	if req.AMD != nil {
+		if e.amd == nil {
+			return "", fmt.Errorf("%w: answering machine detection is not available", ErrInvalidOriginate)
+		}
+		opts := *req.AMD
+		if err := normalizeAMD(&opts, e.config.SoundsDir); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidOriginate, err)
+		}
+		req.AMD = &opts
+		amdTarget, target = target, "&park()"
+	}
+
+	for i, leg := range callerLegs {
+		legUUID := routeUUID
+		if i > 0 {
+			legUUID = newUUID()
+		}
+
+		legVars := make(map[string]string, len(vars)+9)
+		for name, value := range vars {
+			legVars[name] = value
+		}
+		for name, value := range leg.routeVars(routeUUID, req.DomainUUID, i+1) {
+			legVars[name] = value
+		}
+		// Without an external bridge leg the caller leg is charged. When it
+		// is a local extension the external number is reached through the
+		// dialplan, so only the time it spends bridged is billed.
+		if !billed && len(req.PrepaidVariables) > 0 {
+			for name, value := range req.PrepaidVariables {
+				legVars[name] = value
+			}
+			if leg.number == "" {
+				legVars["prepaid_bill_bridged"] = "true"
+			}
+		}
+		legVars["origination_uuid"] = legUUID
+
+		varBlock, err := formatChannelVars(legVars)
+		if err != nil {
+			return "", err
+		}
+
+		callID, err := e.originate(fmt.Sprintf("originate %s%s %s", varBlock, leg.dial, target))
+		cause := HangupCause(err)
+		if leg.route != nil && e.routes != nil {
+			e.recordCallerAttempt(leg, legUUID, routeUUID, req.DomainUUID, i+1, cause)
+		}
+		if err == nil {
+			if req.AMD != nil {
+				e.amd.start(callID, req.DomainUUID, *req.AMD, amdTarget)
+			}
+			return callID, nil
+		}
+
+		if i == len(callerLegs)-1 || e.routes == nil || !e.routes.Failover(cause) {
+			return "", err
+		}
+		log.Printf("Call via gateway %s failed with %s, trying next route", leg.route.Gateway, cause)
 	}
+
+	return "", fmt.Errorf("%w: no route for caller %s", ErrInvalidOriginate, req.Caller)
 }
 
-func (e *ESLManager) OriginateCall(caller, callee string) (string, error) {
+// originateWait bounds how long originate waits for its background job.
+// The job ends once the leg answers or its ring timeout runs out.
+const originateWait = (maxOriginateTimeout + 30) * time.Second
+
+// originate runs cmd with bgapi and waits for its BACKGROUND_JOB event. A
+// blocking api command would be given up by the ESL client after 60
+// seconds while the leg keeps ringing.
+func (e *ESLManager) originate(cmd string) (string, error) {
 	addr := fmt.Sprintf("%s:%s", e.config.Host, e.config.Port)
 	client, err := eventsocket.Dial(addr, e.config.Password)
 	if err != nil {
@@ -41,10 +161,31 @@ func (e *ESLManager) OriginateCall(caller, callee string) (string, error) {
 	}
 	defer client.Close()
 
-	cmd := fmt.Sprintf("originate {origination_caller_id_number=%s}user/%s@172.27.191.2 &bridge(user/%s@172.27.191.2)", caller, caller, callee)
-	resp, err := client.Send(fmt.Sprintf("api %s", cmd))
-	if err != nil {
-		return "", fmt.Errorf("failed to originate call: %w", err)
+	jobUUID := newUUID()
+	for _, sub := range []string{"filter Job-UUID " + jobUUID, "event plain BACKGROUND_JOB"} {
+		if _, err := client.Send(sub); err != nil {
+			return "", fmt.Errorf("failed to subscribe to originate result: %w", err)
+		}
+	}
+	if _, err := client.Send(fmt.Sprintf("bgapi %s\nJob-UUID: %s", cmd, jobUUID)); err != nil {
+		return "", fmt.Errorf("failed to originate call: %w", newOriginateError(err))
+	}
+
+	// Closing the connection ends ReadEvent if the job never reports back.
+	timer := time.AfterFunc(originateWait, client.Close)
+	defer timer.Stop()
+	var resp *eventsocket.Event
+	for resp == nil {
+		ev, err := client.ReadEvent()
+		if err != nil {
+			return "", fmt.Errorf("failed to originate call: no result from FreeSWITCH: %w", err)
+		}
+		if ev.Get("Event-Name") == "BACKGROUND_JOB" && ev.Get("Job-Uuid") == jobUUID {
+			resp = ev
+		}
+	}
+	if body := strings.TrimSpace(resp.Body); strings.HasPrefix(body, "-ERR") {
+		return "", fmt.Errorf("failed to originate call: %w", newOriginateError(errors.New(body)))
 	}
 
 	callID := e.parseCallID(resp)
@@ -55,6 +196,27 @@ func (e *ESLManager) OriginateCall(caller, callee string) (string, error) {
 	return callID, nil
 }
 
+// recordCallerAttempt stores the outcome of a routed caller leg right away,
+// since a leg that fails to originate may never produce a hangup event.
+func (e *ESLManager) recordCallerAttempt(leg dialLeg, legUUID, callUUID, domainUUID string, attempt int, cause string) {
+	cr := models.CallRoute{
+		LegUUID:         legUUID,
+		CallUUID:        callUUID,
+		DomainUUID:      sql.NullString{String: domainUUID},
+		Number:          leg.number,
+		RouteUUID:       sql.NullString{String: leg.route.RouteUUID},
+		Gateway:         leg.route.Gateway,
+		Prefix:          leg.route.Prefix,
+		Rate:            leg.route.Rate,
+		Attempt:         attempt,
+		HangupCause:     cause,
+		HangupCauseQ850: causeCode(cause),
+	}
+	if err := e.routes.RecordAttempt(cr); err != nil {
+		log.Printf("Failed to record route of leg %s: %v", legUUID, err)
+	}
+}
+
 func (e *ESLManager) ListenEvents() {
 	addr := fmt.Sprintf("%s:%s", e.config.Host, e.config.Port)
 	
@@ -66,8 +228,14 @@ func (e *ESLManager) ListenEvents() {
 			continue
 		}
 
-		conn.Send("event plain CHANNEL_HANGUP")
+		if _, err := conn.Send(e.subscriptionCommand()); err != nil {
+			log.Printf("Failed to subscribe to ESL events: %v", err)
+			conn.Close()
+			time.Sleep(5 * time.Second)
+			continue
+		}
 		log.Println("ESL event listener connected")
+		e.connected()
 
 		for {
 			ev, err := conn.ReadEvent()
@@ -77,9 +245,7 @@ func (e *ESLManager) ListenEvents() {
 				break
 			}
 
-			if ev.Get("Event-Name") == "CHANNEL_HANGUP" {
-				go e.handleHangupEvent(ev)
-			}
+			e.dispatch(ev)
 		}
 
 		log.Println("ESL connection lost, reconnecting in 5 seconds...")
@@ -88,14 +254,14 @@ func (e *ESLManager) ListenEvents() {
 }
 
 func (e *ESLManager) handleHangupEvent(ev *eventsocket.Event) {
-	callID := ev.Get("Unique-ID")
+	callID := ev.Get("Unique-Id")
 	if callID == "" {
 		log.Println("No Unique-ID in hangup event")
 		return
 	}
 
-	durationStr := ev.Get("variable_duration")
-	billSecStr := ev.Get("variable_billsec")
+	durationStr := ev.Get("Variable_duration")
+	billSecStr := ev.Get("Variable_billsec")
 	hangupCause := ev.Get("Hangup-Cause")
 
 	var duration, billSec int
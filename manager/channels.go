@@ -0,0 +1,193 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+)
+
+var hangupCausePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]+$`)
+
+// OriginateError carries the hangup cause FreeSWITCH returned for a failed
+// originate, e.g. USER_BUSY or NO_ANSWER.
+type OriginateError struct {
+	Cause string
+}
+
+func (e *OriginateError) Error() string {
+	return e.Cause
+}
+
+// newOriginateError turns the "-ERR <CAUSE>" reply surfaced by eventsocket
+// into an OriginateError. Transport errors are returned unchanged.
+func newOriginateError(err error) error {
+	cause := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(err.Error()), "-ERR"))
+	if hangupCausePattern.MatchString(cause) {
+		return &OriginateError{Cause: cause}
+	}
+	return err
+}
+
+// HangupCause extracts the FreeSWITCH hangup cause from an OriginateCall
+// error, or returns "" when the failure was not a call failure.
+func HangupCause(err error) string {
+	var oe *OriginateError
+	if errors.As(err, &oe) {
+		return oe.Cause
+	}
+	return ""
+}
+
+// Channel is one row of "show channels as json".
+type Channel struct {
+	UUID         string `json:"uuid"`
+	Direction    string `json:"direction"`
+	Created      string `json:"created"`
+	CreatedEpoch string `json:"created_epoch"`
+	Name         string `json:"name"`
+	State        string `json:"state"`
+	CIDName      string `json:"cid_name"`
+	CIDNum       string `json:"cid_num"`
+	Dest         string `json:"dest"`
+	Application  string `json:"application"`
+	CallState    string `json:"callstate"`
+	PresenceID   string `json:"presence_id"`
+	CallUUID     string `json:"call_uuid"`
+}
+
+// Extension returns the user part of the channel presence id, which
+// FreeSWITCH sets to the registered user for user/ and sofia/internal legs.
+func (ch Channel) Extension() string {
+	if i := strings.Index(ch.PresenceID, "@"); i > 0 {
+		return ch.PresenceID[:i]
+	}
+	return ""
+}
+
+func (e *ESLManager) ListChannels() ([]Channel, error) {
+	resp, err := e.api("show channels as json")
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		RowCount int       `json:"row_count"`
+		Rows     []Channel `json:"rows"`
+	}
+	if err := json.Unmarshal([]byte(resp.Body), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse channel list: %w", err)
+	}
+	return result.Rows, nil
+}
+
+// api runs a single API command on a short-lived ESL connection.
+func (e *ESLManager) api(cmd string) (*eventsocket.Event, error) {
+	addr := fmt.Sprintf("%s:%s", e.config.Host, e.config.Port)
+	conn, err := eventsocket.Dial(addr, e.config.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to FreeSWITCH: %w", err)
+	}
+	defer conn.Close()
+
+	resp, err := conn.Send("api " + cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %q: %w", strings.Fields(cmd)[0], err)
+	}
+	return resp, nil
+}
+
+// execute runs a dialplan application on a channel with sendmsg. Events
+// of the execution carry eventUUID as their Application-UUID.
+func (e *ESLManager) execute(uuid, app, arg, eventUUID string) error {
+	addr := fmt.Sprintf("%s:%s", e.config.Host, e.config.Port)
+	conn, err := eventsocket.Dial(addr, e.config.Password)
+	if err != nil {
+		return fmt.Errorf("failed to connect to FreeSWITCH: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.SendMsg(eventsocket.MSG{
+		"call-command":     "execute",
+		"execute-app-name": app,
+		"execute-app-arg":  arg,
+		"event-uuid":       eventUUID,
+	}, uuid, "")
+	if err != nil {
+		return fmt.Errorf("failed to run %q: %w", app, err)
+	}
+	return nil
+}
+
+// sendEvent fires an event inside FreeSWITCH with the given headers, as a
+// module would.
+func (e *ESLManager) sendEvent(name string, headers map[string]string) error {
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var cmd strings.Builder
+	cmd.WriteString("sendevent " + name)
+	for _, k := range names {
+		if strings.ContainsAny(k+headers[k], "\r\n") {
+			return fmt.Errorf("event header %q contains a line break", k)
+		}
+		cmd.WriteString("\n" + k + ": " + headers[k])
+	}
+
+	addr := fmt.Sprintf("%s:%s", e.config.Host, e.config.Port)
+	conn, err := eventsocket.Dial(addr, e.config.Password)
+	if err != nil {
+		return fmt.Errorf("failed to connect to FreeSWITCH: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Send(cmd.String()); err != nil {
+		return fmt.Errorf("failed to send %s event: %w", name, err)
+	}
+	return nil
+}
+
+// channelDomain returns the tenant of a running channel, or "" when the
+// channel has none. found is false when there is no such channel.
+func (e *ESLManager) channelDomain(uuid string) (domainUUID string, found bool, err error) {
+	resp, err := e.api("uuid_getvar " + uuid + " domain_uuid")
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "no such channel") {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	domainUUID = strings.TrimSpace(resp.Body)
+	if !isUUID(domainUUID) {
+		domainUUID = ""
+	}
+	return domainUUID, true, nil
+}
+
+// CallDomain returns the tenant of the running call uuid, or "" when it has
+// none. found is false when there is no such call.
+func (e *ESLManager) CallDomain(uuid string) (domainUUID string, found bool, err error) {
+	if !isUUID(uuid) {
+		return "", false, nil
+	}
+	return e.channelDomain(uuid)
+}
+
+// Kill hangs up a channel with the given cause.
+func (e *ESLManager) Kill(uuid, cause string) error {
+	if !isUUID(uuid) {
+		return fmt.Errorf("invalid channel uuid %q", uuid)
+	}
+	if !hangupCausePattern.MatchString(cause) {
+		cause = "NORMAL_CLEARING"
+	}
+	_, err := e.api(fmt.Sprintf("uuid_kill %s %s", uuid, cause))
+	return err
+}
@@ -0,0 +1,421 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrConferenceNotFound       = errors.New("conference not found")
+	ErrConferenceMemberNotFound = errors.New("conference member not found")
+	ErrInvalidConference        = errors.New("invalid conference request")
+)
+
+// Conference actions. Lock and unlock apply to a room; the others to one
+// member of it.
+const (
+	ConferenceMute   = "mute"
+	ConferenceUnmute = "unmute"
+	ConferenceDeaf   = "deaf"
+	ConferenceUndeaf = "undeaf"
+	ConferenceKick   = "kick"
+	ConferenceFloor  = "floor"
+	ConferenceLock   = "lock"
+	ConferenceUnlock = "unlock"
+)
+
+var memberActions = map[string]bool{
+	ConferenceMute:   true,
+	ConferenceUnmute: true,
+	ConferenceDeaf:   true,
+	ConferenceUndeaf: true,
+	ConferenceKick:   true,
+	ConferenceFloor:  true,
+}
+
+// Conference is a running conference room as last seen in the event
+// stream.
+type Conference struct {
+	Name       string
+	UUID       string
+	DomainUUID string
+	Locked     bool
+	Recordings []string // files being recorded, when known
+	Recording  bool
+	Floor      int // member id holding the floor, 0 for none
+	Started    time.Time
+	Members    []ConferenceMember
+}
+
+// ConferenceMember is one caller in a room.
+type ConferenceMember struct {
+	ID             int
+	CallUUID       string
+	DomainUUID     string
+	CallerIDName   string
+	CallerIDNumber string
+	Muted          bool
+	Deaf           bool
+	Talking        bool
+	Moderator      bool
+	Joined         time.Time
+}
+
+// ConferenceManager controls mod_conference rooms through the conference
+// API command. Which rooms are running and who is in them is followed
+// through conference::maintenance events, and rebuilt from
+// "conference json_list" every SyncInterval in case events were missed.
+type ConferenceManager struct {
+	eslMgr *ESLManager
+	config config.ConferenceConfig
+
+	mu    sync.RWMutex
+	rooms map[string]*Conference // conference name -> room
+}
+
+func NewConferenceManager(eslMgr *ESLManager, cfg config.ConferenceConfig) *ConferenceManager {
+	cm := &ConferenceManager{
+		eslMgr: eslMgr,
+		config: cfg,
+		rooms:  make(map[string]*Conference),
+	}
+	eslMgr.Subscribe("conference::maintenance", cm.handleMaintenance)
+	return cm
+}
+
+// Run loads the running rooms and reloads them every SyncInterval.
+func (cm *ConferenceManager) Run() {
+	if err := cm.Sync(); err != nil {
+		log.Printf("Failed to load running conferences: %v", err)
+	}
+
+	ticker := time.NewTicker(cm.config.SyncInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := cm.Sync(); err != nil {
+			log.Printf("Failed to sync conferences: %v", err)
+		}
+	}
+}
+
+// jsonConference is one room of "conference json_list". Flags are only
+// present when set.
+type jsonConference struct {
+	Name      string `json:"conference_name"`
+	UUID      string `json:"conference_uuid"`
+	RunTime   int64  `json:"run_time"`
+	Locked    bool   `json:"locked"`
+	Recording bool   `json:"recording"`
+	Members   []struct {
+		Type           string `json:"type"`
+		ID             int    `json:"id"`
+		UUID           string `json:"uuid"`
+		CallerIDName   string `json:"caller_id_name"`
+		CallerIDNumber string `json:"caller_id_number"`
+		JoinTime       int64  `json:"join_time"`
+		Flags          struct {
+			CanHear     bool `json:"can_hear"`
+			CanSpeak    bool `json:"can_speak"`
+			Talking     bool `json:"talking"`
+			HasFloor    bool `json:"has_floor"`
+			IsModerator bool `json:"is_moderator"`
+		} `json:"flags"`
+	} `json:"members"`
+}
+
+// Sync replaces the rooms with what FreeSWITCH reports. Tenants and
+// recording files are not listed by FreeSWITCH, so they are kept from the
+// events seen so far.
+func (cm *ConferenceManager) Sync() error {
+	resp, err := cm.eslMgr.api("conference json_list")
+	if err != nil {
+		return err
+	}
+	body := strings.TrimSpace(resp.Body)
+	var list []jsonConference
+	if strings.HasPrefix(body, "[") {
+		if err := json.Unmarshal([]byte(body), &list); err != nil {
+			return fmt.Errorf("failed to parse conference list: %w", err)
+		}
+	}
+
+	now := time.Now()
+	rooms := make(map[string]*Conference, len(list))
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	for _, jc := range list {
+		room := &Conference{
+			Name:      jc.Name,
+			UUID:      jc.UUID,
+			Locked:    jc.Locked,
+			Recording: jc.Recording,
+			Started:   now.Add(-time.Duration(jc.RunTime) * time.Second),
+		}
+		known := cm.rooms[jc.Name]
+		if known != nil {
+			room.DomainUUID = known.DomainUUID
+			if room.Recording {
+				room.Recordings = known.Recordings
+			}
+		}
+		for _, jm := range jc.Members {
+			if jm.Type != "caller" {
+				continue
+			}
+			member := ConferenceMember{
+				ID:             jm.ID,
+				CallUUID:       jm.UUID,
+				CallerIDName:   jm.CallerIDName,
+				CallerIDNumber: jm.CallerIDNumber,
+				Muted:          !jm.Flags.CanSpeak,
+				Deaf:           !jm.Flags.CanHear,
+				Talking:        jm.Flags.Talking,
+				Moderator:      jm.Flags.IsModerator,
+				Joined:         now.Add(-time.Duration(jm.JoinTime) * time.Second),
+			}
+			if known != nil {
+				if m := known.member(jm.ID); m != nil {
+					member.DomainUUID = m.DomainUUID
+				}
+			}
+			if jm.Flags.HasFloor {
+				room.Floor = jm.ID
+			}
+			room.Members = append(room.Members, member)
+		}
+		rooms[jc.Name] = room
+	}
+	cm.rooms = rooms
+	return nil
+}
+
+func (cm *ConferenceManager) handleMaintenance(ev *eventsocket.Event) {
+	name := ev.Get("Conference-Name")
+	if name == "" {
+		return
+	}
+	action := ev.Get("Action")
+	memberID, _ := strconv.Atoi(ev.Get("Member-Id"))
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if action == "conference-destroy" {
+		delete(cm.rooms, name)
+		return
+	}
+	room := cm.rooms[name]
+	if room == nil {
+		room = &Conference{Name: name, Started: time.Now()}
+		cm.rooms[name] = room
+	}
+	if room.UUID == "" {
+		room.UUID = ev.Get("Conference-Unique-Id")
+	}
+
+	switch action {
+	case "add-member":
+		domainUUID := ev.Get("Variable_domain_uuid")
+		if !isUUID(domainUUID) {
+			domainUUID = ""
+		}
+		if room.DomainUUID == "" {
+			room.DomainUUID = domainUUID
+		}
+		if room.member(memberID) != nil {
+			return
+		}
+		room.Members = append(room.Members, ConferenceMember{
+			ID:             memberID,
+			CallUUID:       ev.Get("Unique-Id"),
+			DomainUUID:     domainUUID,
+			CallerIDName:   ev.Get("Caller-Caller-Id-Name"),
+			CallerIDNumber: ev.Get("Caller-Caller-Id-Number"),
+			Muted:          ev.Get("Speak") == "false",
+			Deaf:           ev.Get("Hear") == "false",
+			Moderator:      ev.Get("Member-Type") == "moderator",
+			Joined:         time.Now(),
+		})
+		log.Printf("Member %d (%s) joined conference %s", memberID, ev.Get("Caller-Caller-Id-Number"), name)
+
+	case "del-member":
+		for i, m := range room.Members {
+			if m.ID == memberID {
+				room.Members = append(room.Members[:i], room.Members[i+1:]...)
+				break
+			}
+		}
+		if room.Floor == memberID {
+			room.Floor = 0
+		}
+		log.Printf("Member %d (%s) left conference %s", memberID, ev.Get("Caller-Caller-Id-Number"), name)
+
+	case "mute-member", "unmute-member":
+		if m := room.member(memberID); m != nil {
+			m.Muted = action == "mute-member"
+		}
+	case "deaf-member", "undeaf-member":
+		if m := room.member(memberID); m != nil {
+			m.Deaf = action == "deaf-member"
+		}
+	case "start-talking", "stop-talking":
+		if m := room.member(memberID); m != nil {
+			m.Talking = action == "start-talking"
+		}
+	case "floor-change":
+		room.Floor, _ = strconv.Atoi(ev.Get("New-Id"))
+	case "lock", "unlock":
+		room.Locked = action == "lock"
+	case "start-recording":
+		room.Recording = true
+		if p := ev.Get("Path"); p != "" {
+			room.Recordings = append(room.Recordings, p)
+		}
+	case "stop-recording":
+		for i, p := range room.Recordings {
+			if p == ev.Get("Path") {
+				room.Recordings = append(room.Recordings[:i], room.Recordings[i+1:]...)
+				break
+			}
+		}
+		room.Recording = len(room.Recordings) > 0
+	}
+}
+
+func (room *Conference) member(id int) *ConferenceMember {
+	for i := range room.Members {
+		if room.Members[i].ID == id {
+			return &room.Members[i]
+		}
+	}
+	return nil
+}
+
+// List returns the running rooms by name, only those of a tenant when
+// domainUUID is set.
+func (cm *ConferenceManager) List(domainUUID string) []Conference {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	rooms := make([]Conference, 0, len(cm.rooms))
+	for _, room := range cm.rooms {
+		if domainUUID == "" || room.DomainUUID == domainUUID {
+			rooms = append(rooms, room.copy())
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Name < rooms[j].Name })
+	return rooms
+}
+
+// Get returns a running room.
+func (cm *ConferenceManager) Get(name string) (*Conference, error) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	room, ok := cm.rooms[name]
+	if !ok {
+		return nil, ErrConferenceNotFound
+	}
+	c := room.copy()
+	return &c, nil
+}
+
+func (room *Conference) copy() Conference {
+	c := *room
+	c.Members = append([]ConferenceMember(nil), room.Members...)
+	c.Recordings = append([]string(nil), room.Recordings...)
+	return c
+}
+
+// Dial places a call whose destination is a room, so the callee joins it
+// once they answer. The call goes through the same routing as POST /call;
+// req must already have passed the call checks.
+func (cm *ConferenceManager) Dial(req request.CallRequest) (string, error) {
+	if req.Destination == nil || req.Destination.Type != "conference" {
+		return "", fmt.Errorf("%w: the destination must be a conference", ErrInvalidConference)
+	}
+	if !appArgPattern.MatchString(req.Destination.Conference) {
+		return "", fmt.Errorf("%w: invalid conference name %q", ErrInvalidConference, req.Destination.Conference)
+	}
+	return cm.eslMgr.OriginateCall(req)
+}
+
+// ControlMember runs a member action: mute, unmute, deaf, undeaf, kick or
+// floor. The room is updated by the event FreeSWITCH sends back.
+func (cm *ConferenceManager) ControlMember(name string, memberID int, action string) error {
+	if !memberActions[action] {
+		return fmt.Errorf("%w: unsupported member action %q", ErrInvalidConference, action)
+	}
+	if memberID < 1 {
+		return fmt.Errorf("%w: invalid member id %d", ErrInvalidConference, memberID)
+	}
+	_, err := cm.conferenceAPI(name, action, strconv.Itoa(memberID))
+	return err
+}
+
+// Lock keeps new callers out of a room, or lets them in again.
+func (cm *ConferenceManager) Lock(name string, locked bool) error {
+	action := ConferenceUnlock
+	if locked {
+		action = ConferenceLock
+	}
+	_, err := cm.conferenceAPI(name, action)
+	return err
+}
+
+// StartRecording records a room to a new file under RecordDir and returns
+// its path. Names that would put the file anywhere but in their own
+// directory of RecordDir are refused.
+func (cm *ConferenceManager) StartRecording(name string) (string, error) {
+	if !appArgPattern.MatchString(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("%w: invalid conference name %q", ErrInvalidConference, name)
+	}
+	dir := path.Clean(cm.config.RecordDir)
+	file := path.Join(dir, name, time.Now().UTC().Format("20060102-150405")+".wav")
+	if path.Dir(file) != path.Join(dir, name) || path.Dir(path.Dir(file)) != dir {
+		return "", fmt.Errorf("%w: invalid conference name %q", ErrInvalidConference, name)
+	}
+	if _, err := cm.conferenceAPI(name, "record", file); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// StopRecording stops every recording of a room.
+func (cm *ConferenceManager) StopRecording(name string) error {
+	_, err := cm.conferenceAPI(name, "norecord", "all")
+	return err
+}
+
+// conferenceAPI runs "conference <name> <args>". mod_conference reports
+// unknown rooms and members in the reply text rather than as errors.
+func (cm *ConferenceManager) conferenceAPI(name string, args ...string) (string, error) {
+	if !appArgPattern.MatchString(name) {
+		return "", fmt.Errorf("%w: invalid conference name %q", ErrInvalidConference, name)
+	}
+	resp, err := cm.eslMgr.api("conference " + name + " " + strings.Join(args, " "))
+	if err != nil {
+		return "", err
+	}
+
+	reply := strings.TrimSpace(resp.Body)
+	switch {
+	case strings.Contains(reply, "not found"):
+		return "", ErrConferenceNotFound
+	case strings.Contains(reply, "Non-Existant ID"), strings.Contains(reply, "Non-Existent ID"):
+		return "", ErrConferenceMemberNotFound
+	}
+	return reply, nil
+}
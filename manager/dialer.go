@@ -0,0 +1,372 @@
+package manager
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+// minAnswerSamples is the number of recent attempts needed before a power
+// campaign starts over-dialing; until then it dials one call per free agent.
+const minAnswerSamples = 20
+
+// Run paces every running campaign until the process exits.
+func (cm *CampaignManager) Run() {
+	// Contacts left in "dialing" by a previous process will never see their
+	// originate result, so they go back into the retry pool.
+	if _, err := cm.db.Exec(`
+		UPDATE campaign_contacts SET status = $1, next_attempt_at = now(), updated_at = now()
+		WHERE status = $2 AND last_attempt_at < now() - interval '5 minutes'`,
+		ContactRetry, ContactDialing); err != nil {
+		log.Printf("Failed to recover stale campaign contacts: %v", err)
+	}
+
+	ticker := time.NewTicker(cm.config.TickInterval)
+	defer ticker.Stop()
+
+	log.Printf("Campaign dialer started, pacing every %s", cm.config.TickInterval)
+	for range ticker.C {
+		if err := cm.tick(); err != nil {
+			log.Printf("Campaign dialer run failed: %v", err)
+		}
+	}
+}
+
+func (cm *CampaignManager) tick() error {
+	rows, err := cm.db.Query(`SELECT `+campaignColumns+` FROM campaigns WHERE status = $1`, CampaignRunning)
+	if err != nil {
+		return fmt.Errorf("failed to fetch running campaigns: %w", err)
+	}
+	var campaigns []models.Campaign
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		campaigns = append(campaigns, *campaign)
+	}
+	rows.Close()
+	if len(campaigns) == 0 {
+		return rows.Err()
+	}
+
+	channels, err := cm.eslMgr.ListChannels()
+	if err != nil {
+		return fmt.Errorf("failed to list channels: %w", err)
+	}
+	busy := make(map[string]bool, len(channels))
+	for _, ch := range channels {
+		if ext := ch.Extension(); ext != "" {
+			busy[ext] = true
+		}
+	}
+
+	for _, campaign := range campaigns {
+		if err := cm.dialCampaign(campaign, busy); err != nil {
+			log.Printf("Failed to dial campaign %s: %v", campaign.CampaignUUID, err)
+		}
+	}
+	return nil
+}
+
+func (cm *CampaignManager) dialCampaign(c models.Campaign, busy map[string]bool) error {
+	cm.mu.Lock()
+	var free []string
+	for _, agent := range c.Agents {
+		if _, reserved := cm.reserved[agent]; !reserved && !busy[agent] {
+			free = append(free, agent)
+		}
+	}
+	inFlight := cm.inFlight[c.CampaignUUID]
+	cm.mu.Unlock()
+
+	want := len(free)
+	if c.Mode == "power" {
+		ratio, err := cm.dialRatio(c)
+		if err != nil {
+			return err
+		}
+		want = int(math.Floor(float64(len(free))*ratio)) - inFlight
+	}
+	if want <= 0 {
+		return nil
+	}
+
+	contacts, err := cm.claimContacts(c, want)
+	if err != nil {
+		return err
+	}
+
+	for i, contact := range contacts {
+		agent := ""
+		if c.Mode == "progressive" {
+			agent = free[i]
+		}
+
+		cm.mu.Lock()
+		if agent != "" {
+			cm.reserved[agent] = contact.ContactUUID
+		}
+		cm.inFlight[c.CampaignUUID]++
+		cm.mu.Unlock()
+
+		go cm.dial(c, contact, agent)
+	}
+	return nil
+}
+
+// dialRatio returns how many calls a power campaign places per free agent:
+// the inverse of the recent answer rate, capped at the campaign maximum.
+func (cm *CampaignManager) dialRatio(c models.Campaign) (float64, error) {
+	var attempted, answered int
+	err := cm.db.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE status IN ($2, $3))
+		FROM campaign_contacts
+		WHERE campaign_uuid = $1 AND attempts > 0 AND status <> $4
+			AND last_attempt_at > now() - interval '1 hour'`,
+		c.CampaignUUID, ContactAnswered, ContactCompleted, ContactDialing).Scan(&attempted, &answered)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute answer rate: %w", err)
+	}
+
+	if attempted < minAnswerSamples || answered == 0 {
+		return 1, nil
+	}
+	ratio := float64(attempted) / float64(answered)
+	return math.Max(1, math.Min(ratio, c.MaxDialRatio)), nil
+}
+
+// claimContacts locks up to n dialable contacts and marks them as dialing.
+// Contacts outside their calling window are pushed to the next window
+// opening instead.
+func (cm *CampaignManager) claimContacts(c models.Campaign, n int) ([]models.CampaignContact, error) {
+	tx, err := cm.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT `+contactColumns+` FROM campaign_contacts
+		WHERE campaign_uuid = $1 AND status IN ($2, $3) AND (next_attempt_at IS NULL OR next_attempt_at <= now())
+		ORDER BY next_attempt_at NULLS FIRST, created_at
+		LIMIT $4
+		FOR UPDATE SKIP LOCKED`, c.CampaignUUID, ContactPending, ContactRetry, n*4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim contacts: %w", err)
+	}
+	var candidates []models.CampaignContact
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, *contact)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read contacts: %w", err)
+	}
+
+	now := time.Now()
+	var claimed []models.CampaignContact
+	for _, contact := range candidates {
+		tz := contact.Timezone
+		if tz == "" {
+			tz = c.Timezone
+		}
+		open, nextOpen, err := callingWindow(c.WindowStart, c.WindowEnd, tz, now)
+		if err != nil {
+			log.Printf("Contact %s has an invalid calling window: %v", contact.ContactUUID, err)
+			continue
+		}
+
+		if !open {
+			if _, err := tx.Exec(`UPDATE campaign_contacts SET next_attempt_at = $2, updated_at = now()
+				WHERE contact_uuid = $1`, contact.ContactUUID, nextOpen); err != nil {
+				return nil, fmt.Errorf("failed to defer contact: %w", err)
+			}
+			continue
+		}
+		if len(claimed) == n {
+			continue
+		}
+
+		contact.Status = ContactDialing
+		contact.Attempts++
+		contact.LastCallUUID = newUUID()
+		if _, err := tx.Exec(`
+			UPDATE campaign_contacts
+			SET status = $2, attempts = $3, last_call_uuid = $4, last_attempt_at = now(), updated_at = now()
+			WHERE contact_uuid = $1`,
+			contact.ContactUUID, contact.Status, contact.Attempts, contact.LastCallUUID); err != nil {
+			return nil, fmt.Errorf("failed to claim contact: %w", err)
+		}
+		claimed = append(claimed, contact)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit claimed contacts: %w", err)
+	}
+	return claimed, nil
+}
+
+func (cm *CampaignManager) dial(c models.Campaign, contact models.CampaignContact, agent string) {
+	defer func() {
+		cm.mu.Lock()
+		if agent != "" {
+			delete(cm.reserved, agent)
+		}
+		cm.inFlight[c.CampaignUUID]--
+		cm.mu.Unlock()
+	}()
+
+	vars := make(map[string]string)
+	if err := json.Unmarshal(contact.Variables, &vars); err != nil {
+		log.Printf("Contact %s has invalid variables: %v", contact.ContactUUID, err)
+	}
+
+	req := request.CallRequest{
+		DomainUUID:       c.DomainUUID.String,
+		Caller:           contact.PhoneNumber,
+		CallerIDName:     c.CallerIDName,
+		CallerIDNumber:   c.CallerIDNumber,
+		Timeout:          c.DialTimeout,
+		IgnoreEarlyMedia: true,
+		Variables:        vars,
+		SystemVariables: map[string]string{
+			"origination_uuid":      contact.LastCallUUID,
+			"campaign_uuid":         c.CampaignUUID,
+			"campaign_contact_uuid": contact.ContactUUID,
+		},
+	}
+	if agent != "" {
+		req.Destination = &request.CallDestination{Type: "user", User: agent}
+	} else if err := json.Unmarshal(c.Destination, &req.Destination); err != nil {
+		log.Printf("Campaign %s has an invalid destination: %v", c.CampaignUUID, err)
+	}
+	if len(c.AMD) > 0 {
+		if err := json.Unmarshal(c.AMD, &req.AMD); err != nil {
+			log.Printf("Campaign %s has an invalid AMD setting: %v", c.CampaignUUID, err)
+		}
+	}
+
+	var callID string
+	err := cm.checks.Check(&req)
+	if err == nil {
+		callID, err = cm.eslMgr.OriginateCall(req)
+	}
+	if err == nil {
+		// The agent stays reserved until detection decides whether the
+		// contact is bridged to them. A machine may hang up first, so the
+		// status is only moved on from dialing.
+		var amdResult string
+		if req.AMD != nil {
+			if result, err := cm.eslMgr.amd.wait(callID); err == nil {
+				amdResult = result.Result
+			}
+		}
+		if _, err := cm.db.Exec(`
+			UPDATE campaign_contacts
+			SET status = CASE WHEN status = $4 THEN $2 ELSE status END, last_agent = $3, amd_result = $5,
+				last_call_uuid = $6, updated_at = now()
+			WHERE contact_uuid = $1`,
+			contact.ContactUUID, ContactAnswered, agent, ContactDialing, amdResult, callID); err != nil {
+			log.Printf("Failed to record answered contact %s: %v", contact.ContactUUID, err)
+		}
+		return
+	}
+
+	cause := HangupCause(err)
+	if refused := CheckCause(err); refused != "" {
+		cause = refused
+	}
+	if cause == "" {
+		log.Printf("Campaign %s failed to dial contact %s: %v", c.CampaignUUID, contact.ContactUUID, err)
+		cause = "ORIGINATE_ERROR"
+	}
+
+	status := ContactFailed
+	var nextAttempt sql.NullTime
+	if contact.Attempts < c.MaxAttempts && (cause == "ORIGINATE_ERROR" || containsString(c.RetryCauses, cause)) {
+		status = ContactRetry
+		nextAttempt = sql.NullTime{Time: time.Now().Add(time.Duration(c.RetryDelaySeconds) * time.Second), Valid: true}
+	}
+
+	if _, err := cm.db.Exec(`
+		UPDATE campaign_contacts SET status = $2, last_hangup_cause = $3, next_attempt_at = $4, updated_at = now()
+		WHERE contact_uuid = $1`, contact.ContactUUID, status, cause, nextAttempt); err != nil {
+		log.Printf("Failed to record failed contact %s: %v", contact.ContactUUID, err)
+	}
+}
+
+// handleHangup records the outcome of answered campaign calls. Calls that
+// were never answered are handled by dial from the originate result.
+func (cm *CampaignManager) handleHangup(ev *eventsocket.Event) {
+	if ev.Get("Variable_campaign_contact_uuid") == "" {
+		return
+	}
+
+	billSec, _ := strconv.Atoi(ev.Get("Variable_billsec"))
+	if _, err := cm.db.Exec(`
+		UPDATE campaign_contacts SET status = $2, billsec = $3, last_hangup_cause = $4, updated_at = now()
+		WHERE last_call_uuid = $1 AND status IN ($5, $6)`,
+		ev.Get("Unique-Id"), ContactCompleted, billSec, ev.Get("Hangup-Cause"), ContactAnswered, ContactDialing); err != nil {
+		log.Printf("Failed to record campaign call outcome: %v", err)
+	}
+}
+
+// callingWindow reports whether now falls inside the daily [start, end)
+// window in the given timezone, and when the window next opens. Windows
+// where end is before start wrap past midnight.
+func callingWindow(start, end, timezone string, now time.Time) (bool, time.Time, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("unknown timezone %q", timezone)
+	}
+	startAt, err := time.Parse("15:04", start)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("invalid window start %q", start)
+	}
+	endAt, err := time.Parse("15:04", end)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("invalid window end %q", end)
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	startMin := startAt.Hour()*60 + startAt.Minute()
+	endMin := endAt.Hour()*60 + endAt.Minute()
+
+	var open bool
+	if startMin <= endMin {
+		open = minute >= startMin && minute < endMin
+	} else {
+		open = minute >= startMin || minute < endMin
+	}
+
+	nextOpen := time.Date(local.Year(), local.Month(), local.Day(), startAt.Hour(), startAt.Minute(), 0, 0, loc)
+	if !nextOpen.After(local) {
+		nextOpen = nextOpen.AddDate(0, 0, 1)
+	}
+	return open, nextOpen, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,358 @@
+package manager
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/number"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrDNCEntryNotFound = errors.New("do-not-call entry not found")
+	ErrInvalidDNCEntry  = errors.New("invalid do-not-call entry")
+)
+
+// BlockedError is returned when a call would dial a number on a
+// do-not-call list.
+type BlockedError struct {
+	Number    string
+	EntryUUID string
+	Reason    string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("number %s is on the do-not-call list", e.Number)
+}
+
+const dncColumns = `dnc_entry_uuid, domain_uuid, match_type, pattern, reason, created_at`
+
+const dncAuditColumns = `dnc_audit_uuid, domain_uuid, caller, callee, number, dnc_entry_uuid, reason, created_at`
+
+// DNCManager stores the do-not-call and blocklist entries that are checked
+// before any call is originated. Entries can match a number exactly, by
+// prefix (fraud ranges) or by Postgres regular expression.
+type DNCManager struct {
+	db      *sql.DB
+	numbers *NumberManager
+}
+
+func NewDNCManager(db *sql.DB, numbers *NumberManager) *DNCManager {
+	return &DNCManager{
+		db:      db,
+		numbers: numbers,
+	}
+}
+
+// CheckCall returns a *BlockedError if any number the request would dial is
+// blocked for its tenant, after writing the attempt to the audit log.
+func (d *DNCManager) CheckCall(req request.CallRequest) error {
+	for _, num := range dialedNumbers(req) {
+		entry, err := d.Match(req.DomainUUID, num)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			continue
+		}
+
+		reason := entry.Reason
+		if reason == "" {
+			reason = fmt.Sprintf("matched %s entry %s", entry.MatchType, entry.Pattern)
+		}
+		if _, err := d.db.Exec(`
+			INSERT INTO dnc_audit (dnc_audit_uuid, domain_uuid, caller, callee, number, dnc_entry_uuid, reason)
+			VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, $7)`,
+			newUUID(), req.DomainUUID, req.Caller, req.Callee, num, entry.DNCEntryUUID, reason); err != nil {
+			log.Printf("Failed to audit blocked call to %s: %v", num, err)
+		}
+
+		return &BlockedError{Number: num, EntryUUID: entry.DNCEntryUUID, Reason: reason}
+	}
+	return nil
+}
+
+// Match returns the entry blocking num for the tenant, or nil. The number
+// is matched in every spelling it normalizes to, so an entry stored as
+// E.164 also blocks the national form. Tenant entries take precedence over
+// global ones.
+func (d *DNCManager) Match(domainUUID, num string) (*models.DNCEntry, error) {
+	variants, err := d.numbers.Variants(domainUUID, num)
+	if err != nil {
+		return nil, err
+	}
+
+	row := d.db.QueryRow(`
+		SELECT `+dncColumns+` FROM dnc_entries
+		WHERE (domain_uuid IS NULL OR domain_uuid = NULLIF($1, '')::uuid)
+			AND ((match_type = 'exact' AND pattern = ANY($2))
+				OR (match_type = 'prefix' AND EXISTS (SELECT 1 FROM unnest($2::text[]) v WHERE starts_with(v, pattern)))
+				OR (match_type = 'regex' AND EXISTS (SELECT 1 FROM unnest($2::text[]) v WHERE v ~ pattern)))
+		ORDER BY domain_uuid NULLS LAST, match_type
+		LIMIT 1`, domainUUID, pq.Array(variants))
+
+	entry, err := scanDNCEntry(row)
+	if errors.Is(err, ErrDNCEntryNotFound) {
+		return nil, nil
+	}
+	return entry, err
+}
+
+func (d *DNCManager) Create(req request.DNCEntryRequest) (*models.DNCEntry, error) {
+	if err := d.normalize(&req); err != nil {
+		return nil, err
+	}
+
+	row := d.db.QueryRow(`
+		INSERT INTO dnc_entries (dnc_entry_uuid, domain_uuid, match_type, pattern, reason)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5)
+		ON CONFLICT ((COALESCE(domain_uuid, '00000000-0000-0000-0000-000000000000')), match_type, pattern)
+		DO UPDATE SET reason = EXCLUDED.reason
+		RETURNING `+dncColumns,
+		newUUID(), req.DomainUUID, req.MatchType, req.Pattern, req.Reason)
+
+	return scanDNCEntry(row)
+}
+
+func (d *DNCManager) Get(id string) (*models.DNCEntry, error) {
+	if !isUUID(id) {
+		return nil, ErrDNCEntryNotFound
+	}
+	row := d.db.QueryRow(`SELECT `+dncColumns+` FROM dnc_entries WHERE dnc_entry_uuid = $1`, id)
+	return scanDNCEntry(row)
+}
+
+// List returns entries visible to domainUUID (its own plus global ones), or
+// every entry when domainUUID is empty. search filters on the pattern.
+func (d *DNCManager) List(domainUUID, search string, limit, offset int) ([]models.DNCEntry, int, error) {
+	where := `WHERE (NULLIF($1, '') IS NULL OR domain_uuid IS NULL OR domain_uuid = NULLIF($1, '')::uuid)
+		AND ($2 = '' OR pattern LIKE '%' || $2 || '%')`
+
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM dnc_entries `+where, domainUUID, search).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count do-not-call entries: %w", err)
+	}
+
+	rows, err := d.db.Query(`SELECT `+dncColumns+` FROM dnc_entries `+where+`
+		ORDER BY created_at DESC LIMIT $3 OFFSET $4`, domainUUID, search, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch do-not-call entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.DNCEntry
+	for rows.Next() {
+		entry, err := scanDNCEntry(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read do-not-call entries: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+func (d *DNCManager) Update(id string, req request.DNCEntryRequest) (*models.DNCEntry, error) {
+	if !isUUID(id) {
+		return nil, ErrDNCEntryNotFound
+	}
+	if err := d.normalize(&req); err != nil {
+		return nil, err
+	}
+
+	row := d.db.QueryRow(`
+		UPDATE dnc_entries SET domain_uuid = NULLIF($2, '')::uuid, match_type = $3, pattern = $4, reason = $5
+		WHERE dnc_entry_uuid = $1
+		RETURNING `+dncColumns,
+		id, req.DomainUUID, req.MatchType, req.Pattern, req.Reason)
+
+	return scanDNCEntry(row)
+}
+
+func (d *DNCManager) Delete(id string) error {
+	if !isUUID(id) {
+		return ErrDNCEntryNotFound
+	}
+	res, err := d.db.Exec(`DELETE FROM dnc_entries WHERE dnc_entry_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete do-not-call entry: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrDNCEntryNotFound
+	}
+	return nil
+}
+
+// Import bulk-loads entries from a CSV with a pattern column and optional
+// match_type and reason columns. Rows are applied in one transaction and
+// existing entries only have their reason updated.
+func (d *DNCManager) Import(domainUUID string, r io.Reader) (*ImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read CSV header: %v", ErrInvalidDNCEntry, err)
+	}
+	patternCol, typeCol, reasonCol := -1, -1, -1
+	for i, col := range header {
+		switch strings.ToLower(strings.TrimSpace(col)) {
+		case "pattern", "number", "phone_number":
+			patternCol = i
+		case "match_type", "type":
+			typeCol = i
+		case "reason":
+			reasonCol = i
+		}
+	}
+	if patternCol < 0 {
+		return nil, fmt.Errorf("%w: CSV header has no pattern column", ErrInvalidDNCEntry)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result := &ImportResult{Rejected: []ImportReject{}}
+	line := 1
+	for {
+		record, err := reader.Read()
+		line++
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.Rejected = append(result.Rejected, ImportReject{Line: line, Reason: err.Error()})
+			continue
+		}
+
+		req := request.DNCEntryRequest{
+			DomainUUID: domainUUID,
+			MatchType:  field(record, typeCol),
+			Pattern:    field(record, patternCol),
+			Reason:     field(record, reasonCol),
+		}
+		if err := d.normalize(&req); err != nil {
+			result.Rejected = append(result.Rejected, ImportReject{Line: line, Reason: err.Error()})
+			continue
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO dnc_entries (dnc_entry_uuid, domain_uuid, match_type, pattern, reason)
+			VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5)
+			ON CONFLICT ((COALESCE(domain_uuid, '00000000-0000-0000-0000-000000000000')), match_type, pattern)
+			DO UPDATE SET reason = EXCLUDED.reason`,
+			newUUID(), req.DomainUUID, req.MatchType, req.Pattern, req.Reason); err != nil {
+			return nil, fmt.Errorf("failed to import entry on line %d: %w", line, err)
+		}
+		result.Imported++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit do-not-call import: %w", err)
+	}
+
+	log.Printf("Imported %d do-not-call entries (%d rejected)", result.Imported, len(result.Rejected))
+	return result, nil
+}
+
+func (d *DNCManager) ListAudit(domainUUID string, limit, offset int) ([]models.DNCAudit, int, error) {
+	where := `WHERE NULLIF($1, '') IS NULL OR domain_uuid = NULLIF($1, '')::uuid`
+
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM dnc_audit `+where, domainUUID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count blocked calls: %w", err)
+	}
+
+	rows, err := d.db.Query(`SELECT `+dncAuditColumns+` FROM dnc_audit `+where+`
+		ORDER BY created_at DESC LIMIT $2 OFFSET $3`, domainUUID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch blocked calls: %w", err)
+	}
+	defer rows.Close()
+
+	var audits []models.DNCAudit
+	for rows.Next() {
+		var a models.DNCAudit
+		if err := rows.Scan(&a.DNCAuditUUID, &a.DomainUUID, &a.Caller, &a.Callee, &a.Number,
+			&a.DNCEntryUUID, &a.Reason, &a.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan blocked call: %w", err)
+		}
+		audits = append(audits, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read blocked calls: %w", err)
+	}
+
+	return audits, total, nil
+}
+
+// normalize validates an entry and cleans exact and prefix patterns the same
+// way dialed numbers are cleaned before matching. Regex patterns are checked
+// by Postgres so they cannot break later lookups.
+func (d *DNCManager) normalize(req *request.DNCEntryRequest) error {
+	if req.DomainUUID != "" && !isUUID(req.DomainUUID) {
+		return fmt.Errorf("%w: invalid domain_uuid", ErrInvalidDNCEntry)
+	}
+	if req.MatchType == "" {
+		req.MatchType = "exact"
+	}
+
+	switch req.MatchType {
+	case "exact", "prefix":
+		req.Pattern = number.Clean(req.Pattern)
+		if !dialTokenPattern.MatchString(req.Pattern) {
+			return fmt.Errorf("%w: invalid number pattern %q", ErrInvalidDNCEntry, req.Pattern)
+		}
+	case "regex":
+		var ok bool
+		if req.Pattern == "" || d.db.QueryRow(`SELECT '' ~ $1`, req.Pattern).Scan(&ok) != nil {
+			return fmt.Errorf("%w: invalid regular expression %q", ErrInvalidDNCEntry, req.Pattern)
+		}
+	default:
+		return fmt.Errorf("%w: unknown match_type %q", ErrInvalidDNCEntry, req.MatchType)
+	}
+	return nil
+}
+
+// dialedNumbers lists every number a call request would ring.
+func dialedNumbers(req request.CallRequest) []string {
+	numbers := []string{req.Caller}
+	if req.Callee != "" {
+		numbers = append(numbers, req.Callee)
+	}
+	if dest := req.Destination; dest != nil {
+		switch dest.Type {
+		case "user":
+			numbers = append(numbers, dest.User)
+		case "extension":
+			numbers = append(numbers, dest.Extension)
+		}
+	}
+	return numbers
+}
+
+func scanDNCEntry(row rowScanner) (*models.DNCEntry, error) {
+	var entry models.DNCEntry
+	err := row.Scan(&entry.DNCEntryUUID, &entry.DomainUUID, &entry.MatchType, &entry.Pattern, &entry.Reason, &entry.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDNCEntryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan do-not-call entry: %w", err)
+	}
+	return &entry, nil
+}
@@ -0,0 +1,336 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+)
+
+var (
+	ErrDTMFCallNotFound = errors.New("call not found")
+	ErrInvalidDTMF      = errors.New("invalid DTMF request")
+)
+
+// Digits uuid_send_dtmf accepts: tones, plus w and W for half and one
+// second pauses.
+var dtmfPattern = regexp.MustCompile(`^[0-9A-Da-d*#wW]{1,128}$`)
+
+// DTMFEvent is one digit received on a call. It is also the JSON body
+// posted to the DTMF webhook.
+type DTMFEvent struct {
+	CallUUID     string    `json:"call_uuid"`
+	DomainUUID   string    `json:"domain_uuid"`
+	Digit        string    `json:"digit"`
+	DurationMS   int       `json:"duration_ms"`
+	Source       string    `json:"source"`
+	Seq          int       `json:"seq"`
+	DigitsDialed string    `json:"digits_dialed"`
+	Time         time.Time `json:"time"`
+}
+
+// CallDigits is what a call has received so far. DigitsDialed matches what
+// FreeSWITCH writes to digits_dialed in the CDR once the call ends.
+type CallDigits struct {
+	CallUUID     string
+	DomainUUID   string
+	DigitsDialed string
+	Events       []DTMFEvent
+	Ended        bool
+}
+
+type dtmfCall struct {
+	domainUUID string
+	events     []DTMFEvent
+	sequences  []int64
+	ended      time.Time
+}
+
+type dtmfSub struct {
+	callUUID, domainUUID string
+	ch                   chan DTMFEvent
+}
+
+// DTMFManager sends digits into calls and collects the digits callers
+// press from DTMF events, pushing each one to the webhook and to
+// subscribers.
+type DTMFManager struct {
+	eslMgr *ESLManager
+	config config.DTMFConfig
+	client *http.Client
+
+	mu    sync.Mutex
+	calls map[string]*dtmfCall
+	subs  map[*dtmfSub]bool
+}
+
+func NewDTMFManager(eslMgr *ESLManager, cfg config.DTMFConfig) *DTMFManager {
+	dm := &DTMFManager{
+		eslMgr: eslMgr,
+		config: cfg,
+		client: &http.Client{Timeout: cfg.WebhookTimeout},
+		calls:  make(map[string]*dtmfCall),
+		subs:   make(map[*dtmfSub]bool),
+	}
+	eslMgr.Subscribe("DTMF", dm.handleDTMF)
+	eslMgr.Subscribe("CHANNEL_HANGUP_COMPLETE", dm.handleHangup)
+	return dm
+}
+
+// Run forgets the digits of calls that ended more than Retention ago.
+func (dm *DTMFManager) Run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		dm.mu.Lock()
+		for callUUID, call := range dm.calls {
+			if !call.ended.IsZero() && time.Since(call.ended) > dm.config.Retention {
+				delete(dm.calls, callUUID)
+			}
+		}
+		dm.mu.Unlock()
+	}
+}
+
+// Send plays digits into a call. durationMS sets the length of each tone;
+// zero keeps the FreeSWITCH default.
+func (dm *DTMFManager) Send(callUUID, digits string, durationMS int) error {
+	if !isUUID(callUUID) {
+		return ErrDTMFCallNotFound
+	}
+	if !dtmfPattern.MatchString(digits) {
+		return fmt.Errorf("%w: digits may only contain 0-9, A-D, *, # and the pauses w and W", ErrInvalidDTMF)
+	}
+	arg := digits
+	if durationMS != 0 {
+		if durationMS < 40 || durationMS > 2000 {
+			return fmt.Errorf("%w: duration_ms must be between 40 and 2000", ErrInvalidDTMF)
+		}
+		arg += "@" + strconv.Itoa(durationMS)
+	}
+
+	_, err := dm.eslMgr.api(fmt.Sprintf("uuid_send_dtmf %s %s", callUUID, arg))
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "no such channel") {
+		return ErrDTMFCallNotFound
+	}
+	return err
+}
+
+// Digits returns the digits a call has received. A live call that has not
+// received any yet returns an empty result.
+func (dm *DTMFManager) Digits(callUUID string) (*CallDigits, error) {
+	if !isUUID(callUUID) {
+		return nil, ErrDTMFCallNotFound
+	}
+
+	dm.mu.Lock()
+	call := dm.calls[callUUID]
+	if call != nil {
+		digits := &CallDigits{
+			CallUUID:   callUUID,
+			DomainUUID: call.domainUUID,
+			Events:     append([]DTMFEvent(nil), call.events...),
+			Ended:      !call.ended.IsZero(),
+		}
+		if n := len(call.events); n > 0 {
+			digits.DigitsDialed = call.events[n-1].DigitsDialed
+		}
+		dm.mu.Unlock()
+		return digits, nil
+	}
+	dm.mu.Unlock()
+
+	resp, err := dm.eslMgr.api("uuid_exists " + callUUID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(resp.Body) != "true" {
+		return nil, ErrDTMFCallNotFound
+	}
+	domainUUID, err := dm.callDomain(callUUID)
+	if err != nil {
+		return nil, err
+	}
+	return &CallDigits{CallUUID: callUUID, DomainUUID: domainUUID}, nil
+}
+
+// Subscribe returns a channel receiving the digits of one call, or of every
+// call of a tenant, and a function that ends the subscription. The channel
+// of a call is closed when the call ends. Digits a slow reader cannot take
+// are dropped.
+func (dm *DTMFManager) Subscribe(callUUID, domainUUID string) (<-chan DTMFEvent, func(), error) {
+	if (callUUID == "") == (domainUUID == "") {
+		return nil, nil, fmt.Errorf("%w: subscribe to either a call or a domain_uuid", ErrInvalidDTMF)
+	}
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, nil, ErrInvalidDomain
+	}
+	if callUUID != "" {
+		digits, err := dm.Digits(callUUID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if digits.Ended {
+			return nil, nil, ErrDTMFCallNotFound
+		}
+	}
+
+	sub := &dtmfSub{callUUID: callUUID, domainUUID: domainUUID, ch: make(chan DTMFEvent, 64)}
+	dm.mu.Lock()
+	dm.subs[sub] = true
+	dm.mu.Unlock()
+
+	return sub.ch, func() {
+		dm.mu.Lock()
+		delete(dm.subs, sub)
+		dm.mu.Unlock()
+	}, nil
+}
+
+func (dm *DTMFManager) handleDTMF(ev *eventsocket.Event) {
+	callUUID := ev.Get("Unique-Id")
+	digit := ev.Get("Dtmf-Digit")
+	if callUUID == "" || digit == "" {
+		return
+	}
+	seq, _ := strconv.ParseInt(ev.Get("Event-Sequence"), 10, 64)
+
+	// DTMF events only carry channel variables with verbose_events on.
+	domainUUID := ev.Get("Variable_domain_uuid")
+	dm.mu.Lock()
+	known := dm.calls[callUUID]
+	dm.mu.Unlock()
+	if known != nil {
+		domainUUID = known.domainUUID
+	} else if !isUUID(domainUUID) {
+		var err error
+		if domainUUID, err = dm.callDomain(callUUID); err != nil {
+			log.Printf("Failed to look up domain of call %s: %v", callUUID, err)
+		}
+	}
+
+	event := DTMFEvent{
+		CallUUID:   callUUID,
+		DomainUUID: domainUUID,
+		Digit:      digit,
+		DurationMS: dtmfDurationMS(ev),
+		Source:     ev.Get("Dtmf-Source"),
+		Time:       eventTime(ev),
+	}
+
+	dm.mu.Lock()
+	call := dm.calls[callUUID]
+	if call == nil {
+		call = &dtmfCall{domainUUID: domainUUID}
+		dm.calls[callUUID] = call
+	}
+	// Handlers run concurrently, so digits are put in order by the event
+	// sequence rather than by arrival.
+	i := sort.Search(len(call.sequences), func(i int) bool { return call.sequences[i] > seq })
+	call.sequences = append(call.sequences[:i], append([]int64{seq}, call.sequences[i:]...)...)
+	call.events = append(call.events[:i], append([]DTMFEvent{event}, call.events[i:]...)...)
+	var dialed strings.Builder
+	for j := range call.events {
+		dialed.WriteString(call.events[j].Digit)
+		call.events[j].Seq = j + 1
+		call.events[j].DigitsDialed = dialed.String()
+	}
+	event = call.events[i]
+
+	for sub := range dm.subs {
+		if sub.callUUID != callUUID && (sub.domainUUID == "" || sub.domainUUID != domainUUID) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+	dm.mu.Unlock()
+
+	dm.sendWebhook(event)
+}
+
+func (dm *DTMFManager) handleHangup(ev *eventsocket.Event) {
+	callUUID := ev.Get("Unique-Id")
+
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	if call := dm.calls[callUUID]; call != nil {
+		call.ended = time.Now()
+	}
+	for sub := range dm.subs {
+		if sub.callUUID == callUUID {
+			delete(dm.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// sendWebhook posts a digit to the webhook. Without one it is only kept
+// for the API.
+func (dm *DTMFManager) sendWebhook(event DTMFEvent) {
+	if dm.config.WebhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode DTMF event: %v", err)
+		return
+	}
+
+	resp, err := dm.client.Post(dm.config.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to send DTMF of call %s: %v", event.CallUUID, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("DTMF of call %s was rejected with status %d", event.CallUUID, resp.StatusCode)
+	}
+}
+
+// callDomain returns the tenant of a running call.
+func (dm *DTMFManager) callDomain(callUUID string) (string, error) {
+	domainUUID, found, err := dm.eslMgr.channelDomain(callUUID)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", ErrDTMFCallNotFound
+	}
+	return domainUUID, nil
+}
+
+// dtmfDurationMS converts the tone length, given in samples, to
+// milliseconds.
+func dtmfDurationMS(ev *eventsocket.Event) int {
+	samples, _ := strconv.Atoi(ev.Get("Dtmf-Duration"))
+	rate, _ := strconv.Atoi(ev.Get("Channel-Read-Codec-Rate"))
+	if rate <= 0 {
+		rate = 8000
+	}
+	return samples * 1000 / rate
+}
+
+// eventTime returns when FreeSWITCH fired ev, or now when it does not say.
+func eventTime(ev *eventsocket.Event) time.Time {
+	micros, err := strconv.ParseInt(ev.Get("Event-Date-Timestamp"), 10, 64)
+	if err != nil || micros <= 0 {
+		return time.Now()
+	}
+	return time.UnixMicro(micros)
+}
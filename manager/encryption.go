@@ -0,0 +1,288 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/vishaltalsaniya-7/voip-api/envelope"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+)
+
+var (
+	ErrEncryptionDisabled = errors.New("recording encryption is not configured")
+	ErrDataKeyNotFound    = errors.New("recording data key not found")
+)
+
+const recordingKeyColumns = `k.key_uuid, k.domain_uuid, k.master_key_id, k.active,
+	(SELECT COUNT(*) FROM recording_objects o WHERE o.encryption_key_uuid = k.key_uuid),
+	k.created_at, k.rewrapped_at, k.retired_at`
+
+// EncryptionManager keeps the per-tenant data keys that recordings are
+// encrypted with. Data keys are stored wrapped by the current master key;
+// unwrapped keys are only held in memory.
+type EncryptionManager struct {
+	db      *sql.DB
+	keyring *envelope.Keyring
+
+	mu   sync.Mutex
+	keys map[string][]byte // key uuid -> data key
+}
+
+// NewEncryptionManager takes the master keys, or nil to leave recordings
+// unencrypted.
+func NewEncryptionManager(db *sql.DB, keyring *envelope.Keyring) *EncryptionManager {
+	return &EncryptionManager{
+		db:      db,
+		keyring: keyring,
+		keys:    make(map[string][]byte),
+	}
+}
+
+// Enabled reports whether new recordings are encrypted.
+func (em *EncryptionManager) Enabled() bool {
+	return em.keyring != nil
+}
+
+// DataKey returns the active data key of a tenant, creating it on first
+// use.
+func (em *EncryptionManager) DataKey(domainUUID string) (string, []byte, error) {
+	if !em.Enabled() {
+		return "", nil, ErrEncryptionDisabled
+	}
+
+	var id string
+	err := em.db.QueryRow(`
+		SELECT key_uuid FROM recording_keys
+		WHERE active AND domain_uuid IS NOT DISTINCT FROM NULLIF($1, '')::uuid`, domainUUID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := em.createKey(em.db, domainUUID); err != nil {
+			return "", nil, err
+		}
+		// Another request may have created the key first; either way there
+		// is exactly one active key now.
+		err = em.db.QueryRow(`
+			SELECT key_uuid FROM recording_keys
+			WHERE active AND domain_uuid IS NOT DISTINCT FROM NULLIF($1, '')::uuid`, domainUUID).Scan(&id)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to fetch data key: %w", err)
+	}
+
+	key, err := em.Key(id)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, key, nil
+}
+
+// Key returns the data key id, unwrapped with the master key it is stored
+// under.
+func (em *EncryptionManager) Key(id string) ([]byte, error) {
+	if !em.Enabled() {
+		return nil, ErrEncryptionDisabled
+	}
+	em.mu.Lock()
+	key, ok := em.keys[id]
+	em.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !isUUID(id) {
+		return nil, ErrDataKeyNotFound
+	}
+
+	var domainUUID sql.NullString
+	var masterID string
+	var wrapped []byte
+	err := em.db.QueryRow(`SELECT domain_uuid, master_key_id, wrapped_key FROM recording_keys WHERE key_uuid = $1`, id).
+		Scan(&domainUUID, &masterID, &wrapped)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDataKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch data key: %w", err)
+	}
+
+	key, err = em.keyring.Unwrap(masterID, wrapped, dataKeyAAD(id, domainUUID.String))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key %s: %w", id, err)
+	}
+	em.mu.Lock()
+	em.keys[id] = key
+	em.mu.Unlock()
+	return key, nil
+}
+
+// execer is what createKey needs from *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// createKey generates a data key for a tenant and stores it wrapped. It
+// does nothing when the tenant already has an active key.
+func (em *EncryptionManager) createKey(db execer, domainUUID string) (string, error) {
+	key, err := envelope.NewDataKey()
+	if err != nil {
+		return "", err
+	}
+	id := newUUID()
+	masterID, wrapped, err := em.keyring.Wrap(key, dataKeyAAD(id, domainUUID))
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	if _, err := db.Exec(`
+		INSERT INTO recording_keys (key_uuid, domain_uuid, master_key_id, wrapped_key)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4)
+		ON CONFLICT ((COALESCE(domain_uuid, '00000000-0000-0000-0000-000000000000'))) WHERE active DO NOTHING`,
+		id, domainUUID, masterID, wrapped); err != nil {
+		return "", fmt.Errorf("failed to store data key: %w", err)
+	}
+	return id, nil
+}
+
+// Rotate retires the active data key of a tenant and creates a new one for
+// the recordings stored from now on. Recordings keep the key they were
+// encrypted with.
+func (em *EncryptionManager) Rotate(domainUUID string) (*models.RecordingKey, error) {
+	if !em.Enabled() {
+		return nil, ErrEncryptionDisabled
+	}
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, ErrInvalidDomain
+	}
+
+	tx, err := em.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		UPDATE recording_keys SET active = false, retired_at = now()
+		WHERE active AND domain_uuid IS NOT DISTINCT FROM NULLIF($1, '')::uuid`, domainUUID); err != nil {
+		return nil, fmt.Errorf("failed to retire data key: %w", err)
+	}
+	id, err := em.createKey(tx, domainUUID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit data key rotation: %w", err)
+	}
+
+	return em.getKey(id)
+}
+
+// Rewrap wraps every data key that is stored under an older master key
+// with the current one, and returns how many it re-wrapped. The recordings
+// themselves are untouched. Once it returns without error, older master
+// keys can be removed from the configuration.
+func (em *EncryptionManager) Rewrap() (int, error) {
+	if !em.Enabled() {
+		return 0, ErrEncryptionDisabled
+	}
+
+	rows, err := em.db.Query(`
+		SELECT key_uuid, domain_uuid, master_key_id, wrapped_key FROM recording_keys
+		WHERE master_key_id <> $1`, em.keyring.Current())
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch data keys: %w", err)
+	}
+	type stale struct {
+		id, masterID string
+		domain       sql.NullString
+		wrapped      []byte
+	}
+	var keys []stale
+	for rows.Next() {
+		var k stale
+		if err := rows.Scan(&k.id, &k.domain, &k.masterID, &k.wrapped); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan data key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read data keys: %w", err)
+	}
+
+	n := 0
+	for _, k := range keys {
+		aad := dataKeyAAD(k.id, k.domain.String)
+		key, err := em.keyring.Unwrap(k.masterID, k.wrapped, aad)
+		if err != nil {
+			return n, fmt.Errorf("failed to unwrap data key %s: %w", k.id, err)
+		}
+		masterID, wrapped, err := em.keyring.Wrap(key, aad)
+		if err != nil {
+			return n, fmt.Errorf("failed to wrap data key %s: %w", k.id, err)
+		}
+		res, err := em.db.Exec(`
+			UPDATE recording_keys SET master_key_id = $3, wrapped_key = $4, rewrapped_at = now()
+			WHERE key_uuid = $1 AND master_key_id = $2`, k.id, k.masterID, masterID, wrapped)
+		if err != nil {
+			return n, fmt.Errorf("failed to store data key %s: %w", k.id, err)
+		}
+		if affected, _ := res.RowsAffected(); affected > 0 {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// ListKeys returns the data keys of a tenant, or all keys when domainUUID
+// is empty.
+func (em *EncryptionManager) ListKeys(domainUUID string) ([]models.RecordingKey, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, ErrInvalidDomain
+	}
+	rows, err := em.db.Query(`
+		SELECT `+recordingKeyColumns+` FROM recording_keys k
+		WHERE NULLIF($1, '') IS NULL OR k.domain_uuid = NULLIF($1, '')::uuid
+		ORDER BY k.domain_uuid NULLS FIRST, k.created_at DESC`, domainUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch data keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.RecordingKey
+	for rows.Next() {
+		key, err := scanRecordingKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read data keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (em *EncryptionManager) getKey(id string) (*models.RecordingKey, error) {
+	row := em.db.QueryRow(`SELECT `+recordingKeyColumns+` FROM recording_keys k WHERE k.key_uuid = $1`, id)
+	return scanRecordingKey(row)
+}
+
+// dataKeyAAD binds a wrapped data key to its id and tenant, so a wrapped key
+// copied to another row does not unwrap.
+func dataKeyAAD(id, domainUUID string) []byte {
+	return []byte(id + "/" + domainUUID)
+}
+
+func scanRecordingKey(row rowScanner) (*models.RecordingKey, error) {
+	var k models.RecordingKey
+	err := row.Scan(&k.KeyUUID, &k.DomainUUID, &k.MasterKeyID, &k.Active, &k.Objects, &k.CreatedAt,
+		&k.RewrappedAt, &k.RetiredAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDataKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan data key: %w", err)
+	}
+	return &k, nil
+}
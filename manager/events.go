@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+)
+
+// EventHandler receives FreeSWITCH events from the shared ESL listener.
+// Handlers run on their own goroutine and must not block for long.
+type EventHandler func(ev *eventsocket.Event)
+
+// Subscribe registers fn for an event. name is the Event-Name, or the
+// Event-Subclass for CUSTOM events (for example "conference::maintenance").
+// Handlers must be registered before ListenEvents is started.
+func (e *ESLManager) Subscribe(name string, fn EventHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.handlers[name] = append(e.handlers[name], fn)
+}
+
+// OnConnect registers fn to run each time the event listener connects,
+// including reconnects. Components that follow state through events use it
+// to reload what they may have missed while disconnected.
+func (e *ESLManager) OnConnect(fn func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.connectHooks = append(e.connectHooks, fn)
+}
+
+func (e *ESLManager) connected() {
+	e.mu.RLock()
+	hooks := e.connectHooks
+	e.mu.RUnlock()
+
+	for _, fn := range hooks {
+		go fn()
+	}
+}
+
+// subscriptionCommand builds the "event plain ..." command covering every
+// registered handler.
+func (e *ESLManager) subscriptionCommand() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var names, subclasses []string
+	for name := range e.handlers {
+		if strings.Contains(name, "::") {
+			subclasses = append(subclasses, name)
+		} else {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(subclasses)
+
+	cmd := "event plain " + strings.Join(names, " ")
+	if len(subclasses) > 0 {
+		cmd += " CUSTOM " + strings.Join(subclasses, " ")
+	}
+	return cmd
+}
+
+func (e *ESLManager) dispatch(ev *eventsocket.Event) {
+	name := ev.Get("Event-Name")
+	if name == "CUSTOM" {
+		name = ev.Get("Event-Subclass")
+	}
+
+	e.mu.RLock()
+	handlers := e.handlers[name]
+	e.mu.RUnlock()
+
+	for _, fn := range handlers {
+		go fn(ev)
+	}
+}
@@ -0,0 +1,472 @@
+package manager
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrExtensionNotFound = errors.New("extension not found")
+	ErrExtensionExists   = errors.New("extension or number alias already in use in this domain")
+	// ErrInvalidUserContext is returned for a user_context other than the
+	// extension's domain name, which would let its calls run in the dialplan
+	// of another tenant.
+	ErrInvalidUserContext = errors.New("user_context must be the domain name of the extension")
+)
+
+// Characters of generated SIP passwords, without ones easily mistaken for
+// each other.
+const passwordAlphabet = "abcdefghjkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const (
+	extensionPasswordLength = 16
+	voicemailPINLength      = 6
+	defaultCallTimeout      = 30
+)
+
+// The voicemail box of an extension is the one named after its number
+// alias, or its number when it has none, as in FusionPBX.
+const extensionColumns = `e.extension_uuid, e.domain_uuid, d.domain_name, e.extension, COALESCE(e.number_alias, ''),
+	COALESCE(e.effective_caller_id_name, ''), COALESCE(e.effective_caller_id_number, ''),
+	COALESCE(e.outbound_caller_id_name, ''), COALESCE(e.outbound_caller_id_number, ''),
+	COALESCE(e.directory_first_name, ''), COALESCE(e.directory_last_name, ''), COALESCE(e.user_context, ''),
+	COALESCE(e.call_timeout, 0)::int, COALESCE(e.description, ''), COALESCE(e.enabled, 'true') = 'true',
+	e.insert_date, e.update_date,
+	v.voicemail_uuid, CASE WHEN v.voicemail_uuid IS NOT NULL THEN COALESCE(v.voicemail_enabled, 'true') = 'true' END,
+	v.voicemail_mail_to, CASE WHEN v.voicemail_uuid IS NOT NULL THEN COALESCE(v.voicemail_attach_file, '') = 'true' END,
+	CASE WHEN v.voicemail_uuid IS NOT NULL THEN COALESCE(v.voicemail_local_after_email, 'true') = 'true' END`
+
+const extensionFrom = `v_extensions e
+	JOIN v_domains d ON d.domain_uuid = e.domain_uuid
+	LEFT JOIN v_voicemails v ON v.domain_uuid = e.domain_uuid
+		AND v.voicemail_id = COALESCE(NULLIF(e.number_alias, ''), e.extension)`
+
+// ExtensionManager creates and changes FusionPBX extensions in
+// v_extensions. FreeSWITCH caches the directory, so every change is
+// followed by a cache flush over ESL to take effect at once.
+type ExtensionManager struct {
+	db     *sql.DB
+	eslMgr *ESLManager
+}
+
+func NewExtensionManager(db *sql.DB, eslMgr *ESLManager) *ExtensionManager {
+	return &ExtensionManager{
+		db:     db,
+		eslMgr: eslMgr,
+	}
+}
+
+// CreateExtension adds an extension with its voicemail box. The returned
+// secrets hold the SIP password and voicemail PIN, generated when the
+// request has none; they are not returned again.
+func (em *ExtensionManager) CreateExtension(req request.ExtensionRequest) (*models.Extension, *models.ExtensionSecrets, error) {
+	if !isUUID(req.DomainUUID) {
+		return nil, nil, ErrInvalidDomain
+	}
+
+	tx, err := em.db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var domainName string
+	err = tx.QueryRow(`SELECT domain_name FROM v_domains WHERE domain_uuid = $1`, req.DomainUUID).Scan(&domainName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrInvalidDomain
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch domain: %w", err)
+	}
+	if err := em.checkUnique(tx, req.DomainUUID, "", req.Extension, req.NumberAlias); err != nil {
+		return nil, nil, err
+	}
+
+	secrets := &models.ExtensionSecrets{Password: req.Password}
+	if secrets.Password == "" {
+		if secrets.Password, err = randomString(passwordAlphabet, extensionPasswordLength); err != nil {
+			return nil, nil, err
+		}
+	}
+	if req.UserContext != "" && req.UserContext != domainName {
+		return nil, nil, ErrInvalidUserContext
+	}
+
+	id := newUUID()
+	_, err = tx.Exec(`
+		INSERT INTO v_extensions (extension_uuid, domain_uuid, extension, number_alias, password, accountcode,
+			effective_caller_id_name, effective_caller_id_number, outbound_caller_id_name, outbound_caller_id_number,
+			directory_first_name, directory_last_name, directory_visible, directory_exten_visible, user_context,
+			call_timeout, limit_max, limit_destination, enabled, description, insert_date, update_date)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, 'true', 'true', $13, $14, 5, 'error/user_busy',
+			$15, $16, now(), now())`,
+		id, req.DomainUUID, req.Extension, req.NumberAlias, secrets.Password, domainName,
+		req.EffectiveCallerIDName, req.EffectiveCallerIDNumber, req.OutboundCallerIDName, req.OutboundCallerIDNumber,
+		req.DirectoryFirstName, req.DirectoryLastName, domainName, callTimeout(req.CallTimeout),
+		boolText(req.Enabled == nil || *req.Enabled), req.Description)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create extension: %w", err)
+	}
+
+	vm := req.Voicemail
+	if vm == nil {
+		vm = &request.ExtensionVoicemail{}
+	}
+	if secrets.VoicemailPassword, err = em.saveVoicemail(tx, req.DomainUUID, mailboxID(req), vm); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit extension: %w", err)
+	}
+
+	ext, err := em.GetExtension(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Created extension %s@%s", ext.Extension, ext.DomainName)
+	em.flush(ext.DomainName, ext.Extension, ext.NumberAlias)
+	return ext, secrets, nil
+}
+
+// ListExtensions returns the extensions of a tenant, or of every tenant
+// when domainUUID is empty.
+func (em *ExtensionManager) ListExtensions(domainUUID string, limit, offset int) ([]models.Extension, int, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, 0, ErrInvalidDomain
+	}
+	where := `WHERE NULLIF($1, '') IS NULL OR e.domain_uuid = NULLIF($1, '')::uuid`
+
+	var total int
+	if err := em.db.QueryRow(`SELECT COUNT(*) FROM v_extensions e `+where, domainUUID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count extensions: %w", err)
+	}
+
+	rows, err := em.db.Query(`SELECT `+extensionColumns+` FROM `+extensionFrom+` `+where+`
+		ORDER BY d.domain_name, e.extension
+		LIMIT $2 OFFSET $3`, domainUUID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch extensions: %w", err)
+	}
+	defer rows.Close()
+
+	var extensions []models.Extension
+	for rows.Next() {
+		ext, err := scanExtension(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		extensions = append(extensions, *ext)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read extensions: %w", err)
+	}
+	return extensions, total, nil
+}
+
+func (em *ExtensionManager) GetExtension(id string) (*models.Extension, error) {
+	if !isUUID(id) {
+		return nil, ErrExtensionNotFound
+	}
+	row := em.db.QueryRow(`SELECT `+extensionColumns+` FROM `+extensionFrom+` WHERE e.extension_uuid = $1`, id)
+	return scanExtension(row)
+}
+
+// UpdateExtension replaces the settings of an extension. An empty password
+// keeps the current one, and the voicemail box is only changed when the
+// request has voicemail settings. A box follows its extension when the
+// number changes.
+func (em *ExtensionManager) UpdateExtension(id string, req request.ExtensionRequest) (*models.Extension, error) {
+	old, err := em.GetExtension(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := em.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := em.checkUnique(tx, old.DomainUUID, id, req.Extension, req.NumberAlias); err != nil {
+		return nil, err
+	}
+
+	if req.UserContext != "" && req.UserContext != old.DomainName {
+		return nil, ErrInvalidUserContext
+	}
+	res, err := tx.Exec(`
+		UPDATE v_extensions
+		SET extension = $2, number_alias = $3, password = COALESCE(NULLIF($4, ''), password),
+			effective_caller_id_name = $5, effective_caller_id_number = $6,
+			outbound_caller_id_name = $7, outbound_caller_id_number = $8,
+			directory_first_name = $9, directory_last_name = $10, user_context = $11, call_timeout = $12,
+			enabled = $13, description = $14, update_date = now()
+		WHERE extension_uuid = $1`,
+		id, req.Extension, req.NumberAlias, req.Password, req.EffectiveCallerIDName, req.EffectiveCallerIDNumber,
+		req.OutboundCallerIDName, req.OutboundCallerIDNumber, req.DirectoryFirstName, req.DirectoryLastName,
+		old.DomainName, callTimeout(req.CallTimeout), boolText(req.Enabled == nil || *req.Enabled), req.Description)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update extension: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrExtensionNotFound
+	}
+
+	oldID := old.NumberAlias
+	if oldID == "" {
+		oldID = old.Extension
+	}
+	if newID := mailboxID(req); newID != oldID {
+		_, err := tx.Exec(`UPDATE v_voicemails SET voicemail_id = $3, update_date = now()
+			WHERE domain_uuid = $1 AND voicemail_id = $2`, old.DomainUUID, oldID, newID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move voicemail box: %w", err)
+		}
+	}
+	if req.Voicemail != nil {
+		if _, err := em.saveVoicemail(tx, old.DomainUUID, mailboxID(req), req.Voicemail); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit extension: %w", err)
+	}
+
+	ext, err := em.GetExtension(id)
+	if err != nil {
+		return nil, err
+	}
+	em.flush(ext.DomainName, old.Extension, old.NumberAlias, ext.Extension, ext.NumberAlias)
+	return ext, nil
+}
+
+// SetEnabled enables or disables an extension. Disabled extensions can no
+// longer register or be called.
+func (em *ExtensionManager) SetEnabled(id string, enabled bool) (*models.Extension, error) {
+	if !isUUID(id) {
+		return nil, ErrExtensionNotFound
+	}
+	res, err := em.db.Exec(`UPDATE v_extensions SET enabled = $2, update_date = now() WHERE extension_uuid = $1`,
+		id, boolText(enabled))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update extension: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrExtensionNotFound
+	}
+
+	ext, err := em.GetExtension(id)
+	if err != nil {
+		return nil, err
+	}
+	em.flush(ext.DomainName, ext.Extension, ext.NumberAlias)
+	return ext, nil
+}
+
+// ResetPassword gives an extension a new generated SIP password.
+func (em *ExtensionManager) ResetPassword(id string) (*models.Extension, string, error) {
+	if !isUUID(id) {
+		return nil, "", ErrExtensionNotFound
+	}
+	password, err := randomString(passwordAlphabet, extensionPasswordLength)
+	if err != nil {
+		return nil, "", err
+	}
+	res, err := em.db.Exec(`UPDATE v_extensions SET password = $2, update_date = now() WHERE extension_uuid = $1`,
+		id, password)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to update extension: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, "", ErrExtensionNotFound
+	}
+
+	ext, err := em.GetExtension(id)
+	if err != nil {
+		return nil, "", err
+	}
+	em.flush(ext.DomainName, ext.Extension, ext.NumberAlias)
+	return ext, password, nil
+}
+
+// DeleteExtension removes an extension along with its user assignments,
+// its voicemail box and the box's messages.
+func (em *ExtensionManager) DeleteExtension(id string) error {
+	ext, err := em.GetExtension(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := em.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM v_extension_users WHERE extension_uuid = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete extension users: %w", err)
+	}
+	if ext.VoicemailUUID.Valid {
+		if _, err := tx.Exec(`DELETE FROM v_voicemail_messages WHERE voicemail_uuid = $1`, ext.VoicemailUUID.String); err != nil {
+			return fmt.Errorf("failed to delete voicemail messages: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM v_voicemails WHERE voicemail_uuid = $1`, ext.VoicemailUUID.String); err != nil {
+			return fmt.Errorf("failed to delete voicemail box: %w", err)
+		}
+	}
+	res, err := tx.Exec(`DELETE FROM v_extensions WHERE extension_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete extension: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrExtensionNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit extension: %w", err)
+	}
+	log.Printf("Deleted extension %s@%s", ext.Extension, ext.DomainName)
+	em.flush(ext.DomainName, ext.Extension, ext.NumberAlias)
+	return nil
+}
+
+// checkUnique fails when extension or alias is already the number or alias
+// of another extension of the domain. FusionPBX has no constraint for it.
+func (em *ExtensionManager) checkUnique(tx *sql.Tx, domainUUID, id, extension, alias string) error {
+	var exists bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM v_extensions
+			WHERE domain_uuid = $1 AND extension_uuid::text <> $2
+				AND (extension IN ($3, NULLIF($4, '')) OR NULLIF(number_alias, '') IN ($3, NULLIF($4, '')))
+		)`, domainUUID, id, extension, alias).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check extension: %w", err)
+	}
+	if exists {
+		return ErrExtensionExists
+	}
+	return nil
+}
+
+// saveVoicemail creates or updates the box named id. It returns the PIN
+// when one was set, generating it for new boxes without one.
+func (em *ExtensionManager) saveVoicemail(tx *sql.Tx, domainUUID, id string, vm *request.ExtensionVoicemail) (string, error) {
+	enabled := boolText(vm.Enabled == nil || *vm.Enabled)
+	keepLocal := boolText(vm.KeepLocal == nil || *vm.KeepLocal)
+	file := ""
+	if vm.AttachFile {
+		file = "attach"
+	}
+
+	res, err := tx.Exec(`
+		UPDATE v_voicemails
+		SET voicemail_password = COALESCE(NULLIF($3, ''), voicemail_password), voicemail_mail_to = $4,
+			voicemail_file = $5, voicemail_attach_file = $6, voicemail_local_after_email = $7,
+			voicemail_enabled = $8, voicemail_description = $9, update_date = now()
+		WHERE domain_uuid = $1 AND voicemail_id = $2`,
+		domainUUID, id, vm.Password, vm.MailTo, file, boolText(vm.AttachFile), keepLocal, enabled, vm.Description)
+	if err != nil {
+		return "", fmt.Errorf("failed to update voicemail box: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return vm.Password, nil
+	}
+
+	pin := vm.Password
+	if pin == "" {
+		if pin, err = randomString("0123456789", voicemailPINLength); err != nil {
+			return "", err
+		}
+	}
+	_, err = tx.Exec(`
+		INSERT INTO v_voicemails (voicemail_uuid, domain_uuid, voicemail_id, voicemail_password, voicemail_mail_to,
+			voicemail_file, voicemail_attach_file, voicemail_local_after_email, voicemail_enabled,
+			voicemail_description, insert_date, update_date)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, now(), now())`,
+		newUUID(), domainUUID, id, pin, vm.MailTo, file, boolText(vm.AttachFile), keepLocal, enabled, vm.Description)
+	if err != nil {
+		return "", fmt.Errorf("failed to create voicemail box: %w", err)
+	}
+	return pin, nil
+}
+
+// flush drops the cached directory entries of the given numbers and
+// reloads the XML, so FreeSWITCH sees the change on the next registration
+// or call. The change is already stored, so failures are only logged.
+func (em *ExtensionManager) flush(domainName string, numbers ...string) {
+	seen := make(map[string]bool)
+	for _, n := range numbers {
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		if _, err := em.eslMgr.api(fmt.Sprintf("xml_flush_cache id %s %s", n, domainName)); err != nil {
+			log.Printf("Failed to flush directory cache of %s@%s: %v", n, domainName, err)
+		}
+	}
+	if _, err := em.eslMgr.api("reloadxml"); err != nil {
+		log.Printf("Failed to reload XML: %v", err)
+	}
+}
+
+// mailboxID returns the voicemail box name of the extension in req.
+func mailboxID(req request.ExtensionRequest) string {
+	if req.NumberAlias != "" {
+		return req.NumberAlias
+	}
+	return req.Extension
+}
+
+func callTimeout(seconds int) int {
+	if seconds == 0 {
+		return defaultCallTimeout
+	}
+	return seconds
+}
+
+// boolText spells a flag the way FusionPBX stores it.
+func boolText(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func randomString(alphabet string, n int) (string, error) {
+	b := make([]byte, n)
+	size := big.NewInt(int64(len(alphabet)))
+	for i := range b {
+		k, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		b[i] = alphabet[k.Int64()]
+	}
+	return string(b), nil
+}
+
+func scanExtension(row rowScanner) (*models.Extension, error) {
+	var e models.Extension
+	err := row.Scan(&e.ExtensionUUID, &e.DomainUUID, &e.DomainName, &e.Extension, &e.NumberAlias,
+		&e.EffectiveCallerIDName, &e.EffectiveCallerIDNumber, &e.OutboundCallerIDName, &e.OutboundCallerIDNumber,
+		&e.DirectoryFirstName, &e.DirectoryLastName, &e.UserContext, &e.CallTimeout, &e.Description, &e.Enabled,
+		&e.CreatedAt, &e.UpdatedAt, &e.VoicemailUUID, &e.VoicemailEnabled, &e.VoicemailMailTo,
+		&e.VoicemailAttachFile, &e.VoicemailKeepLocal)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrExtensionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan extension: %w", err)
+	}
+	return &e, nil
+}
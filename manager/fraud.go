@@ -0,0 +1,894 @@
+package manager
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+	"github.com/lib/pq"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/number"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrFraudRuleNotFound  = errors.New("fraud rule not found")
+	ErrInvalidFraudRule   = errors.New("invalid fraud rule")
+	ErrFraudBlockNotFound = errors.New("fraud block not found")
+	ErrExtensionBlocked   = errors.New("extension is blocked for suspected fraud")
+)
+
+// Fraud rule kinds. High-cost spikes, concurrency and after-hours calls are
+// checked as channels are created; short call sweeps are found in the CDRs.
+const (
+	FraudHighCost    = "high_cost"
+	FraudConcurrency = "concurrency"
+	FraudAfterHours  = "after_hours"
+	FraudShortCalls  = "short_calls"
+)
+
+const (
+	FraudActionAlert = "alert"
+	FraudActionBlock = "block"
+	FraudActionKill  = "kill"
+)
+
+// A rule alerts at most once per cooldown for the same extension. Later
+// matches within the cooldown only hang up calls.
+const minFraudCooldown = 5 * time.Minute
+
+const fraudRuleColumns = `rule_uuid, domain_uuid, name, kind, prefixes, threshold, window_seconds, max_duration,
+	to_char(business_start, 'HH24:MI'), to_char(business_end, 'HH24:MI'), business_days, timezone, actions,
+	webhook_url, enabled, created_at, updated_at`
+
+const fraudEventColumns = `event_uuid, rule_uuid, domain_uuid, kind, extension, call_uuid, number, detail, actions, created_at`
+
+const fraudBlockColumns = `block_uuid, domain_uuid, extension, rule_uuid, reason, created_at`
+
+// FraudAlert is the JSON body posted to alert webhooks.
+type FraudAlert struct {
+	EventUUID  string    `json:"event_uuid"`
+	RuleUUID   string    `json:"rule_uuid"`
+	RuleName   string    `json:"rule_name"`
+	Kind       string    `json:"kind"`
+	DomainUUID string    `json:"domain_uuid"`
+	Extension  string    `json:"extension"`
+	CallUUID   string    `json:"call_uuid,omitempty"`
+	Number     string    `json:"number,omitempty"`
+	Detail     string    `json:"detail"`
+	Actions    []string  `json:"actions"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FraudManager watches new channels and recent CDRs for toll fraud. Matches
+// are written to fraud_events and can alert a webhook, block the extension
+// and hang up its calls. Blocked extensions cannot place new calls until
+// the block is removed.
+type FraudManager struct {
+	db      *sql.DB
+	eslMgr  *ESLManager
+	numbers *NumberManager
+	config  config.FraudConfig
+	client  *http.Client
+
+	mu     sync.Mutex
+	rules  []models.FraudRule
+	live   map[string]fraudChannel // channel uuid -> channel placed by an extension
+	recent map[string][]time.Time  // rule/domain/extension -> high-cost call times
+	fired  map[string]time.Time    // rule/domain/extension -> last alert
+}
+
+type fraudChannel struct {
+	domainUUID string
+	extension  string
+	number     string // digits of the E.164 destination, external calls only
+	created    time.Time
+}
+
+// detection is one rule match. calls are the live calls the kill action
+// hangs up.
+type detection struct {
+	domainUUID string
+	extension  string
+	callUUID   string
+	number     string
+	detail     string
+	calls      []string
+}
+
+func NewFraudManager(db *sql.DB, eslMgr *ESLManager, numbers *NumberManager, cfg config.FraudConfig) *FraudManager {
+	fm := &FraudManager{
+		db:      db,
+		eslMgr:  eslMgr,
+		numbers: numbers,
+		config:  cfg,
+		client:  &http.Client{Timeout: cfg.WebhookTimeout},
+		live:    make(map[string]fraudChannel),
+		recent:  make(map[string][]time.Time),
+		fired:   make(map[string]time.Time),
+	}
+	eslMgr.Subscribe("CHANNEL_CREATE", fm.handleCreate)
+	eslMgr.Subscribe("CHANNEL_HANGUP_COMPLETE", fm.handleHangup)
+	return fm
+}
+
+// Run reloads the rules and scans the CDRs for short call sweeps.
+func (fm *FraudManager) Run() {
+	ticker := time.NewTicker(fm.config.CheckInterval)
+	defer ticker.Stop()
+
+	log.Printf("Fraud detection started, checking CDRs every %s", fm.config.CheckInterval)
+	fm.check(time.Now())
+	for now := range ticker.C {
+		fm.check(now)
+	}
+}
+
+func (fm *FraudManager) check(now time.Time) {
+	if err := fm.loadRules(); err != nil {
+		log.Printf("Failed to load fraud rules: %v", err)
+	}
+
+	fm.mu.Lock()
+	var sweeps []models.FraudRule
+	for _, rule := range fm.rules {
+		if rule.Kind == FraudShortCalls {
+			sweeps = append(sweeps, rule)
+		}
+	}
+	fm.mu.Unlock()
+
+	for _, rule := range sweeps {
+		if err := fm.scanShortCalls(rule); err != nil {
+			log.Printf("Failed to scan CDRs for fraud rule %s: %v", rule.Name, err)
+		}
+	}
+	fm.prune(now)
+}
+
+func (fm *FraudManager) loadRules() error {
+	rows, err := fm.db.Query(`SELECT ` + fraudRuleColumns + ` FROM fraud_rules WHERE enabled`)
+	if err != nil {
+		return fmt.Errorf("failed to fetch fraud rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.FraudRule
+	for rows.Next() {
+		rule, err := scanFraudRule(rows)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, *rule)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read fraud rules: %w", err)
+	}
+
+	fm.mu.Lock()
+	fm.rules = rules
+	fm.mu.Unlock()
+	return nil
+}
+
+// prune forgets state no rule can still use. Windows are at most a day.
+func (fm *FraudManager) prune(now time.Time) {
+	cutoff := now.Add(-24 * time.Hour)
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	for key, at := range fm.fired {
+		if at.Before(cutoff) {
+			delete(fm.fired, key)
+		}
+	}
+	for key, times := range fm.recent {
+		if times = trimTimes(times, cutoff); len(times) == 0 {
+			delete(fm.recent, key)
+		} else {
+			fm.recent[key] = times
+		}
+	}
+	// Channels this old missed their hangup event.
+	for callUUID, ch := range fm.live {
+		if ch.created.Before(cutoff) {
+			delete(fm.live, callUUID)
+		}
+	}
+}
+
+func (fm *FraudManager) handleCreate(ev *eventsocket.Event) {
+	callUUID := ev.Get("Unique-Id")
+	extension := eventExtension(ev)
+	// Legs created by a bridge are part of the call that dialed them.
+	if callUUID == "" || extension == "" || ev.Get("Other-Leg-Unique-Id") != "" {
+		return
+	}
+	domainUUID := ev.Get("Variable_domain_uuid")
+	if !isUUID(domainUUID) {
+		domainUUID = ""
+	}
+
+	blocked, err := fm.IsBlocked(domainUUID, extension)
+	if err != nil {
+		log.Printf("Failed to check fraud block of %s: %v", extension, err)
+	} else if blocked {
+		log.Printf("Extension %s is blocked for suspected fraud, hanging up call %s", extension, callUUID)
+		if err := fm.eslMgr.Kill(callUUID, "CALL_REJECTED"); err != nil {
+			log.Printf("Failed to hang up call %s: %v", callUUID, err)
+		}
+		return
+	}
+
+	now := time.Now()
+	ch := fraudChannel{domainUUID: domainUUID, extension: extension, created: now}
+	n, err := fm.numbers.Resolve(domainUUID, ev.Get("Caller-Destination-Number"))
+	if err == nil && n.Kind == number.PSTN {
+		ch.number = strings.TrimPrefix(n.E164, "+")
+	}
+
+	fm.mu.Lock()
+	fm.live[callUUID] = ch
+	var rules []models.FraudRule
+	for _, rule := range fm.rules {
+		if rule.Kind != FraudShortCalls && (!rule.DomainUUID.Valid || rule.DomainUUID.String == domainUUID) {
+			rules = append(rules, rule)
+		}
+	}
+	fm.mu.Unlock()
+
+	for _, rule := range rules {
+		if d := fm.evaluate(rule, callUUID, ch, now); d != nil {
+			fm.trigger(rule, *d)
+		}
+	}
+}
+
+func (fm *FraudManager) handleHangup(ev *eventsocket.Event) {
+	fm.mu.Lock()
+	delete(fm.live, ev.Get("Unique-Id"))
+	fm.mu.Unlock()
+}
+
+// evaluate checks a new channel against a live rule.
+func (fm *FraudManager) evaluate(rule models.FraudRule, callUUID string, ch fraudChannel, now time.Time) *detection {
+	d := &detection{domainUUID: ch.domainUUID, extension: ch.extension, callUUID: callUUID, number: ch.number}
+
+	switch rule.Kind {
+	case FraudConcurrency:
+		calls := fm.liveCalls(ch.domainUUID, ch.extension, nil)
+		if len(calls) <= rule.Threshold {
+			return nil
+		}
+		d.detail = fmt.Sprintf("%d concurrent calls, limit is %d", len(calls), rule.Threshold)
+		d.calls = []string{callUUID}
+
+	case FraudHighCost:
+		if !matchesPrefix(ch.number, rule.Prefixes) {
+			return nil
+		}
+		key := fraudKey(rule, ch.domainUUID, ch.extension)
+		window := time.Duration(rule.WindowSeconds) * time.Second
+
+		fm.mu.Lock()
+		times := append(trimTimes(fm.recent[key], now.Add(-window)), now)
+		fm.recent[key] = times
+		fm.mu.Unlock()
+
+		if len(times) < rule.Threshold {
+			return nil
+		}
+		d.detail = fmt.Sprintf("%d calls to high-cost prefixes within %ds", len(times), rule.WindowSeconds)
+		d.calls = fm.liveCalls(ch.domainUUID, ch.extension, rule.Prefixes)
+
+	case FraudAfterHours:
+		if ch.number == "" || (len(rule.Prefixes) > 0 && !matchesPrefix(ch.number, rule.Prefixes)) {
+			return nil
+		}
+		if withinBusinessHours(rule, now) {
+			return nil
+		}
+		d.detail = fmt.Sprintf("external call outside business hours %s-%s %s",
+			rule.BusinessStart.String, rule.BusinessEnd.String, rule.Timezone)
+		d.calls = []string{callUUID}
+
+	default:
+		return nil
+	}
+	return d
+}
+
+// scanShortCalls looks for extensions that placed at least threshold calls
+// shorter than max_duration within the rule window. Calls are counted by the
+// extension that placed them, not the caller ID it sent, so a sweep cannot
+// hide behind a changing caller ID or block the DID it presented.
+func (fm *FraudManager) scanShortCalls(rule models.FraudRule) error {
+	rows, err := fm.db.Query(`
+		SELECT e.domain_uuid, e.extension, COUNT(*)
+		FROM v_xml_cdr c
+		JOIN v_extensions e ON e.extension_uuid = c.extension_uuid
+		WHERE c.start_stamp >= now() - make_interval(secs => $1)
+			AND (c.leg IS NULL OR c.leg = 'a')
+			AND c.direction IN ('outbound', 'local')
+			AND COALESCE(c.billsec, 0) < $2
+			AND (NULLIF($3, '') IS NULL OR e.domain_uuid = NULLIF($3, '')::uuid)
+		GROUP BY e.domain_uuid, e.extension
+		HAVING COUNT(*) >= $4`,
+		rule.WindowSeconds, rule.MaxDuration, rule.DomainUUID.String, rule.Threshold)
+	if err != nil {
+		return fmt.Errorf("failed to count short calls: %w", err)
+	}
+
+	var found []detection
+	for rows.Next() {
+		var domainUUID sql.NullString
+		var d detection
+		var count int
+		if err := rows.Scan(&domainUUID, &d.extension, &count); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan short calls: %w", err)
+		}
+		d.domainUUID = domainUUID.String
+		d.detail = fmt.Sprintf("%d calls shorter than %ds within %ds", count, rule.MaxDuration, rule.WindowSeconds)
+		found = append(found, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read short calls: %w", err)
+	}
+
+	for _, d := range found {
+		d.calls = fm.liveCalls(d.domainUUID, d.extension, nil)
+		fm.trigger(rule, d)
+	}
+	return nil
+}
+
+// trigger applies the actions of a rule to a detection and records it.
+func (fm *FraudManager) trigger(rule models.FraudRule, d detection) {
+	key := fraudKey(rule, d.domainUUID, d.extension)
+	cooldown := time.Duration(rule.WindowSeconds) * time.Second
+	if cooldown < minFraudCooldown {
+		cooldown = minFraudCooldown
+	}
+
+	now := time.Now()
+	fm.mu.Lock()
+	last, seen := fm.fired[key]
+	repeat := seen && now.Sub(last) < cooldown
+	if !repeat {
+		fm.fired[key] = now
+	}
+	fm.mu.Unlock()
+
+	var taken []string
+	for _, action := range rule.Actions {
+		switch action {
+		case FraudActionKill:
+			for _, callUUID := range d.calls {
+				if err := fm.eslMgr.Kill(callUUID, "CALL_REJECTED"); err != nil {
+					log.Printf("Failed to hang up call %s: %v", callUUID, err)
+				}
+			}
+			if len(d.calls) > 0 {
+				taken = append(taken, action)
+			}
+		case FraudActionBlock:
+			if repeat {
+				continue
+			}
+			ruleUUID := sql.NullString{String: rule.RuleUUID, Valid: true}
+			reason := fmt.Sprintf("%s: %s", rule.Name, d.detail)
+			if _, err := fm.block(d.domainUUID, d.extension, ruleUUID, reason); err != nil {
+				log.Printf("Failed to block extension %s: %v", d.extension, err)
+				continue
+			}
+			taken = append(taken, action)
+		case FraudActionAlert:
+			if !repeat {
+				taken = append(taken, action)
+			}
+		}
+	}
+	if repeat {
+		return
+	}
+
+	log.Printf("Fraud rule %s matched extension %s: %s (actions: %s)",
+		rule.Name, d.extension, d.detail, strings.Join(taken, ", "))
+	event, err := fm.recordEvent(rule, d, taken)
+	if err != nil {
+		log.Printf("Failed to record fraud event: %v", err)
+		return
+	}
+	for _, action := range taken {
+		if action == FraudActionAlert {
+			fm.sendAlert(rule, *event)
+		}
+	}
+}
+
+func (fm *FraudManager) recordEvent(rule models.FraudRule, d detection, actions []string) (*models.FraudEvent, error) {
+	if actions == nil {
+		actions = []string{}
+	}
+	row := fm.db.QueryRow(`
+		INSERT INTO fraud_events (event_uuid, rule_uuid, domain_uuid, kind, extension, call_uuid, number, detail, actions)
+		VALUES ($1, $2, NULLIF($3, '')::uuid, $4, $5, $6, $7, $8, $9)
+		RETURNING `+fraudEventColumns,
+		newUUID(), rule.RuleUUID, d.domainUUID, rule.Kind, d.extension, d.callUUID, d.number, d.detail,
+		pq.Array(actions))
+
+	return scanFraudEvent(row)
+}
+
+// sendAlert posts an event to the rule webhook, or the default one. Without
+// either the alert is only logged.
+func (fm *FraudManager) sendAlert(rule models.FraudRule, event models.FraudEvent) {
+	url := rule.WebhookURL
+	if url == "" {
+		url = fm.config.WebhookURL
+	}
+	if url == "" {
+		return
+	}
+
+	body, err := json.Marshal(FraudAlert{
+		EventUUID:  event.EventUUID,
+		RuleUUID:   rule.RuleUUID,
+		RuleName:   rule.Name,
+		Kind:       event.Kind,
+		DomainUUID: event.DomainUUID.String,
+		Extension:  event.Extension,
+		CallUUID:   event.CallUUID,
+		Number:     event.Number,
+		Detail:     event.Detail,
+		Actions:    event.Actions,
+		CreatedAt:  event.CreatedAt,
+	})
+	if err != nil {
+		log.Printf("Failed to encode fraud alert: %v", err)
+		return
+	}
+
+	resp, err := fm.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to send fraud alert %s: %v", event.EventUUID, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Fraud alert %s was rejected with status %d", event.EventUUID, resp.StatusCode)
+	}
+}
+
+// liveCalls returns the live calls of an extension, limited to destinations
+// under prefixes when given.
+func (fm *FraudManager) liveCalls(domainUUID, extension string, prefixes []string) []string {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	var calls []string
+	for callUUID, ch := range fm.live {
+		if ch.domainUUID != domainUUID || ch.extension != extension {
+			continue
+		}
+		if prefixes != nil && !matchesPrefix(ch.number, prefixes) {
+			continue
+		}
+		calls = append(calls, callUUID)
+	}
+	return calls
+}
+
+// CheckCall returns ErrExtensionBlocked when the caller of a request is
+// blocked.
+func (fm *FraudManager) CheckCall(req request.CallRequest) error {
+	blocked, err := fm.IsBlocked(req.DomainUUID, req.Caller)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return fmt.Errorf("%w: %s", ErrExtensionBlocked, req.Caller)
+	}
+	return nil
+}
+
+// IsBlocked reports whether an extension has a block for its tenant or a
+// global one.
+func (fm *FraudManager) IsBlocked(domainUUID, extension string) (bool, error) {
+	var blocked bool
+	err := fm.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM fraud_blocks
+			WHERE extension = $2 AND (domain_uuid IS NULL OR domain_uuid = NULLIF($1, '')::uuid)
+		)`, domainUUID, extension).Scan(&blocked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check fraud blocks: %w", err)
+	}
+	return blocked, nil
+}
+
+func (fm *FraudManager) block(domainUUID, extension string, ruleUUID sql.NullString, reason string) (*models.FraudBlock, error) {
+	row := fm.db.QueryRow(`
+		INSERT INTO fraud_blocks (block_uuid, domain_uuid, extension, rule_uuid, reason)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5)
+		ON CONFLICT ((COALESCE(domain_uuid, '00000000-0000-0000-0000-000000000000')), extension)
+		DO UPDATE SET reason = EXCLUDED.reason
+		RETURNING `+fraudBlockColumns,
+		newUUID(), domainUUID, extension, ruleUUID, reason)
+
+	return scanFraudBlock(row)
+}
+
+func (fm *FraudManager) CreateBlock(req request.FraudBlockRequest) (*models.FraudBlock, error) {
+	return fm.block(req.DomainUUID, req.Extension, sql.NullString{}, req.Reason)
+}
+
+// ListBlocks returns the blocks of a tenant, or all blocks when domainUUID
+// is empty.
+func (fm *FraudManager) ListBlocks(domainUUID string, limit, offset int) ([]models.FraudBlock, int, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, 0, ErrInvalidDomain
+	}
+	where := `WHERE NULLIF($1, '') IS NULL OR domain_uuid = NULLIF($1, '')::uuid`
+
+	var total int
+	if err := fm.db.QueryRow(`SELECT COUNT(*) FROM fraud_blocks `+where, domainUUID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count fraud blocks: %w", err)
+	}
+
+	rows, err := fm.db.Query(`SELECT `+fraudBlockColumns+` FROM fraud_blocks `+where+`
+		ORDER BY created_at DESC LIMIT $2 OFFSET $3`, domainUUID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch fraud blocks: %w", err)
+	}
+	defer rows.Close()
+
+	var blocks []models.FraudBlock
+	for rows.Next() {
+		b, err := scanFraudBlock(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		blocks = append(blocks, *b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read fraud blocks: %w", err)
+	}
+
+	return blocks, total, nil
+}
+
+func (fm *FraudManager) GetBlock(id string) (*models.FraudBlock, error) {
+	if !isUUID(id) {
+		return nil, ErrFraudBlockNotFound
+	}
+	row := fm.db.QueryRow(`SELECT `+fraudBlockColumns+` FROM fraud_blocks WHERE block_uuid = $1`, id)
+	return scanFraudBlock(row)
+}
+
+// DeleteBlock lets a blocked extension place calls again.
+func (fm *FraudManager) DeleteBlock(id string) error {
+	if !isUUID(id) {
+		return ErrFraudBlockNotFound
+	}
+	res, err := fm.db.Exec(`DELETE FROM fraud_blocks WHERE block_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete fraud block: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrFraudBlockNotFound
+	}
+	return nil
+}
+
+// ListEvents returns the audit trail, newest first. Empty filters match
+// everything.
+func (fm *FraudManager) ListEvents(domainUUID, ruleUUID, extension string, limit, offset int) ([]models.FraudEvent, int, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, 0, ErrInvalidDomain
+	}
+	if ruleUUID != "" && !isUUID(ruleUUID) {
+		return nil, 0, fmt.Errorf("%w: rule_uuid must be a UUID", ErrInvalidFraudRule)
+	}
+	where := `WHERE (NULLIF($1, '') IS NULL OR domain_uuid = NULLIF($1, '')::uuid)
+		AND (NULLIF($2, '') IS NULL OR rule_uuid = NULLIF($2, '')::uuid)
+		AND ($3 = '' OR extension = $3)`
+
+	var total int
+	if err := fm.db.QueryRow(`SELECT COUNT(*) FROM fraud_events `+where, domainUUID, ruleUUID, extension).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count fraud events: %w", err)
+	}
+
+	rows, err := fm.db.Query(`SELECT `+fraudEventColumns+` FROM fraud_events `+where+`
+		ORDER BY created_at DESC LIMIT $4 OFFSET $5`, domainUUID, ruleUUID, extension, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch fraud events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.FraudEvent
+	for rows.Next() {
+		event, err := scanFraudEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, *event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read fraud events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+func (fm *FraudManager) CreateRule(req request.FraudRuleRequest) (*models.FraudRule, error) {
+	if err := normalizeFraudRule(&req); err != nil {
+		return nil, err
+	}
+
+	row := fm.db.QueryRow(`
+		INSERT INTO fraud_rules (rule_uuid, domain_uuid, name, kind, prefixes, threshold, window_seconds, max_duration,
+			business_start, business_end, business_days, timezone, actions, webhook_url, enabled)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, $7, $8, NULLIF($9, '')::time, NULLIF($10, '')::time,
+			$11, $12, $13, $14, $15)
+		RETURNING `+fraudRuleColumns,
+		newUUID(), req.DomainUUID, req.Name, req.Kind, pq.Array(req.Prefixes), req.Threshold, req.WindowSeconds,
+		req.MaxDuration, req.BusinessStart, req.BusinessEnd, pq.Array(req.BusinessDays), req.Timezone,
+		pq.Array(req.Actions), req.WebhookURL, req.Enabled == nil || *req.Enabled)
+
+	return fm.saved(scanFraudRule(row))
+}
+
+func (fm *FraudManager) GetRule(id string) (*models.FraudRule, error) {
+	if !isUUID(id) {
+		return nil, ErrFraudRuleNotFound
+	}
+	row := fm.db.QueryRow(`SELECT `+fraudRuleColumns+` FROM fraud_rules WHERE rule_uuid = $1`, id)
+	return scanFraudRule(row)
+}
+
+// ListRules returns the rules watching domainUUID (its own plus global
+// ones), or every rule when domainUUID is empty.
+func (fm *FraudManager) ListRules(domainUUID string) ([]models.FraudRule, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, ErrInvalidDomain
+	}
+	rows, err := fm.db.Query(`
+		SELECT `+fraudRuleColumns+` FROM fraud_rules
+		WHERE NULLIF($1, '') IS NULL OR domain_uuid IS NULL OR domain_uuid = NULLIF($1, '')::uuid
+		ORDER BY name`, domainUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch fraud rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.FraudRule
+	for rows.Next() {
+		rule, err := scanFraudRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read fraud rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (fm *FraudManager) UpdateRule(id string, req request.FraudRuleRequest) (*models.FraudRule, error) {
+	if !isUUID(id) {
+		return nil, ErrFraudRuleNotFound
+	}
+	if err := normalizeFraudRule(&req); err != nil {
+		return nil, err
+	}
+
+	row := fm.db.QueryRow(`
+		UPDATE fraud_rules
+		SET domain_uuid = NULLIF($2, '')::uuid, name = $3, kind = $4, prefixes = $5, threshold = $6,
+			window_seconds = $7, max_duration = $8, business_start = NULLIF($9, '')::time,
+			business_end = NULLIF($10, '')::time, business_days = $11, timezone = $12, actions = $13,
+			webhook_url = $14, enabled = $15, updated_at = now()
+		WHERE rule_uuid = $1
+		RETURNING `+fraudRuleColumns,
+		id, req.DomainUUID, req.Name, req.Kind, pq.Array(req.Prefixes), req.Threshold, req.WindowSeconds,
+		req.MaxDuration, req.BusinessStart, req.BusinessEnd, pq.Array(req.BusinessDays), req.Timezone,
+		pq.Array(req.Actions), req.WebhookURL, req.Enabled == nil || *req.Enabled)
+
+	return fm.saved(scanFraudRule(row))
+}
+
+func (fm *FraudManager) DeleteRule(id string) error {
+	if !isUUID(id) {
+		return ErrFraudRuleNotFound
+	}
+	res, err := fm.db.Exec(`DELETE FROM fraud_rules WHERE rule_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete fraud rule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrFraudRuleNotFound
+	}
+	if err := fm.loadRules(); err != nil {
+		log.Printf("Failed to reload fraud rules: %v", err)
+	}
+	return nil
+}
+
+// saved reloads the rules after a change so live checks use it right away.
+func (fm *FraudManager) saved(rule *models.FraudRule, err error) (*models.FraudRule, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err := fm.loadRules(); err != nil {
+		log.Printf("Failed to reload fraud rules: %v", err)
+	}
+	return rule, nil
+}
+
+// normalizeFraudRule fills in defaults and checks that the fields the rule
+// kind relies on are set.
+func normalizeFraudRule(req *request.FraudRuleRequest) error {
+	prefixes := make([]string, 0, len(req.Prefixes))
+	for _, p := range req.Prefixes {
+		p = strings.TrimPrefix(strings.TrimSpace(p), "+")
+		if p == "" || strings.Trim(p, "0123456789") != "" {
+			return fmt.Errorf("%w: prefixes must contain digits only", ErrInvalidFraudRule)
+		}
+		prefixes = append(prefixes, p)
+	}
+	req.Prefixes = prefixes
+
+	if len(req.Actions) == 0 {
+		req.Actions = []string{FraudActionAlert}
+	}
+	if req.BusinessDays == nil {
+		req.BusinessDays = []int64{1, 2, 3, 4, 5}
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidFraudRule, req.Timezone)
+	}
+
+	switch req.Kind {
+	case FraudHighCost:
+		if len(req.Prefixes) == 0 || req.Threshold < 1 || req.WindowSeconds < 1 {
+			return fmt.Errorf("%w: high_cost rules need prefixes, threshold and window_seconds", ErrInvalidFraudRule)
+		}
+	case FraudConcurrency:
+		if req.Threshold < 1 {
+			return fmt.Errorf("%w: concurrency rules need a threshold", ErrInvalidFraudRule)
+		}
+	case FraudAfterHours:
+		if req.BusinessStart == "" || req.BusinessEnd == "" {
+			return fmt.Errorf("%w: after_hours rules need business_start and business_end", ErrInvalidFraudRule)
+		}
+	case FraudShortCalls:
+		if req.Threshold < 1 || req.WindowSeconds < 1 || req.MaxDuration < 1 {
+			return fmt.Errorf("%w: short_calls rules need threshold, window_seconds and max_duration", ErrInvalidFraudRule)
+		}
+	}
+	return nil
+}
+
+// eventExtension returns the extension that placed a channel: the user part
+// of its presence id, or the SIP username it authenticated with.
+func eventExtension(ev *eventsocket.Event) string {
+	if presence := ev.Get("Channel-Presence-Id"); presence != "" {
+		if i := strings.Index(presence, "@"); i > 0 {
+			return presence[:i]
+		}
+	}
+	return ev.Get("Caller-Username")
+}
+
+// withinBusinessHours reports whether t falls in the business hours of a
+// rule. Hours that end before they start run past midnight.
+func withinBusinessHours(rule models.FraudRule, t time.Time) bool {
+	loc, err := time.LoadLocation(rule.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+
+	open := false
+	for _, day := range rule.BusinessDays {
+		if day == int64(local.Weekday()) {
+			open = true
+		}
+	}
+	if !open {
+		return false
+	}
+
+	start, end := clockMinutes(rule.BusinessStart.String), clockMinutes(rule.BusinessEnd.String)
+	now := local.Hour()*60 + local.Minute()
+	if start <= end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+func clockMinutes(hhmm string) int {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0
+	}
+	return t.Hour()*60 + t.Minute()
+}
+
+func matchesPrefix(digits string, prefixes []string) bool {
+	if digits == "" {
+		return false
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(digits, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimTimes drops the times before cutoff from an ascending list.
+func trimTimes(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
+
+func fraudKey(rule models.FraudRule, domainUUID, extension string) string {
+	return rule.RuleUUID + "/" + domainUUID + "/" + extension
+}
+
+func scanFraudRule(row rowScanner) (*models.FraudRule, error) {
+	var rule models.FraudRule
+	err := row.Scan(&rule.RuleUUID, &rule.DomainUUID, &rule.Name, &rule.Kind, pq.Array(&rule.Prefixes),
+		&rule.Threshold, &rule.WindowSeconds, &rule.MaxDuration, &rule.BusinessStart, &rule.BusinessEnd,
+		pq.Array(&rule.BusinessDays), &rule.Timezone, pq.Array(&rule.Actions), &rule.WebhookURL, &rule.Enabled,
+		&rule.CreatedAt, &rule.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrFraudRuleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan fraud rule: %w", err)
+	}
+	return &rule, nil
+}
+
+func scanFraudEvent(row rowScanner) (*models.FraudEvent, error) {
+	var e models.FraudEvent
+	err := row.Scan(&e.EventUUID, &e.RuleUUID, &e.DomainUUID, &e.Kind, &e.Extension, &e.CallUUID, &e.Number,
+		&e.Detail, pq.Array(&e.Actions), &e.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan fraud event: %w", err)
+	}
+	return &e, nil
+}
+
+func scanFraudBlock(row rowScanner) (*models.FraudBlock, error) {
+	var b models.FraudBlock
+	err := row.Scan(&b.BlockUUID, &b.DomainUUID, &b.Extension, &b.RuleUUID, &b.Reason, &b.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrFraudBlockNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan fraud block: %w", err)
+	}
+	return &b, nil
+}
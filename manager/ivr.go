@@ -0,0 +1,377 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrIVRCallNotFound = errors.New("call not found")
+	ErrGatherNotFound  = errors.New("gather not found")
+	ErrInvalidIVR      = errors.New("invalid IVR request")
+)
+
+// Gather statuses.
+const (
+	GatherPending   = "pending"
+	GatherCompleted = "completed"
+	GatherNoInput   = "no_input"
+	GatherHangup    = "hangup"
+)
+
+var (
+	terminatorPattern = regexp.MustCompile(`^([0-9*#]{1,4}|none)$`)
+	// Regexes are passed as a single play_and_get_digits argument, so they
+	// cannot contain spaces or quotes.
+	digitRegexPattern = regexp.MustCompile(`^[0-9A-Za-z\\\[\]{}()|^$*+?.,#-]{1,64}$`)
+)
+
+// Gather is one play-and-collect on a call.
+type Gather struct {
+	GatherUUID  string
+	CallUUID    string
+	DomainUUID  string
+	Status      string
+	Digits      string
+	CallbackURL string
+	CreatedAt   time.Time
+	CompletedAt time.Time
+}
+
+// GatherResult is the JSON body posted to the callback once a gather ends.
+type GatherResult struct {
+	Event       string    `json:"event"`
+	GatherUUID  string    `json:"gather_uuid"`
+	CallUUID    string    `json:"call_uuid"`
+	Status      string    `json:"status"`
+	Digits      string    `json:"digits"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// IVRManager plays prompts into live calls and runs play_and_get_digits on
+// them, reporting the collected digits once FreeSWITCH is done.
+type IVRManager struct {
+	eslMgr *ESLManager
+	config config.IVRConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	gathers map[string]*Gather
+}
+
+func NewIVRManager(eslMgr *ESLManager, cfg config.IVRConfig) *IVRManager {
+	im := &IVRManager{
+		eslMgr:  eslMgr,
+		config:  cfg,
+		client:  &http.Client{Timeout: cfg.WebhookTimeout},
+		gathers: make(map[string]*Gather),
+	}
+	eslMgr.Subscribe("CHANNEL_EXECUTE_COMPLETE", im.handleExecuteComplete)
+	eslMgr.Subscribe("CHANNEL_HANGUP_COMPLETE", im.handleHangup)
+	return im
+}
+
+// Run forgets gathers that ended more than Retention ago.
+func (im *IVRManager) Run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		im.mu.Lock()
+		for id, g := range im.gathers {
+			if !g.CompletedAt.IsZero() && time.Since(g.CompletedAt) > im.config.Retention {
+				delete(im.gathers, id)
+			}
+		}
+		im.mu.Unlock()
+	}
+}
+
+// Play starts a file, or speaks text, on a live call. It returns once
+// FreeSWITCH has queued it.
+func (im *IVRManager) Play(callUUID string, req request.PlayRequest) error {
+	if !isUUID(callUUID) {
+		return ErrIVRCallNotFound
+	}
+
+	var err error
+	if req.File != "" {
+		if !soundFile(im.eslMgr.config.SoundsDir, req.File) {
+			return fmt.Errorf("%w: invalid file %q", ErrInvalidIVR, req.File)
+		}
+		leg := req.Leg
+		if leg == "" {
+			leg = "aleg"
+		}
+		_, err = im.eslMgr.api(fmt.Sprintf("uuid_broadcast %s %s %s", callUUID, req.File, leg))
+	} else {
+		// uuid_broadcast splits its arguments on spaces, so text is spoken
+		// with the speak application instead.
+		if req.Leg != "" && req.Leg != "aleg" {
+			return fmt.Errorf("%w: text can only be spoken to the aleg", ErrInvalidIVR)
+		}
+		engine, voice := req.Engine, req.Voice
+		if engine == "" {
+			engine = "flite"
+		}
+		if voice == "" {
+			voice = "kal"
+		}
+		if !appArgPattern.MatchString(engine) || !appArgPattern.MatchString(voice) {
+			return fmt.Errorf("%w: invalid speak engine or voice", ErrInvalidIVR)
+		}
+		if strings.ContainsAny(req.Text, "|\\\r\n\x00") {
+			return fmt.Errorf("%w: invalid speak text", ErrInvalidIVR)
+		}
+		err = im.eslMgr.execute(callUUID, "speak", fmt.Sprintf("%s|%s|%s", engine, voice, req.Text), "")
+	}
+	return ivrError(err)
+}
+
+// Gather queues play_and_get_digits on a call. The result is posted to the
+// callback and can be fetched with GetGather once the caller is done.
+func (im *IVRManager) Gather(callUUID string, req request.GatherRequest) (*Gather, error) {
+	if !isUUID(callUUID) {
+		return nil, ErrIVRCallNotFound
+	}
+	if err := normalizeGather(&req, im.eslMgr.config.SoundsDir); err != nil {
+		return nil, err
+	}
+	// The domain is kept so the gather can still be scoped to its tenant
+	// after the call has hung up.
+	domainUUID, found, err := im.eslMgr.CallDomain(callUUID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrIVRCallNotFound
+	}
+
+	g := &Gather{
+		GatherUUID:  newUUID(),
+		CallUUID:    callUUID,
+		DomainUUID:  domainUUID,
+		Status:      GatherPending,
+		CallbackURL: req.CallbackURL,
+		CreatedAt:   time.Now(),
+	}
+	args := []string{
+		strconv.Itoa(req.MinDigits),
+		strconv.Itoa(req.MaxDigits),
+		strconv.Itoa(req.Tries),
+		strconv.Itoa(req.TimeoutMS),
+		req.Terminators,
+		req.File,
+		req.InvalidFile,
+		gatherVar(g.GatherUUID),
+		req.DigitRegex,
+	}
+	if req.DigitTimeoutMS > 0 {
+		args = append(args, strconv.Itoa(req.DigitTimeoutMS))
+	}
+
+	// The gather is known before it can complete.
+	im.mu.Lock()
+	im.gathers[g.GatherUUID] = g
+	im.mu.Unlock()
+
+	if err := im.eslMgr.execute(callUUID, "play_and_get_digits", strings.Join(args, " "), g.GatherUUID); err != nil {
+		im.mu.Lock()
+		delete(im.gathers, g.GatherUUID)
+		im.mu.Unlock()
+		return nil, ivrError(err)
+	}
+	log.Printf("Gather %s queued on call %s", g.GatherUUID, callUUID)
+
+	gather := *g
+	return &gather, nil
+}
+
+// GetGather returns a gather of the given call.
+func (im *IVRManager) GetGather(callUUID, gatherUUID string) (*Gather, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	g := im.gathers[gatherUUID]
+	if g == nil || g.CallUUID != callUUID {
+		return nil, ErrGatherNotFound
+	}
+	gather := *g
+	return &gather, nil
+}
+
+// normalizeGather validates a gather and fills in the defaults: one try of
+// one digit, a 5 second timeout, # to end input and digits only. Files are
+// checked with soundFile.
+func normalizeGather(req *request.GatherRequest, soundsDir string) error {
+	if req.MinDigits == 0 {
+		req.MinDigits = 1
+	}
+	if req.MaxDigits == 0 {
+		req.MaxDigits = req.MinDigits
+	}
+	if req.MaxDigits < req.MinDigits {
+		return fmt.Errorf("%w: max_digits is below min_digits", ErrInvalidIVR)
+	}
+	if req.Tries == 0 {
+		req.Tries = 1
+	}
+	if req.TimeoutMS == 0 {
+		req.TimeoutMS = 5000
+	}
+	if req.Terminators == "" {
+		req.Terminators = "#"
+	}
+	if req.DigitRegex == "" {
+		req.DigitRegex = `\d+`
+	}
+
+	if !terminatorPattern.MatchString(req.Terminators) {
+		return fmt.Errorf("%w: terminators must be up to 4 of 0-9, * and #, or none", ErrInvalidIVR)
+	}
+	if !soundFile(soundsDir, req.File) {
+		return fmt.Errorf("%w: invalid file %q", ErrInvalidIVR, req.File)
+	}
+	if req.InvalidFile == "" {
+		req.InvalidFile = "silence_stream://250"
+	} else if !soundFile(soundsDir, req.InvalidFile) {
+		return fmt.Errorf("%w: invalid invalid_file %q", ErrInvalidIVR, req.InvalidFile)
+	}
+	if !digitRegexPattern.MatchString(req.DigitRegex) {
+		return fmt.Errorf("%w: invalid digit_regex", ErrInvalidIVR)
+	}
+	if _, err := regexp.Compile(req.DigitRegex); err != nil {
+		return fmt.Errorf("%w: invalid digit_regex: %v", ErrInvalidIVR, err)
+	}
+	return nil
+}
+
+func (im *IVRManager) handleExecuteComplete(ev *eventsocket.Event) {
+	if ev.Get("Application") != "play_and_get_digits" {
+		return
+	}
+	gatherUUID := ev.Get("Application-Uuid")
+
+	im.mu.Lock()
+	g := im.gathers[gatherUUID]
+	im.mu.Unlock()
+	if g == nil {
+		return
+	}
+
+	name := gatherVar(gatherUUID)
+	digits := ev.Get("Variable_" + name)
+	if digits == "" {
+		// Variables are left out of events unless FreeSWITCH includes
+		// them, so the channel is asked as well.
+		if resp, err := im.eslMgr.api(fmt.Sprintf("uuid_getvar %s %s", g.CallUUID, name)); err == nil {
+			if v := strings.TrimSpace(resp.Body); v != "_undef_" {
+				digits = v
+			}
+		}
+	}
+
+	status := GatherCompleted
+	if digits == "" {
+		status = GatherNoInput
+	}
+	im.complete(gatherUUID, status, digits)
+}
+
+func (im *IVRManager) handleHangup(ev *eventsocket.Event) {
+	callUUID := ev.Get("Unique-Id")
+
+	im.mu.Lock()
+	var pending []string
+	for id, g := range im.gathers {
+		if g.CallUUID == callUUID && g.Status == GatherPending {
+			pending = append(pending, id)
+		}
+	}
+	im.mu.Unlock()
+
+	for _, id := range pending {
+		im.complete(id, GatherHangup, "")
+	}
+}
+
+// complete ends a pending gather and posts its result.
+func (im *IVRManager) complete(gatherUUID, status, digits string) {
+	im.mu.Lock()
+	g := im.gathers[gatherUUID]
+	if g == nil || g.Status != GatherPending {
+		im.mu.Unlock()
+		return
+	}
+	g.Status, g.Digits, g.CompletedAt = status, digits, time.Now()
+	gather := *g
+	im.mu.Unlock()
+
+	log.Printf("Gather %s on call %s ended: %s", gather.GatherUUID, gather.CallUUID, gather.Status)
+	im.sendResult(gather)
+}
+
+// sendResult posts a gather result to its callback, or the default webhook.
+// Without either the result is only kept for GetGather.
+func (im *IVRManager) sendResult(g Gather) {
+	url := g.CallbackURL
+	if url == "" {
+		url = im.config.WebhookURL
+	}
+	if url == "" {
+		return
+	}
+
+	body, err := json.Marshal(GatherResult{
+		Event:       "gather.completed",
+		GatherUUID:  g.GatherUUID,
+		CallUUID:    g.CallUUID,
+		Status:      g.Status,
+		Digits:      g.Digits,
+		CompletedAt: g.CompletedAt,
+	})
+	if err != nil {
+		log.Printf("Failed to encode gather result: %v", err)
+		return
+	}
+
+	resp, err := im.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to send result of gather %s: %v", g.GatherUUID, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Result of gather %s was rejected with status %d", g.GatherUUID, resp.StatusCode)
+	}
+}
+
+// gatherVar is the channel variable play_and_get_digits stores the digits
+// of a gather in.
+func gatherVar(gatherUUID string) string {
+	return "gather_" + strings.ReplaceAll(gatherUUID, "-", "")
+}
+
+func ivrError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "no such channel") || strings.Contains(msg, "invalid session id") {
+		return ErrIVRCallNotFound
+	}
+	return err
+}
@@ -0,0 +1,473 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+	"github.com/lib/pq"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrGatewayNotFound = errors.New("gateway not found")
+	ErrGatewayExists   = errors.New("gateway already exists")
+	ErrRouteNotFound   = errors.New("route not found")
+	ErrInvalidRoute    = errors.New("invalid route")
+)
+
+// q850Causes maps Q.850 cause codes to the names FreeSWITCH reports them
+// under.
+var q850Causes = map[int]string{
+	1:   "UNALLOCATED_NUMBER",
+	2:   "NO_ROUTE_TRANSIT_NET",
+	3:   "NO_ROUTE_DESTINATION",
+	6:   "CHANNEL_UNACCEPTABLE",
+	16:  "NORMAL_CLEARING",
+	17:  "USER_BUSY",
+	18:  "NO_USER_RESPONSE",
+	19:  "NO_ANSWER",
+	20:  "SUBSCRIBER_ABSENT",
+	21:  "CALL_REJECTED",
+	22:  "NUMBER_CHANGED",
+	27:  "DESTINATION_OUT_OF_ORDER",
+	28:  "INVALID_NUMBER_FORMAT",
+	29:  "FACILITY_REJECTED",
+	31:  "NORMAL_UNSPECIFIED",
+	34:  "NORMAL_CIRCUIT_CONGESTION",
+	38:  "NETWORK_OUT_OF_ORDER",
+	41:  "NORMAL_TEMPORARY_FAILURE",
+	42:  "SWITCH_CONGESTION",
+	44:  "REQUESTED_CHAN_UNAVAIL",
+	50:  "FACILITY_NOT_SUBSCRIBED",
+	55:  "INCOMING_CALL_BARRED",
+	57:  "BEARERCAPABILITY_NOTAUTH",
+	58:  "BEARERCAPABILITY_NOTAVAIL",
+	63:  "SERVICE_UNAVAILABLE",
+	65:  "BEARERCAPABILITY_NOTIMPL",
+	66:  "CHAN_NOT_IMPLEMENTED",
+	69:  "FACILITY_NOT_IMPLEMENTED",
+	79:  "SERVICE_NOT_IMPLEMENTED",
+	88:  "INCOMPATIBLE_DESTINATION",
+	95:  "INVALID_MSG_UNSPECIFIED",
+	96:  "MANDATORY_IE_MISSING",
+	102: "RECOVERY_ON_TIMER_EXPIRE",
+	111: "PROTOCOL_ERROR",
+	127: "INTERWORKING",
+}
+
+const gatewayColumns = `gateway_uuid, name, enabled, description, created_at, updated_at`
+
+const routeColumns = `r.route_uuid, r.gateway_uuid, g.name, r.domain_uuid, r.prefix, r.rate, r.priority, r.enabled,
+	r.description, r.created_at, r.updated_at`
+
+const callRouteColumns = `leg_uuid, call_uuid, domain_uuid, number, route_uuid, gateway, prefix, rate, attempt,
+	hangup_cause, hangup_cause_q850, billsec, created_at, updated_at`
+
+// RouteCandidate is a gateway that can carry a call to a number, as picked
+// from the rate tables.
+type RouteCandidate struct {
+	RouteUUID string  `json:"route_uuid"`
+	Gateway   string  `json:"gateway"`
+	Prefix    string  `json:"prefix"`
+	Rate      float64 `json:"rate"`
+}
+
+// RouteManager keeps the gateways and their rate tables, picks the routes
+// for external numbers and records which route each call leg took.
+type RouteManager struct {
+	db       *sql.DB
+	failover map[string]bool
+}
+
+func NewRouteManager(db *sql.DB, cfg config.LCRConfig) *RouteManager {
+	rm := &RouteManager{
+		db:       db,
+		failover: make(map[string]bool),
+	}
+	for _, cause := range cfg.FailoverCauses {
+		if code, err := strconv.Atoi(cause); err == nil {
+			name, ok := q850Causes[code]
+			if !ok {
+				log.Printf("Ignoring unknown Q.850 failover cause %d", code)
+				continue
+			}
+			cause = name
+		}
+		rm.failover[strings.ToUpper(cause)] = true
+	}
+	return rm
+}
+
+// Lookup returns the routes for an E.164 number in the order they should be
+// tried. Each gateway contributes its longest matching prefix; gateways are
+// then ordered by prefix length, rate and priority, like mod_lcr does.
+func (rm *RouteManager) Lookup(domainUUID, e164 string) ([]RouteCandidate, error) {
+	rows, err := rm.db.Query(`
+		SELECT route_uuid, gateway, prefix, rate FROM (
+			SELECT DISTINCT ON (r.gateway_uuid) r.route_uuid, g.name AS gateway, r.prefix, r.rate, r.priority
+			FROM lcr_routes r JOIN gateways g ON g.gateway_uuid = r.gateway_uuid
+			WHERE r.enabled AND g.enabled AND starts_with($1, r.prefix)
+				AND (r.domain_uuid IS NULL OR r.domain_uuid = NULLIF($2, '')::uuid)
+			ORDER BY r.gateway_uuid, length(r.prefix) DESC, r.domain_uuid NULLS LAST, r.rate, r.priority
+		) best
+		ORDER BY length(prefix) DESC, rate, priority, gateway`,
+		strings.TrimPrefix(e164, "+"), domainUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up routes: %w", err)
+	}
+	defer rows.Close()
+
+	var routes []RouteCandidate
+	for rows.Next() {
+		var r RouteCandidate
+		if err := rows.Scan(&r.RouteUUID, &r.Gateway, &r.Prefix, &r.Rate); err != nil {
+			return nil, fmt.Errorf("failed to scan route: %w", err)
+		}
+		routes = append(routes, r)
+	}
+	return routes, rows.Err()
+}
+
+// Failover reports whether a call that failed with cause should be retried
+// on the next route.
+func (rm *RouteManager) Failover(cause string) bool {
+	return rm.failover[cause]
+}
+
+// failOnSingleReject renders the failover causes for FreeSWITCH's
+// fail_on_single_reject, which stops a "|" failover list on any cause
+// except the ones listed after "!".
+func (rm *RouteManager) failOnSingleReject() string {
+	causes := make([]string, 0, len(rm.failover))
+	for cause := range rm.failover {
+		causes = append(causes, cause)
+	}
+	sort.Strings(causes)
+	return "!" + strings.Join(causes, ",")
+}
+
+// RecordAttempt stores the outcome of one call leg. Later updates for the
+// same leg, e.g. from the hangup event, keep the most complete data.
+func (rm *RouteManager) RecordAttempt(cr models.CallRoute) error {
+	_, err := rm.db.Exec(`
+		INSERT INTO call_routes (leg_uuid, call_uuid, domain_uuid, number, route_uuid, gateway, prefix, rate, attempt,
+			hangup_cause, hangup_cause_q850, billsec)
+		VALUES ($1, $2, NULLIF($3, '')::uuid, $4, NULLIF($5, '')::uuid, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (leg_uuid) DO UPDATE
+		SET hangup_cause = COALESCE(NULLIF(EXCLUDED.hangup_cause, ''), call_routes.hangup_cause),
+			hangup_cause_q850 = COALESCE(EXCLUDED.hangup_cause_q850, call_routes.hangup_cause_q850),
+			billsec = GREATEST(EXCLUDED.billsec, call_routes.billsec), updated_at = now()`,
+		cr.LegUUID, cr.CallUUID, cr.DomainUUID.String, cr.Number, cr.RouteUUID.String, cr.Gateway, cr.Prefix, cr.Rate,
+		cr.Attempt, cr.HangupCause, cr.HangupCauseQ850, cr.BillSec)
+	if err != nil {
+		return fmt.Errorf("failed to record call route: %w", err)
+	}
+	return nil
+}
+
+// handleHangupEvent records the legs that were placed through an LCR route,
+// which carry the lcr_* variables set when the call was originated.
+func (rm *RouteManager) handleHangupEvent(ev *eventsocket.Event) {
+	routeUUID := ev.Get("Variable_lcr_route_uuid")
+	callUUID := ev.Get("Variable_lcr_call_uuid")
+	legUUID := ev.Get("Unique-Id")
+	if routeUUID == "" || !isUUID(callUUID) || !isUUID(legUUID) {
+		return
+	}
+
+	cr := models.CallRoute{
+		LegUUID:     legUUID,
+		CallUUID:    callUUID,
+		DomainUUID:  sql.NullString{String: ev.Get("Variable_lcr_domain_uuid")},
+		Number:      ev.Get("Variable_lcr_number"),
+		RouteUUID:   sql.NullString{String: routeUUID},
+		Gateway:     ev.Get("Variable_lcr_gateway"),
+		Prefix:      ev.Get("Variable_lcr_prefix"),
+		HangupCause: ev.Get("Hangup-Cause"),
+	}
+	cr.Rate, _ = strconv.ParseFloat(ev.Get("Variable_lcr_rate"), 64)
+	cr.Attempt, _ = strconv.Atoi(ev.Get("Variable_lcr_attempt"))
+	cr.BillSec, _ = strconv.Atoi(ev.Get("Variable_billsec"))
+	if code, err := strconv.ParseInt(ev.Get("Variable_hangup_cause_q850"), 10, 64); err == nil {
+		cr.HangupCauseQ850 = sql.NullInt64{Int64: code, Valid: true}
+	} else {
+		cr.HangupCauseQ850 = causeCode(cr.HangupCause)
+	}
+
+	if err := rm.RecordAttempt(cr); err != nil {
+		log.Printf("Failed to record route of leg %s: %v", legUUID, err)
+	}
+}
+
+// ListCallRoutes returns every routed leg of a call in the order they were
+// attempted. Legs are stored against the uuid of the first caller attempt,
+// so the uuid of any leg of the call finds them all.
+func (rm *RouteManager) ListCallRoutes(uuid string) ([]models.CallRoute, error) {
+	if !isUUID(uuid) {
+		return nil, nil
+	}
+
+	rows, err := rm.db.Query(`SELECT `+callRouteColumns+` FROM call_routes
+		WHERE call_uuid = COALESCE((SELECT call_uuid FROM call_routes WHERE leg_uuid = $1), $1)
+		ORDER BY attempt, created_at`, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch call routes: %w", err)
+	}
+	defer rows.Close()
+
+	var routes []models.CallRoute
+	for rows.Next() {
+		var cr models.CallRoute
+		if err := rows.Scan(&cr.LegUUID, &cr.CallUUID, &cr.DomainUUID, &cr.Number, &cr.RouteUUID, &cr.Gateway,
+			&cr.Prefix, &cr.Rate, &cr.Attempt, &cr.HangupCause, &cr.HangupCauseQ850, &cr.BillSec,
+			&cr.CreatedAt, &cr.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan call route: %w", err)
+		}
+		routes = append(routes, cr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read call routes: %w", err)
+	}
+	return routes, nil
+}
+
+func (rm *RouteManager) CreateGateway(req request.GatewayRequest) (*models.Gateway, error) {
+	row := rm.db.QueryRow(`
+		INSERT INTO gateways (gateway_uuid, name, enabled, description)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+gatewayColumns,
+		newUUID(), req.Name, req.Enabled == nil || *req.Enabled, req.Description)
+
+	return scanGateway(row)
+}
+
+func (rm *RouteManager) ListGateways() ([]models.Gateway, error) {
+	rows, err := rm.db.Query(`SELECT ` + gatewayColumns + ` FROM gateways ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch gateways: %w", err)
+	}
+	defer rows.Close()
+
+	var gateways []models.Gateway
+	for rows.Next() {
+		gw, err := scanGateway(rows)
+		if err != nil {
+			return nil, err
+		}
+		gateways = append(gateways, *gw)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read gateways: %w", err)
+	}
+	return gateways, nil
+}
+
+func (rm *RouteManager) GetGateway(id string) (*models.Gateway, error) {
+	if !isUUID(id) {
+		return nil, ErrGatewayNotFound
+	}
+	row := rm.db.QueryRow(`SELECT `+gatewayColumns+` FROM gateways WHERE gateway_uuid = $1`, id)
+	return scanGateway(row)
+}
+
+func (rm *RouteManager) UpdateGateway(id string, req request.GatewayRequest) (*models.Gateway, error) {
+	if !isUUID(id) {
+		return nil, ErrGatewayNotFound
+	}
+
+	row := rm.db.QueryRow(`
+		UPDATE gateways SET name = $2, enabled = $3, description = $4, updated_at = now()
+		WHERE gateway_uuid = $1
+		RETURNING `+gatewayColumns,
+		id, req.Name, req.Enabled == nil || *req.Enabled, req.Description)
+
+	return scanGateway(row)
+}
+
+// DeleteGateway removes a gateway together with its rate table.
+func (rm *RouteManager) DeleteGateway(id string) error {
+	if !isUUID(id) {
+		return ErrGatewayNotFound
+	}
+	res, err := rm.db.Exec(`DELETE FROM gateways WHERE gateway_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete gateway: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrGatewayNotFound
+	}
+	return nil
+}
+
+func (rm *RouteManager) CreateRoute(req request.RouteRequest) (*models.Route, error) {
+	if err := normalizeRoute(&req); err != nil {
+		return nil, err
+	}
+
+	row := rm.db.QueryRow(`
+		WITH r AS (
+			INSERT INTO lcr_routes (route_uuid, gateway_uuid, domain_uuid, prefix, rate, priority, enabled, description)
+			VALUES ($1, $2, NULLIF($3, '')::uuid, $4, $5, $6, $7, $8)
+			RETURNING *
+		)
+		SELECT `+routeColumns+` FROM r JOIN gateways g ON g.gateway_uuid = r.gateway_uuid`,
+		newUUID(), req.GatewayUUID, req.DomainUUID, req.Prefix, req.Rate, req.Priority,
+		req.Enabled == nil || *req.Enabled, req.Description)
+
+	return scanRoute(row)
+}
+
+// ListRoutes returns the rate table entries matching the optional gateway,
+// tenant and prefix filters, most specific prefix first.
+func (rm *RouteManager) ListRoutes(gatewayUUID, domainUUID, prefix string, limit, offset int) ([]models.Route, int, error) {
+	if (gatewayUUID != "" && !isUUID(gatewayUUID)) || (domainUUID != "" && !isUUID(domainUUID)) {
+		return nil, 0, fmt.Errorf("%w: invalid gateway_uuid or domain_uuid filter", ErrInvalidRoute)
+	}
+
+	where := `WHERE (NULLIF($1, '') IS NULL OR r.gateway_uuid = NULLIF($1, '')::uuid)
+		AND (NULLIF($2, '') IS NULL OR r.domain_uuid IS NULL OR r.domain_uuid = NULLIF($2, '')::uuid)
+		AND ($3 = '' OR r.prefix LIKE $3 || '%')`
+	prefix = strings.TrimPrefix(prefix, "+")
+
+	var total int
+	if err := rm.db.QueryRow(`SELECT COUNT(*) FROM lcr_routes r `+where, gatewayUUID, domainUUID, prefix).
+		Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count routes: %w", err)
+	}
+
+	rows, err := rm.db.Query(`SELECT `+routeColumns+`
+		FROM lcr_routes r JOIN gateways g ON g.gateway_uuid = r.gateway_uuid `+where+`
+		ORDER BY length(r.prefix) DESC, r.prefix, r.rate LIMIT $4 OFFSET $5`,
+		gatewayUUID, domainUUID, prefix, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch routes: %w", err)
+	}
+	defer rows.Close()
+
+	var routes []models.Route
+	for rows.Next() {
+		route, err := scanRoute(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		routes = append(routes, *route)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read routes: %w", err)
+	}
+
+	return routes, total, nil
+}
+
+func (rm *RouteManager) GetRoute(id string) (*models.Route, error) {
+	if !isUUID(id) {
+		return nil, ErrRouteNotFound
+	}
+	row := rm.db.QueryRow(`SELECT `+routeColumns+`
+		FROM lcr_routes r JOIN gateways g ON g.gateway_uuid = r.gateway_uuid
+		WHERE r.route_uuid = $1`, id)
+	return scanRoute(row)
+}
+
+func (rm *RouteManager) UpdateRoute(id string, req request.RouteRequest) (*models.Route, error) {
+	if !isUUID(id) {
+		return nil, ErrRouteNotFound
+	}
+	if err := normalizeRoute(&req); err != nil {
+		return nil, err
+	}
+
+	row := rm.db.QueryRow(`
+		WITH r AS (
+			UPDATE lcr_routes
+			SET gateway_uuid = $2, domain_uuid = NULLIF($3, '')::uuid, prefix = $4, rate = $5, priority = $6,
+				enabled = $7, description = $8, updated_at = now()
+			WHERE route_uuid = $1
+			RETURNING *
+		)
+		SELECT `+routeColumns+` FROM r JOIN gateways g ON g.gateway_uuid = r.gateway_uuid`,
+		id, req.GatewayUUID, req.DomainUUID, req.Prefix, req.Rate, req.Priority,
+		req.Enabled == nil || *req.Enabled, req.Description)
+
+	return scanRoute(row)
+}
+
+func (rm *RouteManager) DeleteRoute(id string) error {
+	if !isUUID(id) {
+		return ErrRouteNotFound
+	}
+	res, err := rm.db.Exec(`DELETE FROM lcr_routes WHERE route_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete route: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRouteNotFound
+	}
+	return nil
+}
+
+// normalizeRoute strips a leading "+" from the prefix, which is matched
+// against E.164 digits only.
+func normalizeRoute(req *request.RouteRequest) error {
+	req.Prefix = strings.TrimPrefix(strings.TrimSpace(req.Prefix), "+")
+	for _, r := range req.Prefix {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("%w: prefix must contain digits only", ErrInvalidRoute)
+		}
+	}
+	if req.Priority == 0 {
+		req.Priority = 100
+	}
+	return nil
+}
+
+// causeCode returns the Q.850 code of a FreeSWITCH cause name.
+func causeCode(cause string) sql.NullInt64 {
+	for code, name := range q850Causes {
+		if name == cause {
+			return sql.NullInt64{Int64: int64(code), Valid: true}
+		}
+	}
+	return sql.NullInt64{}
+}
+
+func scanGateway(row rowScanner) (*models.Gateway, error) {
+	var gw models.Gateway
+	err := row.Scan(&gw.GatewayUUID, &gw.Name, &gw.Enabled, &gw.Description, &gw.CreatedAt, &gw.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrGatewayNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrGatewayExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan gateway: %w", err)
+	}
+	return &gw, nil
+}
+
+func scanRoute(row rowScanner) (*models.Route, error) {
+	var r models.Route
+	err := row.Scan(&r.RouteUUID, &r.GatewayUUID, &r.GatewayName, &r.DomainUUID, &r.Prefix, &r.Rate, &r.Priority,
+		&r.Enabled, &r.Description, &r.CreatedAt, &r.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRouteNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return nil, fmt.Errorf("%w: gateway does not exist", ErrInvalidRoute)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan route: %w", err)
+	}
+	return &r, nil
+}
@@ -0,0 +1,393 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+	"github.com/lib/pq"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrMonitorCallNotFound  = errors.New("call not found")
+	ErrMonitorForbidden     = errors.New("API key cannot monitor calls of this tenant")
+	ErrMonitorNotSupervisor = errors.New("supervisor shares no monitor group with a party to this call")
+	ErrInvalidMonitor       = errors.New("invalid monitor request")
+	ErrMonitorGroupNotFound = errors.New("monitor group not found")
+	ErrInvalidMonitorGroup  = errors.New("invalid monitor group")
+)
+
+// Monitor modes.
+const (
+	MonitorListen  = "listen"
+	MonitorWhisper = "whisper"
+	MonitorBarge   = "barge"
+)
+
+// Monitor session statuses.
+const (
+	MonitorDialing = "dialing"
+	MonitorActive  = "active"
+	MonitorEnded   = "ended"
+	MonitorFailed  = "failed"
+)
+
+// monitorModeVars are the eavesdrop variables of each mode. The "a" leg is
+// the monitored channel, the "b" leg the party it talks to. DTMF mode
+// switching is only left on for barge, so a supervisor sent in to listen or
+// whisper cannot raise the session to a mode they were not granted.
+var monitorModeVars = map[string]map[string]string{
+	MonitorListen: {
+		"eavesdrop_enable_dtmf": "false",
+	},
+	MonitorWhisper: {
+		"eavesdrop_enable_dtmf":  "false",
+		"eavesdrop_whisper_aleg": "true",
+	},
+	MonitorBarge: {
+		"eavesdrop_enable_dtmf":  "true",
+		"eavesdrop_whisper_aleg": "true",
+		"eavesdrop_whisper_bleg": "true",
+	},
+}
+
+const monitorSessionColumns = `session_uuid, call_uuid, domain_uuid, mode, supervisor, key_uuid, key_name, status,
+	hangup_cause, created_at, answered_at, ended_at`
+
+const monitorGroupColumns = `group_uuid, domain_uuid, name, supervisors, agents, created_at, updated_at`
+
+// MonitorManager rings supervisors into running calls with eavesdrop and
+// keeps an audit log of every session.
+type MonitorManager struct {
+	db     *sql.DB
+	eslMgr *ESLManager
+}
+
+func NewMonitorManager(db *sql.DB, eslMgr *ESLManager) *MonitorManager {
+	mm := &MonitorManager{
+		db:     db,
+		eslMgr: eslMgr,
+	}
+	eslMgr.Subscribe("CHANNEL_HANGUP_COMPLETE", mm.handleHangup)
+	return mm
+}
+
+// Monitor rings the supervisor's extension and, once answered, joins it to
+// callUUID in the requested mode. key is the API key asking for it; it can
+// only monitor calls of its own tenant. The supervisor must share a monitor
+// group with an extension on the call. The session is logged whether or
+// not the supervisor answers.
+func (mm *MonitorManager) Monitor(callUUID string, req request.MonitorRequest, key *models.APIKey) (*models.MonitorSession, error) {
+	modeVars, ok := monitorModeVars[req.Mode]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown mode %q", ErrInvalidMonitor, req.Mode)
+	}
+	if !isUUID(callUUID) {
+		return nil, ErrMonitorCallNotFound
+	}
+
+	domainUUID, err := mm.callDomain(callUUID)
+	if err != nil {
+		return nil, err
+	}
+	if !CanAccessDomain(key, domainUUID) {
+		return nil, ErrMonitorForbidden
+	}
+	if err := mm.checkSupervisor(callUUID, domainUUID, req.Supervisor); err != nil {
+		return nil, err
+	}
+
+	legs, err := mm.eslMgr.dialLegs(domainUUID, req.Supervisor)
+	if errors.Is(err, ErrInvalidOriginate) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMonitor, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(legs) != 1 || legs[0].route != nil || !strings.HasPrefix(legs[0].dial, "user/") {
+		return nil, fmt.Errorf("%w: supervisor must be a local extension", ErrInvalidMonitor)
+	}
+
+	sessionUUID := newUUID()
+	vars := map[string]string{
+		"origination_uuid":             sessionUUID,
+		"origination_caller_id_name":   "Monitor",
+		"origination_caller_id_number": req.Supervisor,
+		"monitor_session_uuid":         sessionUUID,
+		"monitor_call_uuid":            callUUID,
+		"monitor_mode":                 req.Mode,
+	}
+	for name, value := range modeVars {
+		vars[name] = value
+	}
+	if domainUUID != "" {
+		vars["domain_uuid"] = domainUUID
+	}
+	if req.CallerIDName != "" {
+		vars["origination_caller_id_name"] = req.CallerIDName
+	}
+	vars["originate_timeout"] = originateTimeout(req.Timeout)
+	if req.AutoAnswer {
+		vars["sip_auto_answer"] = "true"
+		vars["sip_h_Call-Info"] = fmt.Sprintf("<sip:%s>;answer-after=0", mm.eslMgr.config.Domain)
+	}
+	varBlock, err := formatChannelVars(vars)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMonitor, err)
+	}
+
+	if _, err := mm.db.Exec(`
+		INSERT INTO monitor_sessions (session_uuid, call_uuid, domain_uuid, mode, supervisor, key_uuid, key_name)
+		VALUES ($1, $2, NULLIF($3, '')::uuid, $4, $5, NULLIF($6, '')::uuid, $7)`,
+		sessionUUID, callUUID, domainUUID, req.Mode, req.Supervisor, key.KeyUUID, key.Name); err != nil {
+		return nil, fmt.Errorf("failed to log monitor session: %w", err)
+	}
+	log.Printf("Supervisor %s (key %s) requested %s of call %s", req.Supervisor, key.Name, req.Mode, callUUID)
+
+	_, err = mm.eslMgr.originate(fmt.Sprintf("originate %s%s &eavesdrop(%s)", varBlock, legs[0].dial, callUUID))
+	if err != nil {
+		if _, dbErr := mm.db.Exec(`
+			UPDATE monitor_sessions SET status = $2, hangup_cause = $3, ended_at = now()
+			WHERE session_uuid = $1 AND ended_at IS NULL`,
+			sessionUUID, MonitorFailed, HangupCause(err)); dbErr != nil {
+			log.Printf("Failed to log end of monitor session %s: %v", sessionUUID, dbErr)
+		}
+		return nil, err
+	}
+
+	// The hangup event may already have ended a short session.
+	if _, err := mm.db.Exec(`
+		UPDATE monitor_sessions SET status = $2, answered_at = now()
+		WHERE session_uuid = $1 AND status = $3`,
+		sessionUUID, MonitorActive, MonitorDialing); err != nil {
+		log.Printf("Failed to log answer of monitor session %s: %v", sessionUUID, err)
+	}
+	log.Printf("Supervisor %s started %s of call %s (session %s)", req.Supervisor, req.Mode, callUUID, sessionUUID)
+
+	return mm.GetSession(sessionUUID)
+}
+
+// callDomain returns the tenant of a running call.
+func (mm *MonitorManager) callDomain(callUUID string) (string, error) {
+	domainUUID, found, err := mm.eslMgr.channelDomain(callUUID)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", ErrMonitorCallNotFound
+	}
+	return domainUUID, nil
+}
+
+// checkSupervisor returns ErrMonitorNotSupervisor unless supervisor is in a
+// monitor group of the call's tenant whose agents include an extension on
+// one of the call's legs.
+func (mm *MonitorManager) checkSupervisor(callUUID, domainUUID, supervisor string) error {
+	channels, err := mm.eslMgr.ListChannels()
+	if err != nil {
+		return err
+	}
+	call := callUUID
+	for _, ch := range channels {
+		if ch.UUID == callUUID && ch.CallUUID != "" {
+			call = ch.CallUUID
+		}
+	}
+	extensions := []string{}
+	for _, ch := range channels {
+		if ch.UUID == callUUID || ch.UUID == call || ch.CallUUID == call {
+			if ext := ch.Extension(); ext != "" {
+				extensions = append(extensions, ext)
+			}
+		}
+	}
+
+	var allowed bool
+	err = mm.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM monitor_groups
+			WHERE domain_uuid = NULLIF($1, '')::uuid AND $2 = ANY (supervisors) AND agents && $3
+		)`, domainUUID, supervisor, pq.Array(extensions)).Scan(&allowed)
+	if err != nil {
+		return fmt.Errorf("failed to check monitor groups: %w", err)
+	}
+	if !allowed {
+		return ErrMonitorNotSupervisor
+	}
+	return nil
+}
+
+func (mm *MonitorManager) handleHangup(ev *eventsocket.Event) {
+	sessionUUID := ev.Get("Variable_monitor_session_uuid")
+	if !isUUID(sessionUUID) {
+		return
+	}
+
+	_, err := mm.db.Exec(`
+		UPDATE monitor_sessions
+		SET status = CASE WHEN answered_at IS NULL THEN $3 ELSE $2 END, hangup_cause = $4, ended_at = now()
+		WHERE session_uuid = $1 AND ended_at IS NULL`,
+		sessionUUID, MonitorEnded, MonitorFailed, ev.Get("Hangup-Cause"))
+	if err != nil {
+		log.Printf("Failed to log end of monitor session %s: %v", sessionUUID, err)
+		return
+	}
+	log.Printf("Monitor session %s of call %s ended: %s", sessionUUID, ev.Get("Variable_monitor_call_uuid"),
+		ev.Get("Hangup-Cause"))
+}
+
+func (mm *MonitorManager) GetSession(sessionUUID string) (*models.MonitorSession, error) {
+	row := mm.db.QueryRow(`SELECT `+monitorSessionColumns+` FROM monitor_sessions WHERE session_uuid = $1`, sessionUUID)
+	return scanMonitorSession(row)
+}
+
+// ListSessions returns the monitor audit log, newest first, filtered by
+// tenant and monitored call.
+func (mm *MonitorManager) ListSessions(domainUUID, callUUID string, limit, offset int) ([]models.MonitorSession, int, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, 0, ErrInvalidDomain
+	}
+	if callUUID != "" && !isUUID(callUUID) {
+		return nil, 0, fmt.Errorf("%w: call_uuid must be a UUID", ErrInvalidMonitor)
+	}
+	where := `WHERE (NULLIF($1, '') IS NULL OR domain_uuid = NULLIF($1, '')::uuid)
+		AND (NULLIF($2, '') IS NULL OR call_uuid = NULLIF($2, '')::uuid)`
+
+	var total int
+	if err := mm.db.QueryRow(`SELECT COUNT(*) FROM monitor_sessions `+where, domainUUID, callUUID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count monitor sessions: %w", err)
+	}
+
+	rows, err := mm.db.Query(`SELECT `+monitorSessionColumns+` FROM monitor_sessions `+where+`
+		ORDER BY created_at DESC LIMIT $3 OFFSET $4`, domainUUID, callUUID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch monitor sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.MonitorSession
+	for rows.Next() {
+		session, err := scanMonitorSession(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		sessions = append(sessions, *session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read monitor sessions: %w", err)
+	}
+
+	return sessions, total, nil
+}
+
+func scanMonitorSession(row rowScanner) (*models.MonitorSession, error) {
+	var s models.MonitorSession
+	err := row.Scan(&s.SessionUUID, &s.CallUUID, &s.DomainUUID, &s.Mode, &s.Supervisor, &s.KeyUUID, &s.KeyName,
+		&s.Status, &s.HangupCause, &s.CreatedAt, &s.AnsweredAt, &s.EndedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("monitor session not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan monitor session: %w", err)
+	}
+	return &s, nil
+}
+
+func (mm *MonitorManager) CreateGroup(req request.MonitorGroupRequest) (*models.MonitorGroup, error) {
+	if !isUUID(req.DomainUUID) {
+		return nil, fmt.Errorf("%w: domain_uuid is required", ErrInvalidMonitorGroup)
+	}
+	row := mm.db.QueryRow(`
+		INSERT INTO monitor_groups (group_uuid, domain_uuid, name, supervisors, agents)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+monitorGroupColumns,
+		newUUID(), req.DomainUUID, req.Name, pq.Array(req.Supervisors), pq.Array(req.Agents))
+
+	return scanMonitorGroup(row)
+}
+
+func (mm *MonitorManager) GetGroup(id string) (*models.MonitorGroup, error) {
+	if !isUUID(id) {
+		return nil, ErrMonitorGroupNotFound
+	}
+	row := mm.db.QueryRow(`SELECT `+monitorGroupColumns+` FROM monitor_groups WHERE group_uuid = $1`, id)
+	return scanMonitorGroup(row)
+}
+
+// ListGroups returns the groups of a tenant, or every group when domainUUID
+// is empty.
+func (mm *MonitorManager) ListGroups(domainUUID string) ([]models.MonitorGroup, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, ErrInvalidDomain
+	}
+	rows, err := mm.db.Query(`
+		SELECT `+monitorGroupColumns+` FROM monitor_groups
+		WHERE NULLIF($1, '') IS NULL OR domain_uuid = NULLIF($1, '')::uuid
+		ORDER BY name`, domainUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch monitor groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []models.MonitorGroup
+	for rows.Next() {
+		group, err := scanMonitorGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, *group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read monitor groups: %w", err)
+	}
+	return groups, nil
+}
+
+func (mm *MonitorManager) UpdateGroup(id string, req request.MonitorGroupRequest) (*models.MonitorGroup, error) {
+	if !isUUID(id) {
+		return nil, ErrMonitorGroupNotFound
+	}
+	if !isUUID(req.DomainUUID) {
+		return nil, fmt.Errorf("%w: domain_uuid is required", ErrInvalidMonitorGroup)
+	}
+	row := mm.db.QueryRow(`
+		UPDATE monitor_groups
+		SET domain_uuid = $2, name = $3, supervisors = $4, agents = $5, updated_at = now()
+		WHERE group_uuid = $1
+		RETURNING `+monitorGroupColumns,
+		id, req.DomainUUID, req.Name, pq.Array(req.Supervisors), pq.Array(req.Agents))
+
+	return scanMonitorGroup(row)
+}
+
+func (mm *MonitorManager) DeleteGroup(id string) error {
+	if !isUUID(id) {
+		return ErrMonitorGroupNotFound
+	}
+	res, err := mm.db.Exec(`DELETE FROM monitor_groups WHERE group_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete monitor group: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrMonitorGroupNotFound
+	}
+	return nil
+}
+
+func scanMonitorGroup(row rowScanner) (*models.MonitorGroup, error) {
+	var g models.MonitorGroup
+	err := row.Scan(&g.GroupUUID, &g.DomainUUID, &g.Name, pq.Array(&g.Supervisors), pq.Array(&g.Agents),
+		&g.CreatedAt, &g.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMonitorGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan monitor group: %w", err)
+	}
+	return &g, nil
+}
@@ -0,0 +1,254 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/number"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrRuleNotFound  = errors.New("translation rule not found")
+	ErrInvalidRule   = errors.New("invalid translation rule")
+	ErrInvalidDomain = errors.New("invalid domain_uuid")
+)
+
+const ruleColumns = `rule_uuid, domain_uuid, applies_to, match_prefix, strip_digits, prepend, priority, description, created_at`
+
+// NumberManager resolves dialed numbers with the numbering settings and
+// translation rules of a tenant. Tenants without a number_settings row use
+// the defaults from configuration.
+type NumberManager struct {
+	db       *sql.DB
+	defaults number.Settings
+}
+
+func NewNumberManager(db *sql.DB, cfg config.NumberingConfig) *NumberManager {
+	return &NumberManager{
+		db: db,
+		defaults: number.Settings{
+			CountryCode:         cfg.CountryCode,
+			NationalPrefix:      cfg.NationalPrefix,
+			InternationalPrefix: cfg.InternationalPrefix,
+			ExtensionMaxLength:  cfg.ExtensionMaxLength,
+		},
+	}
+}
+
+// Resolve parses raw with the tenant settings and applies its translation
+// rules. Unparseable input returns an error wrapping number.ErrInvalidNumber.
+func (nm *NumberManager) Resolve(domainUUID, raw string) (number.Number, error) {
+	settings, err := nm.Settings(domainUUID)
+	if err != nil {
+		return number.Number{}, err
+	}
+
+	n, err := number.Parse(raw, settings)
+	if err != nil {
+		return n, fmt.Errorf("%w: %q", err, raw)
+	}
+
+	rules, err := nm.translationRules(domainUUID)
+	if err != nil {
+		return n, err
+	}
+	return number.Translate(n, rules), nil
+}
+
+// Variants returns the spellings raw may be stored under in v_xml_cdr. Input
+// that cannot be parsed is matched as typed.
+func (nm *NumberManager) Variants(domainUUID, raw string) ([]string, error) {
+	settings, err := nm.Settings(domainUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := nm.Resolve(domainUUID, raw)
+	if errors.Is(err, number.ErrInvalidNumber) {
+		return []string{number.Clean(raw)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return number.Variants(n, settings), nil
+}
+
+func (nm *NumberManager) Settings(domainUUID string) (number.Settings, error) {
+	if domainUUID == "" {
+		return nm.defaults, nil
+	}
+	if !isUUID(domainUUID) {
+		return number.Settings{}, ErrInvalidDomain
+	}
+
+	var s number.Settings
+	err := nm.db.QueryRow(`
+		SELECT country_code, national_prefix, international_prefix, extension_max_length
+		FROM number_settings WHERE domain_uuid = $1`, domainUUID).
+		Scan(&s.CountryCode, &s.NationalPrefix, &s.InternationalPrefix, &s.ExtensionMaxLength)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nm.defaults, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to load number settings: %w", err)
+	}
+	return s, nil
+}
+
+func (nm *NumberManager) SaveSettings(domainUUID string, req request.NumberSettingsRequest) (number.Settings, error) {
+	if !isUUID(domainUUID) {
+		return number.Settings{}, ErrInvalidDomain
+	}
+	if req.ExtensionMaxLength == 0 {
+		req.ExtensionMaxLength = nm.defaults.ExtensionMaxLength
+	}
+
+	if _, err := nm.db.Exec(`
+		INSERT INTO number_settings (domain_uuid, country_code, national_prefix, international_prefix, extension_max_length)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (domain_uuid) DO UPDATE
+		SET country_code = EXCLUDED.country_code, national_prefix = EXCLUDED.national_prefix,
+			international_prefix = EXCLUDED.international_prefix,
+			extension_max_length = EXCLUDED.extension_max_length, updated_at = now()`,
+		domainUUID, req.CountryCode, req.NationalPrefix, req.InternationalPrefix, req.ExtensionMaxLength); err != nil {
+		return number.Settings{}, fmt.Errorf("failed to save number settings: %w", err)
+	}
+
+	return nm.Settings(domainUUID)
+}
+
+func (nm *NumberManager) DeleteSettings(domainUUID string) error {
+	if !isUUID(domainUUID) {
+		return ErrInvalidDomain
+	}
+	if _, err := nm.db.Exec(`DELETE FROM number_settings WHERE domain_uuid = $1`, domainUUID); err != nil {
+		return fmt.Errorf("failed to delete number settings: %w", err)
+	}
+	return nil
+}
+
+// translationRules returns the tenant rules followed by the global ones,
+// each group in priority order.
+func (nm *NumberManager) translationRules(domainUUID string) ([]number.Rule, error) {
+	rows, err := nm.db.Query(`
+		SELECT applies_to, match_prefix, strip_digits, prepend FROM number_translation_rules
+		WHERE domain_uuid IS NULL OR domain_uuid = NULLIF($1, '')::uuid
+		ORDER BY domain_uuid NULLS LAST, priority, match_prefix DESC`, domainUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load translation rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []number.Rule
+	for rows.Next() {
+		var r number.Rule
+		var appliesTo string
+		if err := rows.Scan(&appliesTo, &r.Match, &r.Strip, &r.Prepend); err != nil {
+			return nil, fmt.Errorf("failed to scan translation rule: %w", err)
+		}
+		r.AppliesTo = number.Kind(appliesTo)
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func (nm *NumberManager) CreateRule(req request.TranslationRuleRequest) (*models.TranslationRule, error) {
+	applyRuleDefaults(&req)
+
+	row := nm.db.QueryRow(`
+		INSERT INTO number_translation_rules (rule_uuid, domain_uuid, applies_to, match_prefix, strip_digits, prepend, priority, description)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, $7, $8)
+		RETURNING `+ruleColumns,
+		newUUID(), req.DomainUUID, req.AppliesTo, req.Match, req.Strip, req.Prepend, req.Priority, req.Description)
+
+	return scanRule(row)
+}
+
+func (nm *NumberManager) GetRule(id string) (*models.TranslationRule, error) {
+	if !isUUID(id) {
+		return nil, ErrRuleNotFound
+	}
+	row := nm.db.QueryRow(`SELECT `+ruleColumns+` FROM number_translation_rules WHERE rule_uuid = $1`, id)
+	return scanRule(row)
+}
+
+func (nm *NumberManager) ListRules(domainUUID string) ([]models.TranslationRule, error) {
+	rows, err := nm.db.Query(`
+		SELECT `+ruleColumns+` FROM number_translation_rules
+		WHERE NULLIF($1, '') IS NULL OR domain_uuid IS NULL OR domain_uuid = NULLIF($1, '')::uuid
+		ORDER BY domain_uuid NULLS LAST, priority, match_prefix DESC`, domainUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch translation rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.TranslationRule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read translation rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (nm *NumberManager) UpdateRule(id string, req request.TranslationRuleRequest) (*models.TranslationRule, error) {
+	if !isUUID(id) {
+		return nil, ErrRuleNotFound
+	}
+	applyRuleDefaults(&req)
+
+	row := nm.db.QueryRow(`
+		UPDATE number_translation_rules
+		SET domain_uuid = NULLIF($2, '')::uuid, applies_to = $3, match_prefix = $4, strip_digits = $5,
+			prepend = $6, priority = $7, description = $8
+		WHERE rule_uuid = $1
+		RETURNING `+ruleColumns,
+		id, req.DomainUUID, req.AppliesTo, req.Match, req.Strip, req.Prepend, req.Priority, req.Description)
+
+	return scanRule(row)
+}
+
+func (nm *NumberManager) DeleteRule(id string) error {
+	if !isUUID(id) {
+		return ErrRuleNotFound
+	}
+	res, err := nm.db.Exec(`DELETE FROM number_translation_rules WHERE rule_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete translation rule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+func applyRuleDefaults(req *request.TranslationRuleRequest) {
+	if req.AppliesTo == "" {
+		req.AppliesTo = string(number.PSTN)
+	}
+	if req.Priority == 0 {
+		req.Priority = 100
+	}
+}
+
+func scanRule(row rowScanner) (*models.TranslationRule, error) {
+	var r models.TranslationRule
+	err := row.Scan(&r.RuleUUID, &r.DomainUUID, &r.AppliesTo, &r.Match, &r.Strip, &r.Prepend, &r.Priority,
+		&r.Description, &r.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRuleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan translation rule: %w", err)
+	}
+	return &r, nil
+}
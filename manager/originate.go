@@ -0,0 +1,363 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vishaltalsaniya-7/voip-api/number"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+// ErrInvalidOriginate is returned when a call request cannot be turned into a
+// safe originate command.
+var ErrInvalidOriginate = errors.New("invalid originate request")
+
+// Ring timeouts of a leg. Without one FreeSWITCH rings for 60 seconds.
+const (
+	defaultOriginateTimeout = 60
+	maxOriginateTimeout     = 300
+)
+
+var (
+	dialTokenPattern = regexp.MustCompile(`^[A-Za-z0-9+*#._-]+$`)
+	varNamePattern   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}$`)
+	appArgPattern    = regexp.MustCompile(`^[A-Za-z0-9_.@+-]+$`)
+	filePattern      = regexp.MustCompile(`^[A-Za-z0-9_./+-]+$`)
+
+	// Variables that make FreeSWITCH run arbitrary apps or API commands, or
+	// that override what the API sets itself (the call uuid, ring timeouts,
+	// the tenant, prepaid limits and the variables events are matched on),
+	// are never accepted from API clients.
+	reservedVarPattern = regexp.MustCompile(`(?i)^(api_|execute_on_|exec_after_|bridge_pre_execute|session_in_hangup_hook|.*_hook$|.*_script$|` +
+		`origination_uuid$|originate_|.*_timeout$|.*_after_bridge$|sched_|domain_|prepaid_|lcr_|campaign_|monitor_)`)
+
+	varValueEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, `,`, `\,`)
+)
+
+// buildChannelVars translates a call request into the variables placed in the
+// originate {...} block. Client supplied variables are applied first so the
+// values derived from typed fields always win.
+func (e *ESLManager) buildChannelVars(req request.CallRequest) (map[string]string, error) {
+	vars := make(map[string]string, len(req.Variables)+8)
+
+	for name, value := range req.Variables {
+		if !varNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%w: invalid channel variable name %q", ErrInvalidOriginate, name)
+		}
+		if reservedVarPattern.MatchString(name) {
+			return nil, fmt.Errorf("%w: channel variable %q is not allowed", ErrInvalidOriginate, name)
+		}
+		vars[name] = value
+	}
+
+	callerIDNumber := req.CallerIDNumber
+	if callerIDNumber == "" {
+		callerIDNumber = req.Caller
+	}
+	vars["origination_caller_id_number"] = callerIDNumber
+	if req.CallerIDName != "" {
+		vars["origination_caller_id_name"] = req.CallerIDName
+	}
+
+	vars["originate_timeout"] = originateTimeout(req.Timeout)
+	if req.IgnoreEarlyMedia {
+		vars["ignore_early_media"] = "true"
+	}
+	if req.AutoAnswer {
+		vars["sip_auto_answer"] = "true"
+		vars["sip_h_Call-Info"] = fmt.Sprintf("<sip:%s>;answer-after=0", e.config.Domain)
+	}
+
+	for name, value := range req.SystemVariables {
+		vars[name] = value
+	}
+
+	return vars, nil
+}
+
+// originateTimeout returns the ring timeout of a leg, capped at
+// maxOriginateTimeout. It is always set, so originate knows how long to
+// wait for the result.
+func originateTimeout(seconds int) string {
+	if seconds <= 0 {
+		seconds = defaultOriginateTimeout
+	}
+	return strconv.Itoa(min(seconds, maxOriginateTimeout))
+}
+
+// formatChannelVars renders vars as a {k='v',...} block. Values are quoted and
+// escaped so they cannot terminate the block or add originate arguments.
+func formatChannelVars(vars map[string]string) (string, error) {
+	return formatVars(vars, "{", "}")
+}
+
+// formatVars renders vars between open and close, "[" and "]" for the
+// variables of a single leg.
+func formatVars(vars map[string]string, open, close string) (string, error) {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		value := vars[name]
+		if strings.ContainsAny(value, "\r\n\x00{}") {
+			return "", fmt.Errorf("%w: channel variable %q contains forbidden characters", ErrInvalidOriginate, name)
+		}
+		parts = append(parts, fmt.Sprintf("%s='%s'", name, varValueEscaper.Replace(value)))
+	}
+
+	return open + strings.Join(parts, ",") + close, nil
+}
+
+func validateDialToken(field, value string) error {
+	if !dialTokenPattern.MatchString(value) {
+		return fmt.Errorf("%w: invalid %s %q", ErrInvalidOriginate, field, value)
+	}
+	return nil
+}
+
+// buildDestination returns the part of the originate command that follows the
+// dial string: either "&app(args)" or "<extension> <dialplan> <context>".
+// Requests without a typed destination keep the original bridge-to-callee
+// behaviour. callUUID identifies the call on any routed bridge legs. billed
+// is true when the destination bridges to an external number, whose legs
+// then carry the prepaid variables of the request.
+func (e *ESLManager) buildDestination(req request.CallRequest, callUUID string) (target string, billed bool, err error) {
+	dest := req.Destination
+	if dest == nil {
+		if req.Callee == "" {
+			return "", false, fmt.Errorf("%w: callee or destination is required", ErrInvalidOriginate)
+		}
+		dest = &request.CallDestination{Type: "user", User: req.Callee}
+	}
+
+	if dest.Type == "user" {
+		legs, err := e.dialLegs(req.DomainUUID, dest.User)
+		if err != nil {
+			return "", false, err
+		}
+		target, err := e.bridgeTarget(legs, callUUID, req.DomainUUID, req.PrepaidVariables)
+		return target, err == nil && len(req.PrepaidVariables) > 0 && legs[0].number != "", err
+	}
+
+	target, err = e.appDestination(dest)
+	return target, false, err
+}
+
+// appDestination renders the destinations that run an application or the
+// dialplan instead of bridging.
+func (e *ESLManager) appDestination(dest *request.CallDestination) (string, error) {
+	switch dest.Type {
+	case "extension":
+		if err := validateDialToken("destination extension", dest.Extension); err != nil {
+			return "", err
+		}
+		dialplan := dest.Dialplan
+		if dialplan == "" {
+			dialplan = "XML"
+		}
+		context := dest.Context
+		if context == "" {
+			context = e.config.Domain
+		}
+		if err := validateDialToken("destination context", context); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s %s", dest.Extension, dialplan, context), nil
+
+	case "ivr":
+		if !appArgPattern.MatchString(dest.Menu) {
+			return "", fmt.Errorf("%w: invalid ivr menu %q", ErrInvalidOriginate, dest.Menu)
+		}
+		return fmt.Sprintf("&ivr(%s)", dest.Menu), nil
+
+	case "conference":
+		if !appArgPattern.MatchString(dest.Conference) {
+			return "", fmt.Errorf("%w: invalid conference %q", ErrInvalidOriginate, dest.Conference)
+		}
+		room := dest.Conference
+		if dest.Profile != "" {
+			if !appArgPattern.MatchString(dest.Profile) {
+				return "", fmt.Errorf("%w: invalid conference profile %q", ErrInvalidOriginate, dest.Profile)
+			}
+			room = fmt.Sprintf("%s@%s", room, dest.Profile)
+		}
+		return fmt.Sprintf("&conference(%s)", room), nil
+
+	case "playback":
+		if !soundFile(e.config.SoundsDir, dest.File) {
+			return "", fmt.Errorf("%w: invalid playback file %q", ErrInvalidOriginate, dest.File)
+		}
+		return fmt.Sprintf("&playback(%s)", dest.File), nil
+
+	case "speak":
+		engine, voice := dest.Engine, dest.Voice
+		if engine == "" {
+			engine = "flite"
+		}
+		if voice == "" {
+			voice = "kal"
+		}
+		if !appArgPattern.MatchString(engine) || !appArgPattern.MatchString(voice) {
+			return "", fmt.Errorf("%w: invalid speak engine or voice", ErrInvalidOriginate)
+		}
+		if dest.Text == "" || strings.ContainsAny(dest.Text, "'|(){}\\\r\n\x00") {
+			return "", fmt.Errorf("%w: invalid speak text", ErrInvalidOriginate)
+		}
+		// The text may contain spaces, so the whole app is quoted to keep
+		// it a single originate argument.
+		return fmt.Sprintf("'&speak(%s|%s|%s)'", engine, voice, dest.Text), nil
+	}
+
+	return "", fmt.Errorf("%w: unsupported destination type %q", ErrInvalidOriginate, dest.Type)
+}
+
+// soundFile reports whether file may be played. Relative paths are looked
+// up by FreeSWITCH under its sound prefix, and absolute ones must be inside
+// soundsDir. Paths with ".." and URLs such as shell_stream:// or http://
+// are refused.
+func soundFile(soundsDir, file string) bool {
+	if !filePattern.MatchString(file) || strings.Contains(file, "..") {
+		return false
+	}
+	if !strings.HasPrefix(file, "/") {
+		return true
+	}
+	dir := strings.TrimRight(soundsDir, "/")
+	return dir != "" && strings.HasPrefix(file, dir+"/")
+}
+
+// dialLeg is one way of reaching a number: a dial string and, for external
+// numbers, the least-cost route it goes through.
+type dialLeg struct {
+	dial   string
+	number string
+	route  *RouteCandidate
+}
+
+// routeVars returns the lcr_* variables that tie a routed leg back to its
+// call and route when its hangup event is recorded.
+func (l dialLeg) routeVars(callUUID, domainUUID string, attempt int) map[string]string {
+	if l.route == nil {
+		return nil
+	}
+	return map[string]string{
+		"lcr_call_uuid":   callUUID,
+		"lcr_domain_uuid": domainUUID,
+		"lcr_number":      l.number,
+		"lcr_route_uuid":  l.route.RouteUUID,
+		"lcr_gateway":     l.route.Gateway,
+		"lcr_prefix":      l.route.Prefix,
+		"lcr_rate":        strconv.FormatFloat(l.route.Rate, 'f', -1, 64),
+		"lcr_attempt":     strconv.Itoa(attempt),
+	}
+}
+
+// dialLegs turns a dialed number into the FreeSWITCH dial strings to try, in
+// order. Local extensions ring the registered user; PSTN numbers get one leg
+// per least-cost route, or the configured default gateway when no route
+// matches.
+func (e *ESLManager) dialLegs(domainUUID, raw string) ([]dialLeg, error) {
+	n := number.Number{Dial: raw, Kind: number.Extension}
+	if e.numbers != nil {
+		var err error
+		n, err = e.numbers.Resolve(domainUUID, raw)
+		if errors.Is(err, number.ErrInvalidNumber) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidOriginate, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateDialToken("number", n.Dial); err != nil {
+		return nil, err
+	}
+
+	if n.Kind != number.PSTN {
+		return []dialLeg{{dial: fmt.Sprintf("user/%s@%s", n.Dial, e.config.Domain)}}, nil
+	}
+
+	var routes []RouteCandidate
+	if e.routes != nil {
+		var err error
+		if routes, err = e.routes.Lookup(domainUUID, n.E164); err != nil {
+			return nil, err
+		}
+	}
+
+	legs := make([]dialLeg, 0, len(routes))
+	for i := range routes {
+		if err := validateDialToken("gateway", routes[i].Gateway); err != nil {
+			return nil, err
+		}
+		legs = append(legs, dialLeg{
+			dial:   fmt.Sprintf("sofia/gateway/%s/%s", routes[i].Gateway, n.Dial),
+			number: n.E164,
+			route:  &routes[i],
+		})
+	}
+	if len(legs) > 0 {
+		return legs, nil
+	}
+
+	if e.config.Gateway == "" {
+		return nil, fmt.Errorf("%w: no route or gateway configured for external number %s", ErrInvalidOriginate, n.Dial)
+	}
+	return []dialLeg{{dial: fmt.Sprintf("sofia/gateway/%s/%s", e.config.Gateway, n.Dial), number: n.E164}}, nil
+}
+
+// bridgeTarget renders a bridge to legs as an originate application.
+func (e *ESLManager) bridgeTarget(legs []dialLeg, callUUID, domainUUID string, prepaid map[string]string) (string, error) {
+	arg, err := e.bridgeArg(legs, callUUID, domainUUID, prepaid)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("&bridge(%s)", arg), nil
+}
+
+// bridgeArg renders the bridge argument for legs. Several legs form a "|"
+// failover list which FreeSWITCH walks until a leg answers or fails with a
+// cause that is not a failover cause. External legs carry the prepaid
+// variables, so the leg that answers is the one tracked and charged.
+func (e *ESLManager) bridgeArg(legs []dialLeg, callUUID, domainUUID string, prepaid map[string]string) (string, error) {
+	var global string
+	if len(legs) > 1 {
+		block, err := formatChannelVars(map[string]string{"fail_on_single_reject": e.routes.failOnSingleReject()})
+		if err != nil {
+			return "", err
+		}
+		global = block
+	}
+
+	parts := make([]string, 0, len(legs))
+	for i, leg := range legs {
+		vars := leg.routeVars(callUUID, domainUUID, i+1)
+		if leg.number != "" && len(prepaid) > 0 {
+			if vars == nil {
+				vars = make(map[string]string, len(prepaid))
+			}
+			for name, value := range prepaid {
+				vars[name] = value
+			}
+		}
+		if vars == nil {
+			parts = append(parts, leg.dial)
+			continue
+		}
+		block, err := formatVars(vars, "[", "]")
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, block+leg.dial)
+	}
+
+	return global + strings.Join(parts, "|"), nil
+}
@@ -0,0 +1,528 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/number"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrPrepaidAccountNotFound = errors.New("prepaid account not found")
+	ErrInsufficientBalance    = errors.New("insufficient prepaid balance")
+	ErrNoRate                 = errors.New("no rate for destination")
+	ErrInvalidTransaction     = errors.New("invalid prepaid transaction")
+	ErrTenantUnknown          = errors.New("external call has no tenant; set domain_uuid")
+)
+
+// Ledger entry kinds. Call entries are unique per call uuid so a hangup event
+// delivered twice is only charged once.
+const (
+	LedgerCredit     = "credit"
+	LedgerAdjustment = "adjustment"
+	LedgerCall       = "call"
+)
+
+const accountColumns = `domain_uuid, balance, currency, enabled, max_call_seconds, created_at, updated_at`
+
+const ledgerColumns = `entry_uuid, domain_uuid, kind, amount, balance_after, reference, description, created_at`
+
+// PrepaidManager keeps prepaid balances and enforces them on calls: the
+// allowed duration is computed before originating, answered calls are
+// tracked and cut when the balance can no longer cover them, and every call
+// is charged to the ledger when it hangs up.
+type PrepaidManager struct {
+	db      *sql.DB
+	eslMgr  *ESLManager
+	rating  *RatingManager
+	numbers *NumberManager
+	config  config.PrepaidConfig
+
+	mu     sync.Mutex
+	active map[string]*prepaidCall // call uuid -> answered call
+}
+
+type prepaidCall struct {
+	domainUUID string
+	rate       RateMatch
+	answeredAt time.Time
+	maxSeconds int
+	bridged    bool // only the time the leg spends bridged is billed
+}
+
+func NewPrepaidManager(db *sql.DB, eslMgr *ESLManager, rating *RatingManager, numbers *NumberManager, cfg config.PrepaidConfig) *PrepaidManager {
+	pm := &PrepaidManager{
+		db:      db,
+		eslMgr:  eslMgr,
+		rating:  rating,
+		numbers: numbers,
+		config:  cfg,
+		active:  make(map[string]*prepaidCall),
+	}
+	eslMgr.Subscribe("CHANNEL_ANSWER", pm.handleAnswer)
+	eslMgr.Subscribe("CHANNEL_BRIDGE", pm.handleBridge)
+	eslMgr.Subscribe("CHANNEL_HANGUP_COMPLETE", pm.handleHangup)
+	return pm
+}
+
+// Authorize checks a call of a prepaid tenant against its balance and
+// returns the channel variables that limit the call to what the balance
+// covers. Tenants without an enabled prepaid account, and calls that only
+// reach extensions, get no variables. External calls without a tenant are
+// refused, as there is no telling whether a balance applies.
+func (pm *PrepaidManager) Authorize(req request.CallRequest) (map[string]string, error) {
+	if req.DomainUUID == "" {
+		external, err := pm.dialsExternal(req)
+		if err != nil {
+			return nil, err
+		}
+		if external {
+			return nil, ErrTenantUnknown
+		}
+		return nil, nil
+	}
+	acct, err := pm.GetAccount(req.DomainUUID)
+	if errors.Is(err, ErrPrepaidAccountNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !acct.Enabled {
+		return nil, nil
+	}
+
+	rate, err := pm.callRate(req)
+	if err != nil || rate == nil {
+		return nil, err
+	}
+
+	limit := acct.MaxCallSeconds
+	if limit == 0 {
+		limit = pm.config.MaxCallSeconds
+	}
+	available := acct.Balance - pm.runningCost(req.DomainUUID, time.Now())
+	seconds := rate.MaxSeconds(available)
+	if seconds == 0 {
+		return nil, ErrInsufficientBalance
+	}
+	if seconds < 0 || seconds > limit {
+		seconds = limit
+	}
+
+	return map[string]string{
+		"execute_on_answer":         fmt.Sprintf("sched_hangup +%d ALLOTTED_TIMEOUT", seconds),
+		"prepaid_domain_uuid":       req.DomainUUID,
+		"prepaid_max_seconds":       strconv.Itoa(seconds),
+		"prepaid_connect_fee":       strconv.FormatFloat(rate.ConnectFee, 'f', -1, 64),
+		"prepaid_rate":              strconv.FormatFloat(rate.Rate, 'f', -1, 64),
+		"prepaid_initial_increment": strconv.Itoa(rate.InitialIncrement),
+		"prepaid_increment":         strconv.Itoa(rate.Increment),
+	}, nil
+}
+
+// dialsExternal reports whether a request rings any external number.
+func (pm *PrepaidManager) dialsExternal(req request.CallRequest) (bool, error) {
+	for _, raw := range dialedNumbers(req) {
+		n, err := pm.numbers.Resolve(req.DomainUUID, raw)
+		if errors.Is(err, number.ErrInvalidNumber) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if n.Kind == number.PSTN {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// callRate returns the price rate of the external numbers a request dials.
+// When both legs are external their rates are added up and the coarser
+// increments apply.
+func (pm *PrepaidManager) callRate(req request.CallRequest) (*RateMatch, error) {
+	var combined *RateMatch
+	for _, raw := range dialedNumbers(req) {
+		n, err := pm.numbers.Resolve(req.DomainUUID, raw)
+		if errors.Is(err, number.ErrInvalidNumber) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n.Kind != number.PSTN {
+			continue
+		}
+
+		m, err := pm.rating.FindRate(DeckPurposePrice, req.DomainUUID, n.E164, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if m == nil {
+			return nil, fmt.Errorf("%w %s", ErrNoRate, n.E164)
+		}
+
+		if combined == nil {
+			combined = m
+			continue
+		}
+		combined.ConnectFee += m.ConnectFee
+		combined.Rate += m.Rate
+		if m.InitialIncrement > combined.InitialIncrement {
+			combined.InitialIncrement = m.InitialIncrement
+		}
+		if m.Increment > combined.Increment {
+			combined.Increment = m.Increment
+		}
+	}
+	return combined, nil
+}
+
+// callFromEvent reads the prepaid_* variables set by Authorize.
+func callFromEvent(ev *eventsocket.Event) *prepaidCall {
+	domainUUID := ev.Get("Variable_prepaid_domain_uuid")
+	if domainUUID == "" {
+		return nil
+	}
+
+	call := &prepaidCall{domainUUID: domainUUID}
+	call.rate.ConnectFee, _ = strconv.ParseFloat(ev.Get("Variable_prepaid_connect_fee"), 64)
+	call.rate.Rate, _ = strconv.ParseFloat(ev.Get("Variable_prepaid_rate"), 64)
+	call.rate.InitialIncrement, _ = strconv.Atoi(ev.Get("Variable_prepaid_initial_increment"))
+	call.rate.Increment, _ = strconv.Atoi(ev.Get("Variable_prepaid_increment"))
+	call.maxSeconds, _ = strconv.Atoi(ev.Get("Variable_prepaid_max_seconds"))
+	call.bridged = ev.Get("Variable_prepaid_bill_bridged") == "true"
+	return call
+}
+
+// handleAnswer starts tracking the external leg of a prepaid call once it
+// answers.
+func (pm *PrepaidManager) handleAnswer(ev *eventsocket.Event) {
+	if call := callFromEvent(ev); call != nil && !call.bridged {
+		pm.track(ev.Get("Unique-Id"), call)
+	}
+}
+
+// handleBridge starts tracking a local leg that reaches the external number
+// through the dialplan once it is bridged.
+func (pm *PrepaidManager) handleBridge(ev *eventsocket.Event) {
+	if call := callFromEvent(ev); call != nil && call.bridged {
+		pm.track(ev.Get("Unique-Id"), call)
+	}
+}
+
+func (pm *PrepaidManager) track(callUUID string, call *prepaidCall) {
+	if callUUID == "" {
+		return
+	}
+	call.answeredAt = time.Now()
+
+	pm.mu.Lock()
+	if _, ok := pm.active[callUUID]; !ok {
+		pm.active[callUUID] = call
+	}
+	pm.mu.Unlock()
+}
+
+// handleHangup stops tracking a prepaid call and charges it to the ledger.
+func (pm *PrepaidManager) handleHangup(ev *eventsocket.Event) {
+	call := callFromEvent(ev)
+	callUUID := ev.Get("Unique-Id")
+	if call == nil || callUUID == "" {
+		return
+	}
+
+	pm.mu.Lock()
+	delete(pm.active, callUUID)
+	pm.mu.Unlock()
+
+	billsec, _ := strconv.Atoi(ev.Get("Variable_billsec"))
+	if call.bridged {
+		billsec = bridgedSeconds(ev)
+	}
+	billed, amount := call.rate.Charge(billsec)
+	if amount == 0 {
+		return
+	}
+
+	description := fmt.Sprintf("Call to %s, %ds billed as %ds", ev.Get("Caller-Destination-Number"), billsec, billed)
+	if _, err := pm.applyEntry(call.domainUUID, LedgerCall, -amount, callUUID, description); err != nil {
+		log.Printf("Failed to charge prepaid call %s: %v", callUUID, err)
+	}
+}
+
+// bridgedSeconds is how long a leg was bridged: from its first bridge until
+// it hung up, or 0 when it never was.
+func bridgedSeconds(ev *eventsocket.Event) int {
+	bridged, _ := strconv.ParseInt(ev.Get("Variable_bridge_epoch"), 10, 64)
+	ended, _ := strconv.ParseInt(ev.Get("Variable_end_epoch"), 10, 64)
+	if bridged <= 0 || ended < bridged {
+		return 0
+	}
+	return int(ended - bridged)
+}
+
+// runningCost is what the answered calls of a tenant have cost so far.
+func (pm *PrepaidManager) runningCost(domainUUID string, now time.Time) float64 {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	var cost float64
+	for _, call := range pm.active {
+		if call.domainUUID == domainUUID {
+			_, amount := call.rate.Charge(int(now.Sub(call.answeredAt).Seconds()))
+			cost += amount
+		}
+	}
+	return cost
+}
+
+// ActiveCalls returns how many answered calls of a tenant are being tracked.
+func (pm *PrepaidManager) ActiveCalls(domainUUID string) int {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	n := 0
+	for _, call := range pm.active {
+		if call.domainUUID == domainUUID {
+			n++
+		}
+	}
+	return n
+}
+
+// Run cuts live calls once their running cost uses up the balance. Each call
+// is already capped by sched_hangup, but concurrent calls of one tenant
+// share the balance and only this check sees them together.
+func (pm *PrepaidManager) Run() {
+	ticker := time.NewTicker(pm.config.CheckInterval)
+	defer ticker.Stop()
+
+	log.Printf("Prepaid credit monitor started, checking every %s", pm.config.CheckInterval)
+	for range ticker.C {
+		pm.enforce(time.Now())
+	}
+}
+
+type liveCall struct {
+	uuid       string
+	answeredAt time.Time
+	cost       float64
+}
+
+func (pm *PrepaidManager) enforce(now time.Time) {
+	byDomain := make(map[string][]liveCall)
+
+	pm.mu.Lock()
+	for callUUID, call := range pm.active {
+		elapsed := int(now.Sub(call.answeredAt).Seconds())
+		// sched_hangup ends every call by maxSeconds, so an entry well past
+		// it missed its hangup event.
+		if call.maxSeconds > 0 && elapsed > call.maxSeconds+60 {
+			delete(pm.active, callUUID)
+			continue
+		}
+		_, cost := call.rate.Charge(elapsed)
+		byDomain[call.domainUUID] = append(byDomain[call.domainUUID], liveCall{callUUID, call.answeredAt, cost})
+	}
+	pm.mu.Unlock()
+
+	for domainUUID, calls := range byDomain {
+		acct, err := pm.GetAccount(domainUUID)
+		if err != nil {
+			log.Printf("Failed to load prepaid account %s: %v", domainUUID, err)
+			continue
+		}
+
+		var running float64
+		for _, call := range calls {
+			running += call.cost
+		}
+		if acct.Balance-running > 0 {
+			continue
+		}
+
+		// Cut the newest calls first until the balance covers the rest.
+		sort.Slice(calls, func(i, j int) bool { return calls[i].answeredAt.After(calls[j].answeredAt) })
+		for _, call := range calls {
+			if acct.Balance-running > 0 {
+				break
+			}
+			log.Printf("Prepaid balance of %s exhausted, hanging up call %s", domainUUID, call.uuid)
+			if err := pm.eslMgr.Kill(call.uuid, "OUTGOING_CALL_BARRED"); err != nil {
+				log.Printf("Failed to hang up call %s: %v", call.uuid, err)
+			}
+			running -= call.cost
+		}
+	}
+}
+
+// applyEntry adds amount to a balance and writes the ledger entry in the
+// same transaction, with the account row locked. A call that was already
+// charged returns nil without changing the balance.
+func (pm *PrepaidManager) applyEntry(domainUUID, kind string, amount float64, reference, description string) (*models.LedgerEntry, error) {
+	tx, err := pm.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var balance float64
+	err = tx.QueryRow(`SELECT balance FROM prepaid_accounts WHERE domain_uuid = $1 FOR UPDATE`, domainUUID).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPrepaidAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock prepaid account: %w", err)
+	}
+	balance = math.Round((balance+amount)*1e6) / 1e6
+
+	var e models.LedgerEntry
+	err = tx.QueryRow(`
+		INSERT INTO prepaid_ledger (entry_uuid, domain_uuid, kind, amount, balance_after, reference, description)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (reference) WHERE kind = 'call' DO NOTHING
+		RETURNING `+ledgerColumns,
+		newUUID(), domainUUID, kind, amount, balance, reference, description).
+		Scan(&e.EntryUUID, &e.DomainUUID, &e.Kind, &e.Amount, &e.BalanceAfter, &e.Reference, &e.Description, &e.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write ledger entry: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE prepaid_accounts SET balance = $2, updated_at = now() WHERE domain_uuid = $1`,
+		domainUUID, balance); err != nil {
+		return nil, fmt.Errorf("failed to update balance: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit ledger entry: %w", err)
+	}
+
+	return &e, nil
+}
+
+// AddTransaction credits or adjusts a balance outside of calls.
+func (pm *PrepaidManager) AddTransaction(domainUUID string, req request.PrepaidTransactionRequest) (*models.LedgerEntry, error) {
+	if !isUUID(domainUUID) {
+		return nil, ErrInvalidDomain
+	}
+	if req.Kind == "" {
+		req.Kind = LedgerCredit
+	}
+	if req.Kind == LedgerCredit && req.Amount <= 0 {
+		return nil, fmt.Errorf("%w: credits must be positive", ErrInvalidTransaction)
+	}
+
+	return pm.applyEntry(domainUUID, req.Kind, req.Amount, req.Reference, req.Description)
+}
+
+// SaveAccount creates or updates the prepaid account of a tenant. New
+// accounts start with a zero balance.
+func (pm *PrepaidManager) SaveAccount(domainUUID string, req request.PrepaidAccountRequest) (*models.PrepaidAccount, error) {
+	if !isUUID(domainUUID) {
+		return nil, ErrInvalidDomain
+	}
+	if req.Currency == "" {
+		req.Currency = "USD"
+	}
+
+	row := pm.db.QueryRow(`
+		INSERT INTO prepaid_accounts (domain_uuid, currency, enabled, max_call_seconds)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (domain_uuid) DO UPDATE
+		SET currency = EXCLUDED.currency, enabled = EXCLUDED.enabled, max_call_seconds = EXCLUDED.max_call_seconds,
+			updated_at = now()
+		RETURNING `+accountColumns,
+		domainUUID, req.Currency, req.Enabled == nil || *req.Enabled, req.MaxCallSeconds)
+
+	return scanAccount(row)
+}
+
+func (pm *PrepaidManager) GetAccount(domainUUID string) (*models.PrepaidAccount, error) {
+	if !isUUID(domainUUID) {
+		return nil, ErrInvalidDomain
+	}
+	row := pm.db.QueryRow(`SELECT `+accountColumns+` FROM prepaid_accounts WHERE domain_uuid = $1`, domainUUID)
+	return scanAccount(row)
+}
+
+func (pm *PrepaidManager) ListAccounts() ([]models.PrepaidAccount, error) {
+	rows, err := pm.db.Query(`SELECT ` + accountColumns + ` FROM prepaid_accounts ORDER BY balance`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch prepaid accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []models.PrepaidAccount
+	for rows.Next() {
+		acct, err := scanAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *acct)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prepaid accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+func (pm *PrepaidManager) ListLedger(domainUUID string, limit, offset int) ([]models.LedgerEntry, int, error) {
+	if _, err := pm.GetAccount(domainUUID); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := pm.db.QueryRow(`SELECT COUNT(*) FROM prepaid_ledger WHERE domain_uuid = $1`, domainUUID).
+		Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count ledger entries: %w", err)
+	}
+
+	rows, err := pm.db.Query(`SELECT `+ledgerColumns+` FROM prepaid_ledger WHERE domain_uuid = $1
+		ORDER BY created_at DESC LIMIT $2 OFFSET $3`, domainUUID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch ledger entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.LedgerEntry
+	for rows.Next() {
+		var e models.LedgerEntry
+		if err := rows.Scan(&e.EntryUUID, &e.DomainUUID, &e.Kind, &e.Amount, &e.BalanceAfter, &e.Reference,
+			&e.Description, &e.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read ledger entries: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+func scanAccount(row rowScanner) (*models.PrepaidAccount, error) {
+	var a models.PrepaidAccount
+	err := row.Scan(&a.DomainUUID, &a.Balance, &a.Currency, &a.Enabled, &a.MaxCallSeconds, &a.CreatedAt, &a.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPrepaidAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan prepaid account: %w", err)
+	}
+	return &a, nil
+}
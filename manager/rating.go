@@ -0,0 +1,657 @@
+package manager
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/number"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrRateDeckNotFound = errors.New("rate deck not found")
+	ErrRateDeckExists   = errors.New("a rate deck with this purpose already exists for the tenant")
+	ErrInvalidRateDeck  = errors.New("invalid rate deck")
+	ErrRatingNotFound   = errors.New("CDR has not been rated")
+)
+
+const (
+	DeckPurposePrice = "price"
+	DeckPurposeCost  = "cost"
+)
+
+// Rating statuses: rated CDRs matched a price rate, unrated ones went to an
+// external number with no rate or an unparseable number, and local ones never
+// left the switch.
+const (
+	RatingRated   = "rated"
+	RatingUnrated = "unrated"
+	RatingLocal   = "local"
+)
+
+const deckColumns = `deck_uuid, name, purpose, domain_uuid, currency, timezone, description, created_at, updated_at`
+
+const rateColumns = `rate_uuid, deck_uuid, prefix, destination, connect_fee, rate, initial_increment, increment,
+	to_char(band_start, 'HH24:MI'), to_char(band_end, 'HH24:MI'), created_at`
+
+const ratingColumns = `xml_cdr_uuid, domain_uuid, start_stamp, number, destination, billsec, billed_seconds,
+	price_rate_uuid, price_prefix, price, cost_rate_uuid, cost, currency, status, rated_at`
+
+// RateMatch is the rate that applies to a number at a given time.
+type RateMatch struct {
+	RateUUID         string
+	Prefix           string
+	Destination      string
+	Currency         string
+	ConnectFee       float64
+	Rate             float64
+	InitialIncrement int
+	Increment        int
+}
+
+// Charge returns the seconds billed for billsec and the amount due. The
+// first increment is billed in full, the rest is rounded up to whole
+// increments, so a 60/6 rate bills 61 seconds as 66. Unanswered calls are
+// free.
+func (m *RateMatch) Charge(billsec int) (int, float64) {
+	if billsec <= 0 {
+		return 0, 0
+	}
+
+	billed := m.InitialIncrement
+	if billsec > billed {
+		increment := m.Increment
+		if increment < 1 {
+			increment = 1
+		}
+		billed += (billsec - billed + increment - 1) / increment * increment
+	}
+
+	amount := m.ConnectFee + m.Rate*float64(billed)/60
+	return billed, math.Round(amount*1e6) / 1e6
+}
+
+// MaxSeconds returns the longest call that balance pays for, in whole
+// increments, or 0 when it does not cover the first increment. Free rates
+// return -1 as they put no limit on the call.
+func (m *RateMatch) MaxSeconds(balance float64) int {
+	first := m.ConnectFee + m.Rate*float64(m.InitialIncrement)/60
+	if balance <= 0 || balance < first {
+		return 0
+	}
+	if m.Rate <= 0 {
+		return -1
+	}
+
+	increment := m.Increment
+	if increment < 1 {
+		increment = 1
+	}
+	perIncrement := m.Rate * float64(increment) / 60
+	return m.InitialIncrement + int((balance-first)/perIncrement)*increment
+}
+
+// RatingManager keeps the rate decks and rates CDRs from v_xml_cdr into
+// cdr_ratings as they arrive.
+type RatingManager struct {
+	db      *sql.DB
+	numbers *NumberManager
+	config  config.RatingConfig
+}
+
+func NewRatingManager(db *sql.DB, numbers *NumberManager, cfg config.RatingConfig) *RatingManager {
+	return &RatingManager{
+		db:      db,
+		numbers: numbers,
+		config:  cfg,
+	}
+}
+
+func (rm *RatingManager) Run() {
+	ticker := time.NewTicker(rm.config.PollInterval)
+	defer ticker.Stop()
+
+	log.Printf("CDR rating started, polling every %s", rm.config.PollInterval)
+	for range ticker.C {
+		for {
+			n, err := rm.rateBatch()
+			if err != nil {
+				log.Printf("CDR rating run failed: %v", err)
+			}
+			if err != nil || n < rm.config.BatchSize {
+				break
+			}
+		}
+	}
+}
+
+type unratedCDR struct {
+	id          string
+	domainUUID  sql.NullString
+	destination string
+	startStamp  time.Time
+	billsec     int
+}
+
+// rateBatch rates the oldest finished outbound a-leg CDRs that have no
+// rating yet and returns how many it picked up. Inbound and local calls are
+// not billed.
+func (rm *RatingManager) rateBatch() (int, error) {
+	rows, err := rm.db.Query(`
+		SELECT c.xml_cdr_uuid, c.domain_uuid, COALESCE(c.destination_number, ''), c.start_stamp, COALESCE(c.billsec, 0)
+		FROM v_xml_cdr c
+		WHERE c.start_stamp >= now() - make_interval(secs => $1)
+			AND c.end_stamp IS NOT NULL
+			AND (c.leg IS NULL OR c.leg = 'a')
+			AND c.direction = 'outbound'
+			AND NOT EXISTS (SELECT 1 FROM cdr_ratings r WHERE r.xml_cdr_uuid = c.xml_cdr_uuid)
+		ORDER BY c.start_stamp
+		LIMIT $2`, rm.config.Lookback.Seconds(), rm.config.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch unrated CDRs: %w", err)
+	}
+
+	var batch []unratedCDR
+	for rows.Next() {
+		var c unratedCDR
+		if err := rows.Scan(&c.id, &c.domainUUID, &c.destination, &c.startStamp, &c.billsec); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan unrated CDR: %w", err)
+		}
+		batch = append(batch, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read unrated CDRs: %w", err)
+	}
+
+	for _, c := range batch {
+		rating, err := rm.rate(c)
+		if err != nil {
+			return len(batch), fmt.Errorf("failed to rate CDR %s: %w", c.id, err)
+		}
+		if err := rm.saveRating(rating); err != nil {
+			return len(batch), err
+		}
+	}
+	return len(batch), nil
+}
+
+func (rm *RatingManager) rate(c unratedCDR) (models.CDRRating, error) {
+	rating := models.CDRRating{
+		XMLCDRUUID: c.id,
+		DomainUUID: c.domainUUID,
+		StartStamp: c.startStamp,
+		Number:     c.destination,
+		BillSec:    c.billsec,
+		Status:     RatingUnrated,
+	}
+
+	n, err := rm.numbers.Resolve(c.domainUUID.String, c.destination)
+	if errors.Is(err, number.ErrInvalidNumber) {
+		return rating, nil
+	}
+	if err != nil {
+		return rating, err
+	}
+	if n.Kind != number.PSTN {
+		rating.Status = RatingLocal
+		return rating, nil
+	}
+	rating.Number = n.E164
+
+	price, err := rm.FindRate(DeckPurposePrice, c.domainUUID.String, n.E164, c.startStamp)
+	if err != nil {
+		return rating, err
+	}
+	if price != nil {
+		rating.Status = RatingRated
+		rating.PriceRateUUID = sql.NullString{String: price.RateUUID, Valid: true}
+		rating.PricePrefix = price.Prefix
+		rating.Destination = price.Destination
+		rating.Currency = price.Currency
+		rating.BilledSeconds, rating.Price = price.Charge(c.billsec)
+	}
+
+	cost, err := rm.FindRate(DeckPurposeCost, c.domainUUID.String, n.E164, c.startStamp)
+	if err != nil {
+		return rating, err
+	}
+	if cost != nil {
+		rating.CostRateUUID = sql.NullString{String: cost.RateUUID, Valid: true}
+		_, rating.Cost = cost.Charge(c.billsec)
+	}
+
+	return rating, nil
+}
+
+func (rm *RatingManager) saveRating(r models.CDRRating) error {
+	_, err := rm.db.Exec(`
+		INSERT INTO cdr_ratings (xml_cdr_uuid, domain_uuid, start_stamp, number, destination, billsec, billed_seconds,
+			price_rate_uuid, price_prefix, price, cost_rate_uuid, cost, currency, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (xml_cdr_uuid) DO NOTHING`,
+		r.XMLCDRUUID, r.DomainUUID, r.StartStamp, r.Number, r.Destination, r.BillSec, r.BilledSeconds,
+		r.PriceRateUUID, r.PricePrefix, r.Price, r.CostRateUUID, r.Cost, r.Currency, r.Status)
+	if err != nil {
+		return fmt.Errorf("failed to save rating of CDR %s: %w", r.XMLCDRUUID, err)
+	}
+	return nil
+}
+
+// FindRate returns the rate of the tenant's deck for purpose (or the default
+// deck) that applies to an E.164 number at the given time, or nil when there
+// is none. The longest prefix wins; among its rates a time band that covers
+// the call start, in the deck's timezone, beats an all-day rate.
+func (rm *RatingManager) FindRate(purpose, domainUUID, e164 string, at time.Time) (*RateMatch, error) {
+	var m RateMatch
+	err := rm.db.QueryRow(`
+		WITH deck AS (
+			SELECT deck_uuid, currency, timezone FROM rate_decks
+			WHERE purpose = $1 AND (domain_uuid IS NULL OR domain_uuid = NULLIF($2, '')::uuid)
+			ORDER BY domain_uuid NULLS LAST
+			LIMIT 1
+		), local AS (
+			SELECT d.deck_uuid, d.currency, ($4::timestamptz AT TIME ZONE d.timezone)::time AS t FROM deck d
+		)
+		SELECT r.rate_uuid, r.prefix, r.destination, l.currency, r.connect_fee, r.rate, r.initial_increment, r.increment
+		FROM rates r JOIN local l ON l.deck_uuid = r.deck_uuid
+		WHERE starts_with($3, r.prefix)
+			AND (r.band_start IS NULL
+				OR (r.band_start <= r.band_end AND l.t >= r.band_start AND l.t < r.band_end)
+				OR (r.band_start > r.band_end AND (l.t >= r.band_start OR l.t < r.band_end)))
+		ORDER BY length(r.prefix) DESC, r.band_start IS NULL, r.rate
+		LIMIT 1`,
+		purpose, domainUUID, strings.TrimPrefix(e164, "+"), at).
+		Scan(&m.RateUUID, &m.Prefix, &m.Destination, &m.Currency, &m.ConnectFee, &m.Rate, &m.InitialIncrement,
+			&m.Increment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s rate: %w", purpose, err)
+	}
+	return &m, nil
+}
+
+func (rm *RatingManager) GetRating(xmlCDRUUID string) (*models.CDRRating, error) {
+	if !isUUID(xmlCDRUUID) {
+		return nil, ErrRatingNotFound
+	}
+
+	var r models.CDRRating
+	err := rm.db.QueryRow(`SELECT `+ratingColumns+` FROM cdr_ratings WHERE xml_cdr_uuid = $1`, xmlCDRUUID).
+		Scan(&r.XMLCDRUUID, &r.DomainUUID, &r.StartStamp, &r.Number, &r.Destination, &r.BillSec, &r.BilledSeconds,
+			&r.PriceRateUUID, &r.PricePrefix, &r.Price, &r.CostRateUUID, &r.Cost, &r.Currency, &r.Status, &r.RatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRatingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch CDR rating: %w", err)
+	}
+	return &r, nil
+}
+
+// Rerate deletes the ratings of the CDRs started in [from, to) so the worker
+// rates them again with the current decks. It returns how many were cleared.
+func (rm *RatingManager) Rerate(domainUUID string, from, to time.Time) (int64, error) {
+	res, err := rm.db.Exec(`
+		DELETE FROM cdr_ratings
+		WHERE start_stamp >= $1 AND start_stamp < $2 AND (NULLIF($3, '') IS NULL OR domain_uuid = NULLIF($3, '')::uuid)`,
+		from, to, domainUUID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear ratings: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// Invoice sums the rated calls of a tenant started in [from, to) by
+// destination, and counts the external calls that could not be rated.
+func (rm *RatingManager) Invoice(domainUUID string, from, to time.Time) ([]models.InvoiceLine, int, error) {
+	if !isUUID(domainUUID) {
+		return nil, 0, ErrInvalidDomain
+	}
+
+	rows, err := rm.db.Query(`
+		SELECT price_prefix, destination, currency, COUNT(*), SUM(billsec), SUM(billed_seconds), SUM(price), SUM(cost)
+		FROM cdr_ratings
+		WHERE domain_uuid = $1 AND start_stamp >= $2 AND start_stamp < $3 AND status = $4
+		GROUP BY price_prefix, destination, currency
+		ORDER BY SUM(price) DESC, price_prefix`, domainUUID, from, to, RatingRated)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to sum ratings: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []models.InvoiceLine
+	for rows.Next() {
+		var l models.InvoiceLine
+		if err := rows.Scan(&l.Prefix, &l.Destination, &l.Currency, &l.Calls, &l.BillSec, &l.BilledSeconds,
+			&l.Price, &l.Cost); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan invoice line: %w", err)
+		}
+		lines = append(lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read invoice lines: %w", err)
+	}
+
+	var unrated int
+	if err := rm.db.QueryRow(`
+		SELECT COUNT(*) FROM cdr_ratings
+		WHERE domain_uuid = $1 AND start_stamp >= $2 AND start_stamp < $3 AND status = $4`,
+		domainUUID, from, to, RatingUnrated).Scan(&unrated); err != nil {
+		return nil, 0, fmt.Errorf("failed to count unrated calls: %w", err)
+	}
+
+	return lines, unrated, nil
+}
+
+func (rm *RatingManager) CreateDeck(req request.RateDeckRequest) (*models.RateDeck, error) {
+	if err := normalizeDeck(&req); err != nil {
+		return nil, err
+	}
+
+	row := rm.db.QueryRow(`
+		INSERT INTO rate_decks (deck_uuid, name, purpose, domain_uuid, currency, timezone, description)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5, $6, $7)
+		RETURNING `+deckColumns,
+		newUUID(), req.Name, req.Purpose, req.DomainUUID, req.Currency, req.Timezone, req.Description)
+
+	return scanDeck(row)
+}
+
+func (rm *RatingManager) ListDecks(domainUUID string) ([]models.RateDeck, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, ErrInvalidDomain
+	}
+
+	rows, err := rm.db.Query(`SELECT `+deckColumns+` FROM rate_decks
+		WHERE NULLIF($1, '') IS NULL OR domain_uuid IS NULL OR domain_uuid = NULLIF($1, '')::uuid
+		ORDER BY domain_uuid NULLS FIRST, purpose, name`, domainUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch rate decks: %w", err)
+	}
+	defer rows.Close()
+
+	var decks []models.RateDeck
+	for rows.Next() {
+		deck, err := scanDeck(rows)
+		if err != nil {
+			return nil, err
+		}
+		decks = append(decks, *deck)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rate decks: %w", err)
+	}
+	return decks, nil
+}
+
+func (rm *RatingManager) GetDeck(id string) (*models.RateDeck, error) {
+	if !isUUID(id) {
+		return nil, ErrRateDeckNotFound
+	}
+	row := rm.db.QueryRow(`SELECT `+deckColumns+` FROM rate_decks WHERE deck_uuid = $1`, id)
+	return scanDeck(row)
+}
+
+func (rm *RatingManager) UpdateDeck(id string, req request.RateDeckRequest) (*models.RateDeck, error) {
+	if !isUUID(id) {
+		return nil, ErrRateDeckNotFound
+	}
+	if err := normalizeDeck(&req); err != nil {
+		return nil, err
+	}
+
+	row := rm.db.QueryRow(`
+		UPDATE rate_decks
+		SET name = $2, purpose = $3, domain_uuid = NULLIF($4, '')::uuid, currency = $5, timezone = $6,
+			description = $7, updated_at = now()
+		WHERE deck_uuid = $1
+		RETURNING `+deckColumns,
+		id, req.Name, req.Purpose, req.DomainUUID, req.Currency, req.Timezone, req.Description)
+
+	return scanDeck(row)
+}
+
+// DeleteDeck removes a deck and its rates. Existing ratings are kept.
+func (rm *RatingManager) DeleteDeck(id string) error {
+	if !isUUID(id) {
+		return ErrRateDeckNotFound
+	}
+	res, err := rm.db.Exec(`DELETE FROM rate_decks WHERE deck_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete rate deck: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRateDeckNotFound
+	}
+	return nil
+}
+
+func (rm *RatingManager) ListRates(deckUUID, prefix string, limit, offset int) ([]models.Rate, int, error) {
+	if _, err := rm.GetDeck(deckUUID); err != nil {
+		return nil, 0, err
+	}
+
+	where := `WHERE deck_uuid = $1 AND ($2 = '' OR prefix LIKE $2 || '%')`
+	prefix = strings.TrimPrefix(prefix, "+")
+
+	var total int
+	if err := rm.db.QueryRow(`SELECT COUNT(*) FROM rates `+where, deckUUID, prefix).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count rates: %w", err)
+	}
+
+	rows, err := rm.db.Query(`SELECT `+rateColumns+` FROM rates `+where+`
+		ORDER BY prefix, band_start NULLS FIRST LIMIT $3 OFFSET $4`, deckUUID, prefix, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []models.Rate
+	for rows.Next() {
+		var r models.Rate
+		if err := rows.Scan(&r.RateUUID, &r.DeckUUID, &r.Prefix, &r.Destination, &r.ConnectFee, &r.Rate,
+			&r.InitialIncrement, &r.Increment, &r.BandStart, &r.BandEnd, &r.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan rate: %w", err)
+		}
+		rates = append(rates, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read rates: %w", err)
+	}
+
+	return rates, total, nil
+}
+
+// ImportRates loads a CSV with prefix and rate (per minute) columns and
+// optional destination, connect_fee, initial_increment, increment,
+// band_start and band_end (HH:MM) columns. Rows for an existing prefix and
+// band replace it; with replace set the deck is emptied first. Everything
+// happens in one transaction.
+func (rm *RatingManager) ImportRates(deckUUID string, r io.Reader, replace bool) (*ImportResult, error) {
+	if _, err := rm.GetDeck(deckUUID); err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read CSV header: %v", ErrInvalidRateDeck, err)
+	}
+	cols := map[string]int{}
+	for i, col := range header {
+		key := strings.ToLower(strings.TrimSpace(col))
+		switch key {
+		case "description", "name":
+			key = "destination"
+		case "rate_per_minute", "per_minute":
+			key = "rate"
+		}
+		cols[key] = i
+	}
+	col := func(name string) int {
+		if i, ok := cols[name]; ok {
+			return i
+		}
+		return -1
+	}
+	if col("prefix") < 0 || col("rate") < 0 {
+		return nil, fmt.Errorf("%w: CSV header needs prefix and rate columns", ErrInvalidRateDeck)
+	}
+
+	tx, err := rm.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if _, err := tx.Exec(`DELETE FROM rates WHERE deck_uuid = $1`, deckUUID); err != nil {
+			return nil, fmt.Errorf("failed to clear rate deck: %w", err)
+		}
+	}
+
+	result := &ImportResult{Rejected: []ImportReject{}}
+	line := 1
+	for {
+		record, err := reader.Read()
+		line++
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			result.Rejected = append(result.Rejected, ImportReject{Line: line, Reason: err.Error()})
+			continue
+		}
+
+		rate, err := parseRateRecord(record, col)
+		if err != nil {
+			result.Rejected = append(result.Rejected, ImportReject{Line: line, Reason: err.Error()})
+			continue
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO rates (rate_uuid, deck_uuid, prefix, destination, connect_fee, rate, initial_increment, increment,
+				band_start, band_end)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9::time, $10::time)
+			ON CONFLICT (deck_uuid, prefix, (COALESCE(band_start, '00:00')), (COALESCE(band_end, '00:00')))
+			DO UPDATE SET destination = EXCLUDED.destination, connect_fee = EXCLUDED.connect_fee, rate = EXCLUDED.rate,
+				initial_increment = EXCLUDED.initial_increment, increment = EXCLUDED.increment`,
+			newUUID(), deckUUID, rate.Prefix, rate.Destination, rate.ConnectFee, rate.Rate, rate.InitialIncrement,
+			rate.Increment, rate.BandStart, rate.BandEnd); err != nil {
+			return nil, fmt.Errorf("failed to import rate on line %d: %w", line, err)
+		}
+		result.Imported++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit rate import: %w", err)
+	}
+
+	log.Printf("Imported %d rates into deck %s (%d rejected)", result.Imported, deckUUID, len(result.Rejected))
+	return result, nil
+}
+
+func parseRateRecord(record []string, col func(string) int) (models.Rate, error) {
+	r := models.Rate{
+		Prefix:           strings.TrimPrefix(field(record, col("prefix")), "+"),
+		Destination:      field(record, col("destination")),
+		InitialIncrement: 60,
+		Increment:        60,
+	}
+	for _, c := range r.Prefix {
+		if c < '0' || c > '9' {
+			return r, fmt.Errorf("prefix %q must contain digits only", r.Prefix)
+		}
+	}
+
+	var err error
+	if r.Rate, err = strconv.ParseFloat(field(record, col("rate")), 64); err != nil || r.Rate < 0 {
+		return r, fmt.Errorf("invalid rate %q", field(record, col("rate")))
+	}
+	if v := field(record, col("connect_fee")); v != "" {
+		if r.ConnectFee, err = strconv.ParseFloat(v, 64); err != nil || r.ConnectFee < 0 {
+			return r, fmt.Errorf("invalid connect_fee %q", v)
+		}
+	}
+	if v := field(record, col("initial_increment")); v != "" {
+		if r.InitialIncrement, err = strconv.Atoi(v); err != nil || r.InitialIncrement < 0 {
+			return r, fmt.Errorf("invalid initial_increment %q", v)
+		}
+	}
+	if v := field(record, col("increment")); v != "" {
+		if r.Increment, err = strconv.Atoi(v); err != nil || r.Increment < 1 {
+			return r, fmt.Errorf("invalid increment %q", v)
+		}
+	}
+
+	start, end := field(record, col("band_start")), field(record, col("band_end"))
+	if (start == "") != (end == "") {
+		return r, fmt.Errorf("band_start and band_end must be given together")
+	}
+	if start != "" {
+		for _, v := range []string{start, end} {
+			if _, err := time.Parse("15:04", v); err != nil {
+				return r, fmt.Errorf("invalid band time %q, want HH:MM", v)
+			}
+		}
+		if start == end {
+			return r, fmt.Errorf("band_start and band_end must differ")
+		}
+		r.BandStart = sql.NullString{String: start, Valid: true}
+		r.BandEnd = sql.NullString{String: end, Valid: true}
+	}
+
+	return r, nil
+}
+
+func normalizeDeck(req *request.RateDeckRequest) error {
+	if req.Purpose == "" {
+		req.Purpose = DeckPurposePrice
+	}
+	if req.Currency == "" {
+		req.Currency = "USD"
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidRateDeck, req.Timezone)
+	}
+	return nil
+}
+
+func scanDeck(row rowScanner) (*models.RateDeck, error) {
+	var d models.RateDeck
+	err := row.Scan(&d.DeckUUID, &d.Name, &d.Purpose, &d.DomainUUID, &d.Currency, &d.Timezone, &d.Description,
+		&d.CreatedAt, &d.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRateDeckNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrRateDeckExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan rate deck: %w", err)
+	}
+	return &d, nil
+}
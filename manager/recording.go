@@ -0,0 +1,855 @@
+package manager
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/envelope"
+	"github.com/vishaltalsaniya-7/voip-api/storage"
+)
+
+var (
+	ErrRecordingNotFound   = errors.New("recording not found")
+	ErrUnsupportedFormat   = errors.New("unsupported recording format, use wav or mp3")
+	ErrTranscodingDisabled = errors.New("recording is not available in the requested format")
+)
+
+const (
+	transcodeTimeout = 2 * time.Minute
+	storageTimeout   = 5 * time.Minute
+	// moveRetryDelay keeps recordings that failed to move out of the next
+	// batches.
+	moveRetryDelay = time.Hour
+)
+
+// transcodeFormats are the formats recordings can be converted between.
+var transcodeFormats = map[string]string{
+	"wav": "audio/wav",
+	"mp3": "audio/mpeg",
+}
+
+// Recording is the file behind a CDR recording. Recordings moved to a
+// storage backend have a Key and the SHA-256 recorded when they were moved,
+// and KeyUUID names the data key of encrypted ones; the others are read from
+// Path.
+type Recording struct {
+	CDRUUID    string
+	DomainUUID string
+	RecordPath string
+	RecordName string
+	Path       string
+	Key        string
+	SHA256     string
+	KeyUUID    string
+
+	store   storage.Store
+	backend string
+}
+
+// archiveBackend names the archive store in recording_objects.
+const archiveBackend = "archive"
+
+type namedStore struct {
+	backend string
+	store   storage.Store
+}
+
+// Ext returns the file extension of the recording, without the dot.
+func (r *Recording) Ext() string {
+	name := r.Path
+	if r.Key != "" {
+		name = r.Key
+	}
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+}
+
+// RecordingManager finds CDR recordings and, when a storage backend is
+// configured, moves finished recordings off the FreeSWITCH disk, encrypting
+// them when encryption is configured. Retention can archive recordings to a
+// separate store and purge them. Local files are only served from inside
+// the recordings root, whatever path the CDR holds.
+type RecordingManager struct {
+	db      *sql.DB
+	store   storage.Store
+	archive storage.Store
+	keys    *EncryptionManager
+	config  config.RecordingsConfig
+	storage config.StorageConfig
+
+	failed map[string]time.Time // source path -> failed move
+}
+
+// NewRecordingManager takes the storage backend and archive store, either
+// of which may be nil.
+func NewRecordingManager(db *sql.DB, store, archive storage.Store, keys *EncryptionManager, cfg config.RecordingsConfig, storageCfg config.StorageConfig) *RecordingManager {
+	return &RecordingManager{
+		db:      db,
+		store:   store,
+		archive: archive,
+		keys:    keys,
+		config:  cfg,
+		storage: storageCfg,
+		failed:  make(map[string]time.Time),
+	}
+}
+
+func (rm *RecordingManager) stores() []namedStore {
+	var stores []namedStore
+	if rm.store != nil {
+		stores = append(stores, namedStore{rm.storage.Driver, rm.store})
+	}
+	if rm.archive != nil {
+		stores = append(stores, namedStore{archiveBackend, rm.archive})
+	}
+	return stores
+}
+
+// Find returns the recording of a CDR.
+func (rm *RecordingManager) Find(cdrUUID string) (*Recording, error) {
+	if !isUUID(cdrUUID) {
+		return nil, ErrRecordingNotFound
+	}
+
+	var domainUUID sql.NullString
+	var recordPath, recordName string
+	err := rm.db.QueryRow(`
+		SELECT domain_uuid, COALESCE(record_path, ''), COALESCE(record_name, '')
+		FROM v_xml_cdr WHERE xml_cdr_uuid = $1`, cdrUUID).Scan(&domainUUID, &recordPath, &recordName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecordingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch CDR recording: %w", err)
+	}
+	if recordName == "" {
+		return nil, ErrRecordingNotFound
+	}
+
+	rec := &Recording{CDRUUID: cdrUUID, DomainUUID: domainUUID.String, RecordPath: recordPath, RecordName: recordName}
+	if err := rm.locate(rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// locate finds where the file of rec.RecordPath and rec.RecordName lives:
+// in a store when the path is one of its URIs, on the local disk otherwise.
+func (rm *RecordingManager) locate(rec *Recording) error {
+	for _, s := range rm.stores() {
+		prefix := s.store.URI("")
+		if !strings.HasPrefix(rec.RecordPath, prefix) {
+			continue
+		}
+		rec.store, rec.backend = s.store, s.backend
+		rec.Key = path.Join(strings.TrimPrefix(rec.RecordPath, prefix), rec.RecordName)
+		var keyUUID sql.NullString
+		err := rm.db.QueryRow(`
+			SELECT sha256, encryption_key_uuid FROM recording_objects WHERE object_key = $1 AND backend = $2`,
+			rec.Key, rec.backend).Scan(&rec.SHA256, &keyUUID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to fetch recording object: %w", err)
+		}
+		rec.KeyUUID = keyUUID.String
+		return nil
+	}
+
+	var err error
+	rec.Path, err = rm.resolve(filepath.Join(rec.RecordPath, rec.RecordName))
+	return err
+}
+
+// resolve maps a recording path as written by FreeSWITCH to a file under
+// the recordings root. Paths that leave the root, directly or through a
+// symlink, are reported as not found.
+func (rm *RecordingManager) resolve(stored string) (string, error) {
+	rel := filepath.Clean(stored)
+	if filepath.IsAbs(rel) {
+		var err error
+		if rel, err = filepath.Rel(rm.config.FSRoot, rel); err != nil {
+			return "", ErrRecordingNotFound
+		}
+	}
+
+	root, err := filepath.EvalSymlinks(rm.config.Root)
+	if err != nil {
+		return "", fmt.Errorf("failed to open recordings root: %w", err)
+	}
+	full, err := filepath.EvalSymlinks(filepath.Join(root, rel))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrRecordingNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve recording: %w", err)
+	}
+	if !withinDir(root, full) {
+		log.Printf("Refused recording path %q outside of %s", stored, rm.config.Root)
+		return "", ErrRecordingNotFound
+	}
+	return full, nil
+}
+
+// PresignURL returns a short-lived URL the client can download a stored
+// recording from directly, or "" when it has to be served by the API.
+// Encrypted recordings are always served by the API, which decrypts them.
+func (rm *RecordingManager) PresignURL(rec *Recording) (string, error) {
+	if rec.Key == "" || rec.KeyUUID != "" {
+		return "", nil
+	}
+	url, err := rec.store.PresignGet(rec.Key, rm.storage.PresignExpiry)
+	if errors.Is(err, storage.ErrPresignUnsupported) {
+		return "", nil
+	}
+	return url, err
+}
+
+// RecordingFile is an opened recording, decrypted as it is read when it is
+// stored encrypted.
+type RecordingFile struct {
+	io.ReadSeeker
+	ContentType string
+	ModTime     time.Time
+
+	file *os.File
+}
+
+func (f *RecordingFile) Close() error {
+	return f.file.Close()
+}
+
+// Open opens a recording in format ("wav" or "mp3", empty for as stored).
+// Other formats are only produced when transcoding is enabled.
+func (rm *RecordingManager) Open(rec *Recording, format string) (*RecordingFile, error) {
+	ext := rec.Ext()
+	format = strings.ToLower(format)
+	if format != "" && format != ext {
+		if _, ok := transcodeFormats[format]; !ok {
+			return nil, ErrUnsupportedFormat
+		}
+		if _, ok := transcodeFormats[ext]; !ok {
+			return nil, ErrUnsupportedFormat
+		}
+		if !rm.config.Transcode {
+			return nil, ErrTranscodingDisabled
+		}
+	}
+
+	src, err := rm.localFile(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	if format != "" && format != ext {
+		if rec.KeyUUID != "" {
+			src, err = rm.transcodeEncrypted(rec, src, format)
+		} else {
+			src, err = rm.transcode(src, format)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ext = format
+	}
+
+	f, err := os.Open(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrRecordingNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat recording: %w", err)
+	}
+
+	rf := &RecordingFile{ReadSeeker: f, ContentType: contentType(ext), ModTime: info.ModTime(), file: f}
+	if rec.KeyUUID != "" {
+		key, err := rm.keys.Key(rec.KeyUUID)
+		if err == nil {
+			rf.ReadSeeker, err = envelope.NewReader(f, info.Size(), key)
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to decrypt recording: %w", err)
+		}
+	}
+	return rf, nil
+}
+
+// localFile returns a local file holding a recording. Files of local stores
+// are used in place; other stored recordings are downloaded into the cache
+// and checked against the SHA-256 taken when they were moved.
+func (rm *RecordingManager) localFile(rec *Recording) (string, error) {
+	if rec.Key == "" {
+		return rec.Path, nil
+	}
+	if filer, ok := rec.store.(storage.Filer); ok {
+		return filer.Path(rec.Key)
+	}
+
+	dst := rm.objectCachePath(rec)
+	if _, err := os.Stat(dst); err == nil {
+		return dst, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	body, err := rec.store.Open(ctx, rec.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", ErrRecordingNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to download recording: %w", err)
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		return "", fmt.Errorf("failed to create recording cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "download-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create recording cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to download recording: %w", err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); rec.SHA256 != "" && got != rec.SHA256 {
+		return "", fmt.Errorf("recording %s failed its checksum: got %s, want %s", rec.Key, got, rec.SHA256)
+	}
+
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", fmt.Errorf("failed to cache recording: %w", err)
+	}
+	return dst, nil
+}
+
+func (rm *RecordingManager) objectCachePath(rec *Recording) string {
+	sum := sha256.Sum256([]byte(rec.store.URI(rec.Key)))
+	return filepath.Join(rm.config.CacheDir, "objects", hex.EncodeToString(sum[:16])+"."+rec.Ext())
+}
+
+// transcodeCachePath is where src converted to format is cached. Entries
+// are keyed by source path, size and mtime.
+func (rm *RecordingManager) transcodeCachePath(src, format string) (string, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat recording: %w", err)
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d", src, info.Size(), info.ModTime().UnixNano())))
+	return filepath.Join(rm.config.CacheDir, hex.EncodeToString(sum[:16])+"."+format), nil
+}
+
+// transcode converts src with ffmpeg into the cache directory and returns
+// the converted file.
+func (rm *RecordingManager) transcode(src, format string) (string, error) {
+	dst, err := rm.transcodeCachePath(src, format)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return dst, nil
+	}
+
+	if err := os.MkdirAll(rm.config.CacheDir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to create transcode cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(rm.config.CacheDir, "transcode-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create transcode file: %w", err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	ctx, cancel := context.WithTimeout(context.Background(), transcodeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, rm.config.FFmpegPath, "-nostdin", "-loglevel", "error", "-y",
+		"-i", src, "-f", format, tmp.Name()).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to transcode recording: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	// Concurrent requests may race to the same file; rename is atomic so
+	// either result is complete.
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", fmt.Errorf("failed to store transcoded recording: %w", err)
+	}
+	return dst, nil
+}
+
+// transcodeEncrypted converts an encrypted recording by piping it through
+// ffmpeg, and caches the result encrypted with the same data key, so no
+// plaintext reaches the disk.
+func (rm *RecordingManager) transcodeEncrypted(rec *Recording, src, format string) (string, error) {
+	dst, err := rm.transcodeCachePath(src, format)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return dst, nil
+	}
+
+	key, err := rm.keys.Key(rec.KeyUUID)
+	if err != nil {
+		return "", err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat recording: %w", err)
+	}
+	plain, err := envelope.NewReader(in, info.Size(), key)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt recording: %w", err)
+	}
+
+	if err := os.MkdirAll(rm.config.CacheDir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to create transcode cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(rm.config.CacheDir, "transcode-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create transcode file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	out, err := envelope.NewWriter(tmp, rec.KeyUUID, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt transcoded recording: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), transcodeTimeout)
+	defer cancel()
+	var stderr strings.Builder
+	cmd := exec.CommandContext(ctx, rm.config.FFmpegPath, "-nostdin", "-loglevel", "error",
+		"-f", rec.Ext(), "-i", "pipe:0", "-f", format, "pipe:1")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = plain, out, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to transcode recording: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if err := out.Close(); err != nil {
+		return "", fmt.Errorf("failed to encrypt transcoded recording: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write transcoded recording: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", fmt.Errorf("failed to store transcoded recording: %w", err)
+	}
+	return dst, nil
+}
+
+// seal encrypts src with the data key of a tenant into a temporary file,
+// which the caller removes, and returns it with the data key id. Without
+// encryption it returns src as is.
+func (rm *RecordingManager) seal(src, domainUUID string) (string, string, error) {
+	if !rm.keys.Enabled() {
+		return src, "", nil
+	}
+	keyUUID, key, err := rm.keys.DataKey(domainUUID)
+	if err != nil {
+		return "", "", err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return "", "", err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(rm.config.CacheDir, 0o750); err != nil {
+		return "", "", fmt.Errorf("failed to create recording cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(rm.config.CacheDir, "seal-*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create encrypted file: %w", err)
+	}
+	w, err := envelope.NewWriter(tmp, keyUUID, key)
+	if err == nil {
+		_, err = io.Copy(w, in)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", "", fmt.Errorf("failed to encrypt recording: %w", err)
+	}
+	return tmp.Name(), keyUUID, nil
+}
+
+// Run moves finished recordings to the storage backend. Without a backend
+// recordings stay on the FreeSWITCH disk and Run returns.
+func (rm *RecordingManager) Run() {
+	if rm.store == nil {
+		return
+	}
+	ticker := time.NewTicker(rm.storage.MoveInterval)
+	defer ticker.Stop()
+
+	log.Printf("Recording mover started, moving to %s every %s", rm.store.URI(""), rm.storage.MoveInterval)
+	for range ticker.C {
+		for {
+			n, err := rm.moveBatch()
+			if err != nil {
+				log.Printf("Recording move run failed: %v", err)
+			}
+			if err != nil || n < rm.storage.MoveBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// moveBatch moves the recordings of CDRs that ended at least MinAge ago and
+// still point at the local disk, and returns how many it picked up.
+// Archived recordings stay in the archive.
+func (rm *RecordingManager) moveBatch() (int, error) {
+	var skip []string
+	for source, at := range rm.failed {
+		if time.Since(at) < moveRetryDelay {
+			skip = append(skip, source)
+		} else {
+			delete(rm.failed, source)
+		}
+	}
+
+	prefix := rm.store.URI("")
+	rows, err := rm.db.Query(`
+		SELECT DISTINCT ON (record_path, record_name) record_path, record_name, domain_uuid
+		FROM v_xml_cdr
+		WHERE COALESCE(record_name, '') <> '' AND COALESCE(record_path, '') <> ''
+			AND end_stamp < now() - make_interval(secs => $1)
+			AND left(record_path, length($2)) <> $2
+			AND ($5 = '' OR left(record_path, length($5)) <> $5)
+			AND NOT (record_path || '/' || record_name = ANY($3))
+		LIMIT $4`, rm.storage.MinAge.Seconds(), prefix, pq.Array(skip), rm.storage.MoveBatchSize, rm.archivePrefix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch recordings to move: %w", err)
+	}
+
+	type pending struct {
+		dir, name string
+		domain    sql.NullString
+	}
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.dir, &p.name, &p.domain); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan recording: %w", err)
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read recordings to move: %w", err)
+	}
+
+	for _, p := range batch {
+		if err := rm.move(p.dir, p.name, p.domain.String); err != nil {
+			log.Printf("Failed to move recording %s/%s: %v", p.dir, p.name, err)
+			rm.failed[p.dir+"/"+p.name] = time.Now()
+		}
+	}
+	return len(batch), nil
+}
+
+// move uploads one recording, encrypted with its tenant's data key when
+// encryption is on, checks the stored copy against the uploaded checksum,
+// points the CDRs at the new location and removes the local file.
+func (rm *RecordingManager) move(dir, name, domainUUID string) error {
+	src, err := rm.resolve(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(rm.config.Root)
+	if err != nil {
+		return fmt.Errorf("failed to open recordings root: %w", err)
+	}
+	rel, err := filepath.Rel(root, src)
+	if err != nil {
+		return err
+	}
+	key := filepath.ToSlash(rel)
+
+	upload, keyUUID, err := rm.seal(src, domainUUID)
+	if err != nil {
+		return err
+	}
+	if upload != src {
+		defer os.Remove(upload)
+	}
+	size, sum, err := rm.copyTo(rm.store, key, upload)
+	if err != nil {
+		return err
+	}
+
+	tx, err := rm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO recording_objects (object_key, backend, source_path, size, sha256, encryption_key_uuid)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid)
+		ON CONFLICT (object_key) DO UPDATE
+		SET backend = EXCLUDED.backend, source_path = EXCLUDED.source_path, size = EXCLUDED.size,
+			sha256 = EXCLUDED.sha256, encryption_key_uuid = EXCLUDED.encryption_key_uuid, stored_at = now()`,
+		key, rm.storage.Driver, src, size, sum, keyUUID); err != nil {
+		return fmt.Errorf("failed to record recording object: %w", err)
+	}
+	keyDir := path.Dir(key)
+	if keyDir == "." {
+		keyDir = ""
+	}
+	if _, err := tx.Exec(`UPDATE v_xml_cdr SET record_path = $3 WHERE record_path = $1 AND record_name = $2`,
+		dir, name, rm.store.URI(keyDir)); err != nil {
+		return fmt.Errorf("failed to update CDR record path: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit recording move: %w", err)
+	}
+
+	// The plaintext of an encrypted recording is overwritten, not just
+	// unlinked.
+	if rm.storage.DeleteLocal {
+		remove := os.Remove
+		if keyUUID != "" {
+			remove = shred
+		}
+		if err := remove(src); err != nil {
+			log.Printf("Failed to remove moved recording %s: %v", src, err)
+		}
+	}
+	return nil
+}
+
+// archivePrefix is the record_path prefix of archived recordings, or ""
+// without an archive store.
+func (rm *RecordingManager) archivePrefix() string {
+	if rm.archive == nil {
+		return ""
+	}
+	return rm.archive.URI("")
+}
+
+// Archive copies a recording into the archive store and verifies the copy.
+// It returns the record_path of the archived copy; the original is left for
+// the caller to purge once the CDRs point at the copy.
+func (rm *RecordingManager) Archive(rec *Recording) (string, error) {
+	if rm.archive == nil {
+		return "", errors.New("no archive store is configured")
+	}
+
+	src, err := rm.localFile(rec)
+	if err != nil {
+		return "", err
+	}
+	key := rec.Key
+	if key == "" {
+		root, err := filepath.EvalSymlinks(rm.config.Root)
+		if err != nil {
+			return "", fmt.Errorf("failed to open recordings root: %w", err)
+		}
+		rel, err := filepath.Rel(root, src)
+		if err != nil {
+			return "", err
+		}
+		key = filepath.ToSlash(rel)
+	}
+
+	// Encrypted recordings are copied as they are; plain ones are encrypted
+	// on the way into the archive when encryption is on.
+	upload, keyUUID := src, rec.KeyUUID
+	if keyUUID == "" {
+		if upload, keyUUID, err = rm.seal(src, rec.DomainUUID); err != nil {
+			return "", err
+		}
+		if upload != src {
+			defer os.Remove(upload)
+		}
+	}
+	size, sum, err := rm.copyTo(rm.archive, key, upload)
+	if err != nil {
+		return "", err
+	}
+	if _, err := rm.db.Exec(`
+		INSERT INTO recording_objects (object_key, backend, source_path, size, sha256, encryption_key_uuid)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid)
+		ON CONFLICT (object_key) DO UPDATE
+		SET backend = EXCLUDED.backend, source_path = EXCLUDED.source_path, size = EXCLUDED.size,
+			sha256 = EXCLUDED.sha256, encryption_key_uuid = EXCLUDED.encryption_key_uuid, stored_at = now()`,
+		key, archiveBackend, rec.RecordPath+"/"+rec.RecordName, size, sum, keyUUID); err != nil {
+		return "", fmt.Errorf("failed to record archived recording: %w", err)
+	}
+
+	keyDir := path.Dir(key)
+	if keyDir == "." {
+		keyDir = ""
+	}
+	return rm.archive.URI(keyDir), nil
+}
+
+// copyTo uploads the file src to store under key and checks the stored copy
+// against its size and SHA-256, which it returns.
+func (rm *RecordingManager) copyTo(store storage.Store, key, src string) (int64, string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to hash recording: %w", err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	if err := store.Put(ctx, key, f, size, sum); err != nil {
+		return 0, "", fmt.Errorf("failed to upload: %w", err)
+	}
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to verify upload: %w", err)
+	}
+	if info.Size != size || (info.SHA256 != "" && info.SHA256 != sum) {
+		return 0, "", fmt.Errorf("uploaded copy does not match: %d bytes sha256 %s, want %d bytes sha256 %s",
+			info.Size, info.SHA256, size, sum)
+	}
+	return size, sum, nil
+}
+
+// Purge destroys a recording and the cached copies made of it. Files on
+// disk are overwritten before they are removed; objects in remote stores
+// are deleted.
+func (rm *RecordingManager) Purge(rec *Recording) error {
+	src, err := rm.cachedSource(rec)
+	if err == nil {
+		for format := range transcodeFormats {
+			if p, err := rm.transcodeCachePath(src, format); err == nil {
+				if err := shred(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+					log.Printf("Failed to purge cached recording %s: %v", p, err)
+				}
+			}
+		}
+	}
+
+	if rec.Key == "" {
+		return shred(rec.Path)
+	}
+
+	if filer, ok := rec.store.(storage.Filer); ok {
+		p, err := filer.Path(rec.Key)
+		if err != nil {
+			return err
+		}
+		if err := shred(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	} else {
+		if err := shred(rm.objectCachePath(rec)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to purge cached recording %s: %v", rec.Key, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+		defer cancel()
+		if err := rec.store.Delete(ctx, rec.Key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("failed to delete stored recording: %w", err)
+		}
+	}
+
+	if _, err := rm.db.Exec(`DELETE FROM recording_objects WHERE object_key = $1 AND backend = $2`,
+		rec.Key, rec.backend); err != nil {
+		return fmt.Errorf("failed to remove recording object: %w", err)
+	}
+	return nil
+}
+
+// cachedSource returns the local file transcoded copies of rec were made
+// from, without downloading anything.
+func (rm *RecordingManager) cachedSource(rec *Recording) (string, error) {
+	if rec.Key == "" {
+		return rec.Path, nil
+	}
+	if filer, ok := rec.store.(storage.Filer); ok {
+		return filer.Path(rec.Key)
+	}
+	return rm.objectCachePath(rec), nil
+}
+
+// shred overwrites a file with random data, syncs it and removes it. On
+// copy-on-write filesystems and SSDs the old blocks may survive, so this is
+// a best effort on top of the removal.
+func shred(name string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		_, err = io.CopyN(f, rand.Reader, info.Size())
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to overwrite %s: %w", name, err)
+	}
+	return os.Remove(name)
+}
+
+func contentType(ext string) string {
+	if t, ok := transcodeFormats[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension("." + ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// withinDir reports whether target is dir or below it.
+func withinDir(dir, target string) bool {
+	rel, err := filepath.Rel(dir, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
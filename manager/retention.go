@@ -0,0 +1,559 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+	"github.com/vishaltalsaniya-7/voip-api/storage"
+)
+
+var (
+	ErrRetentionPolicyNotFound = errors.New("retention policy not found")
+	ErrRetentionPolicyExists   = errors.New("a retention policy already exists for this tenant and direction")
+	ErrInvalidRetentionPolicy  = errors.New("invalid retention policy")
+	ErrLegalHoldNotFound       = errors.New("legal hold not found")
+	ErrInvalidLegalHold        = errors.New("invalid legal hold")
+)
+
+// Retention actions. Archived recordings are moved to the archive store and
+// stay downloadable; deleted ones are destroyed.
+const (
+	RetentionDelete  = "delete"
+	RetentionArchive = "archive"
+)
+
+const retentionPolicyColumns = `policy_uuid, domain_uuid, direction, retain_days, action, description, enabled,
+	created_at, updated_at`
+
+const legalHoldColumns = `hold_uuid, domain_uuid, xml_cdr_uuid, number, numbers, reason, expires_at, released_at,
+	created_at`
+
+const retentionPurgeColumns = `purge_uuid, xml_cdr_uuid, domain_uuid, policy_uuid, action, record_path, record_name,
+	destination, reason, purged_at`
+
+// activeHold matches the legal holds h that cover the CDR s. A hold covers
+// the CDRs that match every field it sets: tenant, CDR and number.
+const activeHold = `h.released_at IS NULL AND (h.expires_at IS NULL OR h.expires_at > now())
+	AND (h.domain_uuid IS NULL OR h.domain_uuid = s.domain_uuid)
+	AND (h.xml_cdr_uuid IS NULL OR h.xml_cdr_uuid = s.xml_cdr_uuid)
+	AND (cardinality(h.numbers) = 0 OR s.caller_id_number = ANY(h.numbers) OR s.destination_number = ANY(h.numbers))`
+
+// retentionCandidates selects one CDR per recording file whose policy has
+// expired it. A CDR gets the most specific enabled policy: its tenant's for
+// its direction, its tenant's for any direction, then the global ones.
+// Files shared with a CDR under legal hold are left alone, and archive
+// policies skip recordings already in the archive. The %s is "NOT" for the
+// recordings to purge and "" for the held ones.
+const retentionCandidates = `
+	SELECT DISTINCT ON (c.record_path, c.record_name)
+		c.xml_cdr_uuid, c.domain_uuid, COALESCE(c.direction, ''), c.start_stamp, c.record_path, c.record_name,
+		p.policy_uuid, p.retain_days, p.action, p.domain_uuid IS NOT NULL, p.direction
+	FROM v_xml_cdr c
+	JOIN LATERAL (
+		SELECT policy_uuid, domain_uuid, direction, retain_days, action
+		FROM retention_policies p
+		WHERE p.enabled
+			AND (p.domain_uuid IS NULL OR p.domain_uuid = c.domain_uuid)
+			AND (p.direction = '' OR p.direction = c.direction)
+		ORDER BY p.domain_uuid IS NULL, p.direction = ''
+		LIMIT 1
+	) p ON true
+	WHERE COALESCE(c.record_name, '') <> '' AND COALESCE(c.record_path, '') <> ''
+		AND c.start_stamp < now() - make_interval(days => p.retain_days)
+		AND (NULLIF($1, '') IS NULL OR c.domain_uuid = NULLIF($1, '')::uuid)
+		AND NOT (p.action = 'archive' AND $2 <> '' AND left(c.record_path, length($2)) = $2)
+		AND %s EXISTS (
+			SELECT 1 FROM v_xml_cdr s JOIN legal_holds h ON ` + activeHold + `
+			WHERE s.record_path = c.record_path AND s.record_name = c.record_name
+		)
+	ORDER BY c.record_path, c.record_name, c.start_stamp`
+
+// RetentionManager applies retention policies to call recordings on a
+// schedule. Every purged or archived recording is written to
+// retention_purges with the policy and reason.
+type RetentionManager struct {
+	db         *sql.DB
+	recordings *RecordingManager
+	numbers    *NumberManager
+	config     config.RetentionConfig
+
+	failed map[string]time.Time // record path/name -> failed purge
+}
+
+func NewRetentionManager(db *sql.DB, recordings *RecordingManager, numbers *NumberManager, cfg config.RetentionConfig) *RetentionManager {
+	return &RetentionManager{
+		db:         db,
+		recordings: recordings,
+		numbers:    numbers,
+		config:     cfg,
+		failed:     make(map[string]time.Time),
+	}
+}
+
+// Run purges expired recordings until none are left, then waits for the
+// next interval. Recordings that fail are retried on the next interval.
+func (rtm *RetentionManager) Run() {
+	ticker := time.NewTicker(rtm.config.Interval)
+	defer ticker.Stop()
+
+	log.Printf("Recording retention started, running every %s", rtm.config.Interval)
+	for {
+		rtm.failed = make(map[string]time.Time)
+		for {
+			n, err := rtm.purgeBatch()
+			if err != nil {
+				log.Printf("Failed to apply retention policies: %v", err)
+				break
+			}
+			if n == 0 {
+				break
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// purgeBatch applies the policies to one batch of expired recordings and
+// returns how many new ones it handled.
+func (rtm *RetentionManager) purgeBatch() (int, error) {
+	candidates, err := rtm.candidates("", rtm.config.BatchSize+len(rtm.failed), 0)
+	if err != nil {
+		return 0, err
+	}
+
+	handled := 0
+	for _, c := range candidates {
+		id := c.RecordPath + "/" + c.RecordName
+		if _, ok := rtm.failed[id]; ok {
+			continue
+		}
+		handled++
+		if err := rtm.apply(c); err != nil {
+			log.Printf("Failed to %s recording %s: %v", c.Action, id, err)
+			rtm.failed[id] = time.Now()
+		}
+	}
+	return handled, nil
+}
+
+// apply archives or deletes one recording and logs it. Archived originals
+// are purged only once the CDRs point at the copy. Recordings whose file is
+// gone are only cleared from the CDRs.
+func (rtm *RetentionManager) apply(c models.RetentionCandidate) error {
+	rec := &Recording{
+		CDRUUID:    c.XMLCDRUUID,
+		DomainUUID: c.DomainUUID.String,
+		RecordPath: c.RecordPath,
+		RecordName: c.RecordName,
+	}
+
+	action, destination := c.Action, ""
+	err := rtm.recordings.locate(rec)
+	if err == nil {
+		if action == RetentionArchive {
+			destination, err = rtm.recordings.Archive(rec)
+		} else {
+			err = rtm.recordings.Purge(rec)
+		}
+	}
+	switch {
+	case errors.Is(err, ErrRecordingNotFound), errors.Is(err, storage.ErrNotFound):
+		action, destination = RetentionDelete, ""
+		c.Reason += "; the file was already gone"
+	case err != nil:
+		return err
+	}
+
+	if err := rtm.record(c, action, destination); err != nil {
+		return err
+	}
+	if destination != "" {
+		if err := rtm.recordings.Purge(rec); err != nil {
+			return fmt.Errorf("archived to %s but failed to purge the original: %w", destination, err)
+		}
+	}
+	return nil
+}
+
+// record points the CDRs sharing the recording at its archived copy, or
+// clears their recording fields, and logs the purge for each of them.
+func (rtm *RetentionManager) record(c models.RetentionCandidate, action, destination string) error {
+	tx, err := rtm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var rows *sql.Rows
+	if action == RetentionArchive {
+		rows, err = tx.Query(`
+			UPDATE v_xml_cdr SET record_path = $3
+			WHERE record_path = $1 AND record_name = $2
+			RETURNING xml_cdr_uuid, domain_uuid`, c.RecordPath, c.RecordName, destination)
+	} else {
+		rows, err = tx.Query(`
+			UPDATE v_xml_cdr
+			SET record_path = NULL, record_name = NULL, record_length = NULL, record_transcription = NULL
+			WHERE record_path = $1 AND record_name = $2
+			RETURNING xml_cdr_uuid, domain_uuid`, c.RecordPath, c.RecordName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update CDRs: %w", err)
+	}
+	type cdr struct {
+		id     string
+		domain sql.NullString
+	}
+	var cdrs []cdr
+	for rows.Next() {
+		var r cdr
+		if err := rows.Scan(&r.id, &r.domain); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan CDR: %w", err)
+		}
+		cdrs = append(cdrs, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read CDRs: %w", err)
+	}
+
+	for _, r := range cdrs {
+		if _, err := tx.Exec(`
+			INSERT INTO retention_purges (purge_uuid, xml_cdr_uuid, domain_uuid, policy_uuid, action, record_path,
+				record_name, destination, reason)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			newUUID(), r.id, r.domain, c.PolicyUUID, action, c.RecordPath, c.RecordName, destination,
+			c.Reason); err != nil {
+			return fmt.Errorf("failed to log purge: %w", err)
+		}
+		if action != RetentionArchive {
+			if _, err := tx.Exec(`DELETE FROM cdr_transcripts WHERE xml_cdr_uuid = $1`, r.id); err != nil {
+				return fmt.Errorf("failed to delete transcript: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit purge: %w", err)
+	}
+	return nil
+}
+
+// Report is the dry run of the retention engine: the recordings it would
+// purge now, and how many expired recordings legal holds keep.
+func (rtm *RetentionManager) Report(domainUUID string, limit, offset int) ([]models.RetentionCandidate, int, int, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, 0, 0, ErrInvalidDomain
+	}
+
+	var total, held int
+	if err := rtm.db.QueryRow(`SELECT COUNT(*) FROM (`+fmt.Sprintf(retentionCandidates, "NOT")+`) candidates`,
+		domainUUID, rtm.recordings.archivePrefix()).Scan(&total); err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to count expired recordings: %w", err)
+	}
+	if err := rtm.db.QueryRow(`SELECT COUNT(*) FROM (`+fmt.Sprintf(retentionCandidates, "")+`) candidates`,
+		domainUUID, rtm.recordings.archivePrefix()).Scan(&held); err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to count held recordings: %w", err)
+	}
+
+	candidates, err := rtm.candidates(domainUUID, limit, offset)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return candidates, total, held, nil
+}
+
+func (rtm *RetentionManager) candidates(domainUUID string, limit, offset int) ([]models.RetentionCandidate, error) {
+	rows, err := rtm.db.Query(`SELECT * FROM (`+fmt.Sprintf(retentionCandidates, "NOT")+`) candidates
+		ORDER BY start_stamp LIMIT $3 OFFSET $4`, domainUUID, rtm.recordings.archivePrefix(), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch expired recordings: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []models.RetentionCandidate
+	for rows.Next() {
+		var c models.RetentionCandidate
+		var tenantPolicy bool
+		var policyDirection string
+		if err := rows.Scan(&c.XMLCDRUUID, &c.DomainUUID, &c.Direction, &c.StartStamp, &c.RecordPath, &c.RecordName,
+			&c.PolicyUUID, &c.RetainDays, &c.Action, &tenantPolicy, &policyDirection); err != nil {
+			return nil, fmt.Errorf("failed to scan expired recording: %w", err)
+		}
+		c.Reason = retentionReason(c, tenantPolicy, policyDirection)
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read expired recordings: %w", err)
+	}
+	return candidates, nil
+}
+
+// ListPurges returns the purge log, newest first.
+func (rtm *RetentionManager) ListPurges(domainUUID, cdrUUID string, limit, offset int) ([]models.RetentionPurge, int, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, 0, ErrInvalidDomain
+	}
+	if cdrUUID != "" && !isUUID(cdrUUID) {
+		return nil, 0, nil
+	}
+	where := `WHERE (NULLIF($1, '') IS NULL OR domain_uuid = NULLIF($1, '')::uuid)
+		AND (NULLIF($2, '') IS NULL OR xml_cdr_uuid = NULLIF($2, '')::uuid)`
+
+	var total int
+	if err := rtm.db.QueryRow(`SELECT COUNT(*) FROM retention_purges `+where, domainUUID, cdrUUID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count purges: %w", err)
+	}
+
+	rows, err := rtm.db.Query(`SELECT `+retentionPurgeColumns+` FROM retention_purges `+where+`
+		ORDER BY purged_at DESC LIMIT $3 OFFSET $4`, domainUUID, cdrUUID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch purges: %w", err)
+	}
+	defer rows.Close()
+
+	var purges []models.RetentionPurge
+	for rows.Next() {
+		var p models.RetentionPurge
+		if err := rows.Scan(&p.PurgeUUID, &p.XMLCDRUUID, &p.DomainUUID, &p.PolicyUUID, &p.Action, &p.RecordPath,
+			&p.RecordName, &p.Destination, &p.Reason, &p.PurgedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan purge: %w", err)
+		}
+		purges = append(purges, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read purges: %w", err)
+	}
+
+	return purges, total, nil
+}
+
+func (rtm *RetentionManager) CreatePolicy(req request.RetentionPolicyRequest) (*models.RetentionPolicy, error) {
+	if err := rtm.normalizePolicy(&req); err != nil {
+		return nil, err
+	}
+
+	row := rtm.db.QueryRow(`
+		INSERT INTO retention_policies (policy_uuid, domain_uuid, direction, retain_days, action, description, enabled)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, $7)
+		RETURNING `+retentionPolicyColumns,
+		newUUID(), req.DomainUUID, req.Direction, req.RetainDays, req.Action, req.Description,
+		req.Enabled == nil || *req.Enabled)
+
+	return scanRetentionPolicy(row)
+}
+
+func (rtm *RetentionManager) GetPolicy(id string) (*models.RetentionPolicy, error) {
+	if !isUUID(id) {
+		return nil, ErrRetentionPolicyNotFound
+	}
+	row := rtm.db.QueryRow(`SELECT `+retentionPolicyColumns+` FROM retention_policies WHERE policy_uuid = $1`, id)
+	return scanRetentionPolicy(row)
+}
+
+// ListPolicies returns the policies that apply to domainUUID (its own plus
+// global ones), or every policy when domainUUID is empty.
+func (rtm *RetentionManager) ListPolicies(domainUUID string) ([]models.RetentionPolicy, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, ErrInvalidDomain
+	}
+	rows, err := rtm.db.Query(`
+		SELECT `+retentionPolicyColumns+` FROM retention_policies
+		WHERE NULLIF($1, '') IS NULL OR domain_uuid IS NULL OR domain_uuid = NULLIF($1, '')::uuid
+		ORDER BY domain_uuid NULLS FIRST, direction`, domainUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch retention policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []models.RetentionPolicy
+	for rows.Next() {
+		policy, err := scanRetentionPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, *policy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read retention policies: %w", err)
+	}
+	return policies, nil
+}
+
+func (rtm *RetentionManager) UpdatePolicy(id string, req request.RetentionPolicyRequest) (*models.RetentionPolicy, error) {
+	if !isUUID(id) {
+		return nil, ErrRetentionPolicyNotFound
+	}
+	if err := rtm.normalizePolicy(&req); err != nil {
+		return nil, err
+	}
+
+	row := rtm.db.QueryRow(`
+		UPDATE retention_policies
+		SET domain_uuid = NULLIF($2, '')::uuid, direction = $3, retain_days = $4, action = $5, description = $6,
+			enabled = $7, updated_at = now()
+		WHERE policy_uuid = $1
+		RETURNING `+retentionPolicyColumns,
+		id, req.DomainUUID, req.Direction, req.RetainDays, req.Action, req.Description,
+		req.Enabled == nil || *req.Enabled)
+
+	return scanRetentionPolicy(row)
+}
+
+func (rtm *RetentionManager) DeletePolicy(id string) error {
+	if !isUUID(id) {
+		return ErrRetentionPolicyNotFound
+	}
+	res, err := rtm.db.Exec(`DELETE FROM retention_policies WHERE policy_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete retention policy: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRetentionPolicyNotFound
+	}
+	return nil
+}
+
+func (rtm *RetentionManager) normalizePolicy(req *request.RetentionPolicyRequest) error {
+	if req.Action == "" {
+		req.Action = RetentionDelete
+	}
+	if req.Action == RetentionArchive && rtm.recordings.archive == nil {
+		return fmt.Errorf("%w: archive needs RETENTION_ARCHIVE_ROOT to be set", ErrInvalidRetentionPolicy)
+	}
+	return nil
+}
+
+// CreateHold places a legal hold. Number holds match every spelling of the
+// number the CDRs may hold.
+func (rtm *RetentionManager) CreateHold(req request.LegalHoldRequest) (*models.LegalHold, error) {
+	if req.DomainUUID == "" && req.XMLCDRUUID == "" && req.Number == "" {
+		return nil, fmt.Errorf("%w: set domain_uuid, xml_cdr_uuid or number", ErrInvalidLegalHold)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidLegalHold)
+	}
+
+	numbers := []string{}
+	if req.Number != "" {
+		var err error
+		if numbers, err = rtm.numbers.Variants(req.DomainUUID, req.Number); err != nil {
+			return nil, err
+		}
+	}
+
+	row := rtm.db.QueryRow(`
+		INSERT INTO legal_holds (hold_uuid, domain_uuid, xml_cdr_uuid, number, numbers, reason, expires_at)
+		VALUES ($1, NULLIF($2, '')::uuid, NULLIF($3, '')::uuid, $4, $5, $6, $7)
+		RETURNING `+legalHoldColumns,
+		newUUID(), req.DomainUUID, req.XMLCDRUUID, req.Number, pq.Array(numbers), req.Reason, req.ExpiresAt)
+
+	return scanLegalHold(row)
+}
+
+// ListHolds returns the holds of a tenant, or all holds when domainUUID is
+// empty. activeOnly leaves out released and expired holds.
+func (rtm *RetentionManager) ListHolds(domainUUID string, activeOnly bool, limit, offset int) ([]models.LegalHold, int, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, 0, ErrInvalidDomain
+	}
+	where := `WHERE (NULLIF($1, '') IS NULL OR domain_uuid = NULLIF($1, '')::uuid)
+		AND (NOT $2 OR (released_at IS NULL AND (expires_at IS NULL OR expires_at > now())))`
+
+	var total int
+	if err := rtm.db.QueryRow(`SELECT COUNT(*) FROM legal_holds `+where, domainUUID, activeOnly).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count legal holds: %w", err)
+	}
+
+	rows, err := rtm.db.Query(`SELECT `+legalHoldColumns+` FROM legal_holds `+where+`
+		ORDER BY created_at DESC LIMIT $3 OFFSET $4`, domainUUID, activeOnly, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch legal holds: %w", err)
+	}
+	defer rows.Close()
+
+	var holds []models.LegalHold
+	for rows.Next() {
+		hold, err := scanLegalHold(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		holds = append(holds, *hold)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read legal holds: %w", err)
+	}
+
+	return holds, total, nil
+}
+
+func (rtm *RetentionManager) GetHold(id string) (*models.LegalHold, error) {
+	if !isUUID(id) {
+		return nil, ErrLegalHoldNotFound
+	}
+	row := rtm.db.QueryRow(`SELECT `+legalHoldColumns+` FROM legal_holds WHERE hold_uuid = $1`, id)
+	return scanLegalHold(row)
+}
+
+// ReleaseHold ends a legal hold. Released holds are kept for the record.
+func (rtm *RetentionManager) ReleaseHold(id string) (*models.LegalHold, error) {
+	if !isUUID(id) {
+		return nil, ErrLegalHoldNotFound
+	}
+	row := rtm.db.QueryRow(`
+		UPDATE legal_holds SET released_at = COALESCE(released_at, now())
+		WHERE hold_uuid = $1
+		RETURNING `+legalHoldColumns, id)
+	return scanLegalHold(row)
+}
+
+// retentionReason explains which policy expired a recording.
+func retentionReason(c models.RetentionCandidate, tenantPolicy bool, policyDirection string) string {
+	scope := "global"
+	if tenantPolicy {
+		scope = "tenant"
+	}
+	if policyDirection != "" {
+		scope += " " + policyDirection
+	}
+	return fmt.Sprintf("recorded %s, past the %d days kept by the %s policy",
+		c.StartStamp.UTC().Format("2006-01-02"), c.RetainDays, scope)
+}
+
+func scanRetentionPolicy(row rowScanner) (*models.RetentionPolicy, error) {
+	var p models.RetentionPolicy
+	err := row.Scan(&p.PolicyUUID, &p.DomainUUID, &p.Direction, &p.RetainDays, &p.Action, &p.Description,
+		&p.Enabled, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRetentionPolicyNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrRetentionPolicyExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan retention policy: %w", err)
+	}
+	return &p, nil
+}
+
+func scanLegalHold(row rowScanner) (*models.LegalHold, error) {
+	var h models.LegalHold
+	err := row.Scan(&h.HoldUUID, &h.DomainUUID, &h.XMLCDRUUID, &h.Number, pq.Array(&h.Numbers), &h.Reason,
+		&h.ExpiresAt, &h.ReleasedAt, &h.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLegalHoldNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan legal hold: %w", err)
+	}
+	return &h, nil
+}
@@ -0,0 +1,320 @@
+package manager
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+)
+
+const scheduleColumns = `schedule_uuid, domain_uuid, name, call_request, run_at, cron_expression, timezone, enabled,
+	next_run_at, last_run_at, last_call_id, last_error, run_count, created_at, updated_at`
+
+// SchedulerManager stores scheduled calls in Postgres and originates them
+// when they are due. Due rows are claimed with FOR UPDATE SKIP LOCKED so
+// several API instances can run the worker against the same database.
+type SchedulerManager struct {
+	db     *sql.DB
+	eslMgr *ESLManager
+	checks *CallChecker
+	config config.SchedulerConfig
+}
+
+func NewSchedulerManager(db *sql.DB, eslMgr *ESLManager, checks *CallChecker, cfg config.SchedulerConfig) *SchedulerManager {
+	return &SchedulerManager{
+		db:     db,
+		eslMgr: eslMgr,
+		checks: checks,
+		config: cfg,
+	}
+}
+
+func (s *SchedulerManager) Create(req request.ScheduleRequest) (*models.CallSchedule, error) {
+	nextRun, err := firstRun(req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	callJSON, err := json.Marshal(req.Call)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode call request: %w", err)
+	}
+
+	enabled := req.Enabled == nil || *req.Enabled
+	row := s.db.QueryRow(`
+		INSERT INTO call_schedules (schedule_uuid, domain_uuid, name, call_request, run_at, cron_expression, timezone, enabled, next_run_at)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+scheduleColumns,
+		newUUID(), req.Call.DomainUUID, req.Name, callJSON, req.RunAt, req.Cron, scheduleTimezone(req), enabled, nextRun)
+
+	return scanSchedule(row)
+}
+
+func (s *SchedulerManager) Get(id string) (*models.CallSchedule, error) {
+	if !isUUID(id) {
+		return nil, ErrScheduleNotFound
+	}
+	row := s.db.QueryRow(`SELECT `+scheduleColumns+` FROM call_schedules WHERE schedule_uuid = $1`, id)
+	return scanSchedule(row)
+}
+
+// List returns the schedules of domainUUID, or of every tenant when it is
+// empty.
+func (s *SchedulerManager) List(domainUUID string, limit, offset int) ([]models.CallSchedule, int, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, 0, ErrInvalidDomain
+	}
+	const where = `WHERE NULLIF($1, '') IS NULL OR domain_uuid = NULLIF($1, '')::uuid`
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM call_schedules `+where, domainUUID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count schedules: %w", err)
+	}
+
+	rows, err := s.db.Query(`SELECT `+scheduleColumns+` FROM call_schedules `+where+`
+		ORDER BY created_at DESC LIMIT $2 OFFSET $3`, domainUUID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []models.CallSchedule
+	for rows.Next() {
+		sched, err := scanSchedule(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		schedules = append(schedules, *sched)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read schedules: %w", err)
+	}
+
+	return schedules, total, nil
+}
+
+func (s *SchedulerManager) Update(id string, req request.ScheduleRequest) (*models.CallSchedule, error) {
+	if !isUUID(id) {
+		return nil, ErrScheduleNotFound
+	}
+	nextRun, err := firstRun(req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	callJSON, err := json.Marshal(req.Call)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode call request: %w", err)
+	}
+
+	enabled := req.Enabled == nil || *req.Enabled
+	row := s.db.QueryRow(`
+		UPDATE call_schedules
+		SET domain_uuid = NULLIF($2, '')::uuid, name = $3, call_request = $4, run_at = $5, cron_expression = $6,
+			timezone = $7, enabled = $8, next_run_at = $9, updated_at = now()
+		WHERE schedule_uuid = $1
+		RETURNING `+scheduleColumns,
+		id, req.Call.DomainUUID, req.Name, callJSON, req.RunAt, req.Cron, scheduleTimezone(req), enabled, nextRun)
+
+	return scanSchedule(row)
+}
+
+func (s *SchedulerManager) Delete(id string) error {
+	if !isUUID(id) {
+		return ErrScheduleNotFound
+	}
+	res, err := s.db.Exec(`DELETE FROM call_schedules WHERE schedule_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// Run polls for due schedules until the process exits.
+func (s *SchedulerManager) Run() {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	log.Printf("Call scheduler started, polling every %s", s.config.PollInterval)
+	for range ticker.C {
+		if err := s.fireDue(); err != nil {
+			log.Printf("Scheduler run failed: %v", err)
+		}
+	}
+}
+
+type dueSchedule struct {
+	id          string
+	callRequest []byte
+	cron        string
+	timezone    string
+}
+
+// fireDue claims due schedules and advances them inside one transaction, then
+// originates the calls after commit so a slow originate never holds row locks.
+func (s *SchedulerManager) fireDue() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT schedule_uuid, call_request, cron_expression, timezone
+		FROM call_schedules
+		WHERE enabled AND next_run_at <= now()
+		ORDER BY next_run_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, s.config.BatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to claim due schedules: %w", err)
+	}
+
+	var due []dueSchedule
+	for rows.Next() {
+		var d dueSchedule
+		if err := rows.Scan(&d.id, &d.callRequest, &d.cron, &d.timezone); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan due schedule: %w", err)
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read due schedules: %w", err)
+	}
+
+	now := time.Now()
+	for _, d := range due {
+		// One-shot schedules are disabled once fired; recurring ones move on
+		// to the next occurrence after now, so runs missed while the service
+		// was down fire once rather than being replayed.
+		var nextRun sql.NullTime
+		if d.cron != "" {
+			if next, err := nextCronRun(d.cron, d.timezone, now); err != nil {
+				log.Printf("Schedule %s has an invalid cron expression: %v", d.id, err)
+			} else if !next.IsZero() {
+				nextRun = sql.NullTime{Time: next, Valid: true}
+			}
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE call_schedules
+			SET next_run_at = $2, enabled = $3, last_run_at = now(), run_count = run_count + 1, updated_at = now()
+			WHERE schedule_uuid = $1`, d.id, nextRun, nextRun.Valid); err != nil {
+			return fmt.Errorf("failed to advance schedule %s: %w", d.id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit claimed schedules: %w", err)
+	}
+
+	for _, d := range due {
+		go s.fire(d)
+	}
+	return nil
+}
+
+func (s *SchedulerManager) fire(d dueSchedule) {
+	var (
+		req    request.CallRequest
+		callID string
+	)
+	err := json.Unmarshal(d.callRequest, &req)
+	if err == nil {
+		err = s.checks.Check(&req)
+	}
+	if err == nil {
+		callID, err = s.eslMgr.OriginateCall(req)
+	}
+
+	// Calls the checks refuse record their cause, e.g. DNC_BLOCKED, so a
+	// refusal reads like any other failed run.
+	lastError := ""
+	if cause := CheckCause(err); cause != "" {
+		lastError = cause + ": " + err.Error()
+		log.Printf("Scheduled call %s refused: %v", d.id, err)
+	} else if err != nil {
+		lastError = err.Error()
+		log.Printf("Scheduled call %s failed: %v", d.id, err)
+	} else {
+		log.Printf("Scheduled call %s originated: %s", d.id, callID)
+	}
+
+	if _, err := s.db.Exec(`
+		UPDATE call_schedules SET last_call_id = $2, last_error = $3, updated_at = now()
+		WHERE schedule_uuid = $1`, d.id, callID, lastError); err != nil {
+		log.Printf("Failed to record result of schedule %s: %v", d.id, err)
+	}
+}
+
+func firstRun(req request.ScheduleRequest, now time.Time) (sql.NullTime, error) {
+	switch {
+	case req.RunAt != nil && req.Cron != "":
+		return sql.NullTime{}, fmt.Errorf("%w: run_at and cron are mutually exclusive", ErrInvalidSchedule)
+	case req.RunAt != nil:
+		return sql.NullTime{Time: *req.RunAt, Valid: true}, nil
+	case req.Cron != "":
+		next, err := nextCronRun(req.Cron, scheduleTimezone(req), now)
+		if err != nil {
+			return sql.NullTime{}, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+		}
+		if next.IsZero() {
+			return sql.NullTime{}, fmt.Errorf("%w: cron expression never fires", ErrInvalidSchedule)
+		}
+		return sql.NullTime{Time: next, Valid: true}, nil
+	}
+	return sql.NullTime{}, fmt.Errorf("%w: run_at or cron is required", ErrInvalidSchedule)
+}
+
+func nextCronRun(expr, timezone string, after time.Time) (time.Time, error) {
+	sched, err := parseCron(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unknown timezone %q", timezone)
+	}
+	return sched.next(after.In(loc)), nil
+}
+
+func scheduleTimezone(req request.ScheduleRequest) string {
+	if req.Timezone == "" {
+		return "UTC"
+	}
+	return req.Timezone
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSchedule(row rowScanner) (*models.CallSchedule, error) {
+	var sched models.CallSchedule
+	err := row.Scan(
+		&sched.ScheduleUUID, &sched.DomainUUID, &sched.Name, &sched.CallRequest, &sched.RunAt, &sched.CronExpression, &sched.Timezone,
+		&sched.Enabled, &sched.NextRunAt, &sched.LastRunAt, &sched.LastCallID, &sched.LastError, &sched.RunCount,
+		&sched.CreatedAt, &sched.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan schedule: %w", err)
+	}
+	return &sched, nil
+}
@@ -0,0 +1,420 @@
+package manager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/transcribe"
+)
+
+var (
+	ErrTranscriptionDisabled = errors.New("no transcription engine is configured")
+	ErrInvalidSearch         = errors.New("invalid search")
+)
+
+// Transcription job statuses.
+const (
+	TranscriptionQueued  = "queued"
+	TranscriptionRunning = "running"
+	TranscriptionDone    = "done"
+	TranscriptionFailed  = "failed"
+)
+
+const transcriptionJobColumns = `job_uuid, xml_cdr_uuid, domain_uuid, status, attempts, engine, language, error,
+	next_attempt_at, created_at, started_at, finished_at`
+
+// Jobs left running this long after the engine timeout are assumed to have
+// died with their worker and are queued again.
+const staleTranscriptionGrace = 5 * time.Minute
+
+// TranscriptionManager runs the transcription queue. Jobs are claimed with
+// FOR UPDATE SKIP LOCKED, so several API instances can share the queue, and
+// the text is written to v_xml_cdr.record_transcription for FusionPBX and
+// to cdr_transcripts, whose full-text index makes it searchable.
+type TranscriptionManager struct {
+	db         *sql.DB
+	recordings *RecordingManager
+	engine     transcribe.Transcriber
+	config     config.TranscriptionConfig
+}
+
+// NewTranscriptionManager takes the engine, or nil when transcription is
+// off. Search works either way.
+func NewTranscriptionManager(db *sql.DB, recordings *RecordingManager, engine transcribe.Transcriber, cfg config.TranscriptionConfig) *TranscriptionManager {
+	return &TranscriptionManager{
+		db:         db,
+		recordings: recordings,
+		engine:     engine,
+		config:     cfg,
+	}
+}
+
+// Run works through the queue. Without an engine it returns.
+func (tm *TranscriptionManager) Run() {
+	if tm.engine == nil {
+		return
+	}
+	ticker := time.NewTicker(tm.config.PollInterval)
+	defer ticker.Stop()
+
+	log.Printf("Transcription worker started with the %s engine, polling every %s", tm.engine.Name(), tm.config.PollInterval)
+	for range ticker.C {
+		if tm.config.AutoEnqueue {
+			if err := tm.enqueueNew(); err != nil {
+				log.Printf("Failed to queue new recordings for transcription: %v", err)
+			}
+		}
+		if err := tm.requeueStale(); err != nil {
+			log.Printf("Failed to requeue stale transcription jobs: %v", err)
+		}
+		for {
+			n, err := tm.processBatch()
+			if err != nil {
+				log.Printf("Transcription run failed: %v", err)
+			}
+			if err != nil || n < tm.config.BatchSize {
+				break
+			}
+		}
+	}
+}
+
+// enqueueNew queues the recordings of recent CDRs that have no
+// transcription and no job yet.
+func (tm *TranscriptionManager) enqueueNew() error {
+	rows, err := tm.db.Query(`
+		SELECT c.xml_cdr_uuid, c.domain_uuid
+		FROM v_xml_cdr c
+		WHERE COALESCE(c.record_name, '') <> '' AND COALESCE(c.record_transcription, '') = ''
+			AND c.start_stamp > now() - make_interval(secs => $1)
+			AND NOT EXISTS (SELECT 1 FROM transcription_jobs j WHERE j.xml_cdr_uuid = c.xml_cdr_uuid)
+		ORDER BY c.start_stamp
+		LIMIT 100`, tm.config.Lookback.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to fetch recordings to transcribe: %w", err)
+	}
+	type cdr struct {
+		id     string
+		domain sql.NullString
+	}
+	var cdrs []cdr
+	for rows.Next() {
+		var c cdr
+		if err := rows.Scan(&c.id, &c.domain); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan recording: %w", err)
+		}
+		cdrs = append(cdrs, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read recordings to transcribe: %w", err)
+	}
+
+	for _, c := range cdrs {
+		if _, err := tm.db.Exec(`
+			INSERT INTO transcription_jobs (job_uuid, xml_cdr_uuid, domain_uuid)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (xml_cdr_uuid) DO NOTHING`, newUUID(), c.id, c.domain); err != nil {
+			return fmt.Errorf("failed to queue transcription: %w", err)
+		}
+	}
+	return nil
+}
+
+func (tm *TranscriptionManager) requeueStale() error {
+	_, err := tm.db.Exec(`
+		UPDATE transcription_jobs SET status = $1, next_attempt_at = now()
+		WHERE status = $2 AND started_at < now() - make_interval(secs => $3)`,
+		TranscriptionQueued, TranscriptionRunning, (tm.config.Timeout + staleTranscriptionGrace).Seconds())
+	return err
+}
+
+type claimedJob struct {
+	id       string
+	cdrUUID  string
+	attempts int
+}
+
+// processBatch claims due jobs and transcribes them one by one. It returns
+// how many it claimed.
+func (tm *TranscriptionManager) processBatch() (int, error) {
+	rows, err := tm.db.Query(`
+		UPDATE transcription_jobs
+		SET status = $1, attempts = attempts + 1, engine = $2, started_at = now()
+		WHERE job_uuid IN (
+			SELECT job_uuid FROM transcription_jobs
+			WHERE status = $3 AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING job_uuid, xml_cdr_uuid, attempts`,
+		TranscriptionRunning, tm.engine.Name(), TranscriptionQueued, tm.config.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim transcription jobs: %w", err)
+	}
+	var jobs []claimedJob
+	for rows.Next() {
+		var j claimedJob
+		if err := rows.Scan(&j.id, &j.cdrUUID, &j.attempts); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan transcription job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read transcription jobs: %w", err)
+	}
+
+	for _, j := range jobs {
+		result, err := tm.transcribe(j.cdrUUID)
+		if err == nil {
+			err = tm.complete(j, result)
+		}
+		if err != nil {
+			tm.fail(j, err)
+		}
+	}
+	return len(jobs), nil
+}
+
+func (tm *TranscriptionManager) transcribe(cdrUUID string) (transcribe.Result, error) {
+	rec, err := tm.recordings.Find(cdrUUID)
+	if err != nil {
+		return transcribe.Result{}, err
+	}
+	f, err := tm.recordings.Open(rec, "")
+	if err != nil {
+		return transcribe.Result{}, err
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), tm.config.Timeout)
+	defer cancel()
+	return tm.engine.Transcribe(ctx, f, path.Base(rec.RecordName))
+}
+
+// complete writes the text back to the CDR and closes the job.
+func (tm *TranscriptionManager) complete(j claimedJob, result transcribe.Result) error {
+	tx, err := tm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE v_xml_cdr SET record_transcription = $2 WHERE xml_cdr_uuid = $1`,
+		j.cdrUUID, result.Text); err != nil {
+		return fmt.Errorf("failed to store transcription: %w", err)
+	}
+	// Engines that do not report the language were asked for the configured one.
+	language := result.Language
+	if language == "" {
+		language = tm.config.Language
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO cdr_transcripts (xml_cdr_uuid, domain_uuid, language, text, search_config, search)
+		SELECT xml_cdr_uuid, domain_uuid, $2, $3, transcript_search_config($2), to_tsvector(transcript_search_config($2), $3)
+		FROM transcription_jobs WHERE job_uuid = $1
+		ON CONFLICT (xml_cdr_uuid) DO UPDATE
+		SET language = EXCLUDED.language, text = EXCLUDED.text, search_config = EXCLUDED.search_config,
+			search = EXCLUDED.search, updated_at = now()`,
+		j.id, language, result.Text); err != nil {
+		return fmt.Errorf("failed to index transcription: %w", err)
+	}
+	if _, err := tx.Exec(`
+		UPDATE transcription_jobs SET status = $2, language = $3, error = '', finished_at = now()
+		WHERE job_uuid = $1`, j.id, TranscriptionDone, language); err != nil {
+		return fmt.Errorf("failed to close transcription job: %w", err)
+	}
+	return tx.Commit()
+}
+
+// fail queues a job again with a growing delay, or marks it failed once it
+// is out of attempts or its recording is gone.
+func (tm *TranscriptionManager) fail(j claimedJob, cause error) {
+	log.Printf("Failed to transcribe CDR %s (attempt %d): %v", j.cdrUUID, j.attempts, cause)
+
+	status, delay := TranscriptionQueued, time.Duration(j.attempts*j.attempts)*time.Minute
+	if j.attempts >= tm.config.MaxAttempts || errors.Is(cause, ErrRecordingNotFound) {
+		status = TranscriptionFailed
+	}
+	if _, err := tm.db.Exec(`
+		UPDATE transcription_jobs
+		SET status = $2, error = $3, next_attempt_at = now() + make_interval(secs => $4),
+			finished_at = CASE WHEN $2 = 'failed' THEN now() END
+		WHERE job_uuid = $1`, j.id, status, cause.Error(), delay.Seconds()); err != nil {
+		log.Printf("Failed to update transcription job %s: %v", j.id, err)
+	}
+}
+
+// Enqueue queues the recording of a CDR, or queues it again when its last
+// job has finished. A job that is still queued or running is returned as
+// it is.
+func (tm *TranscriptionManager) Enqueue(rec *Recording) (*models.TranscriptionJob, error) {
+	if tm.engine == nil {
+		return nil, ErrTranscriptionDisabled
+	}
+
+	row := tm.db.QueryRow(`
+		INSERT INTO transcription_jobs (job_uuid, xml_cdr_uuid, domain_uuid)
+		VALUES ($1, $2, NULLIF($3, '')::uuid)
+		ON CONFLICT (xml_cdr_uuid) DO UPDATE
+		SET status = 'queued', attempts = 0, error = '', next_attempt_at = now(), started_at = NULL,
+			finished_at = NULL
+		WHERE transcription_jobs.status IN ('done', 'failed')
+		RETURNING `+transcriptionJobColumns,
+		newUUID(), rec.CDRUUID, rec.DomainUUID)
+	job, err := scanTranscriptionJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return tm.GetJob(rec.CDRUUID)
+	}
+	return job, err
+}
+
+// GetJob returns the latest transcription job of a CDR, or nil when it has
+// none.
+func (tm *TranscriptionManager) GetJob(cdrUUID string) (*models.TranscriptionJob, error) {
+	row := tm.db.QueryRow(`SELECT `+transcriptionJobColumns+` FROM transcription_jobs WHERE xml_cdr_uuid = $1`, cdrUUID)
+	job, err := scanTranscriptionJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return job, err
+}
+
+// Transcription returns the text stored on a CDR.
+func (tm *TranscriptionManager) Transcription(cdrUUID string) (string, error) {
+	var text string
+	err := tm.db.QueryRow(`SELECT COALESCE(record_transcription, '') FROM v_xml_cdr WHERE xml_cdr_uuid = $1`,
+		cdrUUID).Scan(&text)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrRecordingNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch transcription: %w", err)
+	}
+	return text, nil
+}
+
+// ListJobs returns the queue, newest first. Empty filters match everything.
+func (tm *TranscriptionManager) ListJobs(domainUUID, status string, limit, offset int) ([]models.TranscriptionJob, int, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, 0, ErrInvalidDomain
+	}
+	where := `WHERE (NULLIF($1, '') IS NULL OR domain_uuid = NULLIF($1, '')::uuid) AND ($2 = '' OR status = $2)`
+
+	var total int
+	if err := tm.db.QueryRow(`SELECT COUNT(*) FROM transcription_jobs `+where, domainUUID, status).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count transcription jobs: %w", err)
+	}
+
+	rows, err := tm.db.Query(`SELECT `+transcriptionJobColumns+` FROM transcription_jobs `+where+`
+		ORDER BY created_at DESC LIMIT $3 OFFSET $4`, domainUUID, status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch transcription jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.TranscriptionJob
+	for rows.Next() {
+		job, err := scanTranscriptionJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, *job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read transcription jobs: %w", err)
+	}
+
+	return jobs, total, nil
+}
+
+// Search finds CDRs whose transcription matches q, best matches first. q
+// takes web search syntax: quoted phrases, "or" and -exclusions.
+func (tm *TranscriptionManager) Search(domainUUID, q string, limit, offset int) ([]models.TranscriptHit, int, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, 0, ErrInvalidDomain
+	}
+	if q = strings.TrimSpace(q); q == "" {
+		return nil, 0, fmt.Errorf("%w: q is required", ErrInvalidSearch)
+	}
+	// Each transcript is matched in the text search configuration it was
+	// indexed with. Transcripts whose CDR is gone are skipped.
+	from := `FROM cdr_transcripts t JOIN v_xml_cdr c ON c.xml_cdr_uuid = t.xml_cdr_uuid
+		WHERE t.search @@ websearch_to_tsquery(t.search_config, $1)
+			AND (NULLIF($2, '') IS NULL OR t.domain_uuid = NULLIF($2, '')::uuid)`
+
+	var total int
+	if err := tm.db.QueryRow(`SELECT COUNT(*) `+from, q, domainUUID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	rows, err := tm.db.Query(`
+		SELECT c.xml_cdr_uuid, c.domain_uuid, COALESCE(c.direction, ''), COALESCE(c.caller_id_number, ''),
+			COALESCE(c.destination_number, ''), c.start_stamp,
+			ts_rank(t.search, websearch_to_tsquery(t.search_config, $1)) AS rank,
+			ts_headline(t.search_config, t.text, websearch_to_tsquery(t.search_config, $1),
+				'StartSel='||$5||', StopSel='||$6||', MaxWords=30, MinWords=10, MaxFragments=3')
+		`+from+`
+		ORDER BY rank DESC, c.start_stamp DESC
+		LIMIT $3 OFFSET $4`, q, domainUUID, limit, offset, headlineStart, headlineStop)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search transcriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []models.TranscriptHit
+	for rows.Next() {
+		var h models.TranscriptHit
+		if err := rows.Scan(&h.XMLCDRUUID, &h.DomainUUID, &h.Direction, &h.CallerIDNumber, &h.DestinationNumber,
+			&h.StartStamp, &h.Rank, &h.Snippet); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		h.Snippet = markSnippet(h.Snippet)
+		hits = append(hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read search results: %w", err)
+	}
+
+	return hits, total, nil
+}
+
+// ts_headline wraps matches in these control characters rather than tags,
+// so the snippet can be HTML-escaped before the <mark> tags go in.
+const (
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+// markSnippet HTML-escapes a ts_headline snippet and wraps its matches in
+// <mark> tags. Transcript text is never returned unescaped.
+func markSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	return strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>").Replace(snippet)
+}
+
+func scanTranscriptionJob(row rowScanner) (*models.TranscriptionJob, error) {
+	var j models.TranscriptionJob
+	err := row.Scan(&j.JobUUID, &j.XMLCDRUUID, &j.DomainUUID, &j.Status, &j.Attempts, &j.Engine, &j.Language,
+		&j.Error, &j.NextAttemptAt, &j.CreatedAt, &j.StartedAt, &j.FinishedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan transcription job: %w", err)
+	}
+	return &j, nil
+}
@@ -0,0 +1,19 @@
+package manager
+
+import "testing"
+
+func TestMarkSnippet(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"the " + headlineStart + "refund" + headlineStop + " was sent", "the <mark>refund</mark> was sent"},
+		{"<script>alert(1)</script> " + headlineStart + "refund" + headlineStop,
+			"&lt;script&gt;alert(1)&lt;/script&gt; <mark>refund</mark>"},
+		{`say "<mark>" & leave`, "say &#34;&lt;mark&gt;&#34; &amp; leave"},
+	}
+	for _, tt := range tests {
+		if got := markSnippet(tt.in); got != tt.want {
+			t.Errorf("markSnippet(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+	"github.com/lib/pq"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+var (
+	ErrVoiceAppNotFound = errors.New("voice app not found")
+	ErrVoiceAppExists   = errors.New("number already has a voice app")
+	ErrInvalidVoice     = errors.New("invalid voice document")
+)
+
+const voiceAppColumns = `app_uuid, domain_uuid, number, url, fallback_url, description, enabled, created_at, updated_at`
+
+// VoiceManager runs programmable voice. FreeSWITCH hands calls to its
+// outbound event socket server, which asks the webhook of the dialed number
+// for a document of verbs and runs them on the call.
+type VoiceManager struct {
+	db      *sql.DB
+	eslMgr  *ESLManager
+	numbers *NumberManager
+	checks  *CallChecker
+	config  config.VoiceConfig
+	client  *http.Client
+}
+
+func NewVoiceManager(db *sql.DB, eslMgr *ESLManager, numbers *NumberManager, checks *CallChecker,
+	cfg config.VoiceConfig) *VoiceManager {
+	return &VoiceManager{
+		db:      db,
+		eslMgr:  eslMgr,
+		numbers: numbers,
+		checks:  checks,
+		config:  cfg,
+		client:  &http.Client{Timeout: cfg.WebhookTimeout},
+	}
+}
+
+// Run serves outbound event socket connections on ListenAddr. It does
+// nothing when no address is configured.
+func (vm *VoiceManager) Run() {
+	if vm.config.ListenAddr == "" {
+		return
+	}
+	log.Printf("Voice socket server listening on %s", vm.config.ListenAddr)
+	if err := eventsocket.ListenAndServe(vm.config.ListenAddr, vm.handleCall); err != nil {
+		log.Printf("Voice socket server stopped: %v", err)
+	}
+}
+
+func (vm *VoiceManager) handleCall(conn *eventsocket.Connection) {
+	defer conn.Close()
+
+	info, err := conn.Send("connect")
+	if err != nil {
+		log.Printf("Failed to connect voice call from %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	if _, err := conn.Send("myevents"); err != nil {
+		log.Printf("Failed to subscribe to voice call events: %v", err)
+		return
+	}
+
+	call := &voiceCall{
+		vm:           vm,
+		conn:         conn,
+		uuid:         info.Get("Unique-Id"),
+		domainUUID:   info.Get("Variable_domain_uuid"),
+		from:         info.Get("Caller-Caller-Id-Number"),
+		to:           info.Get("Caller-Destination-Number"),
+		callerIDName: info.Get("Caller-Caller-Id-Name"),
+		answered:     info.Get("Answer-State") == "answered",
+	}
+	if !isUUID(call.domainUUID) {
+		call.domainUUID = ""
+	}
+
+	app, err := vm.findApp(call.domainUUID, call.to)
+	if err != nil {
+		log.Printf("No voice app for call %s to %s: %v", call.uuid, call.to, err)
+		call.hangup("UNALLOCATED_NUMBER")
+		return
+	}
+	call.url, call.fallbackURL = app.URL, app.FallbackURL
+	log.Printf("Voice call %s from %s to %s handled by %s", call.uuid, call.from, call.to, app.URL)
+
+	call.start()
+}
+
+// findApp returns the enabled app of a dialed number, preferring the
+// tenant's own over a global one. The number is matched in every spelling
+// the tenant's numbering plan allows.
+func (vm *VoiceManager) findApp(domainUUID, dialed string) (*models.VoiceApp, error) {
+	candidates := []string{dialed}
+	if vm.numbers != nil {
+		if variants, err := vm.numbers.Variants(domainUUID, dialed); err == nil {
+			candidates = append(candidates, variants...)
+		}
+	}
+
+	row := vm.db.QueryRow(`
+		SELECT `+voiceAppColumns+` FROM voice_apps
+		WHERE enabled AND number = ANY($2)
+			AND (domain_uuid IS NULL OR domain_uuid = NULLIF($1, '')::uuid)
+		ORDER BY domain_uuid NULLS LAST
+		LIMIT 1`, domainUUID, pq.Array(candidates))
+	return scanVoiceApp(row)
+}
+
+func (vm *VoiceManager) CreateApp(req request.VoiceAppRequest) (*models.VoiceApp, error) {
+	row := vm.db.QueryRow(`
+		INSERT INTO voice_apps (app_uuid, domain_uuid, number, url, fallback_url, description, enabled)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, $6, $7)
+		RETURNING `+voiceAppColumns,
+		newUUID(), req.DomainUUID, req.Number, req.URL, req.FallbackURL, req.Description,
+		req.Enabled == nil || *req.Enabled)
+
+	return scanVoiceApp(row)
+}
+
+// ListApps returns the apps of a tenant and the global ones, or every app
+// when domainUUID is empty.
+func (vm *VoiceManager) ListApps(domainUUID string) ([]models.VoiceApp, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, ErrInvalidDomain
+	}
+	rows, err := vm.db.Query(`
+		SELECT `+voiceAppColumns+` FROM voice_apps
+		WHERE NULLIF($1, '') IS NULL OR domain_uuid IS NULL OR domain_uuid = NULLIF($1, '')::uuid
+		ORDER BY domain_uuid NULLS LAST, number`, domainUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch voice apps: %w", err)
+	}
+	defer rows.Close()
+
+	var apps []models.VoiceApp
+	for rows.Next() {
+		app, err := scanVoiceApp(rows)
+		if err != nil {
+			return nil, err
+		}
+		apps = append(apps, *app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read voice apps: %w", err)
+	}
+	return apps, nil
+}
+
+func (vm *VoiceManager) GetApp(id string) (*models.VoiceApp, error) {
+	if !isUUID(id) {
+		return nil, ErrVoiceAppNotFound
+	}
+	row := vm.db.QueryRow(`SELECT `+voiceAppColumns+` FROM voice_apps WHERE app_uuid = $1`, id)
+	return scanVoiceApp(row)
+}
+
+func (vm *VoiceManager) UpdateApp(id string, req request.VoiceAppRequest) (*models.VoiceApp, error) {
+	if !isUUID(id) {
+		return nil, ErrVoiceAppNotFound
+	}
+
+	row := vm.db.QueryRow(`
+		UPDATE voice_apps
+		SET domain_uuid = NULLIF($2, '')::uuid, number = $3, url = $4, fallback_url = $5, description = $6,
+			enabled = $7, updated_at = now()
+		WHERE app_uuid = $1
+		RETURNING `+voiceAppColumns,
+		id, req.DomainUUID, req.Number, req.URL, req.FallbackURL, req.Description, req.Enabled == nil || *req.Enabled)
+
+	return scanVoiceApp(row)
+}
+
+func (vm *VoiceManager) DeleteApp(id string) error {
+	if !isUUID(id) {
+		return ErrVoiceAppNotFound
+	}
+	res, err := vm.db.Exec(`DELETE FROM voice_apps WHERE app_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete voice app: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrVoiceAppNotFound
+	}
+	return nil
+}
+
+func scanVoiceApp(row rowScanner) (*models.VoiceApp, error) {
+	var app models.VoiceApp
+	err := row.Scan(&app.AppUUID, &app.DomainUUID, &app.Number, &app.URL, &app.FallbackURL, &app.Description,
+		&app.Enabled, &app.CreatedAt, &app.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVoiceAppNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return nil, ErrVoiceAppExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan voice app: %w", err)
+	}
+	return &app, nil
+}
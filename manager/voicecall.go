@@ -0,0 +1,506 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+// errCallEnded stops a voice call once the caller has hung up or
+// FreeSWITCH has closed the socket.
+var errCallEnded = errors.New("call ended")
+
+// Limits on what a webhook may return.
+const (
+	maxVoiceDocumentBytes = 1 << 20
+	maxVoiceVerbs         = 100
+)
+
+// VoiceVerb is one instruction of a voice document. Verb picks what is run;
+// the other fields apply to the verbs named in their comments.
+type VoiceVerb struct {
+	Verb string `json:"verb"`
+
+	// say
+	Text   string `json:"text,omitempty"`
+	Engine string `json:"engine,omitempty"`
+	Voice  string `json:"voice,omitempty"`
+	// play, and the prompt of gather
+	File string `json:"file,omitempty"`
+	// say and play
+	Loop int `json:"loop,omitempty"`
+
+	// gather
+	MinDigits   int    `json:"min_digits,omitempty"`
+	MaxDigits   int    `json:"max_digits,omitempty"`
+	Tries       int    `json:"tries,omitempty"`
+	TimeoutMS   int    `json:"timeout_ms,omitempty"`
+	Terminators string `json:"terminators,omitempty"`
+	DigitRegex  string `json:"digit_regex,omitempty"`
+
+	// dial
+	Number         string `json:"number,omitempty"`
+	CallerIDNumber string `json:"caller_id_number,omitempty"`
+	CallerIDName   string `json:"caller_id_name,omitempty"`
+	Timeout        int    `json:"timeout,omitempty"`
+
+	// record
+	MaxSeconds     int `json:"max_seconds,omitempty"`
+	SilenceSeconds int `json:"silence_seconds,omitempty"`
+
+	// hangup
+	Cause string `json:"cause,omitempty"`
+
+	// redirect: the document to continue with. gather, dial and record post
+	// their result to Action and continue with the document it returns.
+	URL    string `json:"url,omitempty"`
+	Action string `json:"action,omitempty"`
+}
+
+// VoiceDocument is what a voice webhook returns.
+type VoiceDocument struct {
+	Verbs []VoiceVerb `json:"verbs"`
+}
+
+// VoiceWebhook is the JSON body posted to a voice webhook. Event says why
+// it is asked: call.start for a new call, or the verb that finished.
+type VoiceWebhook struct {
+	Event            string `json:"event"`
+	CallUUID         string `json:"call_uuid"`
+	DomainUUID       string `json:"domain_uuid"`
+	From             string `json:"from"`
+	To               string `json:"to"`
+	CallerIDName     string `json:"caller_id_name"`
+	Digits           string `json:"digits,omitempty"`
+	DialStatus       string `json:"dial_status,omitempty"`
+	DialHangupCause  string `json:"dial_hangup_cause,omitempty"`
+	RecordingFile    string `json:"recording_file,omitempty"`
+	RecordingSeconds int    `json:"recording_seconds,omitempty"`
+}
+
+// voiceCall is one call handed to the voice socket server.
+type voiceCall struct {
+	vm   *VoiceManager
+	conn *eventsocket.Connection
+
+	uuid, domainUUID, from, to, callerIDName string
+
+	// url is the document being run, which results are posted to when a
+	// verb has no action of its own.
+	url, fallbackURL string
+	answered         bool
+	fetches          int
+	recordings       int
+}
+
+// start fetches the first document of the call and runs it.
+func (call *voiceCall) start() {
+	doc, err := call.fetch(call.url, call.webhook("call.start"))
+	if err != nil {
+		log.Printf("Failed to fetch voice document for call %s: %v", call.uuid, err)
+		call.hangup("NORMAL_TEMPORARY_FAILURE")
+		return
+	}
+	call.run(doc.Verbs)
+}
+
+// run executes verbs in order. A verb that fetches a new document replaces
+// the rest. The call is hung up once the verbs run out.
+func (call *voiceCall) run(verbs []VoiceVerb) {
+	for len(verbs) > 0 {
+		verb := verbs[0]
+		verbs = verbs[1:]
+
+		next, err := call.exec(verb)
+		if errors.Is(err, errCallEnded) {
+			log.Printf("Voice call %s ended", call.uuid)
+			return
+		}
+		if err != nil {
+			log.Printf("Voice call %s failed on %s: %v", call.uuid, verb.Verb, err)
+			call.hangup("NORMAL_TEMPORARY_FAILURE")
+			return
+		}
+		if next != nil {
+			verbs = next.Verbs
+		}
+	}
+	call.hangup("NORMAL_CLEARING")
+}
+
+// exec runs one verb. It returns the document to continue with when the
+// verb fetched one, or nil to go on with the next verb.
+func (call *voiceCall) exec(v VoiceVerb) (*VoiceDocument, error) {
+	switch v.Verb {
+	case "say":
+		return nil, call.say(v)
+	case "play":
+		return nil, call.play(v)
+	case "gather":
+		return call.gather(v)
+	case "dial":
+		return call.dial(v)
+	case "record":
+		return call.record(v)
+	case "hangup":
+		cause := v.Cause
+		if cause == "" {
+			cause = "NORMAL_CLEARING"
+		}
+		if !hangupCausePattern.MatchString(cause) {
+			return nil, fmt.Errorf("%w: invalid hangup cause %q", ErrInvalidVoice, cause)
+		}
+		call.hangup(cause)
+		return nil, errCallEnded
+	case "redirect":
+		if v.URL == "" {
+			return nil, fmt.Errorf("%w: redirect needs a url", ErrInvalidVoice)
+		}
+		return call.fetch(v.URL, call.webhook("redirect"))
+	default:
+		return nil, fmt.Errorf("%w: unknown verb %q", ErrInvalidVoice, v.Verb)
+	}
+}
+
+func (call *voiceCall) say(v VoiceVerb) error {
+	engine, voice := v.Engine, v.Voice
+	if engine == "" {
+		engine = "flite"
+	}
+	if voice == "" {
+		voice = "kal"
+	}
+	if !appArgPattern.MatchString(engine) || !appArgPattern.MatchString(voice) {
+		return fmt.Errorf("%w: invalid speak engine or voice", ErrInvalidVoice)
+	}
+	if v.Text == "" || strings.ContainsAny(v.Text, "|\\\r\n\x00") {
+		return fmt.Errorf("%w: invalid say text", ErrInvalidVoice)
+	}
+	if err := call.answer(); err != nil {
+		return err
+	}
+	for i := 0; i < loops(v.Loop); i++ {
+		if _, err := call.execute("speak", fmt.Sprintf("%s|%s|%s", engine, voice, v.Text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (call *voiceCall) play(v VoiceVerb) error {
+	if !soundFile(call.vm.eslMgr.config.SoundsDir, v.File) {
+		return fmt.Errorf("%w: invalid file %q", ErrInvalidVoice, v.File)
+	}
+	if err := call.answer(); err != nil {
+		return err
+	}
+	for i := 0; i < loops(v.Loop); i++ {
+		if _, err := call.execute("playback", v.File); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gather collects digits and posts them to the action. Without input the
+// call goes on with the next verb.
+func (call *voiceCall) gather(v VoiceVerb) (*VoiceDocument, error) {
+	req := request.GatherRequest{
+		File:        v.File,
+		MinDigits:   v.MinDigits,
+		MaxDigits:   v.MaxDigits,
+		Tries:       v.Tries,
+		TimeoutMS:   v.TimeoutMS,
+		Terminators: v.Terminators,
+		DigitRegex:  v.DigitRegex,
+	}
+	if err := normalizeGather(&req, call.vm.eslMgr.config.SoundsDir); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidVoice, err)
+	}
+	if err := call.answer(); err != nil {
+		return nil, err
+	}
+
+	// The variable is cleared first so a previous gather cannot leak into
+	// this one.
+	if _, err := call.execute("unset", "voice_digits"); err != nil {
+		return nil, err
+	}
+	ev, err := call.execute("play_and_get_digits", strings.Join([]string{
+		strconv.Itoa(req.MinDigits),
+		strconv.Itoa(req.MaxDigits),
+		strconv.Itoa(req.Tries),
+		strconv.Itoa(req.TimeoutMS),
+		req.Terminators,
+		req.File,
+		req.InvalidFile,
+		"voice_digits",
+		req.DigitRegex,
+	}, " "))
+	if err != nil {
+		return nil, err
+	}
+
+	digits := ev.Get("Variable_voice_digits")
+	if digits == "" {
+		return nil, nil
+	}
+	hook := call.webhook("gather")
+	hook.Digits = digits
+	return call.fetch(call.action(v), hook)
+}
+
+// dial bridges the call to a number, routed and checked like any other call
+// of the tenant. The external leg carries the prepaid limits, so it is
+// tracked and charged. The call stays up when the other side hangs up, so
+// the action can decide what happens next.
+func (call *voiceCall) dial(v VoiceVerb) (*VoiceDocument, error) {
+	if v.Number == "" {
+		return nil, fmt.Errorf("%w: dial needs a number", ErrInvalidVoice)
+	}
+	// 0 is an omitted timeout and keeps the FreeSWITCH default.
+	if v.Timeout < 0 || v.Timeout > 300 {
+		return nil, fmt.Errorf("%w: timeout must be between 1 and 300", ErrInvalidVoice)
+	}
+	checked := request.CallRequest{DomainUUID: call.domainUUID, Caller: call.from, Callee: v.Number}
+	if call.vm.checks != nil {
+		if err := call.vm.checks.Check(&checked); err != nil {
+			return nil, err
+		}
+	}
+
+	legs, err := call.vm.eslMgr.dialLegs(checked.DomainUUID, v.Number)
+	if err != nil {
+		return nil, err
+	}
+	arg, err := call.vm.eslMgr.bridgeArg(legs, call.uuid, checked.DomainUUID, checked.PrepaidVariables)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := []string{"hangup_after_bridge=false", "continue_on_fail=true"}
+	for _, name := range slices.Sorted(maps.Keys(checked.SystemVariables)) {
+		vars = append(vars, name+"="+checked.SystemVariables[name])
+	}
+	if v.Timeout > 0 {
+		vars = append(vars, "call_timeout="+strconv.Itoa(v.Timeout))
+	}
+	if v.CallerIDNumber != "" {
+		if !dialTokenPattern.MatchString(v.CallerIDNumber) {
+			return nil, fmt.Errorf("%w: invalid caller_id_number", ErrInvalidVoice)
+		}
+		vars = append(vars, "effective_caller_id_number="+v.CallerIDNumber)
+	}
+	if v.CallerIDName != "" {
+		if strings.ContainsAny(v.CallerIDName, "\r\n\x00") {
+			return nil, fmt.Errorf("%w: invalid caller_id_name", ErrInvalidVoice)
+		}
+		vars = append(vars, "effective_caller_id_name="+v.CallerIDName)
+	}
+	for _, kv := range vars {
+		if _, err := call.execute("set", kv); err != nil {
+			return nil, err
+		}
+	}
+
+	ev, err := call.execute("bridge", arg)
+	if err != nil {
+		return nil, err
+	}
+	if v.Action == "" {
+		return nil, nil
+	}
+
+	hook := call.webhook("dial")
+	hook.DialStatus = ev.Get("Variable_originate_disposition")
+	hook.DialHangupCause = ev.Get("Variable_bridge_hangup_cause")
+	if hook.DialHangupCause == "" {
+		hook.DialHangupCause = ev.Get("Variable_last_bridge_hangup_cause")
+	}
+	return call.fetch(v.Action, hook)
+}
+
+// record records the caller until silence, a terminator or MaxSeconds and
+// posts the file to the action.
+func (call *voiceCall) record(v VoiceVerb) (*VoiceDocument, error) {
+	maxSeconds, silence := v.MaxSeconds, v.SilenceSeconds
+	if maxSeconds == 0 {
+		maxSeconds = 60
+	}
+	if silence == 0 {
+		silence = 5
+	}
+	if maxSeconds < 1 || maxSeconds > 3600 || silence < 1 || silence > 60 {
+		return nil, fmt.Errorf("%w: max_seconds must be 1-3600 and silence_seconds 1-60", ErrInvalidVoice)
+	}
+	terminators := v.Terminators
+	if terminators == "" {
+		terminators = "#"
+	}
+	if !terminatorPattern.MatchString(terminators) {
+		return nil, fmt.Errorf("%w: invalid terminators", ErrInvalidVoice)
+	}
+	if err := call.answer(); err != nil {
+		return nil, err
+	}
+
+	call.recordings++
+	path := filepath.Join(call.vm.config.RecordDir, fmt.Sprintf("%s_%d.wav", call.uuid, call.recordings))
+	if _, err := call.execute("set", "playback_terminators="+terminators); err != nil {
+		return nil, err
+	}
+	ev, err := call.execute("record", fmt.Sprintf("%s %d 200 %d", path, maxSeconds, silence))
+	if err != nil {
+		return nil, err
+	}
+
+	hook := call.webhook("record")
+	hook.RecordingFile = path
+	hook.RecordingSeconds, _ = strconv.Atoi(ev.Get("Variable_record_seconds"))
+	return call.fetch(call.action(v), hook)
+}
+
+// answer answers the call before the first verb that needs media.
+func (call *voiceCall) answer() error {
+	if call.answered {
+		return nil
+	}
+	if _, err := call.execute("answer", ""); err != nil {
+		return err
+	}
+	call.answered = true
+	return nil
+}
+
+func (call *voiceCall) hangup(cause string) {
+	if _, err := call.execute("hangup", cause); err != nil && !errors.Is(err, errCallEnded) {
+		log.Printf("Failed to hang up voice call %s: %v", call.uuid, err)
+	}
+}
+
+// execute runs an application on the call and waits until it completes.
+// The socket is expected in async mode, so sendmsg returns at once and the
+// completion is matched by its event uuid.
+func (call *voiceCall) execute(app, arg string) (*eventsocket.Event, error) {
+	id := newUUID()
+	if _, err := call.conn.SendMsg(eventsocket.MSG{
+		"call-command":     "execute",
+		"execute-app-name": app,
+		"execute-app-arg":  arg,
+		"event-uuid":       id,
+	}, "", ""); err != nil {
+		return nil, fmt.Errorf("failed to execute %s: %w", app, err)
+	}
+
+	for {
+		ev, err := call.conn.ReadEvent()
+		if err != nil {
+			return nil, errCallEnded
+		}
+		if ev.Get("Content-Type") == "text/disconnect-notice" {
+			return nil, errCallEnded
+		}
+		switch ev.Get("Event-Name") {
+		case "CHANNEL_EXECUTE_COMPLETE":
+			if ev.Get("Application-Uuid") == id {
+				return ev, nil
+			}
+		case "CHANNEL_HANGUP", "CHANNEL_HANGUP_COMPLETE":
+			if ev.Get("Unique-Id") == call.uuid {
+				return nil, errCallEnded
+			}
+		}
+	}
+}
+
+// fetch posts hook to a webhook and decodes the document it returns. The
+// app's fallback URL is asked when the webhook fails.
+func (call *voiceCall) fetch(target string, hook VoiceWebhook) (*VoiceDocument, error) {
+	call.fetches++
+	if call.fetches > call.vm.config.MaxFetches {
+		return nil, fmt.Errorf("%w: more than %d documents fetched", ErrInvalidVoice, call.vm.config.MaxFetches)
+	}
+
+	doc, err := call.vm.fetchDocument(target, hook)
+	if err != nil && call.fallbackURL != "" && call.fallbackURL != target {
+		log.Printf("Voice webhook %s failed for call %s, trying fallback: %v", target, call.uuid, err)
+		target = call.fallbackURL
+		doc, err = call.vm.fetchDocument(target, hook)
+	}
+	if err != nil {
+		return nil, err
+	}
+	call.url = target
+	return doc, nil
+}
+
+func (call *voiceCall) webhook(event string) VoiceWebhook {
+	return VoiceWebhook{
+		Event:        event,
+		CallUUID:     call.uuid,
+		DomainUUID:   call.domainUUID,
+		From:         call.from,
+		To:           call.to,
+		CallerIDName: call.callerIDName,
+	}
+}
+
+// action is where a verb posts its result: its own action, or the document
+// it came from.
+func (call *voiceCall) action(v VoiceVerb) string {
+	if v.Action != "" {
+		return v.Action
+	}
+	return call.url
+}
+
+func (vm *VoiceManager) fetchDocument(target string, hook VoiceWebhook) (*VoiceDocument, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: invalid url %q", ErrInvalidVoice, target)
+	}
+
+	body, err := json.Marshal(hook)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode voice webhook: %w", err)
+	}
+	resp, err := vm.client.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to call voice webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("voice webhook returned status %d", resp.StatusCode)
+	}
+
+	var doc VoiceDocument
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxVoiceDocumentBytes)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidVoice, err)
+	}
+	if len(doc.Verbs) > maxVoiceVerbs {
+		return nil, fmt.Errorf("%w: more than %d verbs", ErrInvalidVoice, maxVoiceVerbs)
+	}
+	return &doc, nil
+}
+
+func loops(n int) int {
+	if n < 1 {
+		return 1
+	}
+	if n > 10 {
+		return 10
+	}
+	return n
+}
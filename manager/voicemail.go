@@ -0,0 +1,371 @@
+package manager
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vishaltalsaniya-7/voip-api/config"
+	"github.com/vishaltalsaniya-7/voip-api/models"
+)
+
+var (
+	ErrVoicemailBoxNotFound     = errors.New("voicemail box not found")
+	ErrVoicemailMessageNotFound = errors.New("voicemail message not found")
+	ErrInvalidVoicemail         = errors.New("invalid voicemail request")
+)
+
+// Voicemail message filters.
+const (
+	VoicemailNew   = "new"
+	VoicemailSaved = "saved"
+)
+
+// Formats FusionPBX records voicemail in, in the order they are looked for.
+var voicemailFormats = []string{"wav", "mp3"}
+
+const voicemailBoxColumns = `v.voicemail_uuid, v.domain_uuid, d.domain_name, v.voicemail_id,
+	COALESCE(v.voicemail_mail_to, ''), COALESCE(v.voicemail_description, ''),
+	COALESCE(v.voicemail_enabled, 'true') = 'true',
+	(SELECT COUNT(*) FROM v_voicemail_messages m
+		WHERE m.voicemail_uuid = v.voicemail_uuid AND COALESCE(m.message_status, '') <> 'saved'),
+	(SELECT COUNT(*) FROM v_voicemail_messages m
+		WHERE m.voicemail_uuid = v.voicemail_uuid AND m.message_status = 'saved')`
+
+const voicemailMessageColumns = `m.voicemail_message_uuid, m.voicemail_uuid, m.domain_uuid, d.domain_name,
+	v.voicemail_id, COALESCE(m.caller_id_name, ''), COALESCE(m.caller_id_number, ''),
+	COALESCE(m.message_length, 0)::int, COALESCE(m.message_status, ''), COALESCE(m.message_priority, ''),
+	COALESCE(m.message_transcription, ''), to_timestamp(COALESCE(m.created_epoch, 0)),
+	CASE WHEN m.read_epoch > 0 THEN to_timestamp(m.read_epoch) END`
+
+const voicemailMessageFrom = `v_voicemail_messages m
+	JOIN v_voicemails v ON v.voicemail_uuid = m.voicemail_uuid
+	JOIN v_domains d ON d.domain_uuid = m.domain_uuid`
+
+// VoicemailAudio is the audio of a message, read from disk or decoded from
+// the database.
+type VoicemailAudio struct {
+	io.ReadSeeker
+	Name        string
+	ContentType string
+	ModTime     time.Time
+
+	file *os.File
+}
+
+func (a *VoicemailAudio) Close() error {
+	if a.file == nil {
+		return nil
+	}
+	return a.file.Close()
+}
+
+// VoicemailManager reads and updates the FusionPBX voicemail tables and
+// keeps phones' message waiting lights in step.
+type VoicemailManager struct {
+	db     *sql.DB
+	eslMgr *ESLManager
+	config config.VoicemailConfig
+}
+
+func NewVoicemailManager(db *sql.DB, eslMgr *ESLManager, cfg config.VoicemailConfig) *VoicemailManager {
+	return &VoicemailManager{
+		db:     db,
+		eslMgr: eslMgr,
+		config: cfg,
+	}
+}
+
+// ListBoxes returns the boxes of a tenant, or of every tenant when
+// domainUUID is empty, optionally only the box of one extension.
+func (vm *VoicemailManager) ListBoxes(domainUUID, extension string, limit, offset int) ([]models.VoicemailBox, int, error) {
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, 0, ErrInvalidDomain
+	}
+	where := `WHERE (NULLIF($1, '') IS NULL OR v.domain_uuid = NULLIF($1, '')::uuid)
+		AND (NULLIF($2, '') IS NULL OR v.voicemail_id = $2)`
+
+	var total int
+	if err := vm.db.QueryRow(`SELECT COUNT(*) FROM v_voicemails v `+where, domainUUID, extension).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count voicemail boxes: %w", err)
+	}
+
+	rows, err := vm.db.Query(`
+		SELECT `+voicemailBoxColumns+`
+		FROM v_voicemails v JOIN v_domains d ON d.domain_uuid = v.domain_uuid
+		`+where+`
+		ORDER BY d.domain_name, v.voicemail_id
+		LIMIT $3 OFFSET $4`, domainUUID, extension, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch voicemail boxes: %w", err)
+	}
+	defer rows.Close()
+
+	var boxes []models.VoicemailBox
+	for rows.Next() {
+		box, err := scanVoicemailBox(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		boxes = append(boxes, *box)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read voicemail boxes: %w", err)
+	}
+	return boxes, total, nil
+}
+
+func (vm *VoicemailManager) GetBox(id string) (*models.VoicemailBox, error) {
+	if !isUUID(id) {
+		return nil, ErrVoicemailBoxNotFound
+	}
+	row := vm.db.QueryRow(`
+		SELECT `+voicemailBoxColumns+`
+		FROM v_voicemails v JOIN v_domains d ON d.domain_uuid = v.domain_uuid
+		WHERE v.voicemail_uuid = $1`, id)
+	return scanVoicemailBox(row)
+}
+
+// ListMessages returns the messages of a box, newest first. status is
+// VoicemailNew, VoicemailSaved or empty for both.
+func (vm *VoicemailManager) ListMessages(boxUUID, status string, limit, offset int) ([]models.VoicemailMessage, int, error) {
+	if !isUUID(boxUUID) {
+		return nil, 0, ErrVoicemailBoxNotFound
+	}
+	if status != "" && status != VoicemailNew && status != VoicemailSaved {
+		return nil, 0, fmt.Errorf("%w: status must be new or saved", ErrInvalidVoicemail)
+	}
+	where := `WHERE m.voicemail_uuid = $1
+		AND ($2 = '' OR ($2 = 'saved') = (COALESCE(m.message_status, '') = 'saved'))`
+
+	var total int
+	if err := vm.db.QueryRow(`SELECT COUNT(*) FROM `+voicemailMessageFrom+` `+where, boxUUID, status).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count voicemail messages: %w", err)
+	}
+
+	rows, err := vm.db.Query(`SELECT `+voicemailMessageColumns+` FROM `+voicemailMessageFrom+` `+where+`
+		ORDER BY m.created_epoch DESC LIMIT $3 OFFSET $4`, boxUUID, status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch voicemail messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []models.VoicemailMessage
+	for rows.Next() {
+		msg, err := scanVoicemailMessage(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		messages = append(messages, *msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read voicemail messages: %w", err)
+	}
+	return messages, total, nil
+}
+
+func (vm *VoicemailManager) GetMessage(id string) (*models.VoicemailMessage, error) {
+	if !isUUID(id) {
+		return nil, ErrVoicemailMessageNotFound
+	}
+	row := vm.db.QueryRow(`SELECT `+voicemailMessageColumns+` FROM `+voicemailMessageFrom+`
+		WHERE m.voicemail_message_uuid = $1`, id)
+	return scanVoicemailMessage(row)
+}
+
+// SetRead marks a message read or unread the way the FusionPBX voicemail
+// application does, and updates the box's message waiting light.
+func (vm *VoicemailManager) SetRead(id string, read bool) (*models.VoicemailMessage, error) {
+	if !isUUID(id) {
+		return nil, ErrVoicemailMessageNotFound
+	}
+
+	var query string
+	if read {
+		query = `UPDATE v_voicemail_messages
+			SET message_status = 'saved', read_epoch = COALESCE(NULLIF(read_epoch, 0), floor(extract(epoch FROM now())))
+			WHERE voicemail_message_uuid = $1`
+	} else {
+		query = `UPDATE v_voicemail_messages SET message_status = '', read_epoch = NULL
+			WHERE voicemail_message_uuid = $1`
+	}
+	res, err := vm.db.Exec(query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update voicemail message: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrVoicemailMessageNotFound
+	}
+
+	msg, err := vm.GetMessage(id)
+	if err != nil {
+		return nil, err
+	}
+	vm.notify(msg.VoicemailUUID)
+	return msg, nil
+}
+
+// DeleteMessage removes a message and its audio, and updates the box's
+// message waiting light.
+func (vm *VoicemailManager) DeleteMessage(id string) error {
+	msg, err := vm.GetMessage(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := vm.db.Exec(`DELETE FROM v_voicemail_messages WHERE voicemail_message_uuid = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete voicemail message: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrVoicemailMessageNotFound
+	}
+
+	if dir, err := vm.boxDir(msg); err == nil {
+		for _, prefix := range []string{"msg_", "intro_msg_"} {
+			for _, ext := range voicemailFormats {
+				name := filepath.Join(dir, prefix+msg.MessageUUID+"."+ext)
+				if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+					log.Printf("Failed to remove voicemail audio %s: %v", name, err)
+				}
+			}
+		}
+	}
+
+	vm.notify(msg.VoicemailUUID)
+	return nil
+}
+
+// Audio opens the recording of a message. Messages FusionPBX keeps in the
+// database are decoded from message_base64.
+func (vm *VoicemailManager) Audio(msg *models.VoicemailMessage) (*VoicemailAudio, error) {
+	var encoded sql.NullString
+	err := vm.db.QueryRow(`SELECT message_base64 FROM v_voicemail_messages WHERE voicemail_message_uuid = $1`,
+		msg.MessageUUID).Scan(&encoded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVoicemailMessageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch voicemail audio: %w", err)
+	}
+	if encoded.String != "" {
+		data, err := base64.StdEncoding.DecodeString(encoded.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode voicemail audio: %w", err)
+		}
+		ext := "wav"
+		if bytes.HasPrefix(data, []byte("ID3")) || (len(data) > 1 && data[0] == 0xff && data[1]&0xe0 == 0xe0) {
+			ext = "mp3"
+		}
+		return &VoicemailAudio{
+			ReadSeeker:  bytes.NewReader(data),
+			Name:        "msg_" + msg.MessageUUID + "." + ext,
+			ContentType: contentType(ext),
+			ModTime:     msg.CreatedAt,
+		}, nil
+	}
+
+	dir, err := vm.boxDir(msg)
+	if err != nil {
+		return nil, err
+	}
+	for _, ext := range voicemailFormats {
+		name := filepath.Join(dir, "msg_"+msg.MessageUUID+"."+ext)
+		f, err := os.Open(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to open voicemail audio: %w", err)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to stat voicemail audio: %w", err)
+		}
+		return &VoicemailAudio{
+			ReadSeeker:  f,
+			Name:        filepath.Base(name),
+			ContentType: contentType(ext),
+			ModTime:     info.ModTime(),
+			file:        f,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: no audio for message", ErrVoicemailMessageNotFound)
+}
+
+// NotifyMWI sends the message waiting state of a box to its phones.
+func (vm *VoicemailManager) NotifyMWI(boxUUID string) (*models.VoicemailBox, error) {
+	box, err := vm.GetBox(boxUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	waiting := "no"
+	if box.NewMessages > 0 {
+		waiting = "yes"
+	}
+	err = vm.eslMgr.sendEvent("MESSAGE_WAITING", map[string]string{
+		"MWI-Messages-Waiting": waiting,
+		"MWI-Message-Account":  fmt.Sprintf("sip:%s@%s", box.VoicemailID, box.DomainName),
+		"MWI-Voice-Message":    fmt.Sprintf("%d/%d (0/0)", box.NewMessages, box.SavedMessages),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return box, nil
+}
+
+// notify updates the message waiting light after a change. The change
+// itself has succeeded, so failures are only logged.
+func (vm *VoicemailManager) notify(boxUUID string) {
+	if _, err := vm.NotifyMWI(boxUUID); err != nil {
+		log.Printf("Failed to send MWI for voicemail box %s: %v", boxUUID, err)
+	}
+}
+
+// boxDir returns the directory FusionPBX keeps the audio of a message's box
+// in.
+func (vm *VoicemailManager) boxDir(msg *models.VoicemailMessage) (string, error) {
+	root := filepath.Join(vm.config.StorageDir, "default")
+	dir := filepath.Join(root, msg.DomainName, msg.VoicemailID)
+	if msg.DomainName == "" || msg.VoicemailID == "" || strings.ContainsAny(msg.DomainName+msg.VoicemailID, `/\`) ||
+		!withinDir(root, dir) {
+		return "", fmt.Errorf("%w: no audio for message", ErrVoicemailMessageNotFound)
+	}
+	return dir, nil
+}
+
+func scanVoicemailBox(row rowScanner) (*models.VoicemailBox, error) {
+	var b models.VoicemailBox
+	err := row.Scan(&b.VoicemailUUID, &b.DomainUUID, &b.DomainName, &b.VoicemailID, &b.MailTo, &b.Description,
+		&b.Enabled, &b.NewMessages, &b.SavedMessages)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVoicemailBoxNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan voicemail box: %w", err)
+	}
+	return &b, nil
+}
+
+func scanVoicemailMessage(row rowScanner) (*models.VoicemailMessage, error) {
+	var m models.VoicemailMessage
+	err := row.Scan(&m.MessageUUID, &m.VoicemailUUID, &m.DomainUUID, &m.DomainName, &m.VoicemailID, &m.CallerIDName,
+		&m.CallerIDNumber, &m.Length, &m.Status, &m.Priority, &m.Transcription, &m.CreatedAt, &m.ReadAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVoicemailMessageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan voicemail message: %w", err)
+	}
+	return &m, nil
+}
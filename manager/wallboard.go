@@ -0,0 +1,515 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fiorix/go-eventsocket/eventsocket"
+	"github.com/vishaltalsaniya-7/voip-api/config"
+)
+
+var ErrInvalidWallboard = errors.New("a wallboard needs either a queue or a domain_uuid")
+
+// Agent buckets shown on the wallboard.
+const (
+	WallboardAvailable = "available"
+	WallboardRinging   = "ringing"
+	WallboardOnCall    = "on_call"
+	WallboardIdle      = "idle"
+	WallboardOnBreak   = "on_break"
+	WallboardLoggedOut = "logged_out"
+)
+
+// WallboardSnapshot is what a wallboard shows for a queue, or for all the
+// queues of a tenant. Answered, Abandoned and the figures derived from them
+// count since local midnight.
+type WallboardSnapshot struct {
+	Queue        string
+	DomainUUID   string
+	Time         time.Time
+	Waiting      int
+	LongestWait  int
+	Agents       map[string]int // bucket -> agents
+	Answered     int
+	Abandoned    int
+	AnsweredInSL int
+	ServiceLevel float64 // percent of answered and abandoned calls answered within the threshold
+	AverageWait  float64
+	Since        time.Time
+}
+
+type wallboardQueue struct {
+	domainUUID string
+	members    map[string]time.Time // member session uuid -> joined
+	agents     map[string]bool
+}
+
+type wallboardAgent struct {
+	status, state string
+}
+
+type wallboardCounters struct {
+	answered, abandoned, inSL, waitTotal int
+}
+
+type wallboardSub struct {
+	queue, domainUUID string
+	ch                chan WallboardSnapshot
+}
+
+// WallboardManager aggregates live queue figures for wallboards. Callers
+// waiting and agent states follow callcenter::info events; the day's
+// answered and abandoned calls are seeded from v_xml_cdr and then counted
+// from events. Every call is counted once by its member session uuid, so
+// reloading after an ESL reconnect neither loses nor repeats calls.
+type WallboardManager struct {
+	db         *sql.DB
+	callcenter *CallCenterManager
+	config     config.WallboardConfig
+
+	mu       sync.Mutex
+	day      time.Time
+	queues   map[string]*wallboardQueue
+	agents   map[string]wallboardAgent
+	counted  map[string]bool // member session uuids counted today
+	counters map[string]*wallboardCounters
+	domains  map[string]string // domain name -> uuid
+	subs     map[*wallboardSub]bool
+
+	reseed chan struct{}
+}
+
+func NewWallboardManager(db *sql.DB, eslMgr *ESLManager, callcenter *CallCenterManager, cfg config.WallboardConfig) *WallboardManager {
+	wm := &WallboardManager{
+		db:         db,
+		callcenter: callcenter,
+		config:     cfg,
+		day:        today(),
+		queues:     make(map[string]*wallboardQueue),
+		agents:     make(map[string]wallboardAgent),
+		counted:    make(map[string]bool),
+		counters:   make(map[string]*wallboardCounters),
+		domains:    make(map[string]string),
+		subs:       make(map[*wallboardSub]bool),
+		reseed:     make(chan struct{}, 1),
+	}
+	eslMgr.Subscribe("callcenter::info", wm.handleInfo)
+	eslMgr.OnConnect(wm.requestReseed)
+	return wm
+}
+
+// Run publishes snapshots to subscribers every Interval and reloads the
+// state when asked to, every ReseedInterval and at midnight.
+func (wm *WallboardManager) Run() {
+	ticker := time.NewTicker(wm.config.Interval)
+	defer ticker.Stop()
+	reseed := time.NewTicker(wm.config.ReseedInterval)
+	defer reseed.Stop()
+
+	wm.Reseed()
+	for {
+		select {
+		case <-ticker.C:
+			if wm.rollover() {
+				wm.Reseed()
+			}
+			wm.publish()
+		case <-reseed.C:
+			wm.Reseed()
+		case <-wm.reseed:
+			wm.Reseed()
+		}
+	}
+}
+
+func (wm *WallboardManager) requestReseed() {
+	select {
+	case wm.reseed <- struct{}{}:
+	default:
+	}
+}
+
+// rollover starts a new day at midnight and reports whether it did.
+func (wm *WallboardManager) rollover() bool {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	d := today()
+	if d.Equal(wm.day) {
+		return false
+	}
+	wm.day = d
+	wm.counted = make(map[string]bool)
+	wm.counters = make(map[string]*wallboardCounters)
+	return true
+}
+
+// Reseed reloads the waiting callers and agents from mod_callcenter and the
+// day's calls from v_xml_cdr. Calls already counted from events are kept.
+func (wm *WallboardManager) Reseed() {
+	queues, err := wm.callcenter.ListQueues()
+	if err != nil {
+		log.Printf("Failed to load call center queues for the wallboard: %v", err)
+		return
+	}
+
+	fresh := make(map[string]*wallboardQueue, len(queues))
+	agents := make(map[string]wallboardAgent)
+	for _, name := range queues {
+		members, err := wm.callcenter.ListMembers(name)
+		if err != nil {
+			log.Printf("Failed to load members of queue %s: %v", name, err)
+			return
+		}
+		queueAgents, err := wm.callcenter.ListQueueAgents(name)
+		if err != nil {
+			log.Printf("Failed to load agents of queue %s: %v", name, err)
+			return
+		}
+
+		q := &wallboardQueue{members: make(map[string]time.Time), agents: make(map[string]bool)}
+		for _, m := range members {
+			if m.State == "Waiting" || m.State == "Trying" {
+				q.members[m.SessionUUID] = m.Joined
+			}
+		}
+		for _, a := range queueAgents {
+			q.agents[a.Name] = true
+			agents[a.Name] = wallboardAgent{status: a.Status, state: a.State}
+		}
+		fresh[name] = q
+	}
+
+	wm.mu.Lock()
+	day := wm.day
+	for name, q := range fresh {
+		if known := wm.queues[name]; known != nil {
+			q.domainUUID = known.domainUUID
+		}
+	}
+	wm.queues, wm.agents = fresh, agents
+	wm.mu.Unlock()
+
+	if err := wm.seedCalls(day); err != nil {
+		log.Printf("Failed to load today's queue calls for the wallboard: %v", err)
+	}
+	wm.resolveDomains()
+}
+
+// seedCalls counts the queue calls in v_xml_cdr since day that events have
+// not counted yet.
+func (wm *WallboardManager) seedCalls(day time.Time) error {
+	rows, err := wm.db.Query(`
+		SELECT COALESCE(cc_member_session_uuid::text, xml_cdr_uuid::text), domain_uuid, cc_queue,
+			cc_queue_joined_epoch, cc_queue_answered_epoch, cc_queue_canceled_epoch
+		FROM v_xml_cdr
+		WHERE cc_side = 'member' AND COALESCE(cc_queue, '') <> '' AND start_stamp >= $1`, day)
+	if err != nil {
+		return fmt.Errorf("failed to fetch queue calls: %w", err)
+	}
+	defer rows.Close()
+
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	for rows.Next() {
+		var session, queue string
+		var domainUUID sql.NullString
+		var joined, answered, canceled sql.NullInt64
+		if err := rows.Scan(&session, &domainUUID, &queue, &joined, &answered, &canceled); err != nil {
+			return fmt.Errorf("failed to scan queue call: %w", err)
+		}
+		if domainUUID.Valid {
+			wm.queueDomain(queue, domainUUID.String)
+		}
+		switch {
+		case answered.Int64 > 0:
+			wm.count(session, queue, true, int(answered.Int64-joined.Int64))
+		case canceled.Int64 > 0:
+			wm.count(session, queue, false, 0)
+		}
+	}
+	return rows.Err()
+}
+
+// resolveDomains finds the tenant of queues named <name>@<domain name>
+// that no CDR or event has tied to a tenant yet.
+func (wm *WallboardManager) resolveDomains() {
+	wm.mu.Lock()
+	var names []string
+	for name, q := range wm.queues {
+		if _, domain, ok := strings.Cut(name, "@"); ok && q.domainUUID == "" && wm.domains[domain] == "" {
+			names = append(names, domain)
+		}
+	}
+	wm.mu.Unlock()
+
+	for _, domain := range names {
+		var domainUUID string
+		err := wm.db.QueryRow(`SELECT domain_uuid FROM v_domains WHERE domain_name = $1`, domain).Scan(&domainUUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to look up domain %s: %v", domain, err)
+			return
+		}
+		wm.mu.Lock()
+		wm.domains[domain] = domainUUID
+		wm.mu.Unlock()
+	}
+
+	wm.mu.Lock()
+	for name, q := range wm.queues {
+		if _, domain, ok := strings.Cut(name, "@"); ok && q.domainUUID == "" {
+			q.domainUUID = wm.domains[domain]
+		}
+	}
+	wm.mu.Unlock()
+}
+
+// queueDomain ties a queue to a tenant. The caller holds wm.mu.
+func (wm *WallboardManager) queueDomain(queue, domainUUID string) {
+	if !isUUID(domainUUID) {
+		return
+	}
+	q := wm.queues[queue]
+	if q == nil {
+		q = &wallboardQueue{members: make(map[string]time.Time), agents: make(map[string]bool)}
+		wm.queues[queue] = q
+	}
+	if q.domainUUID == "" {
+		q.domainUUID = domainUUID
+	}
+}
+
+// count records the outcome of a queue call once. The caller holds wm.mu.
+func (wm *WallboardManager) count(session, queue string, answered bool, wait int) {
+	if session == "" || wm.counted[session] {
+		return
+	}
+	wm.counted[session] = true
+
+	c := wm.counters[queue]
+	if c == nil {
+		c = &wallboardCounters{}
+		wm.counters[queue] = c
+	}
+	if !answered {
+		c.abandoned++
+		return
+	}
+	c.answered++
+	if wait > 0 {
+		c.waitTotal += wait
+	}
+	if time.Duration(wait)*time.Second <= wm.config.ServiceLevel {
+		c.inSL++
+	}
+}
+
+func (wm *WallboardManager) handleInfo(ev *eventsocket.Event) {
+	queue := ev.Get("Cc-Queue")
+	action := ev.Get("Cc-Action")
+	session := ev.Get("Cc-Member-Session-Uuid")
+
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	switch action {
+	case "agent-status-change":
+		a := wm.agents[ev.Get("Cc-Agent")]
+		a.status = ev.Get("Cc-Agent-Status")
+		wm.agents[ev.Get("Cc-Agent")] = a
+		return
+	case "agent-state-change":
+		a := wm.agents[ev.Get("Cc-Agent")]
+		a.state = ev.Get("Cc-Agent-State")
+		wm.agents[ev.Get("Cc-Agent")] = a
+		return
+	}
+	if queue == "" {
+		return
+	}
+	wm.queueDomain(queue, ev.Get("Variable_domain_uuid"))
+	q := wm.queues[queue]
+	if q == nil {
+		q = &wallboardQueue{members: make(map[string]time.Time), agents: make(map[string]bool)}
+		wm.queues[queue] = q
+	}
+
+	switch action {
+	case "member-queue-start":
+		joined := epochTime(ev.Get("Cc-Member-Joined-Time"))
+		if joined.IsZero() {
+			joined = time.Now()
+		}
+		if session != "" {
+			q.members[session] = joined
+		}
+	case "bridge-agent-start":
+		delete(q.members, session)
+		joined, _ := strconv.ParseInt(ev.Get("Cc-Member-Joined-Time"), 10, 64)
+		answered, _ := strconv.ParseInt(ev.Get("Cc-Agent-Answered-Time"), 10, 64)
+		wm.count(session, queue, true, int(answered-joined))
+		if agent := ev.Get("Cc-Agent"); agent != "" {
+			q.agents[agent] = true
+		}
+	case "member-queue-end":
+		delete(q.members, session)
+		if ev.Get("Cc-Cause") == "Cancel" {
+			wm.count(session, queue, false, 0)
+		}
+	}
+}
+
+// Snapshot returns the wallboard of a queue, or of every queue of a tenant.
+// Exactly one of queue and domainUUID must be set.
+func (wm *WallboardManager) Snapshot(queue, domainUUID string) (*WallboardSnapshot, error) {
+	if (queue == "") == (domainUUID == "") {
+		return nil, ErrInvalidWallboard
+	}
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, ErrInvalidDomain
+	}
+
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	if queue != "" && wm.queues[queue] == nil && wm.counters[queue] == nil {
+		return nil, ErrCallCenterQueueNotFound
+	}
+	s := wm.snapshot(queue, domainUUID, time.Now())
+	return &s, nil
+}
+
+// snapshot aggregates the queues in scope. The caller holds wm.mu.
+func (wm *WallboardManager) snapshot(queue, domainUUID string, now time.Time) WallboardSnapshot {
+	s := WallboardSnapshot{
+		Queue:      queue,
+		DomainUUID: domainUUID,
+		Time:       now,
+		Agents: map[string]int{
+			WallboardAvailable: 0,
+			WallboardRinging:   0,
+			WallboardOnCall:    0,
+			WallboardIdle:      0,
+			WallboardOnBreak:   0,
+			WallboardLoggedOut: 0,
+		},
+		Since: wm.day,
+	}
+	inScope := func(name string, q *wallboardQueue) bool {
+		if queue != "" {
+			return name == queue
+		}
+		return q != nil && q.domainUUID == domainUUID
+	}
+
+	agents := make(map[string]bool)
+	var waitTotal int
+	for name, q := range wm.queues {
+		if !inScope(name, q) {
+			continue
+		}
+		if s.DomainUUID == "" {
+			s.DomainUUID = q.domainUUID
+		}
+		for _, joined := range q.members {
+			s.Waiting++
+			s.LongestWait = max(s.LongestWait, int(now.Sub(joined).Seconds()))
+		}
+		for agent := range q.agents {
+			agents[agent] = true
+		}
+	}
+	for name, c := range wm.counters {
+		if !inScope(name, wm.queues[name]) {
+			continue
+		}
+		s.Answered += c.answered
+		s.Abandoned += c.abandoned
+		s.AnsweredInSL += c.inSL
+		waitTotal += c.waitTotal
+	}
+	for agent := range agents {
+		s.Agents[wallboardBucket(wm.agents[agent])]++
+	}
+
+	if handled := s.Answered + s.Abandoned; handled > 0 {
+		s.ServiceLevel = float64(s.AnsweredInSL) * 100 / float64(handled)
+	}
+	if s.Answered > 0 {
+		s.AverageWait = float64(waitTotal) / float64(s.Answered)
+	}
+	return s
+}
+
+func wallboardBucket(a wallboardAgent) string {
+	switch {
+	case a.status == AgentLoggedOut || a.status == "":
+		return WallboardLoggedOut
+	case a.status == AgentOnBreak:
+		return WallboardOnBreak
+	case a.state == AgentInQueueCall:
+		return WallboardOnCall
+	case a.state == AgentReceiving || a.state == AgentReserved:
+		return WallboardRinging
+	case a.state == AgentWaiting:
+		return WallboardAvailable
+	}
+	return WallboardIdle
+}
+
+// Subscribe returns a channel that receives a snapshot every Interval, and
+// a function that ends the subscription. A slow reader only misses
+// snapshots.
+func (wm *WallboardManager) Subscribe(queue, domainUUID string) (<-chan WallboardSnapshot, func(), error) {
+	if (queue == "") == (domainUUID == "") {
+		return nil, nil, ErrInvalidWallboard
+	}
+	if domainUUID != "" && !isUUID(domainUUID) {
+		return nil, nil, ErrInvalidDomain
+	}
+
+	sub := &wallboardSub{queue: queue, domainUUID: domainUUID, ch: make(chan WallboardSnapshot, 1)}
+	wm.mu.Lock()
+	wm.subs[sub] = true
+	wm.mu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			wm.mu.Lock()
+			delete(wm.subs, sub)
+			wm.mu.Unlock()
+		})
+	}, nil
+}
+
+// publish sends every subscriber its snapshot. Subscribers to the same
+// scope share one.
+func (wm *WallboardManager) publish() {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	now := time.Now()
+	snapshots := make(map[[2]string]WallboardSnapshot)
+	for sub := range wm.subs {
+		key := [2]string{sub.queue, sub.domainUUID}
+		s, ok := snapshots[key]
+		if !ok {
+			s = wm.snapshot(sub.queue, sub.domainUUID, now)
+			snapshots[key] = s
+		}
+		select {
+		case <-sub.ch:
+		default:
+		}
+		sub.ch <- s
+	}
+}
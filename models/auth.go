@@ -0,0 +1,19 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// APIKey is a stored API key. Only the SHA-256 hash of the key is kept;
+// KeyPrefix identifies it in listings.
+type APIKey struct {
+	KeyUUID    string
+	Name       string
+	KeyPrefix  string
+	Role       string
+	DomainUUID sql.NullString
+	Enabled    bool
+	LastUsedAt sql.NullTime
+	CreatedAt  time.Time
+}
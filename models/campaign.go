@@ -0,0 +1,49 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Campaign struct {
+	CampaignUUID      string
+	DomainUUID        sql.NullString
+	Name              string
+	Mode              string
+	Status            string
+	Agents            []string
+	Destination       []byte
+	CallerIDName      string
+	CallerIDNumber    string
+	DialTimeout       int
+	MaxDialRatio      float64
+	MaxAttempts       int
+	RetryDelaySeconds int
+	RetryCauses       []string
+	Timezone          string
+	WindowStart       string
+	WindowEnd         string
+	AMD               []byte
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+type CampaignContact struct {
+	ContactUUID     string
+	CampaignUUID    string
+	PhoneNumber     string
+	Name            string
+	Timezone        string
+	Variables       []byte
+	Status          string
+	Attempts        int
+	NextAttemptAt   sql.NullTime
+	LastAttemptAt   sql.NullTime
+	LastCallUUID    string
+	LastHangupCause string
+	LastAgent       string
+	BillSec         int
+	AMDResult       string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type DNCEntry struct {
+	DNCEntryUUID string
+	DomainUUID   sql.NullString
+	MatchType    string
+	Pattern      string
+	Reason       string
+	CreatedAt    time.Time
+}
+
+type DNCAudit struct {
+	DNCAuditUUID string
+	DomainUUID   sql.NullString
+	Caller       string
+	Callee       string
+	Number       string
+	DNCEntryUUID sql.NullString
+	Reason       string
+	CreatedAt    time.Time
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RecordingKey is a tenant data key. The key material never leaves the
+// manager.
+type RecordingKey struct {
+	KeyUUID     string
+	DomainUUID  sql.NullString
+	MasterKeyID string
+	Active      bool
+	Objects     int
+	CreatedAt   time.Time
+	RewrappedAt sql.NullTime
+	RetiredAt   sql.NullTime
+}
@@ -0,0 +1,38 @@
+package models
+
+import "database/sql"
+
+// Extension is a row of FusionPBX's v_extensions with its voicemail box,
+// whose fields are null when the extension has none.
+type Extension struct {
+	ExtensionUUID           string
+	DomainUUID              string
+	DomainName              string
+	Extension               string
+	NumberAlias             string
+	EffectiveCallerIDName   string
+	EffectiveCallerIDNumber string
+	OutboundCallerIDName    string
+	OutboundCallerIDNumber  string
+	DirectoryFirstName      string
+	DirectoryLastName       string
+	UserContext             string
+	CallTimeout             int
+	Description             string
+	Enabled                 bool
+	CreatedAt               sql.NullTime
+	UpdatedAt               sql.NullTime
+
+	VoicemailUUID       sql.NullString
+	VoicemailEnabled    sql.NullBool
+	VoicemailMailTo     sql.NullString
+	VoicemailAttachFile sql.NullBool
+	VoicemailKeepLocal  sql.NullBool
+}
+
+// ExtensionSecrets are the credentials of an extension, returned only when
+// they are set.
+type ExtensionSecrets struct {
+	Password          string
+	VoicemailPassword string
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type FraudRule struct {
+	RuleUUID      string
+	DomainUUID    sql.NullString
+	Name          string
+	Kind          string
+	Prefixes      []string
+	Threshold     int
+	WindowSeconds int
+	MaxDuration   int
+	BusinessStart sql.NullString
+	BusinessEnd   sql.NullString
+	BusinessDays  []int64
+	Timezone      string
+	Actions       []string
+	WebhookURL    string
+	Enabled       bool
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type FraudEvent struct {
+	EventUUID  string
+	RuleUUID   sql.NullString
+	DomainUUID sql.NullString
+	Kind       string
+	Extension  string
+	CallUUID   string
+	Number     string
+	Detail     string
+	Actions    []string
+	CreatedAt  time.Time
+}
+
+type FraudBlock struct {
+	BlockUUID  string
+	DomainUUID sql.NullString
+	Extension  string
+	RuleUUID   sql.NullString
+	Reason     string
+	CreatedAt  time.Time
+}
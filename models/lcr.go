@@ -0,0 +1,47 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Gateway struct {
+	GatewayUUID string
+	Name        string
+	Enabled     bool
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type Route struct {
+	RouteUUID   string
+	GatewayUUID string
+	GatewayName string
+	DomainUUID  sql.NullString
+	Prefix      string
+	Rate        float64
+	Priority    int
+	Enabled     bool
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// CallRoute is one attempt to reach an external number through a gateway.
+type CallRoute struct {
+	LegUUID         string
+	CallUUID        string
+	DomainUUID      sql.NullString
+	Number          string
+	RouteUUID       sql.NullString
+	Gateway         string
+	Prefix          string
+	Rate            float64
+	Attempt         int
+	HangupCause     string
+	HangupCauseQ850 sql.NullInt64
+	BillSec         int
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
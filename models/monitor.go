@@ -0,0 +1,35 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// MonitorSession is one supervisor listening in on, whispering to or
+// barging into a call.
+type MonitorSession struct {
+	SessionUUID string
+	CallUUID    string
+	DomainUUID  sql.NullString
+	Mode        string
+	Supervisor  string
+	KeyUUID     sql.NullString
+	KeyName     string
+	Status      string
+	HangupCause string
+	CreatedAt   time.Time
+	AnsweredAt  sql.NullTime
+	EndedAt     sql.NullTime
+}
+
+// MonitorGroup lets its supervisor extensions monitor the calls of its
+// agent extensions, within one tenant.
+type MonitorGroup struct {
+	GroupUUID   string
+	DomainUUID  string
+	Name        string
+	Supervisors []string
+	Agents      []string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type TranslationRule struct {
+	RuleUUID    string
+	DomainUUID  sql.NullString
+	AppliesTo   string
+	Match       string
+	Strip       int
+	Prepend     string
+	Priority    int
+	Description string
+	CreatedAt   time.Time
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type PrepaidAccount struct {
+	DomainUUID     string
+	Balance        float64
+	Currency       string
+	Enabled        bool
+	MaxCallSeconds int
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type LedgerEntry struct {
+	EntryUUID    string
+	DomainUUID   string
+	Kind         string
+	Amount       float64
+	BalanceAfter float64
+	Reference    string
+	Description  string
+	CreatedAt    time.Time
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type RateDeck struct {
+	DeckUUID    string
+	Name        string
+	Purpose     string
+	DomainUUID  sql.NullString
+	Currency    string
+	Timezone    string
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type Rate struct {
+	RateUUID         string
+	DeckUUID         string
+	Prefix           string
+	Destination      string
+	ConnectFee       float64
+	Rate             float64
+	InitialIncrement int
+	Increment        int
+	BandStart        sql.NullString
+	BandEnd          sql.NullString
+	CreatedAt        time.Time
+}
+
+type CDRRating struct {
+	XMLCDRUUID    string
+	DomainUUID    sql.NullString
+	StartStamp    time.Time
+	Number        string
+	Destination   string
+	BillSec       int
+	BilledSeconds int
+	PriceRateUUID sql.NullString
+	PricePrefix   string
+	Price         float64
+	CostRateUUID  sql.NullString
+	Cost          float64
+	Currency      string
+	Status        string
+	RatedAt       time.Time
+}
+
+// InvoiceLine sums the rated calls of one destination.
+type InvoiceLine struct {
+	Prefix        string
+	Destination   string
+	Currency      string
+	Calls         int
+	BillSec       int
+	BilledSeconds int
+	Price         float64
+	Cost          float64
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type RetentionPolicy struct {
+	PolicyUUID  string
+	DomainUUID  sql.NullString
+	Direction   string
+	RetainDays  int
+	Action      string
+	Description string
+	Enabled     bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type LegalHold struct {
+	HoldUUID   string
+	DomainUUID sql.NullString
+	XMLCDRUUID sql.NullString
+	Number     string
+	Numbers    []string
+	Reason     string
+	ExpiresAt  sql.NullTime
+	ReleasedAt sql.NullTime
+	CreatedAt  time.Time
+}
+
+type RetentionPurge struct {
+	PurgeUUID   string
+	XMLCDRUUID  string
+	DomainUUID  sql.NullString
+	PolicyUUID  sql.NullString
+	Action      string
+	RecordPath  string
+	RecordName  string
+	Destination string
+	Reason      string
+	PurgedAt    time.Time
+}
+
+// RetentionCandidate is a recording that a policy has expired.
+type RetentionCandidate struct {
+	XMLCDRUUID string
+	DomainUUID sql.NullString
+	Direction  string
+	StartStamp time.Time
+	RecordPath string
+	RecordName string
+	PolicyUUID string
+	RetainDays int
+	Action     string
+	Reason     string
+}
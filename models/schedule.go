@@ -0,0 +1,24 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type CallSchedule struct {
+	ScheduleUUID   string
+	DomainUUID     sql.NullString
+	Name           string
+	CallRequest    []byte
+	RunAt          sql.NullTime
+	CronExpression string
+	Timezone       string
+	Enabled        bool
+	NextRunAt      sql.NullTime
+	LastRunAt      sql.NullTime
+	LastCallID     string
+	LastError      string
+	RunCount       int64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
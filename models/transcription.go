@@ -0,0 +1,33 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type TranscriptionJob struct {
+	JobUUID       string
+	XMLCDRUUID    string
+	DomainUUID    sql.NullString
+	Status        string
+	Attempts      int
+	Engine        string
+	Language      string
+	Error         string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	StartedAt     sql.NullTime
+	FinishedAt    sql.NullTime
+}
+
+// TranscriptHit is a CDR whose transcription matches a search.
+type TranscriptHit struct {
+	XMLCDRUUID        string
+	DomainUUID        sql.NullString
+	Direction         string
+	CallerIDNumber    string
+	DestinationNumber string
+	StartStamp        sql.NullTime
+	Rank              float64
+	Snippet           string
+}
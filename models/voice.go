@@ -0,0 +1,20 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// VoiceApp ties a dialed number to the webhook that controls its calls.
+// Apps without a domain apply to every tenant.
+type VoiceApp struct {
+	AppUUID     string
+	DomainUUID  sql.NullString
+	Number      string
+	URL         string
+	FallbackURL string
+	Description string
+	Enabled     bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// VoicemailBox is a FusionPBX voicemail box with its message counts.
+type VoicemailBox struct {
+	VoicemailUUID string
+	DomainUUID    string
+	DomainName    string
+	VoicemailID   string
+	MailTo        string
+	Description   string
+	Enabled       bool
+	NewMessages   int
+	SavedMessages int
+}
+
+// VoicemailMessage is a row of v_voicemail_messages. Messages FusionPBX
+// has not marked saved are new.
+type VoicemailMessage struct {
+	MessageUUID    string
+	VoicemailUUID  string
+	DomainUUID     string
+	DomainName     string
+	VoicemailID    string
+	CallerIDName   string
+	CallerIDNumber string
+	Length         int
+	Status         string
+	Priority       string
+	Transcription  string
+	CreatedAt      time.Time
+	ReadAt         sql.NullTime
+}
@@ -0,0 +1,181 @@
+// Package number normalizes dialed numbers to E.164 and applies the dial-plan
+// translation rules used before a call is routed.
+package number
+
+import (
+	"errors"
+	"strings"
+)
+
+type Kind string
+
+const (
+	Extension Kind = "extension"
+	PSTN      Kind = "pstn"
+)
+
+var ErrInvalidNumber = errors.New("invalid number")
+
+// Settings are the numbering defaults of a tenant.
+type Settings struct {
+	CountryCode         string `json:"country_code"`
+	NationalPrefix      string `json:"national_prefix"`
+	InternationalPrefix string `json:"international_prefix"`
+	ExtensionMaxLength  int    `json:"extension_max_length"`
+}
+
+// Rule rewrites a number whose normalized form starts with Match: Strip
+// leading characters are removed and Prepend is added in front. PSTN rules
+// match the E.164 form including the leading "+".
+type Rule struct {
+	AppliesTo Kind
+	Match     string
+	Strip     int
+	Prepend   string
+}
+
+type Number struct {
+	Input string `json:"input"`
+	Kind  Kind   `json:"kind"`
+	// E164 is set for PSTN numbers only.
+	E164 string `json:"e164,omitempty"`
+	// Dial is the number after translation rules, ready for a dial string.
+	Dial string `json:"dial"`
+}
+
+// Parse classifies raw as an extension or a PSTN number and returns the
+// E.164 form of PSTN numbers. Formatting characters are ignored, so
+// "+1 (555) 010-0000" and "1-555-010-0000" give the same result under
+// NANP settings.
+func Parse(raw string, s Settings) (Number, error) {
+	n := Number{Input: raw}
+	cleaned := Clean(raw)
+	if cleaned == "" {
+		return n, ErrInvalidNumber
+	}
+
+	if strings.HasPrefix(cleaned, "+") {
+		return toE164(n, cleaned[1:])
+	}
+
+	if !isDigits(cleaned) {
+		// Feature codes (*97) and alphanumeric SIP users stay local.
+		if !isDialable(cleaned) {
+			return n, ErrInvalidNumber
+		}
+		n.Kind, n.Dial = Extension, cleaned
+		return n, nil
+	}
+
+	if len(cleaned) <= s.ExtensionMaxLength {
+		n.Kind, n.Dial = Extension, cleaned
+		return n, nil
+	}
+
+	switch {
+	case s.InternationalPrefix != "" && strings.HasPrefix(cleaned, s.InternationalPrefix):
+		return toE164(n, cleaned[len(s.InternationalPrefix):])
+	case s.NationalPrefix != "" && strings.HasPrefix(cleaned, s.NationalPrefix):
+		return toE164(n, s.CountryCode+cleaned[len(s.NationalPrefix):])
+	default:
+		return toE164(n, s.CountryCode+cleaned)
+	}
+}
+
+// Translate applies the first rule matching n and sets n.Dial. Rules are
+// expected in priority order.
+func Translate(n Number, rules []Rule) Number {
+	subject := n.Dial
+	if n.Kind == PSTN {
+		subject = n.E164
+	}
+
+	for _, r := range rules {
+		if r.AppliesTo != n.Kind || !strings.HasPrefix(subject, r.Match) {
+			continue
+		}
+		strip := r.Strip
+		if strip > len(subject) {
+			strip = len(subject)
+		}
+		n.Dial = r.Prepend + subject[strip:]
+		return n
+	}
+
+	if n.Kind == PSTN {
+		n.Dial = subject
+	}
+	return n
+}
+
+// Variants returns the spellings a number is commonly stored under, used to
+// match CDR columns written by different dialplans.
+func Variants(n Number, s Settings) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(v string) {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+
+	add(Clean(n.Input))
+	add(n.Dial)
+	if n.Kind == PSTN {
+		add(n.E164)
+		add(n.E164[1:])
+		if national := strings.TrimPrefix(n.E164[1:], s.CountryCode); national != n.E164[1:] {
+			add(national)
+			add(s.NationalPrefix + national)
+		}
+	}
+	return out
+}
+
+// Clean strips the formatting characters people put in phone numbers.
+func Clean(raw string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.', '/', '\t':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(raw))
+}
+
+func toE164(n Number, digits string) (Number, error) {
+	// E.164 numbers have at most 15 digits; anything under 7 is not a
+	// routable international number.
+	if !isDigits(digits) || len(digits) < 7 || len(digits) > 15 || digits[0] == '0' {
+		return n, ErrInvalidNumber
+	}
+	n.Kind = PSTN
+	n.E164 = "+" + digits
+	n.Dial = n.E164
+	return n, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isDialable(s string) bool {
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r == '*' || r == '#' || r == '_' || r == '+':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,15 @@
+package request
+
+// APIKeyRequest creates an API key. Keys with a domain_uuid only reach the
+// data of that tenant.
+type APIKeyRequest struct {
+	Name       string `json:"name" binding:"required,max=128"`
+	Role       string `json:"role" binding:"required,oneof=admin supervisor"`
+	DomainUUID string `json:"domain_uuid" binding:"omitempty,uuid"`
+}
+
+// SignedURLRequest asks for a short-lived URL of a GET endpoint, for
+// clients such as audio elements that cannot send an API key header.
+type SignedURLRequest struct {
+	Path string `json:"path" binding:"required,startswith=/,max=512,excludesall=?#"`
+}
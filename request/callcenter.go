@@ -0,0 +1,14 @@
+package request
+
+// AgentStatusRequest sets an agent's status: Available, Available (On
+// Demand), On Break or Logged Out.
+type AgentStatusRequest struct {
+	Status string `json:"status" binding:"required,max=32"`
+}
+
+// TierRequest puts an agent in a queue. Level and position default to 1.
+type TierRequest struct {
+	Agent    string `json:"agent" binding:"required,max=255"`
+	Level    int    `json:"level" binding:"omitempty,min=1,max=100"`
+	Position int    `json:"position" binding:"omitempty,min=1,max=100"`
+}
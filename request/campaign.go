@@ -0,0 +1,25 @@
+package request
+
+// CampaignRequest creates or replaces an outbound campaign. Progressive
+// campaigns bridge each answered contact to the agent reserved for it; power
+// campaigns over-dial and send answered contacts to Destination (usually a
+// queue), so Destination is required for them. With AMD set, contacts are
+// only bridged once answering machine detection allows it.
+type CampaignRequest struct {
+	DomainUUID        string           `json:"domain_uuid" binding:"omitempty,uuid"`
+	Name              string           `json:"name" binding:"required,max=128"`
+	Mode              string           `json:"mode" binding:"required,oneof=progressive power"`
+	Agents            []string         `json:"agents" binding:"required,min=1,max=500,dive,dialstring"`
+	Destination       *CallDestination `json:"destination"`
+	CallerIDName      string           `json:"caller_id_name" binding:"omitempty,max=64"`
+	CallerIDNumber    string           `json:"caller_id_number" binding:"omitempty,max=32,dialstring"`
+	DialTimeout       int              `json:"dial_timeout" binding:"omitempty,min=5,max=120"`
+	MaxDialRatio      float64          `json:"max_dial_ratio" binding:"omitempty,min=1,max=5"`
+	MaxAttempts       int              `json:"max_attempts" binding:"omitempty,min=1,max=20"`
+	RetryDelaySeconds int              `json:"retry_delay_seconds" binding:"omitempty,min=60"`
+	RetryCauses       []string         `json:"retry_causes" binding:"omitempty,max=20,dive,uppercase,max=64"`
+	Timezone          string           `json:"timezone" binding:"omitempty,max=64"`
+	WindowStart       string           `json:"window_start" binding:"omitempty,datetime=15:04"`
+	WindowEnd         string           `json:"window_end" binding:"omitempty,datetime=15:04"`
+	AMD               *CallAMD         `json:"amd"`
+}
@@ -0,0 +1,15 @@
+package request
+
+// ConferenceDialRequest calls Number and puts it in the room once it
+// answers. The call is checked and routed like POST /call.
+type ConferenceDialRequest struct {
+	DomainUUID       string            `json:"domain_uuid" binding:"omitempty,uuid"`
+	Number           string            `json:"number" binding:"required,dialnumber"`
+	Profile          string            `json:"profile" binding:"omitempty,max=64"`
+	CallerIDName     string            `json:"caller_id_name" binding:"omitempty,max=64"`
+	CallerIDNumber   string            `json:"caller_id_number" binding:"omitempty,max=32,dialstring"`
+	Timeout          int               `json:"timeout" binding:"omitempty,min=1,max=300"`
+	Variables        map[string]string `json:"variables" binding:"omitempty,max=32,dive,keys,chanvar,endkeys,max=256"`
+	IgnoreEarlyMedia bool              `json:"ignore_early_media"`
+	AutoAnswer       bool              `json:"auto_answer"`
+}
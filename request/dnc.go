@@ -0,0 +1,10 @@
+package request
+
+// DNCEntryRequest adds or replaces a do-not-call entry. Entries without a
+// domain_uuid apply to every tenant.
+type DNCEntryRequest struct {
+	DomainUUID string `json:"domain_uuid" binding:"omitempty,uuid"`
+	MatchType  string `json:"match_type" binding:"omitempty,oneof=exact prefix regex"`
+	Pattern    string `json:"pattern" binding:"required,max=128"`
+	Reason     string `json:"reason" binding:"max=256"`
+}
@@ -0,0 +1,6 @@
+package request
+
+type DTMFRequest struct {
+	Digits     string `json:"digits" binding:"required,max=128"`
+	DurationMS int    `json:"duration_ms" binding:"omitempty,min=40,max=2000"`
+}
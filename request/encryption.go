@@ -0,0 +1,7 @@
+package request
+
+// RecordingKeyRotateRequest rotates the data key of a tenant, or the key of
+// recordings without a tenant when domain_uuid is empty.
+type RecordingKeyRotateRequest struct {
+	DomainUUID string `json:"domain_uuid" binding:"omitempty,uuid"`
+}
@@ -0,0 +1,33 @@
+package request
+
+// ExtensionRequest creates or replaces a FusionPBX extension. A password is
+// generated when none is given; on update an empty password keeps the
+// current one. The tenant of an extension cannot change.
+type ExtensionRequest struct {
+	DomainUUID              string              `json:"domain_uuid" binding:"omitempty,uuid"`
+	Extension               string              `json:"extension" binding:"required,max=32,dialstring"`
+	NumberAlias             string              `json:"number_alias" binding:"omitempty,max=32,dialstring"`
+	Password                string              `json:"password" binding:"omitempty,min=8,max=64,printascii"`
+	EffectiveCallerIDName   string              `json:"effective_caller_id_name" binding:"max=64"`
+	EffectiveCallerIDNumber string              `json:"effective_caller_id_number" binding:"omitempty,max=32,dialstring"`
+	OutboundCallerIDName    string              `json:"outbound_caller_id_name" binding:"max=64"`
+	OutboundCallerIDNumber  string              `json:"outbound_caller_id_number" binding:"omitempty,max=32,dialstring"`
+	DirectoryFirstName      string              `json:"directory_first_name" binding:"max=64"`
+	DirectoryLastName       string              `json:"directory_last_name" binding:"max=64"`
+	UserContext             string              `json:"user_context" binding:"omitempty,max=128,dialstring"`
+	CallTimeout             int                 `json:"call_timeout" binding:"omitempty,min=1,max=300"`
+	Description             string              `json:"description" binding:"max=256"`
+	Enabled                 *bool               `json:"enabled"`
+	Voicemail               *ExtensionVoicemail `json:"voicemail"`
+}
+
+// ExtensionVoicemail sets up the voicemail box of an extension. A PIN is
+// generated when none is given for a new box.
+type ExtensionVoicemail struct {
+	Enabled     *bool  `json:"enabled"`
+	Password    string `json:"password" binding:"omitempty,min=4,max=16,numeric"`
+	MailTo      string `json:"mail_to" binding:"omitempty,email,max=256"`
+	AttachFile  bool   `json:"attach_file"`
+	KeepLocal   *bool  `json:"keep_local"`
+	Description string `json:"description" binding:"max=256"`
+}
@@ -0,0 +1,27 @@
+package request
+
+// FraudRuleRequest creates or replaces a fraud rule. Which thresholds apply
+// depends on Kind; rules without a domain_uuid watch every tenant.
+type FraudRuleRequest struct {
+	DomainUUID    string   `json:"domain_uuid" binding:"omitempty,uuid"`
+	Name          string   `json:"name" binding:"required,max=128"`
+	Kind          string   `json:"kind" binding:"required,oneof=high_cost concurrency after_hours short_calls"`
+	Prefixes      []string `json:"prefixes" binding:"omitempty,max=256,dive,max=16"`
+	Threshold     int      `json:"threshold" binding:"min=0"`
+	WindowSeconds int      `json:"window_seconds" binding:"min=0,max=86400"`
+	MaxDuration   int      `json:"max_duration" binding:"min=0,max=3600"`
+	BusinessStart string   `json:"business_start" binding:"omitempty,datetime=15:04"`
+	BusinessEnd   string   `json:"business_end" binding:"omitempty,datetime=15:04"`
+	BusinessDays  []int64  `json:"business_days" binding:"omitempty,max=7,dive,min=0,max=6"`
+	Timezone      string   `json:"timezone" binding:"omitempty,max=64"`
+	Actions       []string `json:"actions" binding:"omitempty,max=3,dive,oneof=alert block kill"`
+	WebhookURL    string   `json:"webhook_url" binding:"omitempty,url,max=512"`
+	Enabled       *bool    `json:"enabled"`
+}
+
+// FraudBlockRequest blocks an extension by hand.
+type FraudBlockRequest struct {
+	DomainUUID string `json:"domain_uuid" binding:"omitempty,uuid"`
+	Extension  string `json:"extension" binding:"required,max=32,dialstring"`
+	Reason     string `json:"reason" binding:"max=256"`
+}
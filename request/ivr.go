@@ -0,0 +1,27 @@
+package request
+
+// PlayRequest plays a file, or speaks Text, on a live call. Leg picks who
+// hears a file: aleg, bleg or both. Text is always spoken to the channel
+// itself.
+type PlayRequest struct {
+	File   string `json:"file" binding:"required_without=Text,omitempty,max=512"`
+	Text   string `json:"text" binding:"required_without=File,omitempty,max=1024"`
+	Engine string `json:"engine" binding:"omitempty,max=32"`
+	Voice  string `json:"voice" binding:"omitempty,max=64"`
+	Leg    string `json:"leg" binding:"omitempty,oneof=aleg bleg both"`
+}
+
+// GatherRequest plays File and collects between MinDigits and MaxDigits
+// digits with play_and_get_digits. The result is posted to CallbackURL.
+type GatherRequest struct {
+	File           string `json:"file" binding:"required,max=512"`
+	InvalidFile    string `json:"invalid_file" binding:"omitempty,max=512"`
+	MinDigits      int    `json:"min_digits" binding:"omitempty,min=1,max=128"`
+	MaxDigits      int    `json:"max_digits" binding:"omitempty,min=1,max=128"`
+	Tries          int    `json:"tries" binding:"omitempty,min=1,max=10"`
+	TimeoutMS      int    `json:"timeout_ms" binding:"omitempty,min=500,max=60000"`
+	DigitTimeoutMS int    `json:"digit_timeout_ms" binding:"omitempty,min=500,max=60000"`
+	Terminators    string `json:"terminators" binding:"omitempty,max=4"`
+	DigitRegex     string `json:"digit_regex" binding:"omitempty,max=64"`
+	CallbackURL    string `json:"callback_url" binding:"omitempty,url,max=512"`
+}
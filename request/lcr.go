@@ -0,0 +1,20 @@
+package request
+
+type GatewayRequest struct {
+	Name        string `json:"name" binding:"required,max=64,dialstring"`
+	Enabled     *bool  `json:"enabled"`
+	Description string `json:"description" binding:"max=256"`
+}
+
+// RouteRequest adds a prefix to the rate table of a gateway. The prefix is
+// matched against the E.164 number without "+"; an empty prefix matches
+// every number. Routes without a domain_uuid apply to every tenant.
+type RouteRequest struct {
+	GatewayUUID string  `json:"gateway_uuid" binding:"required,uuid"`
+	DomainUUID  string  `json:"domain_uuid" binding:"omitempty,uuid"`
+	Prefix      string  `json:"prefix" binding:"max=16"`
+	Rate        float64 `json:"rate" binding:"min=0"`
+	Priority    int     `json:"priority"`
+	Enabled     *bool   `json:"enabled"`
+	Description string  `json:"description" binding:"max=256"`
+}
@@ -0,0 +1,19 @@
+package request
+
+// MonitorRequest rings a supervisor's extension into an existing call.
+type MonitorRequest struct {
+	Supervisor   string `json:"supervisor" binding:"required,dialnumber"`
+	Mode         string `json:"mode" binding:"required,oneof=listen whisper barge"`
+	CallerIDName string `json:"caller_id_name" binding:"omitempty,max=64"`
+	Timeout      int    `json:"timeout" binding:"omitempty,min=1,max=300"`
+	AutoAnswer   bool   `json:"auto_answer"`
+}
+
+// MonitorGroupRequest lets Supervisors monitor the calls of Agents. Both
+// are extensions of the tenant.
+type MonitorGroupRequest struct {
+	DomainUUID  string   `json:"domain_uuid" binding:"omitempty,uuid"`
+	Name        string   `json:"name" binding:"required,max=128"`
+	Supervisors []string `json:"supervisors" binding:"required,min=1,dive,dialnumber"`
+	Agents      []string `json:"agents" binding:"required,min=1,dive,dialnumber"`
+}
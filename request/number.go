@@ -0,0 +1,20 @@
+package request
+
+type NumberSettingsRequest struct {
+	CountryCode         string `json:"country_code" binding:"required,numeric,max=3"`
+	NationalPrefix      string `json:"national_prefix" binding:"omitempty,numeric,max=4"`
+	InternationalPrefix string `json:"international_prefix" binding:"omitempty,numeric,max=4"`
+	ExtensionMaxLength  int    `json:"extension_max_length" binding:"omitempty,min=1,max=10"`
+}
+
+// TranslationRuleRequest rewrites numbers before routing. PSTN rules match
+// the E.164 form including "+"; extension rules match the extension.
+type TranslationRuleRequest struct {
+	DomainUUID  string `json:"domain_uuid" binding:"omitempty,uuid"`
+	AppliesTo   string `json:"applies_to" binding:"omitempty,oneof=pstn extension"`
+	Match       string `json:"match" binding:"max=32"`
+	Strip       int    `json:"strip" binding:"min=0,max=16"`
+	Prepend     string `json:"prepend" binding:"omitempty,max=16,dialstring"`
+	Priority    int    `json:"priority"`
+	Description string `json:"description" binding:"max=256"`
+}
@@ -0,0 +1,18 @@
+package request
+
+// PrepaidAccountRequest enables or updates prepaid billing for a tenant.
+// The balance only changes through transactions.
+type PrepaidAccountRequest struct {
+	Currency       string `json:"currency" binding:"omitempty,len=3,uppercase"`
+	Enabled        *bool  `json:"enabled"`
+	MaxCallSeconds int    `json:"max_call_seconds" binding:"omitempty,min=60,max=86400"`
+}
+
+// PrepaidTransactionRequest credits (positive amount) or debits a prepaid
+// balance outside of calls, e.g. a top-up or a manual correction.
+type PrepaidTransactionRequest struct {
+	Kind        string  `json:"kind" binding:"omitempty,oneof=credit adjustment"`
+	Amount      float64 `json:"amount" binding:"required"`
+	Reference   string  `json:"reference" binding:"max=128"`
+	Description string  `json:"description" binding:"max=256"`
+}
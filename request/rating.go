@@ -0,0 +1,21 @@
+package request
+
+// RateDeckRequest creates or replaces a rate deck. Price decks rate what a
+// tenant is charged, cost decks what the carriers charge us. Decks without a
+// domain_uuid are the defaults for tenants that have none of their own.
+type RateDeckRequest struct {
+	Name        string `json:"name" binding:"required,max=128"`
+	Purpose     string `json:"purpose" binding:"omitempty,oneof=price cost"`
+	DomainUUID  string `json:"domain_uuid" binding:"omitempty,uuid"`
+	Currency    string `json:"currency" binding:"omitempty,len=3,uppercase"`
+	Timezone    string `json:"timezone" binding:"omitempty,max=64"`
+	Description string `json:"description" binding:"max=256"`
+}
+
+// RerateRequest clears the ratings of a period so the rating worker rates
+// those CDRs again, e.g. after a deck was corrected.
+type RerateRequest struct {
+	DomainUUID string `json:"domain_uuid" binding:"omitempty,uuid"`
+	From       string `json:"from" binding:"required"`
+	To         string `json:"to" binding:"required"`
+}
@@ -1,6 +1,58 @@
 package request
 
 type CallRequest struct {
-	Caller string `json:"caller" binding:"required"`
-	Callee string `json:"callee" binding:"required"`
+	DomainUUID       string            `json:"domain_uuid" binding:"omitempty,uuid"`
+	Caller           string            `json:"caller" binding:"required,dialnumber"`
+	Callee           string            `json:"callee" binding:"omitempty,dialnumber"`
+	Destination      *CallDestination  `json:"destination"`
+	CallerIDName     string            `json:"caller_id_name" binding:"omitempty,max=64"`
+	CallerIDNumber   string            `json:"caller_id_number" binding:"omitempty,max=32,dialstring"`
+	Timeout          int               `json:"timeout" binding:"omitempty,min=1,max=300"`
+	Variables        map[string]string `json:"variables" binding:"omitempty,max=32,dive,keys,chanvar,endkeys,max=256"`
+	IgnoreEarlyMedia bool              `json:"ignore_early_media"`
+	AutoAnswer       bool              `json:"auto_answer"`
+	AMD              *CallAMD          `json:"amd"`
+
+	// SystemVariables are set by the API itself, e.g. credit limits, and
+	// bypass the checks applied to client Variables.
+	SystemVariables map[string]string `json:"-"`
+
+	// PrepaidVariables are the prepaid limits set by the call checks. They
+	// go on the leg that reaches the external number, which is the leg
+	// tracked and charged.
+	PrepaidVariables map[string]string `json:"-"`
+}
+
+// CallDestination describes where the originated leg is sent once answered.
+// Only the fields relevant to Type are read.
+type CallDestination struct {
+	Type       string `json:"type" binding:"required,oneof=user extension ivr conference playback speak"`
+	User       string `json:"user" binding:"omitempty,dialnumber"`
+	Extension  string `json:"extension" binding:"omitempty,dialstring"`
+	Dialplan   string `json:"dialplan" binding:"omitempty,oneof=XML inline"`
+	Context    string `json:"context" binding:"omitempty,dialstring"`
+	Menu       string `json:"menu" binding:"omitempty,max=128"`
+	Conference string `json:"conference" binding:"omitempty,max=128"`
+	Profile    string `json:"profile" binding:"omitempty,max=64"`
+	File       string `json:"file" binding:"omitempty,max=512"`
+	Text       string `json:"text" binding:"omitempty,max=1024"`
+	Engine     string `json:"engine" binding:"omitempty,max=32"`
+	Voice      string `json:"voice" binding:"omitempty,max=64"`
+}
+
+// CallAMD turns on answering machine detection for the originated leg.
+// Once answered the leg is parked until detection is done, then the action
+// for the outcome runs. Outcomes left out bridge humans and unknowns to the
+// destination and hang up on machines.
+type CallAMD struct {
+	OnHuman   *AMDAction `json:"on_human"`
+	OnMachine *AMDAction `json:"on_machine"`
+	OnUnknown *AMDAction `json:"on_unknown"`
+}
+
+// AMDAction is what happens to a call once its outcome is known. play
+// waits for the beep, plays File and hangs up.
+type AMDAction struct {
+	Action string `json:"action" binding:"required,oneof=bridge play hangup"`
+	File   string `json:"file" binding:"omitempty,max=512"`
 }
@@ -0,0 +1,25 @@
+package request
+
+import "time"
+
+// RetentionPolicyRequest creates or replaces a retention policy. Policies
+// without a domain_uuid apply to every tenant and policies without a
+// direction to every direction.
+type RetentionPolicyRequest struct {
+	DomainUUID  string `json:"domain_uuid" binding:"omitempty,uuid"`
+	Direction   string `json:"direction" binding:"omitempty,oneof=inbound outbound local"`
+	RetainDays  int    `json:"retain_days" binding:"required,min=1,max=36500"`
+	Action      string `json:"action" binding:"omitempty,oneof=delete archive"`
+	Description string `json:"description" binding:"max=256"`
+	Enabled     *bool  `json:"enabled"`
+}
+
+// LegalHoldRequest keeps recordings from being purged: one CDR, every call
+// to or from a number, or a whole tenant.
+type LegalHoldRequest struct {
+	DomainUUID string     `json:"domain_uuid" binding:"omitempty,uuid"`
+	XMLCDRUUID string     `json:"xml_cdr_uuid" binding:"omitempty,uuid"`
+	Number     string     `json:"number" binding:"omitempty,max=32,dialnumber"`
+	Reason     string     `json:"reason" binding:"required,max=512"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
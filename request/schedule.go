@@ -0,0 +1,14 @@
+package request
+
+import "time"
+
+// ScheduleRequest creates or replaces a scheduled call. Exactly one of RunAt
+// (one-shot) or Cron (recurring, five field syntax) must be set.
+type ScheduleRequest struct {
+	Name     string      `json:"name" binding:"max=128"`
+	Call     CallRequest `json:"call"`
+	RunAt    *time.Time  `json:"run_at"`
+	Cron     string      `json:"cron" binding:"max=128"`
+	Timezone string      `json:"timezone" binding:"max=64"`
+	Enabled  *bool       `json:"enabled"`
+}
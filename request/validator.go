@@ -0,0 +1,32 @@
+package request
+
+import (
+	"regexp"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	dialStringPattern = regexp.MustCompile(`^[A-Za-z0-9+*#._-]+$`)
+	// Numbers may carry formatting such as "+1 (555) 010-0000"; they are
+	// normalized before they reach a dial string.
+	dialNumberPattern = regexp.MustCompile(`^[A-Za-z0-9+*#._() /-]{1,40}$`)
+	chanVarPattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}$`)
+)
+
+// RegisterValidators adds the custom binding tags used by the request DTOs.
+func RegisterValidators(v *validator.Validate) error {
+	if err := v.RegisterValidation("dialstring", func(fl validator.FieldLevel) bool {
+		return dialStringPattern.MatchString(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+	if err := v.RegisterValidation("dialnumber", func(fl validator.FieldLevel) bool {
+		return dialNumberPattern.MatchString(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+	return v.RegisterValidation("chanvar", func(fl validator.FieldLevel) bool {
+		return chanVarPattern.MatchString(fl.Field().String())
+	})
+}
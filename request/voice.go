@@ -0,0 +1,12 @@
+package request
+
+// VoiceAppRequest points calls to Number at a webhook returning verbs.
+// FallbackURL is asked when URL fails.
+type VoiceAppRequest struct {
+	DomainUUID  string `json:"domain_uuid" binding:"omitempty,uuid"`
+	Number      string `json:"number" binding:"required,max=32,dialstring"`
+	URL         string `json:"url" binding:"required,url,max=512"`
+	FallbackURL string `json:"fallback_url" binding:"omitempty,url,max=512"`
+	Description string `json:"description" binding:"max=256"`
+	Enabled     *bool  `json:"enabled"`
+}
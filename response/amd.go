@@ -0,0 +1,16 @@
+package response
+
+import "time"
+
+type AMDResponse struct {
+	CallUUID   string     `json:"call_uuid"`
+	DomainUUID string     `json:"domain_uuid"`
+	Status     string     `json:"status"`
+	Result     string     `json:"result,omitempty"`
+	Beep       bool       `json:"beep"`
+	Action     string     `json:"action,omitempty"`
+	Detail     string     `json:"detail,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	DetectedAt *time.Time `json:"detected_at"`
+	EndedAt    *time.Time `json:"ended_at"`
+}
@@ -0,0 +1,22 @@
+package response
+
+import "time"
+
+// APIKeyResponse describes an API key. Key is only set in the response to
+// its creation.
+type APIKeyResponse struct {
+	KeyUUID    string     `json:"key_uuid"`
+	Name       string     `json:"name"`
+	KeyPrefix  string     `json:"key_prefix"`
+	Role       string     `json:"role"`
+	DomainUUID string     `json:"domain_uuid"`
+	Enabled    bool       `json:"enabled"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Key        string     `json:"key,omitempty"`
+}
+
+type SignedURLResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,71 @@
+package response
+
+import "time"
+
+type CallCenterAgentResponse struct {
+	Name             string     `json:"name"`
+	Type             string     `json:"type"`
+	Contact          string     `json:"contact"`
+	Status           string     `json:"status"`
+	State            string     `json:"state"`
+	LastStatusChange *time.Time `json:"last_status_change"`
+	NoAnswerCount    int        `json:"no_answer_count"`
+	CallsAnswered    int        `json:"calls_answered"`
+	TalkTime         int        `json:"talk_time"`
+}
+
+// CallCenterMemberResponse is a caller in a queue. The cc_* fields match
+// the ones on CDRResponse, so a member can be found in the CDRs afterwards.
+type CallCenterMemberResponse struct {
+	CCQueue             string    `json:"cc_queue"`
+	CCMemberUUID        string    `json:"cc_member_uuid"`
+	CCMemberSessionUUID string    `json:"cc_member_session_uuid"`
+	CallerIDNumber      string    `json:"caller_id_number"`
+	CallerIDName        string    `json:"caller_id_name"`
+	CCQueueJoinedEpoch  int64     `json:"cc_queue_joined_epoch"`
+	Joined              time.Time `json:"joined"`
+	WaitSeconds         int       `json:"wait_seconds"`
+	State               string    `json:"state"`
+	CCAgent             string    `json:"cc_agent"`
+}
+
+type CallCenterTierResponse struct {
+	Queue    string `json:"queue"`
+	Agent    string `json:"agent"`
+	State    string `json:"state"`
+	Level    int    `json:"level"`
+	Position int    `json:"position"`
+}
+
+type CallCenterStatsResponse struct {
+	Queue           string    `json:"queue"`
+	Waiting         int       `json:"waiting"`
+	LongestWait     int       `json:"longest_wait"`
+	AgentsAvailable int       `json:"agents_available"`
+	AgentsOnBreak   int       `json:"agents_on_break"`
+	AgentsLoggedOut int       `json:"agents_logged_out"`
+	AgentsOnCall    int       `json:"agents_on_call"`
+	Offered         int       `json:"offered"`
+	Answered        int       `json:"answered"`
+	Abandoned       int       `json:"abandoned"`
+	AverageWait     float64   `json:"average_wait"`
+	MaxAnsweredWait int       `json:"max_answered_wait"`
+	Since           time.Time `json:"since"`
+}
+
+// WallboardResponse is one wallboard update. Answered, abandoned, the
+// service level and the average wait count since Since, local midnight.
+type WallboardResponse struct {
+	Queue        string         `json:"queue,omitempty"`
+	DomainUUID   string         `json:"domain_uuid,omitempty"`
+	Time         time.Time      `json:"time"`
+	Waiting      int            `json:"waiting"`
+	LongestWait  int            `json:"longest_wait"`
+	Agents       map[string]int `json:"agents"`
+	Answered     int            `json:"answered"`
+	Abandoned    int            `json:"abandoned"`
+	AnsweredInSL int            `json:"answered_in_sl"`
+	ServiceLevel float64        `json:"service_level"`
+	AverageWait  float64        `json:"average_wait"`
+	Since        time.Time      `json:"since"`
+}
@@ -0,0 +1,48 @@
+package response
+
+import (
+	"time"
+
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+type CampaignResponse struct {
+	CampaignUUID      string                   `json:"campaign_uuid"`
+	DomainUUID        string                   `json:"domain_uuid"`
+	Name              string                   `json:"name"`
+	Mode              string                   `json:"mode"`
+	Status            string                   `json:"status"`
+	Agents            []string                 `json:"agents"`
+	Destination       *request.CallDestination `json:"destination"`
+	CallerIDName      string                   `json:"caller_id_name"`
+	CallerIDNumber    string                   `json:"caller_id_number"`
+	DialTimeout       int                      `json:"dial_timeout"`
+	MaxDialRatio      float64                  `json:"max_dial_ratio"`
+	MaxAttempts       int                      `json:"max_attempts"`
+	RetryDelaySeconds int                      `json:"retry_delay_seconds"`
+	RetryCauses       []string                 `json:"retry_causes"`
+	Timezone          string                   `json:"timezone"`
+	WindowStart       string                   `json:"window_start"`
+	WindowEnd         string                   `json:"window_end"`
+	AMD               *request.CallAMD         `json:"amd"`
+	Contacts          map[string]int           `json:"contacts,omitempty"`
+	CreatedAt         time.Time                `json:"created_at"`
+	UpdatedAt         time.Time                `json:"updated_at"`
+}
+
+type CampaignContactResponse struct {
+	ContactUUID     string            `json:"contact_uuid"`
+	PhoneNumber     string            `json:"phone_number"`
+	Name            string            `json:"name"`
+	Timezone        string            `json:"timezone"`
+	Variables       map[string]string `json:"variables"`
+	Status          string            `json:"status"`
+	Attempts        int               `json:"attempts"`
+	NextAttemptAt   *time.Time        `json:"next_attempt_at"`
+	LastAttemptAt   *time.Time        `json:"last_attempt_at"`
+	LastCallUUID    string            `json:"last_call_uuid"`
+	LastHangupCause string            `json:"last_hangup_cause"`
+	LastAgent       string            `json:"last_agent"`
+	BillSec         int               `json:"billsec"`
+	AMDResult       string            `json:"amd_result"`
+}
@@ -0,0 +1,30 @@
+package response
+
+import "time"
+
+type ConferenceResponse struct {
+	Name        string                     `json:"name"`
+	UUID        string                     `json:"uuid"`
+	DomainUUID  string                     `json:"domain_uuid"`
+	Locked      bool                       `json:"locked"`
+	Recording   bool                       `json:"recording"`
+	Recordings  []string                   `json:"recordings"`
+	Floor       int                        `json:"floor"`
+	Started     time.Time                  `json:"started"`
+	MemberCount int                        `json:"member_count"`
+	Members     []ConferenceMemberResponse `json:"members"`
+}
+
+type ConferenceMemberResponse struct {
+	ID             int       `json:"id"`
+	CallUUID       string    `json:"call_uuid"`
+	DomainUUID     string    `json:"domain_uuid"`
+	CallerIDName   string    `json:"caller_id_name"`
+	CallerIDNumber string    `json:"caller_id_number"`
+	Muted          bool      `json:"muted"`
+	Deaf           bool      `json:"deaf"`
+	Talking        bool      `json:"talking"`
+	Floor          bool      `json:"floor"`
+	Moderator      bool      `json:"moderator"`
+	Joined         time.Time `json:"joined"`
+}
@@ -0,0 +1,23 @@
+package response
+
+import "time"
+
+type DNCEntryResponse struct {
+	DNCEntryUUID string    `json:"dnc_entry_uuid"`
+	DomainUUID   string    `json:"domain_uuid"`
+	MatchType    string    `json:"match_type"`
+	Pattern      string    `json:"pattern"`
+	Reason       string    `json:"reason"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type DNCAuditResponse struct {
+	DNCAuditUUID string    `json:"dnc_audit_uuid"`
+	DomainUUID   string    `json:"domain_uuid"`
+	Caller       string    `json:"caller"`
+	Callee       string    `json:"callee"`
+	Number       string    `json:"number"`
+	DNCEntryUUID string    `json:"dnc_entry_uuid"`
+	Reason       string    `json:"reason"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package response
+
+import "time"
+
+type DTMFEventResponse struct {
+	CallUUID     string    `json:"call_uuid"`
+	DomainUUID   string    `json:"domain_uuid"`
+	Digit        string    `json:"digit"`
+	DurationMS   int       `json:"duration_ms"`
+	Source       string    `json:"source"`
+	Seq          int       `json:"seq"`
+	DigitsDialed string    `json:"digits_dialed"`
+	Time         time.Time `json:"time"`
+}
+
+// CallDigitsResponse is the digits a call has received so far. DigitsDialed
+// is named after the CDR field it ends up in.
+type CallDigitsResponse struct {
+	CallUUID     string              `json:"call_uuid"`
+	DomainUUID   string              `json:"domain_uuid"`
+	DigitsDialed string              `json:"digits_dialed"`
+	Ended        bool                `json:"ended"`
+	Events       []DTMFEventResponse `json:"events"`
+}
@@ -0,0 +1,14 @@
+package response
+
+import "time"
+
+type RecordingKeyResponse struct {
+	KeyUUID     string     `json:"key_uuid"`
+	DomainUUID  string     `json:"domain_uuid"`
+	MasterKeyID string     `json:"master_key_id"`
+	Active      bool       `json:"active"`
+	Objects     int        `json:"objects"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RewrappedAt *time.Time `json:"rewrapped_at"`
+	RetiredAt   *time.Time `json:"retired_at"`
+}
@@ -0,0 +1,36 @@
+package response
+
+import "time"
+
+// ExtensionResponse describes an extension. Password and the voicemail
+// password are only set in the responses that create or reset them.
+type ExtensionResponse struct {
+	ExtensionUUID           string                      `json:"extension_uuid"`
+	DomainUUID              string                      `json:"domain_uuid"`
+	DomainName              string                      `json:"domain_name"`
+	Extension               string                      `json:"extension"`
+	NumberAlias             string                      `json:"number_alias"`
+	EffectiveCallerIDName   string                      `json:"effective_caller_id_name"`
+	EffectiveCallerIDNumber string                      `json:"effective_caller_id_number"`
+	OutboundCallerIDName    string                      `json:"outbound_caller_id_name"`
+	OutboundCallerIDNumber  string                      `json:"outbound_caller_id_number"`
+	DirectoryFirstName      string                      `json:"directory_first_name"`
+	DirectoryLastName       string                      `json:"directory_last_name"`
+	UserContext             string                      `json:"user_context"`
+	CallTimeout             int                         `json:"call_timeout"`
+	Description             string                      `json:"description"`
+	Enabled                 bool                        `json:"enabled"`
+	Voicemail               *ExtensionVoicemailResponse `json:"voicemail"`
+	CreatedAt               *time.Time                  `json:"created_at"`
+	UpdatedAt               *time.Time                  `json:"updated_at"`
+	Password                string                      `json:"password,omitempty"`
+}
+
+type ExtensionVoicemailResponse struct {
+	VoicemailUUID string `json:"voicemail_uuid"`
+	Enabled       bool   `json:"enabled"`
+	MailTo        string `json:"mail_to"`
+	AttachFile    bool   `json:"attach_file"`
+	KeepLocal     bool   `json:"keep_local"`
+	Password      string `json:"password,omitempty"`
+}
@@ -0,0 +1,45 @@
+package response
+
+import "time"
+
+type FraudRuleResponse struct {
+	RuleUUID      string    `json:"rule_uuid"`
+	DomainUUID    string    `json:"domain_uuid"`
+	Name          string    `json:"name"`
+	Kind          string    `json:"kind"`
+	Prefixes      []string  `json:"prefixes"`
+	Threshold     int       `json:"threshold"`
+	WindowSeconds int       `json:"window_seconds"`
+	MaxDuration   int       `json:"max_duration"`
+	BusinessStart string    `json:"business_start"`
+	BusinessEnd   string    `json:"business_end"`
+	BusinessDays  []int64   `json:"business_days"`
+	Timezone      string    `json:"timezone"`
+	Actions       []string  `json:"actions"`
+	WebhookURL    string    `json:"webhook_url"`
+	Enabled       bool      `json:"enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type FraudEventResponse struct {
+	EventUUID  string    `json:"event_uuid"`
+	RuleUUID   string    `json:"rule_uuid"`
+	DomainUUID string    `json:"domain_uuid"`
+	Kind       string    `json:"kind"`
+	Extension  string    `json:"extension"`
+	CallUUID   string    `json:"call_uuid"`
+	Number     string    `json:"number"`
+	Detail     string    `json:"detail"`
+	Actions    []string  `json:"actions"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type FraudBlockResponse struct {
+	BlockUUID  string    `json:"block_uuid"`
+	DomainUUID string    `json:"domain_uuid"`
+	Extension  string    `json:"extension"`
+	RuleUUID   string    `json:"rule_uuid"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
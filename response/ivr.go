@@ -0,0 +1,12 @@
+package response
+
+import "time"
+
+type GatherResponse struct {
+	GatherUUID  string     `json:"gather_uuid"`
+	CallUUID    string     `json:"call_uuid"`
+	Status      string     `json:"status"`
+	Digits      string     `json:"digits"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
@@ -0,0 +1,43 @@
+package response
+
+import "time"
+
+type GatewayResponse struct {
+	GatewayUUID string    `json:"gateway_uuid"`
+	Name        string    `json:"name"`
+	Enabled     bool      `json:"enabled"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RouteResponse struct {
+	RouteUUID   string    `json:"route_uuid"`
+	GatewayUUID string    `json:"gateway_uuid"`
+	GatewayName string    `json:"gateway_name"`
+	DomainUUID  string    `json:"domain_uuid"`
+	Prefix      string    `json:"prefix"`
+	Rate        float64   `json:"rate"`
+	Priority    int       `json:"priority"`
+	Enabled     bool      `json:"enabled"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type CallRouteResponse struct {
+	LegUUID         string    `json:"leg_uuid"`
+	CallUUID        string    `json:"call_uuid"`
+	DomainUUID      string    `json:"domain_uuid"`
+	Number          string    `json:"number"`
+	RouteUUID       string    `json:"route_uuid"`
+	Gateway         string    `json:"gateway"`
+	Prefix          string    `json:"prefix"`
+	Rate            float64   `json:"rate"`
+	Attempt         int       `json:"attempt"`
+	HangupCause     string    `json:"hangup_cause"`
+	HangupCauseQ850 *int64    `json:"hangup_cause_q850"`
+	BillSec         int       `json:"billsec"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
@@ -0,0 +1,28 @@
+package response
+
+import "time"
+
+type MonitorSessionResponse struct {
+	SessionUUID string     `json:"session_uuid"`
+	CallUUID    string     `json:"call_uuid"`
+	DomainUUID  string     `json:"domain_uuid"`
+	Mode        string     `json:"mode"`
+	Supervisor  string     `json:"supervisor"`
+	KeyUUID     string     `json:"key_uuid"`
+	KeyName     string     `json:"key_name"`
+	Status      string     `json:"status"`
+	HangupCause string     `json:"hangup_cause"`
+	CreatedAt   time.Time  `json:"created_at"`
+	AnsweredAt  *time.Time `json:"answered_at"`
+	EndedAt     *time.Time `json:"ended_at"`
+}
+
+type MonitorGroupResponse struct {
+	GroupUUID   string    `json:"group_uuid"`
+	DomainUUID  string    `json:"domain_uuid"`
+	Name        string    `json:"name"`
+	Supervisors []string  `json:"supervisors"`
+	Agents      []string  `json:"agents"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
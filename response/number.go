@@ -0,0 +1,15 @@
+package response
+
+import "time"
+
+type TranslationRuleResponse struct {
+	RuleUUID    string    `json:"rule_uuid"`
+	DomainUUID  string    `json:"domain_uuid"`
+	AppliesTo   string    `json:"applies_to"`
+	Match       string    `json:"match"`
+	Strip       int       `json:"strip"`
+	Prepend     string    `json:"prepend"`
+	Priority    int       `json:"priority"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,25 @@
+package response
+
+import "time"
+
+type PrepaidAccountResponse struct {
+	DomainUUID     string    `json:"domain_uuid"`
+	Balance        float64   `json:"balance"`
+	Currency       string    `json:"currency"`
+	Enabled        bool      `json:"enabled"`
+	MaxCallSeconds int       `json:"max_call_seconds"`
+	ActiveCalls    int       `json:"active_calls"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type LedgerEntryResponse struct {
+	EntryUUID    string    `json:"entry_uuid"`
+	DomainUUID   string    `json:"domain_uuid"`
+	Kind         string    `json:"kind"`
+	Amount       float64   `json:"amount"`
+	BalanceAfter float64   `json:"balance_after"`
+	Reference    string    `json:"reference"`
+	Description  string    `json:"description"`
+	CreatedAt    time.Time `json:"created_at"`
+}
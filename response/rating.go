@@ -0,0 +1,70 @@
+package response
+
+import "time"
+
+type RateDeckResponse struct {
+	DeckUUID    string    `json:"deck_uuid"`
+	Name        string    `json:"name"`
+	Purpose     string    `json:"purpose"`
+	DomainUUID  string    `json:"domain_uuid"`
+	Currency    string    `json:"currency"`
+	Timezone    string    `json:"timezone"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RateResponse struct {
+	RateUUID         string    `json:"rate_uuid"`
+	DeckUUID         string    `json:"deck_uuid"`
+	Prefix           string    `json:"prefix"`
+	Destination      string    `json:"destination"`
+	ConnectFee       float64   `json:"connect_fee"`
+	Rate             float64   `json:"rate"`
+	InitialIncrement int       `json:"initial_increment"`
+	Increment        int       `json:"increment"`
+	BandStart        string    `json:"band_start"`
+	BandEnd          string    `json:"band_end"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type CDRRatingResponse struct {
+	XMLCDRUUID    string    `json:"xml_cdr_uuid"`
+	DomainUUID    string    `json:"domain_uuid"`
+	StartStamp    time.Time `json:"start_stamp"`
+	Number        string    `json:"number"`
+	Destination   string    `json:"destination"`
+	BillSec       int       `json:"billsec"`
+	BilledSeconds int       `json:"billed_seconds"`
+	PriceRateUUID string    `json:"price_rate_uuid"`
+	PricePrefix   string    `json:"price_prefix"`
+	Price         float64   `json:"price"`
+	CostRateUUID  string    `json:"cost_rate_uuid"`
+	Cost          float64   `json:"cost"`
+	Currency      string    `json:"currency"`
+	Status        string    `json:"status"`
+	RatedAt       time.Time `json:"rated_at"`
+}
+
+type InvoiceLineResponse struct {
+	Prefix        string  `json:"prefix"`
+	Destination   string  `json:"destination"`
+	Currency      string  `json:"currency"`
+	Calls         int     `json:"calls"`
+	BillSec       int     `json:"billsec"`
+	BilledMinutes float64 `json:"billed_minutes"`
+	Price         float64 `json:"price"`
+	Cost          float64 `json:"cost"`
+}
+
+type InvoiceResponse struct {
+	DomainUUID    string                `json:"domain_uuid"`
+	From          time.Time             `json:"from"`
+	To            time.Time             `json:"to"`
+	Lines         []InvoiceLineResponse `json:"lines"`
+	Calls         int                   `json:"calls"`
+	BilledMinutes float64               `json:"billed_minutes"`
+	Price         float64               `json:"price"`
+	Cost          float64               `json:"cost"`
+	UnratedCalls  int                   `json:"unrated_calls"`
+}
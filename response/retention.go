@@ -0,0 +1,54 @@
+package response
+
+import "time"
+
+type RetentionPolicyResponse struct {
+	PolicyUUID  string    `json:"policy_uuid"`
+	DomainUUID  string    `json:"domain_uuid"`
+	Direction   string    `json:"direction"`
+	RetainDays  int       `json:"retain_days"`
+	Action      string    `json:"action"`
+	Description string    `json:"description"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type LegalHoldResponse struct {
+	HoldUUID   string     `json:"hold_uuid"`
+	DomainUUID string     `json:"domain_uuid"`
+	XMLCDRUUID string     `json:"xml_cdr_uuid"`
+	Number     string     `json:"number"`
+	Reason     string     `json:"reason"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	ReleasedAt *time.Time `json:"released_at"`
+	Active     bool       `json:"active"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type RetentionPurgeResponse struct {
+	PurgeUUID   string    `json:"purge_uuid"`
+	XMLCDRUUID  string    `json:"xml_cdr_uuid"`
+	DomainUUID  string    `json:"domain_uuid"`
+	PolicyUUID  string    `json:"policy_uuid"`
+	Action      string    `json:"action"`
+	RecordPath  string    `json:"record_path"`
+	RecordName  string    `json:"record_name"`
+	Destination string    `json:"destination"`
+	Reason      string    `json:"reason"`
+	PurgedAt    time.Time `json:"purged_at"`
+}
+
+// RetentionCandidateResponse is one line of the dry-run report.
+type RetentionCandidateResponse struct {
+	XMLCDRUUID string    `json:"xml_cdr_uuid"`
+	DomainUUID string    `json:"domain_uuid"`
+	Direction  string    `json:"direction"`
+	StartStamp time.Time `json:"start_stamp"`
+	RecordPath string    `json:"record_path"`
+	RecordName string    `json:"record_name"`
+	PolicyUUID string    `json:"policy_uuid"`
+	RetainDays int       `json:"retain_days"`
+	Action     string    `json:"action"`
+	Reason     string    `json:"reason"`
+}
@@ -0,0 +1,25 @@
+package response
+
+import (
+	"time"
+
+	"github.com/vishaltalsaniya-7/voip-api/request"
+)
+
+type ScheduleResponse struct {
+	ScheduleUUID string              `json:"schedule_uuid"`
+	DomainUUID   string              `json:"domain_uuid"`
+	Name         string              `json:"name"`
+	Call         request.CallRequest `json:"call"`
+	RunAt        *time.Time          `json:"run_at"`
+	Cron         string              `json:"cron"`
+	Timezone     string              `json:"timezone"`
+	Enabled      bool                `json:"enabled"`
+	NextRunAt    *time.Time          `json:"next_run_at"`
+	LastRunAt    *time.Time          `json:"last_run_at"`
+	LastCallID   string              `json:"last_call_id"`
+	LastError    string              `json:"last_error"`
+	RunCount     int64               `json:"run_count"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+}
@@ -0,0 +1,39 @@
+package response
+
+import "time"
+
+type TranscriptionJobResponse struct {
+	JobUUID       string     `json:"job_uuid"`
+	XMLCDRUUID    string     `json:"xml_cdr_uuid"`
+	DomainUUID    string     `json:"domain_uuid"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	Engine        string     `json:"engine"`
+	Language      string     `json:"language"`
+	Error         string     `json:"error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at"`
+}
+
+// TranscriptionResponse is the transcription of a CDR and the job that
+// produced it, if any.
+type TranscriptionResponse struct {
+	XMLCDRUUID    string                    `json:"xml_cdr_uuid"`
+	Transcription string                    `json:"transcription"`
+	Job           *TranscriptionJobResponse `json:"job"`
+}
+
+// TranscriptHitResponse is one search result. Snippet marks the matches
+// with <mark> tags; the rest of the text is HTML-escaped.
+type TranscriptHitResponse struct {
+	XMLCDRUUID        string     `json:"xml_cdr_uuid"`
+	DomainUUID        string     `json:"domain_uuid"`
+	Direction         string     `json:"direction"`
+	CallerIDNumber    string     `json:"caller_id_number"`
+	DestinationNumber string     `json:"destination_number"`
+	StartStamp        *time.Time `json:"start_stamp"`
+	Rank              float64    `json:"rank"`
+	Snippet           string     `json:"snippet"`
+}
@@ -0,0 +1,15 @@
+package response
+
+import "time"
+
+type VoiceAppResponse struct {
+	AppUUID     string    `json:"app_uuid"`
+	DomainUUID  string    `json:"domain_uuid"`
+	Number      string    `json:"number"`
+	URL         string    `json:"url"`
+	FallbackURL string    `json:"fallback_url"`
+	Description string    `json:"description"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,30 @@
+package response
+
+import "time"
+
+type VoicemailBoxResponse struct {
+	VoicemailUUID string `json:"voicemail_uuid"`
+	DomainUUID    string `json:"domain_uuid"`
+	DomainName    string `json:"domain_name"`
+	VoicemailID   string `json:"voicemail_id"`
+	MailTo        string `json:"mail_to"`
+	Description   string `json:"description"`
+	Enabled       bool   `json:"enabled"`
+	NewMessages   int    `json:"new_messages"`
+	SavedMessages int    `json:"saved_messages"`
+}
+
+type VoicemailMessageResponse struct {
+	MessageUUID    string     `json:"message_uuid"`
+	VoicemailUUID  string     `json:"voicemail_uuid"`
+	DomainUUID     string     `json:"domain_uuid"`
+	VoicemailID    string     `json:"voicemail_id"`
+	CallerIDName   string     `json:"caller_id_name"`
+	CallerIDNumber string     `json:"caller_id_number"`
+	Length         int        `json:"length"`
+	Read           bool       `json:"read"`
+	Priority       string     `json:"priority"`
+	Transcription  string     `json:"transcription"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ReadAt         *time.Time `json:"read_at"`
+}
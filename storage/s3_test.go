@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testBucket    = "recordings"
+	testAccessKey = "minioadmin"
+	testSecretKey = "minioadmin-secret"
+	testRegion    = "us-east-1"
+)
+
+// fakeMinIO is a path-style S3 stand-in. Like MinIO it checks the SigV4
+// signature of every request and rejects uploads whose body does not match
+// the payload hash they were signed with.
+type fakeMinIO struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data     []byte
+	sum      string
+	modified time.Time
+}
+
+func newFakeMinIO(t *testing.T) (*fakeMinIO, *httptest.Server) {
+	t.Helper()
+	f := &fakeMinIO{objects: make(map[string]fakeObject)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeMinIO) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !verifySignature(r, testSecretKey) {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+testBucket+"/")
+	if !ok {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		hash := sha256.Sum256(data)
+		if hex.EncodeToString(hash[:]) != r.Header.Get("X-Amz-Content-Sha256") {
+			http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = fakeObject{data: data, sum: r.Header.Get("X-Amz-Meta-Sha256"), modified: time.Now()}
+	case http.MethodGet, http.MethodHead:
+		obj, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.Header().Set("Last-Modified", obj.modified.UTC().Format(http.TimeFormat))
+		w.Header().Set("X-Amz-Meta-Sha256", obj.sum)
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// verifySignature checks the SigV4 signature of a request signed in its
+// Authorization header or, for presigned URLs, in its query string.
+func verifySignature(r *http.Request, secret string) bool {
+	query := r.URL.Query()
+	var credential, signedHeaders, signature, amzDate, payloadHash string
+	if query.Get("X-Amz-Signature") != "" {
+		credential = query.Get("X-Amz-Credential")
+		signedHeaders = query.Get("X-Amz-SignedHeaders")
+		signature = query.Get("X-Amz-Signature")
+		amzDate = query.Get("X-Amz-Date")
+		payloadHash = unsignedPayload
+		query.Del("X-Amz-Signature")
+
+		date, err := time.Parse(amzDateFormat, amzDate)
+		expires, _ := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil || time.Since(date) > time.Duration(expires)*time.Second {
+			return false
+		}
+	} else {
+		auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ")
+		if !ok {
+			return false
+		}
+		for _, part := range strings.Split(auth, ", ") {
+			name, value, _ := strings.Cut(part, "=")
+			switch name {
+			case "Credential":
+				credential = value
+			case "SignedHeaders":
+				signedHeaders = value
+			case "Signature":
+				signature = value
+			}
+		}
+		amzDate = r.Header.Get("X-Amz-Date")
+		payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+	}
+
+	accessKey, scope, _ := strings.Cut(credential, "/")
+	if accessKey != testAccessKey {
+		return false
+	}
+
+	var headers strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonical := strings.Join([]string{
+		r.Method, r.URL.EscapedPath(), strings.ReplaceAll(query.Encode(), "+", "%20"), headers.String(),
+		signedHeaders, payloadHash,
+	}, "\n")
+
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	parts := strings.Split(scope, "/")
+	if len(parts) != 4 {
+		return false
+	}
+	key := []byte("AWS4" + secret)
+	for _, part := range parts {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	return hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(signature))
+}
+
+func newTestS3(t *testing.T, endpoint, secret string) *S3 {
+	t.Helper()
+	s, err := NewS3(S3Options{
+		Endpoint:  endpoint,
+		Region:    testRegion,
+		Bucket:    testBucket,
+		AccessKey: testAccessKey,
+		SecretKey: secret,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+	return s
+}
+
+func sha256Hex(data string) string {
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	_, srv := newFakeMinIO(t)
+	s := newTestS3(t, srv.URL, testSecretKey)
+	ctx := context.Background()
+
+	const key = "2026/10/19/call one.wav"
+	const body = "RIFF recording"
+	sum := sha256Hex(body)
+
+	if err := s.Put(ctx, key, strings.NewReader(body), int64(len(body)), sum); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size != int64(len(body)) || info.SHA256 != sum || info.ModTime.IsZero() {
+		t.Errorf("Stat = %+v, want size %d and sha256 %s", info, len(body), sum)
+	}
+
+	rc, err := s.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(got) != body {
+		t.Errorf("Open read %q, %v; want %q", got, err, body)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open after Delete = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat after Delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestS3RejectsCorruptUpload(t *testing.T) {
+	f, srv := newFakeMinIO(t)
+	s := newTestS3(t, srv.URL, testSecretKey)
+
+	const body = "RIFF recording"
+	err := s.Put(context.Background(), "corrupt.wav", strings.NewReader(body), int64(len(body)), sha256Hex("other"))
+	if err == nil {
+		t.Fatal("Put with a wrong checksum succeeded")
+	}
+	f.mu.Lock()
+	_, stored := f.objects["corrupt.wav"]
+	f.mu.Unlock()
+	if stored {
+		t.Error("corrupt upload was stored")
+	}
+}
+
+func TestS3WrongSecret(t *testing.T) {
+	_, srv := newFakeMinIO(t)
+	s := newTestS3(t, srv.URL, "wrong-secret")
+
+	const body = "RIFF recording"
+	if err := s.Put(context.Background(), "a.wav", strings.NewReader(body), int64(len(body)), sha256Hex(body)); err == nil {
+		t.Fatal("Put signed with the wrong secret succeeded")
+	}
+}
+
+func TestS3PresignGet(t *testing.T) {
+	_, srv := newFakeMinIO(t)
+	s := newTestS3(t, srv.URL, testSecretKey)
+
+	const key = "presigned/call.wav"
+	const body = "RIFF recording"
+	if err := s.Put(context.Background(), key, strings.NewReader(body), int64(len(body)), sha256Hex(body)); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	link, err := s.PresignGet(key, time.Minute)
+	if err != nil {
+		t.Fatalf("PresignGet: %v", err)
+	}
+	resp, err := http.Get(link)
+	if err != nil {
+		t.Fatalf("GET presigned URL: %v", err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(got) != body {
+		t.Errorf("presigned GET = %d %q, want 200 %q", resp.StatusCode, got, body)
+	}
+
+	// A tampered URL must not verify.
+	u, _ := url.Parse(link)
+	u.Path = "/" + testBucket + "/presigned/other.wav"
+	resp, err = http.Get(u.String())
+	if err != nil {
+		t.Fatalf("GET tampered URL: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("tampered presigned GET = %d, want 403", resp.StatusCode)
+	}
+}
+
+func TestS3InvalidKey(t *testing.T) {
+	s := newTestS3(t, "http://127.0.0.1:9000", testSecretKey)
+	for _, key := range []string{"", "/abs.wav", "../escape.wav", "a/../../b.wav", "a//b.wav"} {
+		if _, err := s.Open(context.Background(), key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Open(%q) = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
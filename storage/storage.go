@@ -0,0 +1,80 @@
+// Package storage keeps recording files in a local directory or an
+// S3-compatible bucket behind one interface.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/vishaltalsaniya-7/voip-api/config"
+)
+
+var (
+	ErrNotFound           = errors.New("object not found")
+	ErrInvalidKey         = errors.New("invalid object key")
+	ErrPresignUnsupported = errors.New("store does not support presigned URLs")
+)
+
+// Info describes a stored object. SHA256 is the hex digest of its content.
+type Info struct {
+	Size    int64
+	ModTime time.Time
+	SHA256  string
+}
+
+// Store holds objects under slash-separated keys.
+type Store interface {
+	// Put stores size bytes read from r under key. sum is the hex SHA-256
+	// of the content and is kept with the object.
+	Put(ctx context.Context, key string, r io.Reader, size int64, sum string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (Info, error)
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns a URL that downloads key without credentials
+	// until it expires.
+	PresignGet(key string, expires time.Duration) (string, error)
+	// URI names key in this store, e.g. s3://bucket/key.
+	URI(key string) string
+}
+
+// Filer is implemented by stores whose objects are plain files that can be
+// served in place.
+type Filer interface {
+	Path(key string) (string, error)
+}
+
+// New returns the store selected by cfg.Driver, or nil when no driver is
+// configured.
+func New(cfg config.StorageConfig) (Store, error) {
+	switch cfg.Driver {
+	case "":
+		return nil, nil
+	case "local":
+		return NewLocal(cfg.LocalRoot)
+	case "s3":
+		return NewS3(S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+// cleanKey rejects keys that are absolute or climb out of the store.
+func cleanKey(key string) (string, error) {
+	clean := path.Clean("/" + key)[1:]
+	if clean == "" || clean != key || strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return clean, nil
+}
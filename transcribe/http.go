@@ -0,0 +1,105 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/vishaltalsaniya-7/voip-api/config"
+)
+
+// HTTP posts recordings to a speech-to-text server with the OpenAI
+// /v1/audio/transcriptions API, which OpenAI, whisper.cpp's server and
+// faster-whisper servers implement.
+type HTTP struct {
+	url      string
+	apiKey   string
+	model    string
+	language string
+	client   *http.Client
+}
+
+func NewHTTP(cfg config.TranscriptionConfig) (*HTTP, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("the http transcription engine needs TRANSCRIPTION_URL")
+	}
+	return &HTTP{
+		url:      strings.TrimSuffix(cfg.URL, "/") + "/v1/audio/transcriptions",
+		apiKey:   cfg.APIKey,
+		model:    cfg.Model,
+		language: cfg.Language,
+		client:   &http.Client{},
+	}, nil
+}
+
+func (h *HTTP) Name() string {
+	return "http:" + h.model
+}
+
+// Transcribe streams the recording as a multipart upload, so it is never
+// held in memory whole.
+func (h *HTTP) Transcribe(ctx context.Context, audio io.Reader, name string) (Result, error) {
+	body, w := io.Pipe()
+	form := multipart.NewWriter(w)
+	go func() {
+		err := writeForm(form, audio, name, map[string]string{
+			"model":           h.model,
+			"language":        h.language,
+			"response_format": "verbose_json",
+		})
+		w.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, body)
+	if err != nil {
+		body.Close()
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if h.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("transcription request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return Result{}, fmt.Errorf("transcription server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var out struct {
+		Text     string `json:"text"`
+		Language string `json:"language"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return Result{}, fmt.Errorf("failed to parse transcription: %w", err)
+	}
+	return Result{Text: strings.TrimSpace(out.Text), Language: out.Language}, nil
+}
+
+func writeForm(form *multipart.Writer, audio io.Reader, name string, fields map[string]string) error {
+	for k, v := range fields {
+		if v == "" {
+			continue
+		}
+		if err := form.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	part, err := form.CreateFormFile("file", name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return err
+	}
+	return form.Close()
+}
@@ -0,0 +1,131 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishaltalsaniya-7/voip-api/config"
+)
+
+// stubServer stands in for an OpenAI-compatible transcription server. It
+// checks the upload the way the real API reads it and answers with text.
+func stubServer(t *testing.T, handler http.HandlerFunc) *HTTP {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	h, err := NewHTTP(config.TranscriptionConfig{
+		URL:      srv.URL + "/",
+		APIKey:   "test-key",
+		Model:    "whisper-1",
+		Language: "es",
+	})
+	if err != nil {
+		t.Fatalf("NewHTTP: %v", err)
+	}
+	return h
+}
+
+func TestHTTPTranscribe(t *testing.T) {
+	const audio = "RIFF fake wav data"
+	h := stubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/audio/transcriptions" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q, want Bearer test-key", got)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("ParseMultipartForm: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for field, want := range map[string]string{"model": "whisper-1", "language": "es", "response_format": "verbose_json"} {
+			if got := r.FormValue(field); got != want {
+				t.Errorf("form field %s = %q, want %q", field, got, want)
+			}
+		}
+		f, hdr, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("FormFile: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		data, _ := io.ReadAll(f)
+		if hdr.Filename != "call.wav" || string(data) != audio {
+			t.Errorf("file = %s %q, want call.wav %q", hdr.Filename, data, audio)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"text": "  Hola, buenos días.\n", "language": "spanish", "duration": 2.5})
+	})
+
+	if name := h.Name(); name != "http:whisper-1" {
+		t.Errorf("Name = %q, want http:whisper-1", name)
+	}
+	result, err := h.Transcribe(context.Background(), strings.NewReader(audio), "call.wav")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if result.Text != "Hola, buenos días." || result.Language != "spanish" {
+		t.Errorf("Transcribe = %+v, want trimmed text and language spanish", result)
+	}
+}
+
+func TestHTTPTranscribeServerError(t *testing.T) {
+	h := stubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+	})
+
+	_, err := h.Transcribe(context.Background(), strings.NewReader("audio"), "call.wav")
+	if err == nil || !strings.Contains(err.Error(), "model not loaded") {
+		t.Fatalf("Transcribe = %v, want the server error", err)
+	}
+}
+
+func TestHTTPTranscribeTimeout(t *testing.T) {
+	h := stubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := h.Transcribe(ctx, strings.NewReader("audio"), "call.wav"); err == nil {
+		t.Fatal("Transcribe succeeded after the context expired")
+	}
+}
+
+func TestNew(t *testing.T) {
+	engine, err := New(config.TranscriptionConfig{})
+	if err != nil || engine != nil {
+		t.Errorf("New without an engine = %v, %v; want nil, nil", engine, err)
+	}
+	if _, err := New(config.TranscriptionConfig{Engine: "http"}); err == nil {
+		t.Error("New http without TRANSCRIPTION_URL succeeded")
+	}
+	if _, err := New(config.TranscriptionConfig{Engine: "nope"}); err == nil {
+		t.Error("New with an unknown engine succeeded")
+	}
+
+	engine, err = New(config.TranscriptionConfig{Engine: "stub", StubText: "fixed"})
+	if err != nil {
+		t.Fatalf("New stub: %v", err)
+	}
+	result, err := engine.Transcribe(context.Background(), strings.NewReader("audio"), "call.wav")
+	if err != nil || result.Text != "fixed" {
+		t.Errorf("stub Transcribe = %+v, %v; want fixed", result, err)
+	}
+}
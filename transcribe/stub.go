@@ -0,0 +1,34 @@
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Stub is a local engine for development and tests. It reads the whole
+// recording, so decryption and checksum errors still surface, and returns
+// fixed text.
+type Stub struct {
+	Text string
+}
+
+func (s *Stub) Name() string {
+	return "stub"
+}
+
+func (s *Stub) Transcribe(ctx context.Context, audio io.Reader, name string) (Result, error) {
+	n, err := io.Copy(io.Discard, audio)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read recording: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	text := s.Text
+	if text == "" {
+		text = fmt.Sprintf("Stub transcription of %s (%d bytes of audio).", name, n)
+	}
+	return Result{Text: text, Language: "en"}, nil
+}
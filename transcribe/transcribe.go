@@ -0,0 +1,40 @@
+// Package transcribe turns call recordings into text. Engines implement
+// Transcriber; New picks one from the configuration.
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/vishaltalsaniya-7/voip-api/config"
+)
+
+// Result is the text of a recording and, when the engine detects it, its
+// language.
+type Result struct {
+	Text     string
+	Language string
+}
+
+// Transcriber transcribes one recording. name is the recording file name,
+// which engines use to tell the audio format.
+type Transcriber interface {
+	Name() string
+	Transcribe(ctx context.Context, audio io.Reader, name string) (Result, error)
+}
+
+// New returns the engine selected by cfg.Engine, or nil when transcription
+// is off.
+func New(cfg config.TranscriptionConfig) (Transcriber, error) {
+	switch cfg.Engine {
+	case "":
+		return nil, nil
+	case "stub":
+		return &Stub{Text: cfg.StubText}, nil
+	case "http":
+		return NewHTTP(cfg)
+	default:
+		return nil, fmt.Errorf("unknown transcription engine %q", cfg.Engine)
+	}
+}